@@ -21,6 +21,7 @@ import (
 	"fmt"
 	"io"
 	"io/fs"
+	"maps"
 	"math"
 	"math/rand"
 	"net/http"
@@ -113,6 +114,24 @@ type Account struct {
 	// and if it falls between 0 and that value, message tracing will be triggered.
 	traceDest         string
 	traceDestSampling int
+	// Core NATS rate limits of the account, its limiter and the
+	// counters of messages exceeding them.
+	rateLimits *RateLimits
+	rlim       atomic.Pointer[rateLimiter]
+	rlStats    rateLimitCounters
+	// Deduplication of the core NATS messages published to the account,
+	// its deduper and the counters of messages dropped as duplicates.
+	dedupe  *DedupeOpts
+	ddup    atomic.Pointer[msgDeduper]
+	ddStats dedupeCounters
+	// Last value cache of the core NATS subjects of the account.
+	lastValues *LastValueCacheOpts
+	lvc        atomic.Pointer[lastValueCache]
+	// Rolling latency histograms of the tracked service exports.
+	lhmu   sync.Mutex
+	lhists map[serviceLatencyKey]*latencyHistogram
+	// Policies for selecting the members of queue groups.
+	qpols atomic.Pointer[[]*QueuePolicy]
 	// Guarantee that only one goroutine can be running either checkJetStreamMigrate
 	// or clearObserverState at a given time for this account to prevent interleaving.
 	jscmMu sync.Mutex
@@ -239,6 +258,57 @@ type serviceExport struct {
 	// and trace egresses on that other account. If `false`, we stop at the
 	// account boundary.
 	atrc bool
+	// Optional policies protecting the service. Requests over the maximum
+	// number in flight, or while the circuit breaker is open, are rejected
+	// with a 503 status. Requests not answered within the timeout are
+	// answered with a 504 status.
+	maxInflight int
+	inflight    int
+	timeout     time.Duration
+	cb          *circuitBreaker
+}
+
+// Circuit breaker of a service export. The circuit opens after a number
+// of consecutive requests that had no responders or timed out. While open,
+// requests are rejected until the reset period has elapsed, after which a
+// single request is let through to probe the service. The circuit closes
+// when that request gets a response, and opens again otherwise.
+type circuitBreaker struct {
+	failures  int
+	reset     time.Duration
+	fails     int
+	openUntil int64
+	probing   bool
+}
+
+// Returns true if a request can be sent to the service.
+func (cb *circuitBreaker) allow(now int64) bool {
+	if cb.fails < cb.failures {
+		return true
+	}
+	if cb.probing || now < cb.openUntil {
+		return false
+	}
+	cb.probing = true
+	return true
+}
+
+// Records the outcome of a request.
+func (cb *circuitBreaker) record(failed bool, now int64) {
+	cb.probing = false
+	if !failed {
+		cb.fails, cb.openUntil = 0, 0
+		return
+	}
+	if cb.fails++; cb.fails >= cb.failures {
+		cb.fails = cb.failures
+		cb.openUntil = now + int64(cb.reset)
+	}
+}
+
+// Returns true if the circuit is open.
+func (cb *circuitBreaker) isOpen() bool {
+	return cb != nil && cb.fails >= cb.failures
 }
 
 // Used to track service latency.
@@ -299,6 +369,10 @@ func (a *Account) shallowCopy(na *Account) {
 	na.Nkey = a.Nkey
 	na.Issuer = a.Issuer
 	na.traceDest, na.traceDestSampling = a.traceDest, a.traceDestSampling
+	na.rateLimits = a.rateLimits.clone()
+	na.rlim.Store(newRateLimiter(a.rateLimits))
+	na.setDedupe(a.dedupe)
+	na.setLastValueCache(a.lastValues)
 
 	if a.imports.streams != nil {
 		na.imports.streams = make([]*streamImport, 0, len(a.imports.streams))
@@ -342,6 +416,7 @@ func (a *Account) shallowCopy(na *Account) {
 	}
 	na.mappings = a.mappings
 	na.hasMapped.Store(len(na.mappings) > 0)
+	na.qpols.Store(a.qpols.Load())
 
 	// JetStream
 	na.jsLimits = a.jsLimits
@@ -660,10 +735,13 @@ type MapDest struct {
 	Subject string `json:"subject"`
 	Weight  uint8  `json:"weight"`
 	Cluster string `json:"cluster,omitempty"`
+	// Headers makes this destination selected for messages with all of
+	// these header values, ahead of and regardless of the weighted ones.
+	Headers map[string]string `json:"headers,omitempty"`
 }
 
 func NewMapDest(subject string, weight uint8) *MapDest {
-	return &MapDest{subject, weight, _EMPTY_}
+	return &MapDest{Subject: subject, Weight: weight}
 }
 
 // destination is for internal representation for a weighted mapped destination.
@@ -672,12 +750,20 @@ type destination struct {
 	weight uint8
 }
 
+// headerDestination is a mapped destination selected by message headers.
+type headerDestination struct {
+	tr      *subjectTransform
+	cluster string
+	hdrs    map[string]string
+}
+
 // mapping is an internal entry for mapping subjects.
 type mapping struct {
 	src    string
 	wc     bool
 	dests  []*destination
 	cdests map[string][]*destination
+	hdests []*headerDestination
 }
 
 // AddMapping adds in a simple route mapping from src subject to dest subject
@@ -700,6 +786,18 @@ func (a *Account) AddWeightedMappings(src string, dests ...*MapDest) error {
 
 	var tw = make(map[string]uint8)
 	for _, d := range dests {
+		// Destinations selected by headers are checked in order, and take no weight.
+		if len(d.Headers) > 0 {
+			if err := ValidateMapping(src, d.Subject); err != nil {
+				return err
+			}
+			tr, err := NewSubjectTransform(src, d.Subject)
+			if err != nil {
+				return err
+			}
+			m.hdests = append(m.hdests, &headerDestination{tr, d.Cluster, maps.Clone(d.Headers)})
+			continue
+		}
 		if _, ok := seen[d.Subject]; ok {
 			return fmt.Errorf("duplicate entry for %q", d.Subject)
 		}
@@ -844,7 +942,7 @@ func (a *Account) hasMappings() bool {
 
 // This performs the logic to map to a new dest subject based on mappings.
 // Should only be called from processInboundClientMsg or service import processing.
-func (a *Account) selectMappedSubject(dest string) (string, bool) {
+func (a *Account) selectMappedSubject(dest string, hdr []byte) (string, bool) {
 	if !a.hasMappings() {
 		return dest, false
 	}
@@ -898,8 +996,16 @@ func (a *Account) selectMappedSubject(dest string) (string, bool) {
 		}
 	}
 
+	// Destinations selected by headers come first.
+	var hd *headerDestination
+	if len(m.hdests) > 0 && len(hdr) > 0 {
+		hd = m.selectHeaderDestination(hdr, a.srv.cachedClusterName())
+	}
+
 	// Optimize for single entry case.
-	if len(dests) == 1 && dests[0].weight == 100 {
+	if hd != nil {
+		ndest = hd.tr.transformTokenizedSubject(tts, hdr)
+	} else if len(dests) == 1 && dests[0].weight == 100 {
 		d = dests[0]
 	} else {
 		w := uint8(fastrand.Uint32n(100))
@@ -915,7 +1021,7 @@ func (a *Account) selectMappedSubject(dest string) (string, bool) {
 		if len(d.tr.dtokmftokindexesargs) == 0 {
 			ndest = d.tr.dest
 		} else {
-			ndest = d.tr.TransformTokenizedSubject(tts)
+			ndest = d.tr.transformTokenizedSubject(tts, hdr)
 		}
 	}
 
@@ -923,6 +1029,27 @@ func (a *Account) selectMappedSubject(dest string) (string, bool) {
 	return ndest, true
 }
 
+// Returns the first header destination whose headers all match, if any.
+// Account lock should be held.
+func (m *mapping) selectHeaderDestination(hdr []byte, cluster string) *headerDestination {
+	for _, hd := range m.hdests {
+		if hd.cluster != _EMPTY_ && hd.cluster != cluster {
+			continue
+		}
+		match := true
+		for k, v := range hd.hdrs {
+			if string(sliceHeader(k, hdr)) != v {
+				match = false
+				break
+			}
+		}
+		if match {
+			return hd
+		}
+	}
+	return nil
+}
+
 // SubscriptionInterest returns true if this account has a matching subscription
 // for the given `subject`.
 func (a *Account) SubscriptionInterest(subject string) bool {
@@ -1405,6 +1532,9 @@ func (a *Account) sendBadRequestTrackingLatency(si *serviceImport, requestor *cl
 	}
 	sl.RequestHeader = header
 	sl.RequestStart = time.Now().Add(-sl.Requestor.RTT).UTC()
+	if acc := requestor.Account(); acc != nil {
+		a.recordServiceLatency(si.se, acc.Name, sl)
+	}
 	a.sendLatencyResult(si, sl)
 }
 
@@ -1423,6 +1553,7 @@ func (a *Account) sendReplyInterestLostTrackLatency(si *serviceImport) {
 		sl.Requestor = rc.getClientInfo(share)
 	}
 	sl.RequestStart = time.Unix(0, ts-int64(sl.Requestor.RTT)).UTC()
+	a.recordServiceLatency(si.se, si.acc.Name, sl)
 	a.sendLatencyResult(si, sl)
 }
 
@@ -1447,6 +1578,7 @@ func (a *Account) sendBackendErrorTrackingLatency(si *serviceImport, reason rsiR
 		sl.Status = 504
 		sl.Error = "Service Timeout"
 	}
+	a.recordServiceLatency(si.se, si.acc.Name, sl)
 	a.sendLatencyResult(si, sl)
 }
 
@@ -1503,6 +1635,7 @@ func (a *Account) sendTrackingLatency(si *serviceImport, responder *client) bool
 			m1, m2 := sl, si.m1
 			m1.merge(m2)
 			si.acc.mu.Unlock()
+			a.recordServiceLatency(si.se, si.acc.Name, m1)
 			a.srv.sendInternalAccountMsg(a, si.latency.subject, m1)
 			a.mu.Lock()
 			si.rc = nil
@@ -1513,6 +1646,7 @@ func (a *Account) sendTrackingLatency(si *serviceImport, responder *client) bool
 		si.acc.mu.Unlock()
 		return false
 	} else {
+		a.recordServiceLatency(si.se, si.acc.Name, sl)
 		a.srv.sendInternalAccountMsg(a, si.latency.subject, sl)
 		a.mu.Lock()
 		si.rc = nil
@@ -1765,17 +1899,25 @@ const (
 	rsiOk = rsiReason(iota)
 	rsiNoDelivery
 	rsiTimeout
+	rsiNoInterest
 )
 
 // removeRespServiceImport removes a response si mapping and the reverse entries for interest detection.
-func (a *Account) removeRespServiceImport(si *serviceImport, reason rsiReason) {
+// Returns true if the mapping was still present.
+func (a *Account) removeRespServiceImport(si *serviceImport, reason rsiReason) bool {
 	if si == nil {
-		return
+		return false
 	}
 
 	a.mu.Lock()
 	c := a.ic
-	delete(a.exports.responses, si.from)
+	removed := a.exports.responses[si.from] == si
+	if removed {
+		delete(a.exports.responses, si.from)
+		if si.se != nil {
+			si.se.requestDone(si, reason)
+		}
+	}
 	dest, to, tracking, rc, didDeliver := si.acc, si.to, si.tracking, si.rc, si.didDeliver
 	a.mu.Unlock()
 
@@ -1785,10 +1927,16 @@ func (a *Account) removeRespServiceImport(si *serviceImport, reason rsiReason) {
 	}
 
 	if tracking && rc != nil && !didDeliver {
+		// The requestor going away only matters to the circuit breaker,
+		// for latency tracking the response was not delivered.
+		if reason == rsiNoInterest {
+			reason = rsiNoDelivery
+		}
 		a.sendBackendErrorTrackingLatency(si, reason)
 	}
 
 	dest.checkForReverseEntry(to, si, false)
+	return removed
 }
 
 func (a *Account) getServiceImportForAccountLocked(dstAccName, subject string) *serviceImport {
@@ -1995,6 +2143,9 @@ func (a *Account) _checkForReverseEntry(reply string, si *serviceImport, checkIn
 			c := acc.ic
 			if rsi = acc.exports.responses[sre.msub]; rsi != nil && !rsi.didDeliver {
 				delete(acc.exports.responses, rsi.from)
+				if rsi.se != nil {
+					rsi.se.requestDone(rsi, rsiNoInterest)
+				}
 				trackingCleanup = rsi.tracking && rsi.rc != nil
 			}
 			acc.mu.Unlock()
@@ -2148,7 +2299,7 @@ func (a *Account) subscribeInternalEx(subject string, cb msgHandler, ri bool) (*
 		return nil, fmt.Errorf("no internal account client")
 	}
 
-	return c.processSubEx([]byte(subject), nil, []byte(sid), cb, false, false, ri)
+	return c.processSubEx([]byte(subject), nil, []byte(sid), 0, cb, false, false, ri)
 }
 
 // This will add an account subscription that matches the "from" from a service import entry.
@@ -2173,7 +2324,7 @@ func (a *Account) addServiceImportSub(si *serviceImport) error {
 	cb := func(sub *subscription, c *client, acc *Account, subject, reply string, msg []byte) {
 		c.pa.delivered = c.processServiceImport(si, acc, msg)
 	}
-	sub, err := c.processSubEx([]byte(subject), nil, []byte(sid), cb, true, true, false)
+	sub, err := c.processSubEx([]byte(subject), nil, []byte(sid), 0, cb, true, true, false)
 	if err != nil {
 		return err
 	}
@@ -2440,13 +2591,67 @@ func (si *serviceImport) isRespServiceImport() bool {
 	return si != nil && si.response
 }
 
+// Checks the policies of a service export before letting a request through,
+// in which case the request is counted as in flight.
+// Account lock should be held.
+func (se *serviceExport) admitRequest() error {
+	if se.maxInflight > 0 && se.inflight >= se.maxInflight {
+		return ErrServiceMaxInflight
+	}
+	if se.cb != nil && !se.cb.allow(time.Now().UnixNano()) {
+		return ErrServiceCircuitOpen
+	}
+	se.inflight++
+	return nil
+}
+
+// Called when the response service import of a request is removed.
+// Account lock should be held.
+func (se *serviceExport) requestDone(si *serviceImport, reason rsiReason) {
+	if se.inflight > 0 {
+		se.inflight--
+	}
+	// Only singletons tell us if the service did respond. A response that could
+	// not be delivered back, or a requestor that went away, does not count.
+	if se.cb == nil || si.rt != Singleton {
+		return
+	}
+	if reason == rsiNoInterest {
+		// Let the next request probe the service if this one was.
+		se.cb.probing = false
+		return
+	}
+	se.cb.record(reason != rsiOk, time.Now().UnixNano())
+}
+
+// Returns the time after which pending responses expire, which is the
+// lowest of the response threshold and the timeout.
+// Account lock should be held.
+func (se *serviceExport) responseExpiration() time.Duration {
+	if se.timeout > 0 && se.timeout < se.respThresh {
+		return se.timeout
+	}
+	return se.respThresh
+}
+
+// Returns how often pending responses are checked for expiration. When a
+// timeout is set, this is done more often to enforce it with some precision.
+// Account lock should be held.
+func (se *serviceExport) responseCheckInterval() time.Duration {
+	exp := se.responseExpiration()
+	if se.timeout > 0 && exp == se.timeout {
+		return max(exp/4, time.Millisecond)
+	}
+	return exp
+}
+
 // Sets the response threshold timer for a service export.
 // Account lock should be held
 func (se *serviceExport) setResponseThresholdTimer() {
 	if se.rtmr != nil {
 		return // Already set
 	}
-	se.rtmr = time.AfterFunc(se.respThresh, se.checkExpiredResponses)
+	se.rtmr = time.AfterFunc(se.responseCheckInterval(), se.checkExpiredResponses)
 }
 
 // Account lock should be held
@@ -2469,7 +2674,6 @@ func (se *serviceExport) checkExpiredResponses() {
 	}
 
 	var expired []*serviceImport
-	mints := time.Now().UnixNano() - int64(se.respThresh)
 
 	// TODO(dlc) - Should we release lock while doing this? Or only do these in batches?
 	// Should we break this up for responses only from this service export?
@@ -2477,6 +2681,8 @@ func (se *serviceExport) checkExpiredResponses() {
 	// We could do another indirection at this level but just to get to the service export?
 	var totalResponses int
 	acc.mu.RLock()
+	mints := time.Now().UnixNano() - int64(se.responseExpiration())
+	timeout, s := se.timeout, acc.srv
 	for _, si := range acc.exports.responses {
 		if si.se == se {
 			totalResponses++
@@ -2488,7 +2694,10 @@ func (se *serviceExport) checkExpiredResponses() {
 	acc.mu.RUnlock()
 
 	for _, si := range expired {
-		acc.removeRespServiceImport(si, rsiTimeout)
+		// Let the requestor know right away if the server enforces a timeout.
+		if acc.removeRespServiceImport(si, rsiTimeout) && timeout > 0 && si.rt == Singleton && s != nil {
+			s.sendInternalAccountMsgWithReply(si.acc, si.to, _EMPTY_, []byte(serviceTimeoutHdr), nil, false)
+		}
 	}
 
 	// Pull out expired to determine if we have any left for timer.
@@ -2498,7 +2707,7 @@ func (se *serviceExport) checkExpiredResponses() {
 	acc.mu.Lock()
 	if totalResponses > 0 && se.rtmr != nil {
 		se.rtmr.Stop()
-		se.rtmr.Reset(se.respThresh)
+		se.rtmr.Reset(se.responseCheckInterval())
 	} else {
 		se.clearResponseThresholdTimer()
 	}
@@ -2557,11 +2766,92 @@ func (a *Account) SetServiceExportAllowTrace(export string, allowTrace bool) err
 	return nil
 }
 
+// SetServiceExportMaxInflight sets the maximum number of requests to a service export
+// that can be waiting for a response. Requests over that limit are rejected with a
+// 503 status. Zero means no limit.
+func (a *Account) SetServiceExportMaxInflight(export string, maxInflight int) error {
+	if maxInflight < 0 {
+		return fmt.Errorf("maximum in-flight requests can not be negative")
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.isClaimAccount() {
+		return fmt.Errorf("claim based accounts can not be updated directly")
+	}
+	se := a.getServiceExport(export)
+	if se == nil {
+		return fmt.Errorf("no export defined for %q", export)
+	}
+	se.maxInflight = maxInflight
+	return nil
+}
+
+// SetServiceExportTimeout sets the time after which the server stops waiting for the
+// response to a request to a singleton service export and sends a 504 status to the
+// requestor. Zero disables the timeout.
+func (a *Account) SetServiceExportTimeout(export string, timeout time.Duration) error {
+	if timeout < 0 {
+		return fmt.Errorf("service timeout can not be negative")
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.isClaimAccount() {
+		return fmt.Errorf("claim based accounts can not be updated directly")
+	}
+	se := a.getServiceExport(export)
+	if se == nil {
+		return fmt.Errorf("no export defined for %q", export)
+	}
+	se.timeout = timeout
+	// Make sure pending responses are checked against the new timeout.
+	if se.rtmr != nil {
+		se.rtmr.Reset(se.responseCheckInterval())
+	}
+	return nil
+}
+
+// SetServiceExportCircuitBreaker sets a circuit breaker on a singleton service export. After the
+// given number of consecutive requests with no responders or that timed out, requests are rejected
+// with a 503 status until the reset period has elapsed. Zero failures removes the circuit breaker.
+func (a *Account) SetServiceExportCircuitBreaker(export string, failures int, reset time.Duration) error {
+	if failures < 0 || reset < 0 {
+		return fmt.Errorf("circuit breaker failures and reset can not be negative")
+	}
+	if reset == 0 {
+		reset = DEFAULT_SERVICE_EXPORT_CIRCUIT_RESET
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.isClaimAccount() {
+		return fmt.Errorf("claim based accounts can not be updated directly")
+	}
+	se := a.getServiceExport(export)
+	if se == nil {
+		return fmt.Errorf("no export defined for %q", export)
+	}
+	if failures > 0 && se.respType != Singleton {
+		return ErrBadServiceType
+	}
+	if failures == 0 {
+		se.cb = nil
+	} else {
+		se.cb = &circuitBreaker{failures: failures, reset: reset}
+	}
+	return nil
+}
+
 // This is for internal service import responses.
-func (a *Account) addRespServiceImport(dest *Account, to string, osi *serviceImport, tracking bool, header http.Header) *serviceImport {
+// Returns an error if the policies of the service export reject the request.
+func (a *Account) addRespServiceImport(dest *Account, to string, osi *serviceImport, tracking bool, header http.Header) (*serviceImport, error) {
 	nrr := string(osi.acc.newServiceReply(tracking))
 
 	a.mu.Lock()
+	if osi.se != nil {
+		if err := osi.se.admitRequest(); err != nil {
+			a.mu.Unlock()
+			return nil, err
+		}
+	}
 	rt := osi.rt
 
 	// dest is the requestor's account. a is the service responder with the export.
@@ -2590,7 +2880,7 @@ func (a *Account) addRespServiceImport(dest *Account, to string, osi *serviceImp
 	// cleanup of this si as interest goes away.
 	dest.addReverseRespMapEntry(a, to, nrr)
 
-	return si
+	return si, nil
 }
 
 // AddStreamImportWithClaim will add in the stream import from a specific account with optional token.
@@ -3401,6 +3691,14 @@ func (s *Server) updateAccountClaimsWithRefresh(a *Account, ac *jwt.AccountClaim
 	}
 	a.traceDest, a.traceDestSampling = td, tds
 
+	// Rate limits are set through tags.
+	rl, err := rateLimitsFromTags(ac.Tags)
+	if err != nil {
+		s.Warnf("Account %q has invalid rate limits: %v", a.Name, err)
+	}
+	a.rateLimits = rl
+	a.rlim.Store(newRateLimiter(rl))
+
 	// Check for external authorization.
 	if ac.HasExternalAuthorization() {
 		a.extAuth = &jwt.ExternalAuthorization{}
@@ -4020,6 +4318,13 @@ func buildInternalNkeyUser(uc *jwt.UserClaims, acts map[string]struct{}, acc *Ac
 		p = acc.defaultPerms.clone()
 	}
 	nu.Permissions = p
+
+	// Rate limits are set through tags.
+	if rl, err := rateLimitsFromTags(uc.Tags); err == nil {
+		nu.RateLimits = rl
+	} else if acc.srv != nil {
+		acc.srv.Warnf("User %q has invalid rate limits: %v", uc.Subject, err)
+	}
 	return nu
 }
 
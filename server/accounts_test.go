@@ -17,7 +17,9 @@ import (
 	"encoding/base64"
 	"encoding/json"
 	"fmt"
+	"io"
 	"net/http"
+	"reflect"
 	"slices"
 	"strconv"
 	"strings"
@@ -2002,6 +2004,354 @@ func TestAccountTrackLatencyRemoteLeaks(t *testing.T) {
 	})
 }
 
+func TestAccountServiceLatencyHistogram(t *testing.T) {
+	h := newLatencyHistogram([]time.Duration{time.Minute, 10 * time.Minute})
+	now := time.Now()
+	for i := 1; i <= 100; i++ {
+		h.record(now, time.Duration(i)*time.Millisecond, false)
+	}
+	h.record(now, 0, true)
+
+	stats, active := h.stats(now)
+	require_True(t, active)
+	require_Len(t, len(stats), 2)
+	for _, st := range stats {
+		require_Equal(t, st.Count, 100)
+		require_Equal(t, st.Errors, 1)
+		require_Equal(t, st.Max, 100*time.Millisecond)
+		// Buckets are a quarter power of two wide, so within 19%.
+		for _, pc := range []struct {
+			got  time.Duration
+			want time.Duration
+		}{{st.P50, 50 * time.Millisecond}, {st.P90, 90 * time.Millisecond}, {st.P99, 99 * time.Millisecond}} {
+			if pc.got < pc.want || float64(pc.got) > 1.19*float64(pc.want) {
+				t.Fatalf("Expected percentile close to %v, got %v", pc.want, pc.got)
+			}
+		}
+	}
+
+	// Measurements roll out of the smaller window first.
+	later := now.Add(2 * time.Minute)
+	h.record(later, time.Second, false)
+	stats, active = h.stats(later)
+	require_True(t, active)
+	require_Equal(t, stats[0].Count, 1)
+	require_Equal(t, stats[0].Errors, 0)
+	require_Equal(t, stats[0].P50, time.Second)
+	require_Equal(t, stats[1].Count, 101)
+	require_Equal(t, stats[1].Max, time.Second)
+
+	// And are all gone after the largest one.
+	_, active = h.stats(later.Add(11 * time.Minute))
+	require_False(t, active)
+}
+
+func TestAccountParseConfigServiceExportPolicies(t *testing.T) {
+	conf := createConfFile(t, []byte(`
+	accounts {
+		A {
+			exports = [
+				{service: "svc.a", max_inflight: 10, timeout: "2s", circuit_breaker: {failures: 3, reset: "30s"}}
+				{service: "svc.b", circuit_breaker: {failures: 5}}
+				{service: "svc.c"}
+			]
+		}
+	}
+	`))
+	opts, err := ProcessConfigFile(conf)
+	require_NoError(t, err)
+	require_Len(t, len(opts.Accounts), 1)
+	acc := opts.Accounts[0]
+
+	se := acc.exports.services["svc.a"]
+	require_Equal(t, se.maxInflight, 10)
+	require_Equal(t, se.timeout, 2*time.Second)
+	require_True(t, se.cb != nil)
+	require_Equal(t, se.cb.failures, 3)
+	require_Equal(t, se.cb.reset, 30*time.Second)
+
+	se = acc.exports.services["svc.b"]
+	require_Equal(t, se.maxInflight, 0)
+	require_Equal(t, se.timeout, 0)
+	require_Equal(t, se.cb.failures, 5)
+	require_Equal(t, se.cb.reset, DEFAULT_SERVICE_EXPORT_CIRCUIT_RESET)
+
+	se = acc.exports.services["svc.c"]
+	require_True(t, se.cb == nil)
+
+	for _, test := range []struct {
+		name string
+		exp  string
+		err  string
+	}{
+		{"stream", `{stream: "foo", max_inflight: 1}`, "non-service"},
+		{"negative max inflight", `{service: "foo", max_inflight: -1}`, "max in-flight"},
+		{"bad timeout", `{service: "foo", timeout: "x"}`, "timeout"},
+		{"no failures", `{service: "foo", circuit_breaker: {reset: "1s"}}`, "failures"},
+		{"streamed", `{service: "foo", response: stream, circuit_breaker: {failures: 1}}`, "bad service response type"},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			conf := createConfFile(t, []byte(fmt.Sprintf(`accounts { A { exports = [%s] } }`, test.exp)))
+			_, err := ProcessConfigFile(conf)
+			require_Error(t, err)
+			require_Contains(t, err.Error(), test.err)
+		})
+	}
+}
+
+func TestAccountParseConfigQueuePolicies(t *testing.T) {
+	conf := createConfFile(t, []byte(`
+	accounts {
+		A {
+			queue_policies = [
+				{subject: "req.>", queue: "workers", policy: least_outstanding}
+				{subject: "orders.*", policy: consistent_hash, hash_token: 2}
+				{subject: "events", policy: hash, hash_header: "Customer-Id"}
+				{subject: "jobs", policy: weighted}
+			]
+		}
+	}
+	`))
+	opts, err := ProcessConfigFile(conf)
+	require_NoError(t, err)
+	require_Len(t, len(opts.Accounts), 1)
+	acc := opts.Accounts[0]
+
+	qp := acc.queuePolicy("req.foo", []byte("workers"))
+	require_True(t, qp != nil)
+	require_Equal(t, qp.Type, QueueLeastOutstanding)
+	require_True(t, acc.queuePolicy("req.foo", []byte("other")) == nil)
+
+	qp = acc.queuePolicy("orders.123", []byte("any"))
+	require_True(t, qp != nil)
+	require_Equal(t, qp.Type, QueueConsistentHash)
+	require_Equal(t, qp.HashToken, 2)
+
+	qp = acc.queuePolicy("events", []byte("any"))
+	require_True(t, qp != nil)
+	require_Equal(t, qp.HashHeader, "Customer-Id")
+
+	qp = acc.queuePolicy("jobs", []byte("any"))
+	require_True(t, qp != nil)
+	require_Equal(t, qp.Type, QueueWeighted)
+	require_Len(t, len(acc.QueuePolicies()), 4)
+
+	for _, test := range []struct {
+		name string
+		qp   string
+		err  string
+	}{
+		{"unknown policy", `{subject: "foo", policy: "bogus"}`, "unknown queue policy"},
+		{"bad subject", `{subject: "foo..bar", policy: random}`, "not valid"},
+		{"hash without key", `{subject: "foo", policy: consistent_hash}`, "hash header or token"},
+		{"hash token out of range", `{subject: "foo.*", policy: consistent_hash, hash_token: 3}`, "out of range"},
+		{"weighted with header", `{subject: "foo", policy: weighted, hash_header: "x"}`, "can not have"},
+		{"unknown field", `{subject: "foo", policy: random, bogus: 1}`, "Unknown field"},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			conf := createConfFile(t, []byte(fmt.Sprintf(`accounts { A { queue_policies = [%s] } }`, test.qp)))
+			_, err := ProcessConfigFile(conf)
+			require_Error(t, err)
+			require_Contains(t, err.Error(), test.err)
+		})
+	}
+}
+
+func TestAccountServiceExportPolicies(t *testing.T) {
+	fooAcc, barAcc := NewAccount("FOO"), NewAccount("BAR")
+	require_NoError(t, fooAcc.AddServiceExport("svc", nil))
+	require_NoError(t, barAcc.AddServiceImport(fooAcc, "svc", "svc"))
+	o := DefaultOptions()
+	o.Accounts = []*Account{fooAcc, barAcc}
+	o.Users = []*User{
+		{Username: "foo", Password: "pass", Account: fooAcc},
+		{Username: "bar", Password: "pass", Account: barAcc},
+	}
+	s := RunServer(o)
+	defer s.Shutdown()
+
+	fooAcc, err := s.LookupAccount("FOO")
+	require_NoError(t, err)
+
+	nc := natsConnect(t, s.ClientURL(), nats.UserInfo("foo", "pass"))
+	defer nc.Close()
+	svc := natsSubSync(t, nc, "svc")
+	natsFlush(t, nc)
+
+	rnc := natsConnect(t, s.ClientURL(), nats.UserInfo("bar", "pass"))
+	defer rnc.Close()
+	// Use a channel since status messages are turned into errors by sync subscriptions.
+	replies := make(chan *nats.Msg, 10)
+	_, err = rnc.ChanSubscribe("reply", replies)
+	require_NoError(t, err)
+	natsFlush(t, rnc)
+	nextReply := func() *nats.Msg {
+		t.Helper()
+		select {
+		case m := <-replies:
+			return m
+		case <-time.After(time.Second):
+			t.Fatalf("Did not receive reply")
+		}
+		return nil
+	}
+
+	request := func() {
+		t.Helper()
+		require_NoError(t, rnc.PublishRequest("svc", "reply", []byte("req")))
+	}
+	expectRequest := func() *nats.Msg {
+		t.Helper()
+		return natsNexMsg(t, svc, time.Second)
+	}
+	expectNoRequest := func() {
+		t.Helper()
+		if m, err := svc.NextMsg(50 * time.Millisecond); err == nil {
+			t.Fatalf("Expected no request, got %q", m.Data)
+		}
+	}
+	expectStatus := func(status, desc string) {
+		t.Helper()
+		m := nextReply()
+		require_Equal(t, m.Header.Get("Status"), status)
+		require_Equal(t, m.Header.Get("Description"), desc)
+	}
+
+	// Requests over the maximum in flight are rejected right away.
+	require_NoError(t, fooAcc.SetServiceExportMaxInflight("svc", 1))
+	request()
+	req := expectRequest()
+	request()
+	expectStatus("503", "Max In-Flight Exceeded")
+	expectNoRequest()
+
+	// Until the pending one has been answered.
+	require_NoError(t, req.Respond([]byte("ok")))
+	m := nextReply()
+	require_Equal(t, string(m.Data), "ok")
+	request()
+	require_NoError(t, expectRequest().Respond([]byte("ok")))
+	nextReply()
+	require_NoError(t, fooAcc.SetServiceExportMaxInflight("svc", 0))
+
+	// Requests not answered in time get a timeout status.
+	require_NoError(t, fooAcc.SetServiceExportTimeout("svc", 50*time.Millisecond))
+	request()
+	req = expectRequest()
+	expectStatus("504", "Service Timeout")
+	// A late response is dropped.
+	require_NoError(t, req.Respond([]byte("late")))
+	select {
+	case m := <-replies:
+		t.Fatalf("Expected no late response, got %q", m.Data)
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	// The circuit opens after consecutive failures.
+	require_NoError(t, fooAcc.SetServiceExportCircuitBreaker("svc", 2, 250*time.Millisecond))
+	for range 2 {
+		request()
+		expectRequest()
+		expectStatus("504", "Service Timeout")
+	}
+	request()
+	expectStatus("503", "Circuit Open")
+	expectNoRequest()
+
+	// After the reset period, a single request probes the service.
+	time.Sleep(250 * time.Millisecond)
+	request()
+	req = expectRequest()
+	request()
+	expectStatus("503", "Circuit Open")
+	require_NoError(t, req.Respond([]byte("ok")))
+	m = nextReply()
+	require_Equal(t, string(m.Data), "ok")
+
+	// Which closed the circuit.
+	request()
+	require_NoError(t, expectRequest().Respond([]byte("ok")))
+	m = nextReply()
+	require_Equal(t, string(m.Data), "ok")
+
+	// Requests with no responders count as failures too.
+	svc.Unsubscribe()
+	natsFlush(t, nc)
+	for range 2 {
+		request()
+		nextReply()
+	}
+	request()
+	expectStatus("503", "Circuit Open")
+
+	// Policies are reported in the account details.
+	info, err := s.accountInfo("FOO")
+	require_NoError(t, err)
+	require_Len(t, len(info.Exports), 1)
+	require_True(t, info.Exports[0].CircuitOpen)
+	require_Equal(t, info.Exports[0].Timeout, 50*time.Millisecond)
+}
+
+func TestAccountServiceExportPoliciesRequestorGone(t *testing.T) {
+	fooAcc, barAcc := NewAccount("FOO"), NewAccount("BAR")
+	require_NoError(t, fooAcc.AddServiceExport("svc", nil))
+	require_NoError(t, fooAcc.TrackServiceExport("svc", "results"))
+	require_NoError(t, fooAcc.SetServiceExportCircuitBreaker("svc", 1, time.Minute))
+	require_NoError(t, barAcc.AddServiceImport(fooAcc, "svc", "svc"))
+	o := DefaultOptions()
+	o.Accounts = []*Account{fooAcc, barAcc}
+	o.Users = []*User{
+		{Username: "foo", Password: "pass", Account: fooAcc},
+		{Username: "bar", Password: "pass", Account: barAcc},
+	}
+	s := RunServer(o)
+	defer s.Shutdown()
+
+	fooAcc, err := s.LookupAccount("FOO")
+	require_NoError(t, err)
+	barAcc, err = s.LookupAccount("BAR")
+	require_NoError(t, err)
+
+	nc := natsConnect(t, s.ClientURL(), nats.UserInfo("foo", "pass"))
+	defer nc.Close()
+	results := natsSubSync(t, nc, "results")
+	natsFlush(t, nc)
+
+	rc, _, _ := newClientForServer(s)
+	defer rc.close()
+	require_NoError(t, rc.registerWithAccount(barAcc))
+
+	// A tracked response that could not be delivered since the requestor is gone.
+	fooAcc.mu.Lock()
+	se := fooAcc.exports.services["svc"]
+	si := &serviceImport{
+		acc:      barAcc,
+		se:       se,
+		from:     "_R_.gone",
+		to:       "gone",
+		rt:       Singleton,
+		tracking: true,
+		rc:       rc.client,
+		latency:  se.latency,
+		ts:       time.Now().UnixNano(),
+	}
+	if fooAcc.exports.responses == nil {
+		fooAcc.exports.responses = make(map[string]*serviceImport)
+	}
+	fooAcc.exports.responses[si.from] = si
+	fooAcc.mu.Unlock()
+	require_True(t, fooAcc.removeRespServiceImport(si, rsiNoInterest))
+
+	// Latency tracking reports it as not delivered, but the circuit stays closed.
+	var sl ServiceLatency
+	require_NoError(t, json.Unmarshal(natsNexMsg(t, results, time.Second).Data, &sl))
+	require_Equal(t, sl.Status, 503)
+	fooAcc.mu.RLock()
+	open := se.cb.isOpen()
+	fooAcc.mu.RUnlock()
+	require_False(t, open)
+}
+
 func TestCrossAccountServiceResponseTypes(t *testing.T) {
 	s, fooAcc, barAcc := simpleAccountServer(t)
 	defer s.Shutdown()
@@ -3037,6 +3387,47 @@ func TestAccountRouteMappingsConfiguration(t *testing.T) {
 	}
 }
 
+func TestAccountRouteMappingsWithHeaders(t *testing.T) {
+	cf := createConfFile(t, []byte(`
+		port: -1
+		mappings = {
+			orders.*: [
+				{ dest: orders.priority.$1, headers: { Priority: high } }
+				{ dest: "orders.{{header(Region, default)}}.$1", weight: 100% }
+			]
+		}
+	`))
+	s, _ := RunServerWithConfig(cf)
+	defer s.Shutdown()
+
+	nc := natsConnect(t, s.ClientURL())
+	defer nc.Close()
+
+	sub := natsSubSync(t, nc, "orders.>")
+	natsFlush(t, nc)
+
+	publish := func(hdrs map[string]string) string {
+		t.Helper()
+		m := nats.NewMsg("orders.1")
+		for k, v := range hdrs {
+			m.Header.Set(k, v)
+		}
+		require_NoError(t, nc.PublishMsg(m))
+		msg := natsNexMsg(t, sub, time.Second)
+		return msg.Subject
+	}
+	require_Equal(t, publish(map[string]string{"Region": "eu"}), "orders.eu.1")
+	require_Equal(t, publish(map[string]string{"Region": "us", "Priority": "high"}), "orders.priority.1")
+	require_Equal(t, publish(map[string]string{"Priority": "low"}), "orders.default.1")
+	require_Equal(t, publish(nil), "orders.default.1")
+
+	az, err := s.Accountz(&AccountzOptions{DEFAULT_GLOBAL_ACCOUNT})
+	require_NoError(t, err)
+	dests := az.Account.Mappings["orders.*"]
+	require_Len(t, len(dests), 2)
+	require_Equal(t, dests[1].Headers["Priority"], "high")
+}
+
 func TestAccountRouteMappingsWithLossInjection(t *testing.T) {
 	cf := createConfFile(t, []byte(`
 	port: -1
@@ -4034,3 +4425,369 @@ func TestAccountServiceImportNoResponders(t *testing.T) {
 	_, err := nc.Request("foo", []byte("request"), 250*time.Millisecond)
 	require_Error(t, err, nats.ErrNoResponders)
 }
+
+func TestAccountRateLimitsConfig(t *testing.T) {
+	cf := createConfFile(t, []byte(`
+		accounts: {
+			A: {
+				users: [
+					{user: a, password: a}
+					{user: d, password: d, rate_limits: {msgs_in: 5, action: disconnect}}
+				]
+				rate_limits: {msgs_in: 10, bytes_out: 1KB}
+			}
+		}
+	`))
+	opts, err := ProcessConfigFile(cf)
+	require_NoError(t, err)
+	require_Len(t, len(opts.Accounts), 1)
+	require_Equal(t, *opts.Accounts[0].rateLimits, RateLimits{MsgsIn: 10, BytesOut: 1024})
+	for _, u := range opts.Users {
+		switch u.Username {
+		case "a":
+			require_True(t, u.RateLimits == nil)
+		case "d":
+			require_Equal(t, *u.RateLimits, RateLimits{MsgsIn: 5, Action: RateLimitDisconnect})
+		}
+	}
+
+	cf = createConfFile(t, []byte(`
+		accounts: { A: { rate_limits: {msgs_in: 10, action: explode} } }
+	`))
+	_, err = ProcessConfigFile(cf)
+	require_Error(t, err)
+}
+
+func TestAccountRateLimitsFromTags(t *testing.T) {
+	rl, err := rateLimitsFromTags(jwt.TagList{"team:a", "rate_msgs_in:100", "rate_bytes_out:1mb", "rate_action:stall"})
+	require_NoError(t, err)
+	require_Equal(t, *rl, RateLimits{MsgsIn: 100, BytesOut: 1024 * 1024, Action: RateLimitStall})
+
+	rl, err = rateLimitsFromTags(jwt.TagList{"team:a"})
+	require_NoError(t, err)
+	require_True(t, rl == nil)
+
+	_, err = rateLimitsFromTags(jwt.TagList{"rate_action:explode"})
+	require_Error(t, err)
+	_, err = rateLimitsFromTags(jwt.TagList{"rate_msgs:10"})
+	require_Error(t, err)
+}
+
+func TestAccountDedupeConfig(t *testing.T) {
+	cf := createConfFile(t, []byte(`
+		accounts: {
+			A: { dedupe: {subjects: ["orders.>", "events.*"], window: "30s", max_msg_ids: 1000} }
+			B: { dedupe: {} }
+		}
+	`))
+	opts, err := ProcessConfigFile(cf)
+	require_NoError(t, err)
+	require_Len(t, len(opts.Accounts), 2)
+	for _, acc := range opts.Accounts {
+		switch acc.Name {
+		case "A":
+			require_True(t, reflect.DeepEqual(*acc.dedupe, DedupeOpts{
+				Subjects:  []string{"orders.>", "events.*"},
+				Window:    30 * time.Second,
+				MaxMsgIds: 1000,
+			}))
+		case "B":
+			require_True(t, reflect.DeepEqual(*acc.dedupe, DedupeOpts{}))
+		}
+	}
+
+	for _, cfg := range []string{
+		`dedupe: {subjects: ["orders..bad"]}`,
+		`dedupe: {window: "-1s"}`,
+		`dedupe: {max_msg_ids: -1}`,
+		`dedupe: {unknown: 1}`,
+	} {
+		cf = createConfFile(t, []byte(fmt.Sprintf(`accounts: { A: { %s } }`, cfg)))
+		_, err = ProcessConfigFile(cf)
+		require_Error(t, err)
+	}
+}
+
+func TestAccountDedupeMsgs(t *testing.T) {
+	acc := NewAccount("A")
+	acc.dedupe = &DedupeOpts{Subjects: []string{"orders.>"}}
+	o := DefaultOptions()
+	o.Accounts = []*Account{acc}
+	o.Users = []*User{{Username: "a", Password: "a", Account: acc}}
+	s := RunServer(o)
+	defer s.Shutdown()
+
+	nc := natsConnect(t, s.ClientURL(), nats.UserInfo("a", "a"))
+	defer nc.Close()
+	sub := natsSubSync(t, nc, ">")
+	natsFlush(t, nc)
+
+	publish := func(subj, id string) {
+		t.Helper()
+		m := nats.NewMsg(subj)
+		if id != _EMPTY_ {
+			m.Header.Set(JSMsgId, id)
+		}
+		m.Data = []byte("hello")
+		require_NoError(t, nc.PublishMsg(m))
+	}
+	// Duplicates on orders.> are dropped, other messages are delivered.
+	publish("orders.1", "1")
+	publish("orders.1", "1")
+	publish("orders.1", "2")
+	publish("orders.2", "1")
+	publish("orders.1", _EMPTY_)
+	publish("events.1", "1")
+	publish("events.1", "1")
+	natsFlush(t, nc)
+
+	for _, expected := range []string{"orders.1", "orders.1", "orders.2", "orders.1", "events.1", "events.1"} {
+		m := natsNexMsg(t, sub, time.Second)
+		require_Equal(t, m.Subject, expected)
+	}
+	_, err := sub.NextMsg(100 * time.Millisecond)
+	require_Error(t, err, nats.ErrTimeout)
+
+	sacc, err := s.lookupAccount("A")
+	require_NoError(t, err)
+	st := sacc.statz().Deduplicated
+	require_True(t, st != nil)
+	require_Equal(t, st.Msgs, 1)
+	require_True(t, st.Bytes > 0)
+
+	// The message IDs are kept if deduplication did not change.
+	sacc.setDedupe(&DedupeOpts{Subjects: []string{"orders.>"}})
+	publish("orders.1", "1")
+	publish("orders.3", "1")
+	natsFlush(t, nc)
+	require_Equal(t, natsNexMsg(t, sub, time.Second).Subject, "orders.3")
+	require_Equal(t, sacc.statz().Deduplicated.Msgs, 2)
+
+	// But not if it changed.
+	sacc.setDedupe(&DedupeOpts{Subjects: []string{"orders.>"}, Window: time.Minute})
+	publish("orders.1", "1")
+	natsFlush(t, nc)
+	require_Equal(t, natsNexMsg(t, sub, time.Second).Subject, "orders.1")
+
+	// And no longer applies once removed.
+	sacc.setDedupe(nil)
+	publish("orders.1", "1")
+	natsFlush(t, nc)
+	require_Equal(t, natsNexMsg(t, sub, time.Second).Subject, "orders.1")
+	require_Equal(t, sacc.statz().Deduplicated.Msgs, 2)
+}
+
+func TestAccountDedupeMsgsWithStream(t *testing.T) {
+	s := RunBasicJetStreamServer(t)
+	defer s.Shutdown()
+
+	// Deduplicates all subjects.
+	s.GlobalAccount().setDedupe(&DedupeOpts{})
+
+	nc, js := jsClientConnect(t, s)
+	defer nc.Close()
+	_, err := js.AddStream(&nats.StreamConfig{Name: "ORDERS", Subjects: []string{"orders.>"}})
+	require_NoError(t, err)
+	sub := natsSubSync(t, nc, "events.>")
+	natsFlush(t, nc)
+
+	// A retry of a message stored by the stream is acknowledged as a duplicate.
+	m := nats.NewMsg("orders.1")
+	m.Header.Set(JSMsgId, "1")
+	for i := 0; i < 2; i++ {
+		pa, err := js.PublishMsg(m)
+		require_NoError(t, err)
+		require_Equal(t, pa.Sequence, 1)
+		require_Equal(t, pa.Duplicate, i > 0)
+	}
+
+	// Other subjects are deduplicated.
+	m = nats.NewMsg("events.1")
+	m.Header.Set(JSMsgId, "1")
+	require_NoError(t, nc.PublishMsg(m))
+	require_NoError(t, nc.PublishMsg(m))
+	natsFlush(t, nc)
+	natsNexMsg(t, sub, time.Second)
+	_, err = sub.NextMsg(100 * time.Millisecond)
+	require_Error(t, err, nats.ErrTimeout)
+	require_Equal(t, s.GlobalAccount().statz().Deduplicated.Msgs, 1)
+}
+
+func TestAccountDedupeWindowAndMaxMsgIds(t *testing.T) {
+	window := time.Second
+	d := newMsgDeduper(&DedupeOpts{Window: window, MaxMsgIds: 4})
+	now := time.Now().UnixNano()
+	subj := []byte("foo")
+
+	require_False(t, d.isDuplicate(subj, []byte("1"), now))
+	require_True(t, d.isDuplicate(subj, []byte("1"), now+1))
+	require_False(t, d.isDuplicate([]byte("bar"), []byte("1"), now+1))
+	// After the window, the ID is no longer a duplicate.
+	require_False(t, d.isDuplicate(subj, []byte("1"), now+int64(window)))
+	require_True(t, d.isDuplicate(subj, []byte("1"), now+int64(window)+1))
+
+	// The number of IDs remembered is bounded.
+	for i := range 100 {
+		d.isDuplicate(subj, []byte(strconv.Itoa(i)), now+int64(window)+2)
+	}
+	require_True(t, len(d.cur)+len(d.prev) <= 4)
+	require_True(t, d.isDuplicate(subj, []byte("99"), now+int64(window)+3))
+}
+
+func TestAccountLastValueCacheConfig(t *testing.T) {
+	cf := createConfFile(t, []byte(`
+		accounts: {
+			A: { last_value_cache: {subjects: ["telemetry.>", "state.*"], max_subjects: 100, max_bytes: 1KB} }
+			B: { last_value_cache: {subject: "foo"} }
+		}
+	`))
+	opts, err := ProcessConfigFile(cf)
+	require_NoError(t, err)
+	require_Len(t, len(opts.Accounts), 2)
+	for _, acc := range opts.Accounts {
+		switch acc.Name {
+		case "A":
+			require_True(t, reflect.DeepEqual(*acc.lastValues, LastValueCacheOpts{
+				Subjects:    []string{"telemetry.>", "state.*"},
+				MaxSubjects: 100,
+				MaxBytes:    1024,
+			}))
+		case "B":
+			require_True(t, reflect.DeepEqual(*acc.lastValues, LastValueCacheOpts{Subjects: []string{"foo"}}))
+		}
+	}
+
+	for _, cfg := range []string{
+		`last_value_cache: {}`,
+		`last_value_cache: {subjects: ["telemetry..bad"]}`,
+		`last_value_cache: {subjects: ["foo"], max_subjects: -1}`,
+		`last_value_cache: {subjects: ["foo"], unknown: 1}`,
+	} {
+		cf = createConfFile(t, []byte(fmt.Sprintf(`accounts: { A: { %s } }`, cfg)))
+		_, err = ProcessConfigFile(cf)
+		require_Error(t, err)
+	}
+}
+
+func TestAccountLastValueCacheSubs(t *testing.T) {
+	acc := NewAccount("A")
+	acc.lastValues = &LastValueCacheOpts{Subjects: []string{"telemetry.>"}}
+	o := DefaultOptions()
+	o.Accounts = []*Account{acc}
+	o.Users = []*User{{Username: "a", Password: "a", Account: acc}}
+	s := RunServer(o)
+	defer s.Shutdown()
+
+	nc := natsConnect(t, s.ClientURL(), nats.UserInfo("a", "a"))
+	defer nc.Close()
+	publish := func(subj, data string, hdr bool) {
+		t.Helper()
+		m := nats.NewMsg(subj)
+		if hdr {
+			m.Header.Set("Unit", "C")
+		}
+		m.Data = []byte(data)
+		require_NoError(t, nc.PublishMsg(m))
+	}
+	publish("telemetry.1", "1", false)
+	publish("telemetry.1", "2", true)
+	publish("telemetry.2", "3", false)
+	publish("other", "4", false)
+	natsFlush(t, nc)
+
+	sub, cr, _ := newClientForServer(s)
+	defer sub.close()
+	readMsg := func() (string, string) {
+		t.Helper()
+		l, err := cr.ReadString('\n')
+		require_NoError(t, err)
+		args := strings.Fields(l)
+		require_True(t, args[0] == "MSG" || args[0] == "HMSG")
+		size, err := strconv.Atoi(args[len(args)-1])
+		require_NoError(t, err)
+		buf := make([]byte, size+2)
+		_, err = io.ReadFull(cr, buf)
+		require_NoError(t, err)
+		return strings.Join(args[:len(args)-1], " "), string(buf[:size])
+	}
+	sub.parseAsync("CONNECT {\"user\":\"a\",\"pass\":\"a\",\"headers\":true,\"verbose\":false,\"last_values\":true}\r\n" +
+		"SUB telemetry.> 1\r\nSUB other 3\r\nPING\r\n")
+
+	// The last values are delivered to the subscriptions of clients with the
+	// option, with their headers, then live messages.
+	received := map[string]string{}
+	for range 2 {
+		proto, data := readMsg()
+		received[proto] = data
+	}
+	require_True(t, reflect.DeepEqual(received, map[string]string{
+		"HMSG telemetry.1 1 21": "NATS/1.0\r\nUnit: C\r\n\r\n2",
+		"MSG telemetry.2 1":     "3",
+	}))
+	l, err := cr.ReadString('\n')
+	require_NoError(t, err)
+	require_Equal(t, l, "PONG\r\n")
+
+	publish("telemetry.2", "5", false)
+	natsFlush(t, nc)
+	proto, data := readMsg()
+	require_Equal(t, proto, "MSG telemetry.2 1")
+	require_Equal(t, data, "5")
+
+	// Headers are stripped for clients that do not support them.
+	hsub, hcr, _ := newClientForServer(s)
+	defer hsub.close()
+	hsub.parseAsync("CONNECT {\"user\":\"a\",\"pass\":\"a\",\"verbose\":false,\"last_values\":true}\r\nSUB telemetry.1 1\r\nPING\r\n")
+	l, err = hcr.ReadString('\n')
+	require_NoError(t, err)
+	require_Equal(t, l, "MSG telemetry.1 1 1\r\n")
+	l, err = hcr.ReadString('\n')
+	require_NoError(t, err)
+	require_Equal(t, l, "2\r\n")
+	l, err = hcr.ReadString('\n')
+	require_NoError(t, err)
+	require_Equal(t, l, "PONG\r\n")
+
+	// Clients without the option only receive live messages, and a sid
+	// named "+last" is a regular one.
+	lsub, lcr, _ := newClientForServer(s)
+	defer lsub.close()
+	lsub.parseAsync("CONNECT {\"user\":\"a\",\"pass\":\"a\",\"verbose\":false}\r\nSUB telemetry.1 q +last\r\nPING\r\n")
+	l, err = lcr.ReadString('\n')
+	require_NoError(t, err)
+	require_Equal(t, l, "PONG\r\n")
+	checkSubInterest(t, s, "A", "telemetry.1", time.Second)
+	publish("telemetry.1", "6", false)
+	natsFlush(t, nc)
+	l, err = lcr.ReadString('\n')
+	require_NoError(t, err)
+	require_Equal(t, l, "MSG telemetry.1 +last 1\r\n")
+}
+
+func TestAccountLastValueCacheLimits(t *testing.T) {
+	lvc := newLastValueCache(&LastValueCacheOpts{Subjects: []string{"foo.*"}, MaxSubjects: 3, MaxBytes: 21})
+	msg := []byte("12345\r\n")
+	for _, subj := range []string{"foo.1", "foo.2", "foo.3", "bar", "foo.1", "foo.4"} {
+		lvc.store([]byte(subj), 0, msg)
+	}
+	// The least recently updated subject is evicted.
+	var subjects []string
+	lvc.msgs.Match([]byte(">"), func(subj []byte, _ **lastValue) {
+		subjects = append(subjects, string(subj))
+	})
+	slices.Sort(subjects)
+	require_Equal(t, strings.Join(subjects, ","), "foo.1,foo.3,foo.4")
+	require_Equal(t, lvc.bytes, 21)
+
+	// Which is also the case when the size is exceeded.
+	lvc.store([]byte("foo.3"), 0, []byte("123456789\r\n"))
+	require_Equal(t, lvc.msgs.Size(), 2)
+	require_Equal(t, lvc.bytes, 18)
+	_, ok := lvc.msgs.Find([]byte("foo.1"))
+	require_False(t, ok)
+
+	// Messages larger than the cache are not stored.
+	lvc.store([]byte("foo.5"), 0, make([]byte, 22))
+	_, ok = lvc.msgs.Find([]byte("foo.5"))
+	require_False(t, ok)
+}
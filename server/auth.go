@@ -67,6 +67,7 @@ type NkeyUser struct {
 	SigningKey             string              `json:"signing_key,omitempty"`
 	AllowedConnectionTypes map[string]struct{} `json:"connection_types,omitempty"`
 	ProxyRequired          bool                `json:"proxy_required,omitempty"`
+	RateLimits             *RateLimits         `json:"rate_limits,omitempty"`
 }
 
 // User is for multiple accounts/users.
@@ -78,6 +79,7 @@ type User struct {
 	ConnectionDeadline     time.Time           `json:"connection_deadline,omitempty"`
 	AllowedConnectionTypes map[string]struct{} `json:"connection_types,omitempty"`
 	ProxyRequired          bool                `json:"proxy_required,omitempty"`
+	RateLimits             *RateLimits         `json:"rate_limits,omitempty"`
 }
 
 // clone performs a deep copy of the User struct, returning a new clone with
@@ -90,6 +92,7 @@ func (u *User) clone() *User {
 	*clone = *u
 	// Account is not cloned because it is always by reference to an existing struct.
 	clone.Permissions = u.Permissions.clone()
+	clone.RateLimits = u.RateLimits.clone()
 
 	if u.AllowedConnectionTypes != nil {
 		clone.AllowedConnectionTypes = make(map[string]struct{})
@@ -111,6 +114,7 @@ func (n *NkeyUser) clone() *NkeyUser {
 	*clone = *n
 	// Account is not cloned because it is always by reference to an existing struct.
 	clone.Permissions = n.Permissions.clone()
+	clone.RateLimits = n.RateLimits.clone()
 
 	if n.AllowedConnectionTypes != nil {
 		clone.AllowedConnectionTypes = make(map[string]struct{})
@@ -142,6 +146,11 @@ type Permissions struct {
 	Publish   *SubjectPermission  `json:"publish"`
 	Subscribe *SubjectPermission  `json:"subscribe"`
 	Response  *ResponsePermission `json:"responses,omitempty"`
+	// Queue groups that can be joined, regardless of the subject.
+	// Names can contain wildcards, and plain subscriptions are not affected.
+	// User JWTs have no such field, there queue groups qualify subscribe
+	// permissions with the "subject queue" syntax, which can use templates.
+	QueueGroups *SubjectPermission `json:"queue_groups,omitempty"`
 }
 
 // RoutePermissions are similar to user permissions
@@ -188,6 +197,9 @@ func (p *Permissions) clone() *Permissions {
 			Expires: p.Response.Expires,
 		}
 	}
+	if p.QueueGroups != nil {
+		clone.QueueGroups = p.QueueGroups.clone()
+	}
 	return clone
 }
 
@@ -538,7 +550,7 @@ func processUserPermissionsTemplate(lim jwt.UserPermissionLimits, ujwt *jwt.User
 				for idx, m := range srcs {
 					subj = strings.Replace(subj, m, values[idx][0], -1)
 				}
-				if IsValidSubject(subj) {
+				if isValidPermSubject(subj) {
 					emittedList = append(emittedList, subj)
 				} else if failOnBadSubject {
 					return nil, fmt.Errorf("generated invalid subject")
@@ -550,7 +562,7 @@ func processUserPermissionsTemplate(lim jwt.UserPermissionLimits, ujwt *jwt.User
 					for j := 0; j < len(srcs); j++ {
 						subj = strings.Replace(subj, srcs[j], aa[j], -1)
 					}
-					if IsValidSubject(subj) {
+					if isValidPermSubject(subj) {
 						emittedList = append(emittedList, subj)
 					} else if failOnBadSubject {
 						return nil, fmt.Errorf("generated invalid subject")
@@ -691,7 +703,11 @@ func (s *Server) processClientOrLeafAuthentication(c *client, opts *Options) (au
 			}
 			c.mu.Lock()
 			if c.acc != nil && c.acc.Name == authAccountName {
-				c.mergeDenyPermissions(pub, []string{AuthCalloutSubject})
+				deny := []string{AuthCalloutSubject}
+				if juc == nil && opts.AuthCallout.AuthorizeSubjects {
+					deny = append(deny, AuthCalloutSubjectsSubject)
+				}
+				c.mergeDenyPermissions(pub, deny)
 			}
 			c.mu.Unlock()
 		} else {
@@ -77,14 +77,14 @@ func (s *Server) processClientOrLeafCallout(c *client, opts *Options, proxyRequi
 	reply := s.newRespInbox()
 	respCh := make(chan string, 1)
 
-	decodeResponse := func(rc *client, rmsg []byte, acc *Account) (*jwt.UserClaims, error) {
+	decodeResponse := func(rc *client, rmsg []byte, acc *Account) (*jwt.AuthorizationResponseClaims, *jwt.UserClaims, error) {
 		account := acc.Name
 		_, msg := rc.msgParts(rmsg)
 
 		// This signals not authorized.
 		// Since this is an account subscription will always have "\r\n".
 		if len(msg) <= LEN_CR_LF {
-			return nil, fmt.Errorf("auth callout violation: %q on account %q", "no reason supplied", account)
+			return nil, nil, fmt.Errorf("auth callout violation: %q on account %q", "no reason supplied", account)
 		}
 		// Strip trailing CRLF.
 		msg = msg[:len(msg)-LEN_CR_LF]
@@ -95,34 +95,34 @@ func (s *Server) processClientOrLeafCallout(c *client, opts *Options, proxyRequi
 			var err error
 			msg, err = xkp.Open(msg, pubAccXKey)
 			if err != nil {
-				return nil, fmt.Errorf("error decrypting auth callout response on account %q: %v", account, err)
+				return nil, nil, fmt.Errorf("error decrypting auth callout response on account %q: %v", account, err)
 			}
 			encrypted = true
 		}
 
 		cr, err := jwt.DecodeAuthorizationResponseClaims(string(msg))
 		if err != nil {
-			return nil, err
+			return nil, nil, err
 		}
 		vr := jwt.CreateValidationResults()
 		cr.Validate(vr)
 		if len(vr.Issues) > 0 {
-			return nil, fmt.Errorf("authorization response had validation errors: %v", vr.Issues[0])
+			return nil, nil, fmt.Errorf("authorization response had validation errors: %v", vr.Issues[0])
 		}
 
 		// the subject is the user id
 		if cr.Subject != pub {
-			return nil, errors.New("auth callout violation: auth callout response is not for expected user")
+			return nil, nil, errors.New("auth callout violation: auth callout response is not for expected user")
 		}
 
 		// check the audience to be the server ID
 		if cr.Audience != s.info.ID {
-			return nil, errors.New("auth callout violation: auth callout response is not for server")
+			return nil, nil, errors.New("auth callout violation: auth callout response is not for server")
 		}
 
 		// check if had an error message from the auth account
 		if cr.Error != _EMPTY_ {
-			return nil, fmt.Errorf("auth callout service returned an error: %v", cr.Error)
+			return nil, nil, fmt.Errorf("auth callout service returned an error: %v", cr.Error)
 		}
 
 		// if response is encrypted none of this is needed
@@ -133,12 +133,13 @@ func (s *Server) processClientOrLeafCallout(c *client, opts *Options, proxyRequi
 			}
 			if pkStr != account {
 				if _, ok := acc.signingKeys[pkStr]; !ok {
-					return nil, errors.New("auth callout signing key is unknown")
+					return nil, nil, errors.New("auth callout signing key is unknown")
 				}
 			}
 		}
 
-		return jwt.DecodeUserClaims(cr.Jwt)
+		arc, err := jwt.DecodeUserClaims(cr.Jwt)
+		return cr, arc, err
 	}
 
 	// getIssuerAccount returns the issuer (as per JWT) - it also asserts that
@@ -233,55 +234,47 @@ func (s *Server) processClientOrLeafCallout(c *client, opts *Options, proxyRequi
 		return targetAcc, nil
 	}
 
-	processReply := func(_ *subscription, rc *client, racc *Account, subject, reply string, rmsg []byte) {
-		titleCase := func(m string) string {
-			r := []rune(m)
-			return string(append([]rune{unicode.ToUpper(r[0])}, r[1:]...))
-		}
+	titleCase := func(m string) string {
+		r := []rune(m)
+		return string(append([]rune{unicode.ToUpper(r[0])}, r[1:]...))
+	}
 
-		arc, err := decodeResponse(rc, rmsg, racc)
-		if err != nil {
-			c.authViolation()
-			respCh <- titleCase(err.Error())
-			return
-		}
+	// Applies the authorized user of a response to the client, returns the
+	// reason if it could not be. The user of cached responses was requested
+	// for another connection.
+	authorize := func(arc *jwt.UserClaims, racc *Account, cached bool) string {
 		// If the caller had established that the user should go through a proxy,
 		// or if the `arc` JWT requires it, and we don't have a trusted proxy,
 		// reject the connection.
 		if (proxyRequired || arc.ProxyRequired) && !trustedProxy {
-			err = ErrAuthProxyRequired
+			err := ErrAuthProxyRequired
 			c.setAuthError(err)
 			c.authViolation()
-			respCh <- titleCase(err.Error())
-			return
+			return titleCase(err.Error())
 		}
 		vr := jwt.CreateValidationResults()
 		arc.Validate(vr)
 		if len(vr.Issues) > 0 {
 			c.authViolation()
-			respCh <- fmt.Sprintf("Error validating user JWT: %v", vr.Issues[0])
-			return
+			return fmt.Sprintf("Error validating user JWT: %v", vr.Issues[0])
 		}
 
 		// Make sure that the user is what we requested.
-		if arc.Subject != pub {
+		if !cached && arc.Subject != pub {
 			c.authViolation()
-			respCh <- fmt.Sprintf("Expected authorized user of %q but got %q on account %q", pub, arc.Subject, racc.Name)
-			return
+			return fmt.Sprintf("Expected authorized user of %q but got %q on account %q", pub, arc.Subject, racc.Name)
 		}
 
 		expiration, allowedConnTypes, err := getExpirationAndAllowedConnections(arc, racc.Name)
 		if err != nil {
 			c.authViolation()
-			respCh <- titleCase(err.Error())
-			return
+			return titleCase(err.Error())
 		}
 
 		targetAcc, err := assignAccountAndPermissions(arc, racc.Name)
 		if err != nil {
 			c.authViolation()
-			respCh <- titleCase(err.Error())
-			return
+			return titleCase(err.Error())
 		}
 
 		// the JWT is cleared, because if in operator mode it may hold the JWT
@@ -296,8 +289,7 @@ func (s *Server) processClientOrLeafCallout(c *client, opts *Options, proxyRequi
 		nkuser := buildInternalNkeyUser(arc, allowedConnTypes, targetAcc)
 		if err := c.RegisterNkeyUser(nkuser); err != nil {
 			c.authViolation()
-			respCh <- fmt.Sprintf("Could not register auth callout user: %v", err)
-			return
+			return fmt.Sprintf("Could not register auth callout user: %v", err)
 		}
 
 		// See if the response wants to override the username.
@@ -311,9 +303,99 @@ func (s *Server) processClientOrLeafCallout(c *client, opts *Options, proxyRequi
 			c.mu.Unlock()
 		}
 
+		// Subjects not allowed by the permissions can be authorized by the
+		// auth service later on.
+		if !isOperatorMode && opts.AuthCallout != nil && opts.AuthCallout.AuthorizeSubjects {
+			c.mu.Lock()
+			c.authSubjAcc = acc
+			c.mu.Unlock()
+		}
+
 		// Check if we need to set an auth timer if the user jwt expires.
 		c.setExpiration(arc.Claims(), expiration)
+		return _EMPTY_
+	}
 
+	// Check for a cached response for the same credentials and client
+	// information, which is discarded if no longer valid.
+	c.mu.Lock()
+	key, cacheable := c.authCalloutCacheKey(acc)
+	c.mu.Unlock()
+	useCached := func(stale bool) (bool, string) {
+		ujwt, ok := s.acCache.get(key, stale)
+		if !ok {
+			return false, _EMPTY_
+		}
+		arc, err := jwt.DecodeUserClaims(ujwt)
+		if err != nil {
+			s.acCache.remove(key)
+			return false, _EMPTY_
+		}
+		if errStr := authorize(arc, acc, true); errStr != _EMPTY_ {
+			s.acCache.remove(key)
+			return true, errStr
+		}
+		c.Debugf("Authorized with a cached auth callout response")
+		return true, _EMPTY_
+	}
+	// When the auth service did not respond, use an expired cached response
+	// if the failure policy allows it.
+	calloutFailed := func(errStr string) (bool, string) {
+		if cacheable {
+			if done, cerrStr := useCached(true); done {
+				if cerrStr == _EMPTY_ {
+					s.Warnf("Authorization callout failed, using an expired cached response for %s", c)
+				}
+				return cerrStr == _EMPTY_, cerrStr
+			}
+		}
+		return false, errStr
+	}
+
+	authTimeout := secondsToDuration(s.getOpts().AuthTimeout)
+	if cacheable {
+		if done, errStr := useCached(false); done {
+			return errStr == _EMPTY_, errStr
+		}
+		// Wait for a request with the same credentials, which is likely to
+		// be cached, instead of sending another one.
+		if wait, first := s.acCache.join(key); first {
+			defer s.acCache.leave(key)
+		} else {
+			select {
+			case <-wait:
+			case <-time.After(authTimeout):
+			}
+			if done, errStr := useCached(false); done {
+				return errStr == _EMPTY_, errStr
+			}
+		}
+	}
+	// Limit the number of concurrent requests to the auth service.
+	if !s.acCache.acquire(authTimeout) {
+		errStr = fmt.Sprintf("Too many pending authorization callout requests on account %q", acc.Name)
+		s.Warnf(errStr)
+		return calloutFailed(errStr)
+	}
+	defer s.acCache.release()
+
+	processReply := func(_ *subscription, rc *client, racc *Account, subject, reply string, rmsg []byte) {
+		cr, arc, err := decodeResponse(rc, rmsg, racc)
+		if err != nil {
+			c.authViolation()
+			respCh <- titleCase(err.Error())
+			return
+		}
+		if errStr := authorize(arc, racc, false); errStr != _EMPTY_ {
+			respCh <- errStr
+			return
+		}
+		if cacheable {
+			c.mu.Lock()
+			target := c.acc.Name
+			c.mu.Unlock()
+			s.acCache.store(key, cr, arc, racc.Name, target)
+		}
 		respCh <- _EMPTY_
 	}
 
@@ -361,7 +443,6 @@ func (s *Server) processClientOrLeafCallout(c *client, opts *Options, proxyRequi
 		claim.Server.XKey = xkey
 	}
 
-	authTimeout := secondsToDuration(s.getOpts().AuthTimeout)
 	claim.Expires = time.Now().Add(time.Duration(authTimeout)).UTC().Unix()
 
 	// Grab client info for the request.
@@ -430,7 +511,7 @@ func (s *Server) processClientOrLeafCallout(c *client, opts *Options, proxyRequi
 	if err := s.sendInternalAccountMsgWithReply(acc, AuthCalloutSubject, reply, hdr, req, false); err != nil {
 		errStr = fmt.Sprintf("Error sending authorization request: %v", err)
 		s.Debugf(errStr)
-		return false, errStr
+		return calloutFailed(errStr)
 	}
 	select {
 	case errStr = <-respCh:
@@ -439,6 +520,9 @@ func (s *Server) processClientOrLeafCallout(c *client, opts *Options, proxyRequi
 		}
 	case <-time.After(authTimeout):
 		s.Debugf(fmt.Sprintf("Authorization callout response not received in time on account %q", acc.Name))
+		// Ignore a late response.
+		acc.unsubscribeInternal(sub)
+		return calloutFailed(_EMPTY_)
 	}
 
 	return authorized, errStr
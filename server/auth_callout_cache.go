@@ -0,0 +1,270 @@
+// Copyright 2025 The NATS Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"crypto/sha256"
+	"crypto/tls"
+	"sync"
+	"time"
+
+	"github.com/nats-io/jwt/v2"
+)
+
+// Default maximum number of cached authorization responses.
+const defaultAuthCalloutCacheMaxEntries = 10_000
+
+// AuthCalloutCachePurgeResponse is the response to a request purging the
+// cached authorization responses of an account.
+type AuthCalloutCachePurgeResponse struct {
+	Account string `json:"account"`
+	Purged  int    `json:"purged"`
+}
+
+// Key of a cached authorization response, the hash of the credentials
+// and client information sent in the request.
+type authCalloutCacheKey [sha256.Size]byte
+
+// An authorization response of the auth callout service.
+type authCalloutCacheEntry struct {
+	// Account the request was sent on.
+	account string
+	// Account the user was bound to.
+	target string
+	// User JWT of the response.
+	ujwt string
+	// When the entry is no longer used, unless the service can not be
+	// reached and stale responses are allowed.
+	expires time.Time
+	// When the entry is removed.
+	stale time.Time
+}
+
+// Caches the responses of the auth callout service, so that clients that
+// reconnect do not all wait for the service, and limits the concurrent
+// requests to it. Concurrent connections with the same credentials wait
+// for the response of the first one.
+type authCalloutCache struct {
+	mu       sync.Mutex
+	ttl      time.Duration
+	max      int
+	failOpen bool
+	entries  map[authCalloutCacheKey]*authCalloutCacheEntry
+	inflight map[authCalloutCacheKey]chan struct{}
+	sem      chan struct{}
+}
+
+func newAuthCalloutCache(ac *AuthCallout) *authCalloutCache {
+	cache := &authCalloutCache{
+		max:      defaultAuthCalloutCacheMaxEntries,
+		entries:  make(map[authCalloutCacheKey]*authCalloutCacheEntry),
+		inflight: make(map[authCalloutCacheKey]chan struct{}),
+	}
+	if ac != nil {
+		cache.ttl, cache.failOpen = ac.CacheTTL, ac.FailOpen
+		if ac.CacheMaxEntries > 0 {
+			cache.max = ac.CacheMaxEntries
+		}
+		if ac.MaxPending > 0 {
+			cache.sem = make(chan struct{}, ac.MaxPending)
+		}
+	}
+	return cache
+}
+
+// Returns the cache key of the client's authorization request sent on the
+// account. Requests with a signed nonce are unique, so are not cached.
+// Lock should be held.
+func (c *client) authCalloutCacheKey(acc *Account) (authCalloutCacheKey, bool) {
+	o := &c.opts
+	if o.Sig != _EMPTY_ {
+		return authCalloutCacheKey{}, false
+	}
+	h := sha256.New()
+	for _, v := range []string{acc.Name, c.kindString(), c.clientTypeString(), c.host,
+		o.JWT, o.Nkey, o.Token, o.Username, o.Password, o.Name, c.getMQTTClientID()} {
+		h.Write([]byte(v))
+		h.Write([]byte{0})
+	}
+	if conn, ok := c.nc.(*tls.Conn); ok && c.flags.isSet(handshakeComplete) {
+		for _, cert := range conn.ConnectionState().PeerCertificates {
+			h.Write(cert.Raw)
+			h.Write([]byte{0})
+		}
+	}
+	var key authCalloutCacheKey
+	h.Sum(key[:0])
+	return key, true
+}
+
+// Returns the user JWT of a cached response. Expired responses are
+// returned only if stale ones are allowed.
+func (ac *authCalloutCache) get(key authCalloutCacheKey, stale bool) (string, bool) {
+	now := time.Now()
+	ac.mu.Lock()
+	defer ac.mu.Unlock()
+	e := ac.entries[key]
+	if e == nil {
+		return _EMPTY_, false
+	}
+	if now.After(e.stale) {
+		delete(ac.entries, key)
+		return _EMPTY_, false
+	}
+	if now.After(e.expires) && !(stale && ac.failOpen) {
+		return _EMPTY_, false
+	}
+	return e.ujwt, true
+}
+
+// Caches a response until the response JWT expires, or for the default TTL
+// if it does not. The entry is kept until the user JWT expires if stale
+// responses are allowed.
+func (ac *authCalloutCache) store(key authCalloutCacheKey, cr *jwt.AuthorizationResponseClaims, arc *jwt.UserClaims, account, target string) {
+	ttl := ac.ttl
+	if cr.Expires > 0 {
+		ttl = time.Until(time.Unix(cr.Expires, 0))
+	}
+	if ttl <= 0 {
+		return
+	}
+	now := time.Now()
+	e := &authCalloutCacheEntry{account: account, target: target, ujwt: cr.Jwt, expires: now.Add(ttl)}
+	var exp time.Time
+	if arc.Expires > 0 {
+		if exp = time.Unix(arc.Expires, 0); exp.Before(e.expires) {
+			e.expires = exp
+		}
+	}
+	e.stale = e.expires
+	if ac.failOpen {
+		// Stale responses are usable until the user JWT expires, or for
+		// ten times the TTL without expiration.
+		if e.stale = exp; exp.IsZero() {
+			e.stale = now.Add(10 * ttl)
+		}
+	}
+
+	ac.mu.Lock()
+	defer ac.mu.Unlock()
+	if _, ok := ac.entries[key]; !ok && len(ac.entries) >= ac.max {
+		for k, e := range ac.entries {
+			if now.After(e.stale) {
+				delete(ac.entries, k)
+			}
+		}
+		// Random delete if still full.
+		for k := range ac.entries {
+			if len(ac.entries) < ac.max {
+				break
+			}
+			delete(ac.entries, k)
+		}
+	}
+	ac.entries[key] = e
+}
+
+// Removes a cached response.
+func (ac *authCalloutCache) remove(key authCalloutCacheKey) {
+	ac.mu.Lock()
+	delete(ac.entries, key)
+	ac.mu.Unlock()
+}
+
+// Removes the cached responses of requests sent on the account, or of
+// users bound to it, and returns how many were.
+func (ac *authCalloutCache) purge(account string) int {
+	ac.mu.Lock()
+	defer ac.mu.Unlock()
+	var n int
+	for k, e := range ac.entries {
+		if e.account == account || e.target == account {
+			delete(ac.entries, k)
+			n++
+		}
+	}
+	return n
+}
+
+// Registers a request for the key. If one is already in flight, returns
+// a channel closed once it completes.
+func (ac *authCalloutCache) join(key authCalloutCacheKey) (<-chan struct{}, bool) {
+	ac.mu.Lock()
+	defer ac.mu.Unlock()
+	if ch, ok := ac.inflight[key]; ok {
+		return ch, false
+	}
+	ac.inflight[key] = make(chan struct{})
+	return nil, true
+}
+
+// Marks the request for the key as completed.
+func (ac *authCalloutCache) leave(key authCalloutCacheKey) {
+	ac.mu.Lock()
+	defer ac.mu.Unlock()
+	if ch, ok := ac.inflight[key]; ok {
+		close(ch)
+		delete(ac.inflight, key)
+	}
+}
+
+// Waits for a request slot to the auth service, up to the timeout.
+func (ac *authCalloutCache) acquire(timeout time.Duration) bool {
+	if ac.sem == nil {
+		return true
+	}
+	select {
+	case ac.sem <- struct{}{}:
+		return true
+	default:
+	}
+	t := time.NewTimer(timeout)
+	defer t.Stop()
+	select {
+	case ac.sem <- struct{}{}:
+		return true
+	case <-t.C:
+		return false
+	}
+}
+
+// Releases a request slot acquired with acquire().
+func (ac *authCalloutCache) release() {
+	if ac.sem != nil {
+		<-ac.sem
+	}
+}
+
+// PurgeAuthCalloutCache removes the cached authorization responses of
+// the account, and returns how many were.
+func (s *Server) PurgeAuthCalloutCache(account string) int {
+	n := s.acCache.purge(account)
+	if n > 0 {
+		s.Noticef("Purged %d cached authorization responses of account %q", n, account)
+	}
+	return n
+}
+
+// Handles requests to purge the cached authorization responses of an account.
+func (s *Server) authCalloutCachePurgeRequest(_ *subscription, c *client, _ *Account, subject, reply string, hdr, msg []byte) {
+	if !s.eventsRunning() {
+		return
+	}
+	optz := &EventFilterOptions{}
+	s.zReq(c, reply, hdr, msg, optz, optz, func() (any, error) {
+		// Subject is "$SYS.REQ.ACCOUNT.<account>.AUTH.CACHE.PURGE".
+		acc := tokenAt(subject, 4)
+		return &AuthCalloutCachePurgeResponse{Account: acc, Purged: s.PurgeAuthCalloutCache(acc)}, nil
+	})
+}
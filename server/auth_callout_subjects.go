@@ -0,0 +1,286 @@
+// Copyright 2025 The NATS Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/nats-io/jwt/v2"
+	"github.com/nats-io/nkeys"
+)
+
+// AuthCalloutSubjectsSubject is the subject of the requests sent to the
+// auth callout service when a user it authorized publishes or subscribes
+// to a subject its permissions do not allow, nor explicitly deny. The
+// request is a JSON encoded AuthCalloutSubjectRequest, encrypted with the
+// xkey of the service if configured. The response is a generic JWT signed
+// by the auth callout issuer, whose subject is the user nkey of the request,
+// whose audience is the server id, and whose "nats" claim is the
+// AuthCalloutSubjectResponse. It can be encrypted as well.
+const AuthCalloutSubjectsSubject = "$SYS.REQ.USER.AUTH.SUBJECT"
+
+const (
+	// Maximum time to wait for the auth service to authorize a subject.
+	// Requests are sent from the read loop of the client, which does not
+	// process any other message of the connection meanwhile, so are bounded
+	// tighter than the authorization timeout. Decisions are kept for the
+	// lifetime of the connection, so this is paid once per subject.
+	authSubjectCalloutTimeout = 250 * time.Millisecond
+	// How long a failed request is cached as a denial, so that the read
+	// loop of the client does not wait on the service for every message.
+	authSubjectFailureTTL = 5 * time.Second
+)
+
+// AuthCalloutSubjectRequest asks the auth callout service whether a client
+// can publish or subscribe to a subject.
+type AuthCalloutSubjectRequest struct {
+	Client  *ClientInfo `json:"client"`
+	Subject string      `json:"subject"`
+	Queue   string      `json:"queue,omitempty"`
+	Publish bool        `json:"publish,omitempty"`
+	// Public user nkey expected as the subject of the response, unique
+	// to the request, to prevent replays.
+	UserNkey string `json:"user_nkey"`
+	// Id of the server, expected as the audience of the response.
+	ServerID string `json:"server_id"`
+}
+
+// AuthCalloutSubjectResponse is the decision of the auth callout service,
+// which is kept for the lifetime of the connection.
+type AuthCalloutSubjectResponse struct {
+	Allowed bool   `json:"allowed"`
+	Error   string `json:"error,omitempty"`
+}
+
+// A subject authorization decision of the auth callout service.
+type authSubjectDecision struct {
+	allowed bool
+	// Expiration in unix nanoseconds of a denial due to a failed
+	// request, zero if kept for the lifetime of the connection.
+	expires int64
+}
+
+// Key of a subject authorization decision of a client.
+func authSubjectKey(pub bool, subject, queue string) string {
+	if pub {
+		return "pub " + subject
+	}
+	if queue != _EMPTY_ {
+		return "sub " + subject + " " + queue
+	}
+	return "sub " + subject
+}
+
+// Returns true if the auth callout service allowed the subscription.
+// Lock should be held.
+func (c *client) authSubjectAllowed(subject, queue string) bool {
+	return c.authSubjs[authSubjectKey(false, subject, queue)].allowed
+}
+
+// Returns true if the subject, or subscription, is explicitly denied by
+// the permissions of the client.
+// Lock should be held.
+func (c *client) authSubjectDenied(pub bool, subject, queue string) bool {
+	if c.perms == nil {
+		return false
+	}
+	if pub {
+		if c.perms.pub.deny == nil {
+			return false
+		}
+		np, _ := c.perms.pub.deny.NumInterest(subject)
+		return np > 0
+	}
+	if c.perms.sub.deny != nil {
+		r := c.perms.sub.deny.Match(subject)
+		if len(r.psubs) > 0 || (queue != _EMPTY_ && len(r.qsubs) > 0 && queueMatches(queue, r.qsubs)) {
+			return true
+		}
+	}
+	return queue == sysGroup || (queue != _EMPTY_ && c.perms.queue != nil && queueNameMatches(queue, c.perms.queue.Deny))
+}
+
+// Checks with the auth callout service whether the client can publish or
+// subscribe to a subject its permissions do not allow. The decision is
+// cached on the client, failed requests are cached as denials for a while.
+// Lock should not be held.
+func (c *client) checkAuthSubject(pub bool, subject, queue string) bool {
+	c.mu.Lock()
+	acc, srv := c.authSubjAcc, c.srv
+	if acc == nil || srv == nil || c.isClosed() || c.authSubjectDenied(pub, subject, queue) {
+		c.mu.Unlock()
+		return false
+	}
+	key := authSubjectKey(pub, subject, queue)
+	if d, ok := c.authSubjs[key]; ok && (d.expires == 0 || time.Now().UnixNano() < d.expires) {
+		c.mu.Unlock()
+		return d.allowed
+	}
+	c.mu.Unlock()
+
+	req := &AuthCalloutSubjectRequest{Client: c.getClientInfo(true), Subject: subject, Queue: queue, Publish: pub}
+	var d authSubjectDecision
+	allowed, err := srv.authSubjectCallout(acc, req)
+	if err != nil {
+		c.Warnf("Authorization callout for subject %q failed: %v", subject, err)
+		d.expires = time.Now().Add(authSubjectFailureTTL).UnixNano()
+	} else {
+		c.Debugf("Authorization callout for subject %q: allowed %v", subject, allowed)
+		d.allowed = allowed
+	}
+
+	c.mu.Lock()
+	if c.authSubjs == nil {
+		c.authSubjs = make(map[string]authSubjectDecision)
+	} else if len(c.authSubjs) >= maxPermCacheSize {
+		// Prune the decisions cache. Random delete.
+		n := 0
+		for k := range c.authSubjs {
+			delete(c.authSubjs, k)
+			if n++; n > pruneSize {
+				break
+			}
+		}
+	}
+	c.authSubjs[key] = d
+	if pub && allowed && c.perms != nil {
+		c.perms.pcache.Store(subject, true)
+	}
+	// As in canSubscribe(), wildcard subscriptions may need the deny filter.
+	if !pub && allowed && c.mperms == nil && subjectHasWildcard(subject) {
+		for _, deny := range c.darray {
+			if subjectIsSubsetMatch(deny, subject) {
+				c.loadMsgDenyFilter()
+				break
+			}
+		}
+	}
+	c.mu.Unlock()
+	return allowed
+}
+
+// Sends a subject authorization request to the auth callout service on the
+// account and waits for the response, up to the authorization timeout and
+// at most authSubjectCalloutTimeout.
+func (s *Server) authSubjectCallout(acc *Account, req *AuthCalloutSubjectRequest) (bool, error) {
+	ac := s.getOpts().AuthCallout
+	if ac == nil {
+		return false, errors.New("auth callout not configured")
+	}
+	// The response must be for this user nkey, which prevents replays.
+	ukp, err := nkeys.CreateUser()
+	if err != nil {
+		return false, err
+	}
+	if req.UserNkey, err = ukp.PublicKey(); err != nil {
+		return false, err
+	}
+	req.ServerID = s.info.ID
+	b, err := json.Marshal(req)
+	if err != nil {
+		return false, err
+	}
+	// Check if we have been requested to encrypt.
+	var hdr []byte
+	xkp := s.xkp
+	if ac.XKey == _EMPTY_ {
+		xkp = nil
+	}
+	if xkp != nil {
+		if b, err = xkp.Seal(b, ac.XKey); err != nil {
+			return false, fmt.Errorf("error encrypting request: %v", err)
+		}
+		hdr = genHeader(hdr, AuthRequestXKeyHeader, s.info.XKey)
+	}
+
+	respCh := make(chan []byte, 1)
+	reply := s.newRespInbox()
+	sub, err := acc.subscribeInternal(reply, func(_ *subscription, rc *client, _ *Account, _, _ string, rmsg []byte) {
+		_, msg := rc.msgParts(rmsg)
+		select {
+		case respCh <- bytes.Clone(msg):
+		default:
+		}
+	})
+	if err != nil {
+		return false, err
+	}
+	defer acc.unsubscribeInternal(sub)
+
+	if err := s.sendInternalAccountMsgWithReply(acc, AuthCalloutSubjectsSubject, reply, hdr, b, false); err != nil {
+		return false, err
+	}
+	timeout := min(secondsToDuration(s.getOpts().AuthTimeout), authSubjectCalloutTimeout)
+	select {
+	case msg := <-respCh:
+		resp, err := s.decodeAuthSubjectResponse(msg, ac, xkp, req.UserNkey)
+		if err != nil {
+			return false, err
+		}
+		if resp.Error != _EMPTY_ {
+			return false, errors.New(resp.Error)
+		}
+		return resp.Allowed, nil
+	case <-time.After(timeout):
+		return false, errors.New("response not received in time")
+	}
+}
+
+// Decodes and validates the signed response of the auth callout service
+// to a subject authorization request for the user nkey.
+func (s *Server) decodeAuthSubjectResponse(msg []byte, ac *AuthCallout, xkp nkeys.KeyPair, unkey string) (*AuthCalloutSubjectResponse, error) {
+	// Since this is an account subscription will always have "\r\n".
+	if len(msg) <= LEN_CR_LF {
+		return nil, errors.New("empty response")
+	}
+	msg = msg[:len(msg)-LEN_CR_LF]
+	// If we sent an encrypted request the response could be encrypted as well.
+	if xkp != nil && !bytes.HasPrefix(msg, []byte(jwtPrefix)) {
+		var err error
+		if msg, err = xkp.Open(msg, ac.XKey); err != nil {
+			return nil, fmt.Errorf("error decrypting response: %v", err)
+		}
+	}
+	gc, err := jwt.DecodeGeneric(string(msg))
+	if err != nil {
+		return nil, fmt.Errorf("invalid response: %v", err)
+	}
+	vr := jwt.CreateValidationResults()
+	gc.Validate(vr)
+	if len(vr.Issues) > 0 {
+		return nil, fmt.Errorf("response had validation errors: %v", vr.Issues[0])
+	}
+	if gc.Issuer != ac.Issuer {
+		return nil, fmt.Errorf("wrong issuer for response, expected %q got %q", ac.Issuer, gc.Issuer)
+	}
+	if gc.Subject != unkey {
+		return nil, errors.New("response is not for expected user")
+	}
+	if gc.Audience != s.info.ID {
+		return nil, errors.New("response is not for server")
+	}
+	b, err := json.Marshal(gc.Data)
+	if err != nil {
+		return nil, err
+	}
+	var resp AuthCalloutSubjectResponse
+	if err := json.Unmarshal(b, &resp); err != nil {
+		return nil, fmt.Errorf("invalid response: %v", err)
+	}
+	return &resp, nil
+}
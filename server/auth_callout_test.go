@@ -2492,3 +2492,430 @@ func TestAuthCalloutProxyRequiredInUserNotInAuthJWT(t *testing.T) {
 	// Auth callout should have been invoked once.
 	require_Equal(t, 1, int(invoked.Load()))
 }
+
+func TestAuthCalloutCacheConfig(t *testing.T) {
+	cf := createConfFile(t, []byte(`
+		authorization {
+			users: [ { user: "auth", password: "pwd" } ]
+			auth_callout {
+				issuer: "ABJHLOVMPA4CI6R5KLNGOB4GSLNIY7IOUPAJC4YFNDLQVIOBYQGUWVLA"
+				auth_users: [ auth ]
+				cache_ttl: "5m"
+				cache_max_entries: 100
+				max_pending: 10
+				failure_policy: open
+				authorize_subjects: true
+			}
+		}
+	`))
+	opts, err := ProcessConfigFile(cf)
+	require_NoError(t, err)
+	ac := opts.AuthCallout
+	require_Equal(t, ac.CacheTTL, 5*time.Minute)
+	require_Equal(t, ac.CacheMaxEntries, 100)
+	require_Equal(t, ac.MaxPending, 10)
+	require_True(t, ac.FailOpen)
+	require_True(t, ac.AuthorizeSubjects)
+
+	for _, cfg := range []string{
+		`cache_ttl: "bad"`,
+		`max_pending: -1`,
+		`failure_policy: "maybe"`,
+	} {
+		cf = createConfFile(t, []byte(fmt.Sprintf(`
+			authorization {
+				users: [ { user: "auth", password: "pwd" } ]
+				auth_callout {
+					issuer: "ABJHLOVMPA4CI6R5KLNGOB4GSLNIY7IOUPAJC4YFNDLQVIOBYQGUWVLA"
+					auth_users: [ auth ]
+					%s
+				}
+			}
+		`, cfg)))
+		_, err = ProcessConfigFile(cf)
+		require_Error(t, err)
+	}
+}
+
+// Runs a server in config mode with the auth callout account and a system
+// account, since account users are set programmatically.
+func runAuthCalloutCacheServer(t *testing.T, ac *AuthCallout) *Server {
+	t.Helper()
+	sysAcc := NewAccount("SYS")
+	o := DefaultOptions()
+	o.Accounts = []*Account{sysAcc}
+	o.SystemAccount = "SYS"
+	o.Users = []*User{
+		{Username: "auth", Password: "pwd"},
+		{Username: "sys", Password: "pwd", Account: sysAcc},
+	}
+	o.AuthTimeout = 0.5
+	ac.Issuer, ac.Account, ac.AuthUsers = authCalloutIssuer, globalAccountName, []string{"auth", "sys"}
+	o.AuthCallout = ac
+	return RunServer(o)
+}
+
+func TestAuthCalloutResponseCache(t *testing.T) {
+	s := runAuthCalloutCacheServer(t, &AuthCallout{CacheTTL: time.Minute})
+	defer s.Shutdown()
+
+	var callouts atomic.Int32
+	handler := func(m *nats.Msg) {
+		callouts.Add(1)
+		user, si, _, opts, _ := decodeAuthRequest(t, m.Data)
+		if opts.Password != "zzz" {
+			m.Respond(nil)
+			return
+		}
+		if opts.Username == "slow" {
+			time.Sleep(100 * time.Millisecond)
+		}
+		var j jwt.UserPermissionLimits
+		j.Pub.Allow.Add("foo", "$SYS.>")
+		cr := jwt.NewAuthorizationResponseClaims(user)
+		cr.Audience = si.ID
+		cr.Jwt = createAuthUser(t, user, _EMPTY_, globalAccountName, _EMPTY_, nil, 10*time.Minute, &j)
+		if opts.Username == "short" {
+			// Cached until the response expires, instead of the TTL.
+			cr.Expires = time.Now().Add(2 * time.Second).Unix()
+		}
+		aa, err := nkeys.FromSeed([]byte(authCalloutIssuerSeed))
+		require_NoError(t, err)
+		token, err := cr.Encode(aa)
+		require_NoError(t, err)
+		m.Respond([]byte(token))
+	}
+	ac := natsConnect(t, s.ClientURL(), nats.UserInfo("auth", "pwd"))
+	defer ac.Close()
+	natsSub(t, ac, AuthCalloutSubject, handler)
+	natsFlush(t, ac)
+
+	connect := func(user, pass string) (*nats.Conn, error) {
+		return nats.Connect(s.ClientURL(), nats.UserInfo(user, pass), nats.MaxReconnects(0))
+	}
+	userInfo := func(nc *nats.Conn) *UserInfo {
+		t.Helper()
+		resp, err := nc.Request(userDirectInfoSubj, nil, time.Second)
+		require_NoError(t, err)
+		response := ServerAPIResponse{Data: &UserInfo{}}
+		require_NoError(t, json.Unmarshal(resp.Data, &response))
+		return response.Data.(*UserInfo)
+	}
+
+	// Only the first connection sends a request, the others get the same
+	// account and permissions from the cache.
+	for range 3 {
+		nc, err := connect("dlc", "zzz")
+		require_NoError(t, err)
+		ui := userInfo(nc)
+		require_Equal(t, ui.Account, globalAccountName)
+		require_True(t, slices.Contains(ui.Permissions.Publish.Allow, "foo"))
+		nc.Close()
+	}
+	require_Equal(t, callouts.Load(), 1)
+
+	// Denials are not cached.
+	for range 2 {
+		_, err := connect("dlc", "bad")
+		require_Error(t, err)
+	}
+	require_Equal(t, callouts.Load(), 3)
+
+	// Responses that expire are cached until then.
+	for range 2 {
+		nc, err := connect("short", "zzz")
+		require_NoError(t, err)
+		nc.Close()
+	}
+	require_Equal(t, callouts.Load(), 4)
+	time.Sleep(2100 * time.Millisecond)
+	nc, err := connect("short", "zzz")
+	require_NoError(t, err)
+	nc.Close()
+	require_Equal(t, callouts.Load(), 5)
+
+	// Concurrent connections with the same credentials wait for the
+	// response of the first one.
+	var wg sync.WaitGroup
+	errCh := make(chan error, 5)
+	for range 5 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			nc, err := connect("slow", "zzz")
+			if err == nil {
+				nc.Close()
+			}
+			errCh <- err
+		}()
+	}
+	wg.Wait()
+	close(errCh)
+	for err := range errCh {
+		require_NoError(t, err)
+	}
+	require_Equal(t, callouts.Load(), 6)
+
+	// Purging the cache of the account through the system account.
+	sc := natsConnect(t, s.ClientURL(), nats.UserInfo("sys", "pwd"))
+	defer sc.Close()
+	resp, err := sc.Request(fmt.Sprintf(authCachePurgeReqSubj, globalAccountName), nil, time.Second)
+	require_NoError(t, err)
+	response := ServerAPIResponse{Data: &AuthCalloutCachePurgeResponse{}}
+	require_NoError(t, json.Unmarshal(resp.Data, &response))
+	require_True(t, response.Error == nil)
+	require_Equal(t, *response.Data.(*AuthCalloutCachePurgeResponse), AuthCalloutCachePurgeResponse{Account: globalAccountName, Purged: 3})
+
+	nc, err = connect("dlc", "zzz")
+	require_NoError(t, err)
+	nc.Close()
+	require_Equal(t, callouts.Load(), 7)
+}
+
+func TestAuthCalloutFailurePolicy(t *testing.T) {
+	for _, failOpen := range []bool{false, true} {
+		t.Run(fmt.Sprintf("fail open %v", failOpen), func(t *testing.T) {
+			s := runAuthCalloutCacheServer(t, &AuthCallout{CacheTTL: 50 * time.Millisecond, MaxPending: 1, FailOpen: failOpen})
+			defer s.Shutdown()
+
+			handler := func(m *nats.Msg) {
+				user, si, _, _, _ := decodeAuthRequest(t, m.Data)
+				ujwt := createAuthUser(t, user, _EMPTY_, globalAccountName, _EMPTY_, nil, 10*time.Minute, nil)
+				m.Respond(serviceResponse(t, user, si.ID, ujwt, _EMPTY_, 0))
+			}
+			ac := natsConnect(t, s.ClientURL(), nats.UserInfo("auth", "pwd"))
+			defer ac.Close()
+			sub := natsSub(t, ac, AuthCalloutSubject, handler)
+			natsFlush(t, ac)
+
+			connect := func() error {
+				nc, err := nats.Connect(s.ClientURL(), nats.UserInfo("dlc", "zzz"), nats.MaxReconnects(0))
+				if err == nil {
+					nc.Close()
+				}
+				return err
+			}
+			require_NoError(t, connect())
+
+			// Once the cached response expired, a request is sent but
+			// the auth service does not respond.
+			natsUnsub(t, sub)
+			natsFlush(t, ac)
+			time.Sleep(100 * time.Millisecond)
+			if err := connect(); failOpen {
+				require_NoError(t, err)
+			} else {
+				require_Error(t, err)
+			}
+		})
+	}
+}
+
+func TestAuthCalloutSubjectAuthorization(t *testing.T) {
+	s := runAuthCalloutCacheServer(t, &AuthCallout{AuthorizeSubjects: true})
+	defer s.Shutdown()
+
+	handler := func(m *nats.Msg) {
+		user, si, _, _, _ := decodeAuthRequest(t, m.Data)
+		var j jwt.UserPermissionLimits
+		j.Pub.Allow.Add("foo")
+		j.Sub.Allow.Add("foo")
+		j.Sub.Deny.Add("secret.>")
+		ujwt := createAuthUser(t, user, _EMPTY_, globalAccountName, _EMPTY_, nil, 10*time.Minute, &j)
+		m.Respond(serviceResponse(t, user, si.ID, ujwt, _EMPTY_, 0))
+	}
+	var requests sync.Map
+	subjHandler := func(m *nats.Msg) {
+		var req AuthCalloutSubjectRequest
+		require_NoError(t, json.Unmarshal(m.Data, &req))
+		require_Equal(t, req.Client.User, "dlc")
+		key := fmt.Sprintf("%v %s", req.Publish, req.Subject)
+		n, _ := requests.LoadOrStore(key, new(atomic.Int32))
+		n.(*atomic.Int32).Add(1)
+		allowed := req.Subject == "orders.customer1" || req.Subject == "orders.customer1.>"
+		switch req.Subject {
+		case "orders.forged":
+			// Unsigned responses, e.g. from any subscriber of the auth account, are rejected.
+			b, err := json.Marshal(&AuthCalloutSubjectResponse{Allowed: true})
+			require_NoError(t, err)
+			m.Respond(b)
+			return
+		case "orders.replayed":
+			// Responses must be for the user nkey of the request.
+			ukp, err := nkeys.CreateUser()
+			require_NoError(t, err)
+			req.UserNkey, err = ukp.PublicKey()
+			require_NoError(t, err)
+			allowed = true
+		case "orders.slow":
+			return
+		}
+		gc := jwt.NewGenericClaims(req.UserNkey)
+		gc.Audience = req.ServerID
+		gc.Data["allowed"] = allowed
+		aa, err := nkeys.FromSeed([]byte(authCalloutIssuerSeed))
+		require_NoError(t, err)
+		token, err := gc.Encode(aa)
+		require_NoError(t, err)
+		m.Respond([]byte(token))
+	}
+	ac := natsConnect(t, s.ClientURL(), nats.UserInfo("auth", "pwd"))
+	defer ac.Close()
+	natsSub(t, ac, AuthCalloutSubject, handler)
+	natsSub(t, ac, AuthCalloutSubjectsSubject, subjHandler)
+	natsFlush(t, ac)
+	numRequests := func(pub bool, subject string) int32 {
+		if n, ok := requests.Load(fmt.Sprintf("%v %s", pub, subject)); ok {
+			return n.(*atomic.Int32).Load()
+		}
+		return 0
+	}
+
+	errCh := make(chan error, 10)
+	nc := natsConnect(t, s.ClientURL(), nats.UserInfo("dlc", "zzz"),
+		nats.ErrorHandler(func(_ *nats.Conn, _ *nats.Subscription, err error) { errCh <- err }))
+	defer nc.Close()
+
+	// Subjects the auth service allows.
+	sub := natsSubSync(t, nc, "orders.customer1")
+	wsub := natsSubSync(t, nc, "orders.customer1.>")
+	for range 2 {
+		natsPub(t, nc, "orders.customer1", []byte("hello"))
+		natsPub(t, nc, "orders.customer1.item", []byte("hello"))
+	}
+	natsFlush(t, nc)
+	for range 2 {
+		natsNexMsg(t, sub, time.Second)
+	}
+	// The publish to orders.customer1.item is denied by the auth service.
+	for range 2 {
+		select {
+		case err := <-errCh:
+			require_Contains(t, err.Error(), `Publish to "orders.customer1.item"`)
+		case <-time.After(time.Second):
+			t.Fatal("Expected a permissions violation")
+		}
+	}
+	_, err := wsub.NextMsg(100 * time.Millisecond)
+	require_Error(t, err, nats.ErrTimeout)
+
+	// Explicitly denied subjects are not sent to the auth service.
+	natsSubSync(t, nc, "secret.x")
+	natsFlush(t, nc)
+	select {
+	case err := <-errCh:
+		require_Contains(t, err.Error(), `Subscription to "secret.x"`)
+	case <-time.After(time.Second):
+		t.Fatal("Expected a permissions violation")
+	}
+
+	// Forged responses and failed requests are denials, the latter are
+	// cached for a while.
+	for _, subj := range []string{"orders.forged", "orders.replayed", "orders.slow", "orders.slow"} {
+		natsPub(t, nc, subj, []byte("hello"))
+		natsFlush(t, nc)
+		select {
+		case err := <-errCh:
+			require_Contains(t, err.Error(), fmt.Sprintf("Publish to %q", subj))
+		case <-time.After(time.Second):
+			t.Fatal("Expected a permissions violation")
+		}
+	}
+	require_Equal(t, numRequests(true, "orders.slow"), 1)
+
+	// Decisions are requested once per connection.
+	require_Equal(t, numRequests(false, "orders.customer1"), 1)
+	require_Equal(t, numRequests(false, "orders.customer1.>"), 1)
+	require_Equal(t, numRequests(true, "orders.customer1"), 1)
+	require_Equal(t, numRequests(true, "orders.customer1.item"), 1)
+	require_Equal(t, numRequests(false, "secret.x"), 0)
+	require_Equal(t, numRequests(true, "foo"), 0)
+}
+
+func TestAuthCalloutSubjectAuthorizationEncrypted(t *testing.T) {
+	s := runAuthCalloutCacheServer(t, &AuthCallout{AuthorizeSubjects: true, XKey: curvePublic})
+	defer s.Shutdown()
+
+	rkp, err := nkeys.FromCurveSeed([]byte(curveSeed))
+	require_NoError(t, err)
+	aa, err := nkeys.FromSeed([]byte(authCalloutIssuerSeed))
+	require_NoError(t, err)
+
+	handler := func(m *nats.Msg) {
+		decrypted, err := rkp.Open(m.Data, m.Header.Get(AuthRequestXKeyHeader))
+		require_NoError(t, err)
+		user, si, _, _, _ := decodeAuthRequest(t, decrypted)
+		var j jwt.UserPermissionLimits
+		j.Pub.Allow.Add("foo")
+		j.Sub.Allow.Add("foo")
+		ujwt := createAuthUser(t, user, _EMPTY_, globalAccountName, _EMPTY_, nil, 10*time.Minute, &j)
+		data, err := rkp.Seal(serviceResponse(t, user, si.ID, ujwt, _EMPTY_, 0), si.XKey)
+		require_NoError(t, err)
+		m.Respond(data)
+	}
+	subjHandler := func(m *nats.Msg) {
+		// The request is encrypted.
+		var req AuthCalloutSubjectRequest
+		require_Error(t, json.Unmarshal(m.Data, &req))
+		xkey := m.Header.Get(AuthRequestXKeyHeader)
+		decrypted, err := rkp.Open(m.Data, xkey)
+		require_NoError(t, err)
+		require_NoError(t, json.Unmarshal(decrypted, &req))
+		gc := jwt.NewGenericClaims(req.UserNkey)
+		gc.Audience = req.ServerID
+		gc.Data["allowed"] = req.Subject == "orders.customer1"
+		token, err := gc.Encode(aa)
+		require_NoError(t, err)
+		data, err := rkp.Seal([]byte(token), xkey)
+		require_NoError(t, err)
+		m.Respond(data)
+	}
+	ac := natsConnect(t, s.ClientURL(), nats.UserInfo("auth", "pwd"))
+	defer ac.Close()
+	natsSub(t, ac, AuthCalloutSubject, handler)
+	natsSub(t, ac, AuthCalloutSubjectsSubject, subjHandler)
+	natsFlush(t, ac)
+
+	errCh := make(chan error, 10)
+	nc := natsConnect(t, s.ClientURL(), nats.UserInfo("dlc", "zzz"),
+		nats.ErrorHandler(func(_ *nats.Conn, _ *nats.Subscription, err error) { errCh <- err }))
+	defer nc.Close()
+
+	sub := natsSubSync(t, nc, "orders.customer1")
+	natsPub(t, nc, "orders.customer1", []byte("hello"))
+	natsNexMsg(t, sub, time.Second)
+
+	natsPub(t, nc, "orders.customer2", []byte("hello"))
+	natsFlush(t, nc)
+	select {
+	case err := <-errCh:
+		require_Contains(t, err.Error(), `Publish to "orders.customer2"`)
+	case <-time.After(time.Second):
+		t.Fatal("Expected a permissions violation")
+	}
+}
+
+func TestAuthCalloutSubjectAuthorizationOperatorModeWarning(t *testing.T) {
+	opub, err := oKp.PublicKey()
+	require_NoError(t, err)
+	o := DefaultOptions()
+	o.TrustedKeys = []string{opub}
+	o.AuthCallout = &AuthCallout{AuthorizeSubjects: true}
+	s, err := NewServer(o)
+	require_NoError(t, err)
+	l := &captureWarnLogger{warn: make(chan string, 10)}
+	s.SetLogger(l, false, false)
+	s.Start()
+	defer s.Shutdown()
+
+	for {
+		select {
+		case w := <-l.warn:
+			if strings.Contains(w, "not supported in operator mode") {
+				return
+			}
+		case <-time.After(time.Second):
+			t.Fatal("Expected a warning for subject authorization in operator mode")
+		}
+	}
+}
@@ -137,6 +137,13 @@ const (
 	emptyHdrLine = "NATS/1.0\r\n\r\n"
 )
 
+// Status sent to requestors when the policies of a service export apply.
+const (
+	serviceCircuitOpenHdr = "NATS/1.0 503 Circuit Open\r\n\r\n"
+	serviceMaxInflightHdr = "NATS/1.0 503 Max In-Flight Exceeded\r\n\r\n"
+	serviceTimeoutHdr     = "NATS/1.0 504 Service Timeout\r\n\r\n"
+)
+
 // Some client state represented as flags
 const (
 	connectReceived        clientFlag = 1 << iota // The CONNECT proto has been received
@@ -226,6 +233,8 @@ const (
 	Kicked
 	ProxyNotTrusted
 	ProxyRequired
+	RateLimitExceeded
+	Drained
 )
 
 // Some flags passed to processMsgResults
@@ -252,6 +261,7 @@ type client struct {
 	mpay       int32
 	msubs      int32
 	mcl        int32
+	rlim       atomic.Pointer[rateLimiter]
 	mu         sync.Mutex
 	cid        uint64
 	start      time.Time
@@ -267,10 +277,14 @@ type client struct {
 	port       uint16
 	subs       map[string]*subscription
 	replies    map[string]*resp
+	outreqs    map[string]*outstandingRequest
+	outreqq    []*outstandingRequest // outstanding requests in delivery order
+	outexp     atomic.Int64          // expiration of the oldest outstanding request
 	mperms     *msgDeny
 	darray     []string
 	pcd        map[*client]struct{}
 	atmr       *time.Timer
+	dtmr       *time.Timer // closes the connection at the end of a drain
 	expires    time.Time
 	ping       pinfo
 	msgb       [msgScratchSize]byte
@@ -281,11 +295,20 @@ type client struct {
 	repliesSincePrune uint16
 	lastReplyPrune    time.Time
 
+	// Account of the auth callout requests for subjects not allowed by the
+	// permissions, and the decisions of the service.
+	authSubjAcc *Account
+	authSubjs   map[string]authSubjectDecision
+
 	headers bool
 
 	rtt      time.Duration
 	rttStart time.Time
 
+	// Compression mode of a client connection, the one offered in the INFO
+	// protocol until negotiated on CONNECT.
+	compression string
+
 	route *route
 	gw    *gateway
 	leaf  *leaf
@@ -338,6 +361,11 @@ type outbound struct {
 	lft time.Duration // Last flush time for Write.
 	stc chan struct{} // Stall chan we create to slow down producers on overrun, e.g. fan-in.
 	cw  *s2.Writer
+	// Messages in "nb" that expire, the number of bytes in "nb" and the
+	// earliest expiration.
+	exp  []outExpiry
+	nbs  int64
+	nexp int64
 }
 
 const nbMaxVectorSize = 1024 // == IOV_MAX on Linux/Darwin and most other Unices (except Solaris/AIX)
@@ -414,6 +442,7 @@ type permissions struct {
 	sub    perm
 	pub    perm
 	resp   *ResponsePermission
+	queue  *SubjectPermission
 	pcache sync.Map
 }
 
@@ -490,6 +519,10 @@ type readCache struct {
 
 	// Total time stalled so far for readLoop processing.
 	tst time.Duration
+
+	// Compressed bytes read along with the CONNECT protocol of a client
+	// that negotiated compression.
+	cpre []byte
 }
 
 // set the flag (would be equivalent to set the boolean to true)
@@ -625,6 +658,7 @@ type subscription struct {
 	max     int64
 	qw      int32
 	closed  int32
+	out     int32 // outstanding requests for the least outstanding queue policy
 	mqtt    *mqttSub
 }
 
@@ -669,6 +703,16 @@ type ClientOpts struct {
 
 	// Proxy would include its own nonce signature.
 	ProxySig string `json:"proxy_sig,omitempty"`
+
+	// Compression mode requested by the client.
+	Compression string `json:"compression,omitempty"`
+
+	// Maximum time messages are kept pending for this client, after
+	// which they are discarded instead of being delivered.
+	MaxMsgAge time.Duration `json:"max_msg_age,omitempty"`
+
+	// Subscriptions first receive the last value cache of the account.
+	LastValues bool `json:"last_values,omitempty"`
 }
 
 var defaultOpts = ClientOpts{Verbose: true, Pedantic: true, Echo: true}
@@ -953,6 +997,7 @@ func (c *client) RegisterUser(user *User) {
 	} else {
 		c.setPermissions(user.Permissions)
 	}
+	c.rlim.Store(newRateLimiter(user.RateLimits))
 
 	// allows custom authenticators to set a username to be reported in
 	// server events and more
@@ -990,6 +1035,7 @@ func (c *client) RegisterNkeyUser(user *NkeyUser) error {
 	} else {
 		c.setPermissions(user.Permissions)
 	}
+	c.rlim.Store(newRateLimiter(user.RateLimits))
 	c.mu.Unlock()
 	return nil
 }
@@ -1070,6 +1116,11 @@ func (c *client) setPermissions(perms *Permissions) {
 		}
 	}
 
+	// Queue groups that can be joined.
+	if perms.QueueGroups != nil {
+		c.perms.queue = perms.QueueGroups.clone()
+	}
+
 	// If we are a leafnode and we are the hub copy the extracted perms
 	// to resend back to soliciting server. These are reversed from the
 	// way routes interpret them since this is how the soliciting server
@@ -1131,6 +1182,10 @@ func (c *client) publicPermissions() *Permissions {
 		rp := *c.perms.resp
 		perms.Response = &rp
 	}
+	// Queue groups.
+	if c.perms.queue != nil {
+		perms.QueueGroups = c.perms.queue.clone()
+	}
 
 	return perms
 }
@@ -1337,7 +1392,7 @@ func (c *client) readLoop(pre []byte) {
 	if ws {
 		masking = c.ws.maskread
 	}
-	checkCompress := c.kind == ROUTER || c.kind == LEAF
+	checkCompress := c.kind == ROUTER || c.kind == LEAF || (c.kind == CLIENT && !ws)
 	c.mu.Unlock()
 
 	defer func() {
@@ -1457,7 +1512,12 @@ func (c *client) readLoop(pre []byte) {
 		if checkCompress && c.in.flags.isSet(switchToCompression) {
 			c.in.flags.clear(switchToCompression)
 			// For now we support only s2 compression...
-			reader = s2.NewReader(nc)
+			if cpre := c.in.cpre; len(cpre) > 0 {
+				c.in.cpre = nil
+				reader = s2.NewReader(io.MultiReader(bytes.NewReader(cpre), nc))
+			} else {
+				reader = s2.NewReader(nc)
+			}
 			decompress = true
 		}
 
@@ -1598,6 +1658,13 @@ func (c *client) flushOutbound() bool {
 		return true // true because no need to queue a signal.
 	}
 
+	// Discard the messages that expired while pending.
+	if len(c.out.exp) > 0 {
+		if c.discardExpiredMsgs(time.Now().UnixNano()); c.out.pb == 0 {
+			return true
+		}
+	}
+
 	// In the case of a normal socket connection, "collapsed" is just a ref
 	// to "nb". In the case of WebSockets, additional framing is added to
 	// anything that is waiting in "nb". Also keep a note of how many bytes
@@ -1615,6 +1682,7 @@ func (c *client) flushOutbound() bool {
 	// "nb" will be set to nil so that we can manipulate "collapsed" outside
 	// of the client's lock, which is interesting in case of compression.
 	c.out.nb = nil
+	c.resetExpiringMsgs()
 
 	// In case it goes away after releasing the lock.
 	nc := c.nc
@@ -2160,6 +2228,14 @@ func (c *client) processConnect(arg []byte) error {
 	// server now knows which protocol this client supports.
 	firstConnect := !c.flags.isSet(connectReceived)
 	c.flags.set(connectReceived)
+	// Anything sent after the CONNECT protocol is compressed if negotiated,
+	// so this needs to be done before replying to the client.
+	if firstConnect && kind == CLIENT && c.compression != _EMPTY_ {
+		if err := c.negotiateClientCompression(); err != nil {
+			c.mu.Unlock()
+			return err
+		}
+	}
 	// Capture these under lock
 	c.echo = c.opts.Echo
 	proto := c.opts.Protocol
@@ -2409,6 +2485,7 @@ func (c *client) queueOutbound(data []byte) {
 
 	// Add to pending bytes total.
 	c.out.pb += int64(len(data))
+	c.out.nbs += int64(len(data))
 
 	// Take a copy of the slice ref so that we can chop bits off the beginning
 	// without affecting the original "data" slice.
@@ -2658,6 +2735,8 @@ func (c *client) processPong() {
 			co = &srv.getOpts().LeafNode.Compression
 		}
 		c.updateS2AutoCompressionLevel(co, &c.leaf.compression)
+	} else if c.kind == CLIENT && needsCompression(c.compression) {
+		c.updateS2AutoCompressionLevel(&srv.getOpts().Compression, &c.compression)
 	}
 	c.mu.Unlock()
 	if reorderGWs {
@@ -2670,6 +2749,36 @@ func (c *client) processPong() {
 	}
 }
 
+// Negotiates the compression with a client, based on the compression mode
+// offered in the INFO protocol and the one requested in the CONNECT protocol.
+// The client makes the same selection, and both sides switch to compression
+// right after the CONNECT protocol.
+// Lock held on entry.
+func (c *client) negotiateClientCompression() error {
+	cm, err := selectCompressionMode(c.compression, c.opts.Compression)
+	if err != nil {
+		c.compression = CompressionNotSupported
+		return err
+	}
+	// For "auto" mode, set the initial compression mode based on RTT.
+	if cm == CompressionS2Auto {
+		rtts := defaultCompressionS2AutoRTTThresholds
+		if co := &c.srv.getOpts().Compression; co.Mode == CompressionS2Auto {
+			rtts = co.RTTThresholds
+		}
+		cm = selectS2AutoModeBasedOnRTT(c.rtt, rtts)
+	}
+	c.compression = cm
+	if !needsCompression(cm) {
+		return nil
+	}
+	// Notify the readLoop that it should switch to a decompression reader.
+	c.in.flags.set(switchToCompression)
+	c.out.cw = s2.NewWriter(nil, s2WriterOptions(cm)...)
+	c.Debugf("Client compression=%v", cm)
+	return nil
+}
+
 // Select the s2 compression level based on the client's current RTT and the configured
 // RTT thresholds slice. If current level is different than selected one, save the
 // new compression level string and create a new s2 writer.
@@ -2856,6 +2965,7 @@ func (c *client) parseSub(argo []byte, noForward bool) error {
 		subject []byte
 		queue   []byte
 		sid     []byte
+		weight  int
 	)
 	switch len(args) {
 	case 2:
@@ -2866,22 +2976,34 @@ func (c *client) parseSub(argo []byte, noForward bool) error {
 		subject = args[0]
 		queue = args[1]
 		sid = args[2]
+	case 4:
+		// Queue subscription with a weight for the weighted queue policy.
+		subject = args[0]
+		queue = args[1]
+		sid = args[2]
+		if weight = parseSize(args[3]); weight < 1 || weight > maxQueueWeight {
+			return fmt.Errorf("processSub Bad Queue Weight: %q", args[3])
+		}
 	default:
 		return fmt.Errorf("processSub Parse Error: %q", arg)
 	}
 	// If there was an error, it has been sent to the client. We don't return an
 	// error here to not close the connection as a parsing error.
-	c.processSub(subject, queue, sid, nil, noForward)
+	if c.opts.LastValues {
+		c.processSubWithLastValues(subject, queue, sid, int32(weight), noForward)
+		return nil
+	}
+	c.processSubEx(subject, queue, sid, int32(weight), nil, noForward, false, false)
 	return nil
 }
 
 func (c *client) processSub(subject, queue, bsid []byte, cb msgHandler, noForward bool) (*subscription, error) {
-	return c.processSubEx(subject, queue, bsid, cb, noForward, false, false)
+	return c.processSubEx(subject, queue, bsid, 0, cb, noForward, false, false)
 }
 
-func (c *client) processSubEx(subject, queue, bsid []byte, cb msgHandler, noForward, si, rsi bool) (*subscription, error) {
+func (c *client) processSubEx(subject, queue, bsid []byte, qw int32, cb msgHandler, noForward, si, rsi bool) (*subscription, error) {
 	// Create the subscription
-	sub := &subscription{client: c, subject: subject, queue: queue, sid: bsid, icb: cb, si: si, rsi: rsi}
+	sub := &subscription{client: c, subject: subject, queue: queue, sid: bsid, qw: qw, icb: cb, si: si, rsi: rsi}
 
 	c.mu.Lock()
 
@@ -2911,13 +3033,23 @@ func (c *client) processSubEx(subject, queue, bsid []byte, cb msgHandler, noForw
 		// allow = ["foo v1"]         -> can only queue subscribe to 'foo v1', no plain subs allowed.
 		// allow = ["foo", "foo v1"]  -> can subscribe to 'foo' but can only queue subscribe to 'foo v1'
 		//
+		var allowed bool
 		if sub.queue != nil {
-			if !c.canSubscribe(string(sub.subject), string(sub.queue)) || string(sub.queue) == sysGroup {
+			allowed = c.canSubscribe(string(sub.subject), string(sub.queue)) && string(sub.queue) != sysGroup
+		} else {
+			allowed = c.canSubscribe(string(sub.subject))
+		}
+		// The auth callout service may allow it.
+		if !allowed && c.authSubjAcc != nil {
+			c.mu.Unlock()
+			allowed = c.checkAuthSubject(false, string(sub.subject), string(sub.queue))
+			c.mu.Lock()
+			if allowed && (c.isClosed() || c.subs == nil) {
 				c.mu.Unlock()
-				c.subPermissionViolation(sub)
-				return nil, ErrSubscribePermissionViolation
+				return nil, ErrConnectionClosed
 			}
-		} else if !c.canSubscribe(string(sub.subject)) {
+		}
+		if !allowed {
 			c.mu.Unlock()
 			c.subPermissionViolation(sub)
 			return nil, ErrSubscribePermissionViolation
@@ -2985,13 +3117,13 @@ func (c *client) processSubEx(subject, queue, bsid []byte, cb msgHandler, noForw
 
 	// If we are routing and this is a local sub, add to the route map for the associated account.
 	if kind == CLIENT || kind == SYSTEM || kind == JETSTREAM || kind == ACCOUNT {
-		srv.updateRouteSubscriptionMap(acc, sub, 1)
+		srv.updateRouteSubscriptionMap(acc, sub, sub.weight())
 		if updateGWs {
-			srv.gatewayUpdateSubInterest(acc.Name, sub, 1)
+			srv.gatewayUpdateSubInterest(acc.Name, sub, sub.weight())
 		}
 	}
 	// Now check on leafnode updates.
-	acc.updateLeafNodes(sub, 1)
+	acc.updateLeafNodes(sub, sub.weight())
 	return sub, nil
 }
 
@@ -3237,9 +3369,25 @@ func (c *client) canSubscribe(subject string, optQueue ...string) bool {
 			}
 		}
 	}
+	// Queue group permissions apply to queue subscriptions on any subject.
+	if allowed && queue != _EMPTY_ && c.perms.queue != nil {
+		qp := c.perms.queue
+		allowed = (qp.Allow == nil || queueNameMatches(queue, qp.Allow)) && !queueNameMatches(queue, qp.Deny)
+	}
 	return allowed
 }
 
+// Returns true if the queue group name matches any of the names,
+// which can contain wildcards.
+func queueNameMatches(queue string, names []string) bool {
+	for _, qname := range names {
+		if queue == qname || (subjectHasWildcard(qname) && subjectIsSubsetMatch(queue, qname)) {
+			return true
+		}
+	}
+	return false
+}
+
 func queueMatches(queue string, qsubs [][]*subscription) bool {
 	if len(qsubs) == 0 {
 		return true
@@ -3364,13 +3512,13 @@ func (c *client) processUnsub(arg []byte) error {
 	if unsub {
 		c.unsubscribe(acc, sub, false, true)
 		if acc != nil && (kind == CLIENT || kind == SYSTEM || kind == ACCOUNT || kind == JETSTREAM) {
-			srv.updateRouteSubscriptionMap(acc, sub, -1)
+			srv.updateRouteSubscriptionMap(acc, sub, -sub.weight())
 			if updateGWs {
-				srv.gatewayUpdateSubInterest(acc.Name, sub, -1)
+				srv.gatewayUpdateSubInterest(acc.Name, sub, -sub.weight())
 			}
 		}
 		// Now check on leafnode updates.
-		acc.updateLeafNodes(sub, -1)
+		acc.updateLeafNodes(sub, -sub.weight())
 	}
 
 	return nil
@@ -3601,6 +3749,12 @@ func (c *client) deliverMsg(prodIsMQTT bool, sub *subscription, acc *Account, su
 		return false
 	}
 
+	// Check rate limits of the receiving user and account.
+	if client.kind == CLIENT && sub.icb == nil && !client.checkOutboundRateLimits(len(msg)) {
+		client.mu.Unlock()
+		return false
+	}
+
 	// Check if we are a leafnode and have perms to check.
 	if client.kind == LEAF && client.perms != nil {
 		var subjectToCheck []byte
@@ -3680,7 +3834,7 @@ func (c *client) deliverMsg(prodIsMQTT bool, sub *subscription, acc *Account, su
 				// Due to defer, reverse the code order so that execution
 				// is consistent with other cases where we unsubscribe.
 				if shouldForward {
-					defer srv.updateRemoteSubscription(client.acc, sub, -1)
+					defer srv.updateRemoteSubscription(client.acc, sub, -sub.weight())
 				}
 				defer client.unsubscribe(client.acc, sub, true, true)
 			} else if sub.nm > sub.max {
@@ -3689,7 +3843,7 @@ func (c *client) deliverMsg(prodIsMQTT bool, sub *subscription, acc *Account, su
 				client.mu.Unlock()
 				client.unsubscribe(client.acc, sub, true, true)
 				if shouldForward {
-					srv.updateRemoteSubscription(client.acc, sub, -1)
+					srv.updateRemoteSubscription(client.acc, sub, -sub.weight())
 				}
 				return false
 			}
@@ -3701,6 +3855,17 @@ func (c *client) deliverMsg(prodIsMQTT bool, sub *subscription, acc *Account, su
 	// The actual header would have been processed correctly for us, so just
 	// need to update payload.
 	hdrSize := c.pa.hdr
+
+	// Check if the message expires while pending, before headers are stripped.
+	var deadline int64
+	if client.kind == CLIENT && sub.icb == nil && !client.isMqtt() && (hdrSize > 0 || client.opts.MaxMsgAge > 0) {
+		var hdr []byte
+		if hdrSize > 0 {
+			hdr = msg[:hdrSize]
+		}
+		deadline = client.msgDeadline(hdr)
+	}
+
 	if c.pa.hdr > 0 && !sub.client.headers {
 		msg = msg[c.pa.hdr:]
 	}
@@ -3809,12 +3974,16 @@ func (c *client) deliverMsg(prodIsMQTT bool, sub *subscription, acc *Account, su
 	}
 
 	// Queue to outbound buffer
+	start := client.out.nbs
 	client.queueOutbound(mh)
 	client.queueOutbound(msg)
 	if prodIsMQTT {
 		// Need to add CR_LF since MQTT producers don't send CR_LF
 		client.queueOutbound([]byte(CR_LF))
 	}
+	if deadline > 0 {
+		client.trackExpiringMsg(start, deadline)
+	}
 
 	// If we are tracking dynamic publish permissions that track reply subjects,
 	// do that accounting here. We only look at client.replies which will be non-nil.
@@ -4095,9 +4264,10 @@ func (c *client) processInboundMsg(msg []byte) {
 	}
 }
 
-// selectMappedSubject will choose the mapped subject based on the client's inbound subject.
-func (c *client) selectMappedSubject() bool {
-	nsubj, changed := c.acc.selectMappedSubject(bytesToString(c.pa.subject))
+// selectMappedSubject will choose the mapped subject based on the client's inbound subject
+// and the message header, if any.
+func (c *client) selectMappedSubject(hdr []byte) bool {
+	nsubj, changed := c.acc.selectMappedSubject(bytesToString(c.pa.subject), hdr)
 	if changed {
 		c.pa.mapped = c.pa.subject
 		c.pa.subject = []byte(nsubj)
@@ -4136,8 +4306,16 @@ func (c *client) processInboundClientMsg(msg []byte) (bool, bool) {
 	// Check pub permissions
 	if c.perms != nil && (c.perms.pub.allow != nil || c.perms.pub.deny != nil) && !c.pubAllowedFullCheck(string(c.pa.subject), true, true) {
 		c.mu.Unlock()
-		c.pubPermissionViolation(c.pa.subject)
-		return false, true
+		// The auth callout service may allow it.
+		if c.authSubjAcc == nil || !c.checkAuthSubject(true, string(c.pa.subject), _EMPTY_) {
+			c.pubPermissionViolation(c.pa.subject)
+			return false, true
+		}
+		c.mu.Lock()
+	}
+	// Check if this responds to a request from a queue group with the least outstanding policy.
+	if c.outreqs != nil {
+		c.checkOutstandingRequest(c.pa.subject)
 	}
 	c.mu.Unlock()
 
@@ -4154,10 +4332,20 @@ func (c *client) processInboundClientMsg(msg []byte) (bool, bool) {
 		return false, true
 	}
 
+	// Check rate limits of the user and account.
+	if c.kind == CLIENT && c.acc != nil && !c.checkInboundRateLimits(c.acc, c.pa.size) {
+		return false, true
+	}
+
 	if c.opts.Verbose {
 		c.sendOK()
 	}
 
+	// Drop messages already published within the deduplication window of the account.
+	if c.kind == CLIENT && c.acc != nil && c.checkDuplicateMsg(c.acc, msg) {
+		return false, false
+	}
+
 	// If MQTT client, check for retain flag now that we have passed permissions check
 	if c.isMqtt() {
 		c.mqttHandlePubRetain()
@@ -4208,6 +4396,10 @@ func (c *client) processInboundClientMsg(msg []byte) (bool, bool) {
 		return true, false
 	}
 
+	// Cache the message before matching the subscriptions, so that a
+	// subscription created meanwhile receives it at least once.
+	c.cacheLastValue(acc, msg)
+
 	// Match the subscriptions. We will use our own L1 map if
 	// it's still valid, avoiding contention on the shared sublist.
 	var r *SublistResult
@@ -4323,8 +4515,12 @@ func (c *client) handleGWReplyMap(msg []byte) bool {
 }
 
 // Used to setup the response map for a service import request that has a reply subject.
-func (c *client) setupResponseServiceImport(acc *Account, si *serviceImport, tracking bool, header http.Header) *serviceImport {
-	rsi := si.acc.addRespServiceImport(acc, string(c.pa.reply), si, tracking, header)
+// Returns an error if the service export rejected the request.
+func (c *client) setupResponseServiceImport(acc *Account, si *serviceImport, tracking bool, header http.Header) (*serviceImport, error) {
+	rsi, err := si.acc.addRespServiceImport(acc, string(c.pa.reply), si, tracking, header)
+	if err != nil {
+		return nil, err
+	}
 	if si.latency != nil {
 		if c.rtt == 0 {
 			// We have a service import that we are tracking but have not established RTT.
@@ -4334,7 +4530,7 @@ func (c *client) setupResponseServiceImport(acc *Account, si *serviceImport, tra
 		rsi.rc = c
 		si.acc.mu.Unlock()
 	}
-	return rsi
+	return rsi, nil
 }
 
 // Will remove a header if present.
@@ -4564,8 +4760,17 @@ func (c *client) processServiceImport(si *serviceImport, acc *Account, msg []byt
 		// TODO(dlc) - Formalize as a service import option for reply rewrite.
 		// For now we can't do $JS.ACK since that breaks pull consumers across accounts.
 		if !bytes.HasPrefix(c.pa.reply, []byte(jsAckPre)) {
-			if rsi = c.setupResponseServiceImport(acc, si, tracking, headers); rsi != nil {
+			var err error
+			if rsi, err = c.setupResponseServiceImport(acc, si, tracking, headers); rsi != nil {
 				nrr = []byte(rsi.from)
+			} else if err != nil {
+				// The service export rejected the request, answer the requestor right away.
+				hdr := serviceMaxInflightHdr
+				if err == ErrServiceCircuitOpen {
+					hdr = serviceCircuitOpenHdr
+				}
+				c.srv.sendInternalAccountMsgWithReply(acc, string(c.pa.reply), _EMPTY_, []byte(hdr), nil, false)
+				return true
 			}
 		} else {
 			// This only happens when we do a pull subscriber that trampolines through another account.
@@ -4602,7 +4807,11 @@ func (c *client) processServiceImport(si *serviceImport, acc *Account, msg []byt
 	// Now check to see if this account has mappings that could affect the service import.
 	// Can't use non-locked trick like in processInboundClientMsg, so just call into selectMappedSubject
 	// so we only lock once.
-	nsubj, changed := siAcc.selectMappedSubject(to)
+	var hdr []byte
+	if c.pa.hdr > 0 && c.pa.hdr <= len(msg) {
+		hdr = msg[:c.pa.hdr]
+	}
+	nsubj, changed := siAcc.selectMappedSubject(to, hdr)
 	if changed {
 		c.pa.mapped = []byte(to)
 		to = nsubj
@@ -4754,6 +4963,10 @@ func (c *client) processServiceImport(si *serviceImport, acc *Account, msg []byt
 		reason := rsiOk
 		if !didDeliver {
 			reason = rsiNoDelivery
+			// The service did respond, but the requestor is gone.
+			if isResponse {
+				reason = rsiNoInterest
+			}
 		}
 		if isResponse {
 			acc.removeRespServiceImport(si, reason)
@@ -5101,8 +5314,17 @@ func (c *client) processMsgResults(acc *Account, r *SublistResult, msg, deliver,
 
 		sindex := 0
 		lqs := len(qsubs)
-		if lqs > 1 {
+		var qp *QueuePolicy
+		if acc != nil && lqs > 0 {
+			qp = acc.queuePolicy(bytesToString(subject), qsubs[0].queue)
+		}
+		if qp != nil {
+			sindex = c.selectQueueSub(qp, qsubs, subject, msg)
+		}
+		if lqs > 1 && (qp == nil || sindex < 0) {
 			sindex = int(fastrand.Uint32() % uint32(lqs))
+		} else if sindex < 0 {
+			sindex = 0
 		}
 
 		// Find a subscription that is able to deliver this message starting at a random index.
@@ -5221,6 +5443,12 @@ func (c *client) processMsgResults(acc *Account, r *SublistResult, msg, deliver,
 				if restorePaTrace {
 					c.pa.trace = mt
 				}
+				// Track the request until responded to for the least outstanding policy.
+				if delivered && qp != nil && qp.Type == QueueLeastOutstanding && len(creply) > 0 && sub.client.kind == CLIENT {
+					sub.client.mu.Lock()
+					sub.client.trackOutstandingRequest(sub, creply)
+					sub.client.mu.Unlock()
+				}
 			}
 			if skipDelivery || delivered {
 				// Update only if not skipped.
@@ -5597,6 +5825,7 @@ func (c *client) flushAndClose(minimalFlush bool) {
 		nbPoolPut(c.out.nb[i])
 	}
 	c.out.nb = nil
+	c.resetExpiringMsgs()
 	// We can't touch c.out.wnb when a flushOutbound is in progress since it
 	// is accessed outside the lock there. If in progress, the cleanup will be
 	// done in flushOutbound when detecting that connection is closed.
@@ -5695,8 +5924,13 @@ func (c *client) processSubsOnConfigReload(awcsti map[string]struct{}) {
 	for _, sub := range c.subs {
 		// Just checking to rebuild mperms under the lock, will collect removed though here.
 		// Only collect under subs array of canSubscribe and checkAcc true.
-		canSub := c.canSubscribe(string(sub.subject))
-		canQSub := sub.queue != nil && c.canSubscribe(string(sub.subject), string(sub.queue))
+		canSub := c.canSubscribe(string(sub.subject)) || c.authSubjectAllowed(string(sub.subject), _EMPTY_)
+		canQSub := sub.queue != nil && (c.canSubscribe(string(sub.subject), string(sub.queue)) ||
+			c.authSubjectAllowed(string(sub.subject), string(sub.queue)))
+		// Queue subscriptions also need to be in an allowed queue group.
+		if canSub && sub.queue != nil && c.perms != nil && c.perms.queue != nil {
+			canSub = canQSub
+		}
 
 		if !canSub && !canQSub {
 			removed = append(removed, sub)
@@ -5744,6 +5978,7 @@ func (c *client) closeConnection(reason ClosedState) {
 	c.flags.set(closeConnection)
 	c.clearAuthTimer()
 	c.clearPingTimer()
+	clearTimer(&c.dtmr)
 	c.clearTlsToTimer()
 	c.markConnAsClosed(reason)
 
@@ -5830,7 +6065,7 @@ func (c *client) closeConnection(reason ClosedState) {
 						// We handle queue subscribers special in case we
 						// have a bunch we can just send one update to the
 						// connected routes.
-						num := int32(1)
+						num := sub.weight()
 						if kind == LEAF {
 							num = sub.qw
 						}
@@ -5995,7 +6230,9 @@ func (c *client) getRTTValue() time.Duration {
 // If the c.pa.subject is found in the cache, the cached result
 // is returned, otherwse, we match the account's sublist and update
 // the cache. The cache is pruned if reaching a certain size.
-func (c *client) getAccAndResultFromCache() (*Account, *SublistResult) {
+// If msg is not nil, it is stored in the account's last value cache
+// before matching, as done for client messages.
+func (c *client) getAccAndResultFromCache(msg []byte) (*Account, *SublistResult) {
 	var (
 		acc *Account
 		pac *perAccountCache
@@ -6004,34 +6241,40 @@ func (c *client) getAccAndResultFromCache() (*Account, *SublistResult) {
 	)
 	// Check our cache.
 	if pac, ok = c.in.pacache[string(c.pa.pacache)]; ok {
-		// Check the genid to see if it's still valid.
-		// Since v2.10.0, the config reload of accounts has been fixed
-		// and an account's sublist pointer should not change, so no need to
-		// lock to access it.
-		sl := pac.acc.sl
+		acc = pac.acc
+	} else if c.kind == ROUTER && len(c.route.accName) > 0 {
+		if acc = c.acc; acc == nil {
+			return nil, nil
+		}
+	} else {
+		// Match correct account and sublist.
+		if acc, _ = c.srv.LookupAccount(bytesToString(c.pa.account)); acc == nil {
+			return nil, nil
+		}
+	}
+
+	// Cache the message before matching the subscriptions, so that a
+	// subscription created meanwhile receives it at least once.
+	if msg != nil {
+		c.cacheLastValue(acc, msg)
+	}
+
+	// Since v2.10.0, the config reload of accounts has been fixed
+	// and an account's sublist pointer should not change, so no need to
+	// lock to access it.
+	sl := acc.sl
 
+	if ok {
+		// Check the genid to see if it's still valid.
 		if genid := atomic.LoadUint64(&sl.genid); genid != pac.genid {
 			ok = false
 			c.in.pacache = make(map[string]*perAccountCache)
 		} else {
-			acc = pac.acc
 			r = pac.results
 		}
 	}
 
 	if !ok {
-		if c.kind == ROUTER && len(c.route.accName) > 0 {
-			if acc = c.acc; acc == nil {
-				return nil, nil
-			}
-		} else {
-			// Match correct account and sublist.
-			if acc, _ = c.srv.LookupAccount(bytesToString(c.pa.account)); acc == nil {
-				return nil, nil
-			}
-		}
-		sl := acc.sl
-
 		// Match against the account sublist.
 		r = sl.MatchBytes(c.pa.subject)
 
@@ -27,12 +27,14 @@ import (
 	"reflect"
 	"regexp"
 	"runtime"
+	"strconv"
 	"strings"
 	"sync"
 	"sync/atomic"
 	"testing"
 	"time"
 
+	"github.com/klauspost/compress/s2"
 	"github.com/nats-io/jwt/v2"
 	"github.com/nats-io/nats.go"
 	"github.com/nats-io/nkeys"
@@ -807,6 +809,266 @@ func TestClientPubWithQueueSub(t *testing.T) {
 	}
 }
 
+func TestClientQueueSubWeighted(t *testing.T) {
+	s, c, cr := setupClient()
+	defer c.close()
+
+	require_NoError(t, s.GlobalAccount().AddQueuePolicy(&QueuePolicy{Subject: "foo", Type: QueueWeighted}))
+
+	num := 200
+	op := []byte("SUB foo g1 1 1\r\nSUB foo g1 2 9\r\n")
+	for i := 0; i < num; i++ {
+		op = append(op, "PUB foo 5\r\nhello\r\n"...)
+	}
+	go c.parseAndClose(op)
+
+	var n1, n2, received int
+	for ; ; received++ {
+		l, err := cr.ReadString('\n')
+		if err != nil {
+			break
+		}
+		matches := msgPat.FindAllStringSubmatch(l, -1)[0]
+		switch matches[SID_INDEX] {
+		case "1":
+			n1++
+		case "2":
+			n2++
+		}
+		if l, _ = cr.ReadString('\n'); l != "hello\r\n" {
+			t.Fatalf("Unexpected payload: %q", l)
+		}
+	}
+	require_Equal(t, received, num)
+	// Expecting 20 and 180, with a threshold for randomness.
+	if n1 < 5 || n1 > 50 || n2 < 150 {
+		t.Fatalf("Received wrong # of msgs per subscriber: %d - %d", n1, n2)
+	}
+}
+
+func TestClientQueueSubWeightParse(t *testing.T) {
+	_, c, _ := setupClient()
+	defer c.close()
+
+	require_NoError(t, c.parse([]byte("SUB foo g1 1 5\r\n")))
+	c.mu.Lock()
+	sub := c.subs["1"]
+	c.mu.Unlock()
+	require_Equal(t, sub.qw, 5)
+	require_Equal(t, sub.weight(), 5)
+
+	for _, w := range []string{"0", "-1", "x", "1001"} {
+		require_Error(t, c.parse([]byte(fmt.Sprintf("SUB foo g1 2 %s\r\n", w))))
+	}
+}
+
+func TestClientQueueSubConsistentHash(t *testing.T) {
+	s := RunServer(DefaultOptions())
+	defer s.Shutdown()
+
+	gacc := s.GlobalAccount()
+	require_NoError(t, gacc.AddQueuePolicy(&QueuePolicy{Subject: "hdr.>", Type: QueueConsistentHash, HashHeader: "Key"}))
+	require_NoError(t, gacc.AddQueuePolicy(&QueuePolicy{Subject: "tok.*.*", Type: QueueConsistentHash, HashToken: 2}))
+
+	nc := natsConnect(t, s.ClientURL())
+	defer nc.Close()
+
+	var subs []*nats.Subscription
+	for range 4 {
+		sub, err := nc.QueueSubscribeSync(">", "workers")
+		require_NoError(t, err)
+		subs = append(subs, sub)
+	}
+	natsFlush(t, nc)
+
+	// Returns the index of the member that received each message.
+	receivers := func(num int) []int {
+		t.Helper()
+		got := make([]int, 0, num)
+		checkFor(t, 2*time.Second, 10*time.Millisecond, func() error {
+			for i, sub := range subs {
+				for {
+					if _, err := sub.NextMsg(0); err != nil {
+						break
+					}
+					got = append(got, i)
+				}
+			}
+			if len(got) != num {
+				return fmt.Errorf("Expected %d messages, got %d", num, len(got))
+			}
+			return nil
+		})
+		return got
+	}
+
+	// Messages with the same key go to the same member.
+	for key := range 10 {
+		for i := range 20 {
+			m := nats.NewMsg(fmt.Sprintf("hdr.%d", i))
+			m.Header.Set("Key", fmt.Sprintf("key-%d", key))
+			require_NoError(t, nc.PublishMsg(m))
+		}
+		natsFlush(t, nc)
+		got := receivers(20)
+		for _, r := range got {
+			require_Equal(t, r, got[0])
+		}
+	}
+	for key := range 10 {
+		for i := range 20 {
+			natsPub(t, nc, fmt.Sprintf("tok.%d.%d", key, i), []byte("msg"))
+		}
+		natsFlush(t, nc)
+		got := receivers(20)
+		for _, r := range got {
+			require_Equal(t, r, got[0])
+		}
+	}
+
+	// Keys are spread across members.
+	used := map[int]struct{}{}
+	for key := range 100 {
+		natsPub(t, nc, fmt.Sprintf("tok.%d.x", key), []byte("msg"))
+	}
+	natsFlush(t, nc)
+	for _, r := range receivers(100) {
+		used[r] = struct{}{}
+	}
+	require_Len(t, len(used), 4)
+
+	// Messages without the header are delivered at random.
+	for i := range 100 {
+		natsPub(t, nc, fmt.Sprintf("hdr.%d", i), []byte("msg"))
+	}
+	natsFlush(t, nc)
+	require_Len(t, len(receivers(100)), 100)
+}
+
+func TestClientQueueSubLeastOutstanding(t *testing.T) {
+	s := RunServer(DefaultOptions())
+	defer s.Shutdown()
+
+	require_NoError(t, s.GlobalAccount().AddQueuePolicy(&QueuePolicy{Subject: "rpc", Queue: "workers", Type: QueueLeastOutstanding}))
+
+	nc1 := natsConnect(t, s.ClientURL())
+	defer nc1.Close()
+	sub1 := natsQueueSubSync(t, nc1, "rpc", "workers")
+	natsFlush(t, nc1)
+	nc2 := natsConnect(t, s.ClientURL())
+	defer nc2.Close()
+	sub2 := natsQueueSubSync(t, nc2, "rpc", "workers")
+	natsFlush(t, nc2)
+
+	nc := natsConnect(t, s.ClientURL())
+	defer nc.Close()
+	request := func(num int) {
+		t.Helper()
+		for i := range num {
+			require_NoError(t, nc.PublishRequest("rpc", fmt.Sprintf("reply.%d", i), []byte("req")))
+		}
+		natsFlush(t, nc)
+	}
+	pending := func(sub *nats.Subscription, expected int) []*nats.Msg {
+		t.Helper()
+		var msgs []*nats.Msg
+		checkFor(t, 2*time.Second, 10*time.Millisecond, func() error {
+			for {
+				m, err := sub.NextMsg(0)
+				if err != nil {
+					break
+				}
+				msgs = append(msgs, m)
+			}
+			if len(msgs) != expected {
+				return fmt.Errorf("Expected %d requests, got %d", expected, len(msgs))
+			}
+			return nil
+		})
+		return msgs
+	}
+
+	// Without responses, requests alternate between members.
+	request(10)
+	reqs := pending(sub1, 5)
+	pending(sub2, 5)
+
+	// Once the first member responded, it gets all new requests.
+	for _, m := range reqs {
+		require_NoError(t, m.Respond([]byte("ok")))
+	}
+	natsFlush(t, nc1)
+	request(5)
+	pending(sub1, 5)
+	pending(sub2, 0)
+}
+
+func TestClientQueueSubLeastOutstandingExpiration(t *testing.T) {
+	defer func(exp time.Duration) { queueOutstandingExpiration = exp }(queueOutstandingExpiration)
+	queueOutstandingExpiration = 500 * time.Millisecond
+
+	s := RunServer(DefaultOptions())
+	defer s.Shutdown()
+
+	require_NoError(t, s.GlobalAccount().AddQueuePolicy(&QueuePolicy{Subject: "rpc", Queue: "workers", Type: QueueLeastOutstanding}))
+
+	nc1 := natsConnect(t, s.ClientURL())
+	defer nc1.Close()
+	sub1 := natsQueueSubSync(t, nc1, "rpc", "workers")
+	natsFlush(t, nc1)
+	nc2 := natsConnect(t, s.ClientURL())
+	defer nc2.Close()
+	sub2 := natsQueueSubSync(t, nc2, "rpc", "workers")
+	natsFlush(t, nc2)
+
+	nc := natsConnect(t, s.ClientURL())
+	defer nc.Close()
+	request := func(num int) {
+		t.Helper()
+		for i := range num {
+			require_NoError(t, nc.PublishRequest("rpc", fmt.Sprintf("reply.%d", i), []byte("req")))
+		}
+		natsFlush(t, nc)
+	}
+	// Responds to the requests received by the first member.
+	pending := func(sub *nats.Subscription, expected int) {
+		t.Helper()
+		var n int
+		checkFor(t, 2*time.Second, 10*time.Millisecond, func() error {
+			for {
+				m, err := sub.NextMsg(0)
+				if err != nil {
+					break
+				}
+				if sub == sub1 {
+					require_NoError(t, m.Respond([]byte("ok")))
+				}
+				n++
+			}
+			if n != expected {
+				return fmt.Errorf("Expected %d requests, got %d", expected, n)
+			}
+			return nil
+		})
+		natsFlush(t, nc1)
+	}
+
+	request(10)
+	pending(sub1, 5)
+	pending(sub2, 5)
+
+	// The second member does not respond, so is not selected.
+	request(5)
+	pending(sub1, 5)
+	pending(sub2, 0)
+
+	// Once expired, its requests no longer count.
+	time.Sleep(queueOutstandingExpiration + 100*time.Millisecond)
+	request(10)
+	pending(sub1, 5)
+	pending(sub2, 5)
+}
+
 func TestSplitSubjectQueue(t *testing.T) {
 	cases := []struct {
 		name        string
@@ -1039,6 +1301,44 @@ func TestQueueSubscribePermissions(t *testing.T) {
 	}
 }
 
+func TestQueueGroupPermissions(t *testing.T) {
+	cases := []struct {
+		name    string
+		perms   *Permissions
+		subject string
+		queue   string
+		ok      bool
+	}{
+		{"plain subscription not affected", &Permissions{QueueGroups: &SubjectPermission{Allow: []string{"svc"}}}, "foo", "", true},
+		{"allowed group", &Permissions{QueueGroups: &SubjectPermission{Allow: []string{"svc"}}}, "foo", "svc", true},
+		{"group not in allow list", &Permissions{QueueGroups: &SubjectPermission{Allow: []string{"svc"}}}, "foo", "other", false},
+		{"wildcard allowed group", &Permissions{QueueGroups: &SubjectPermission{Allow: []string{"dev.>"}}}, "foo", "dev.workers", true},
+		{"denied group", &Permissions{QueueGroups: &SubjectPermission{Deny: []string{"prod.*"}}}, "foo", "prod.workers", false},
+		{"group not in deny list", &Permissions{QueueGroups: &SubjectPermission{Deny: []string{"prod.*"}}}, "foo", "dev.workers", true},
+		{"deny overrules allow", &Permissions{QueueGroups: &SubjectPermission{Allow: []string{">"}, Deny: []string{"prod.>"}}}, "foo", "prod.workers", false},
+		{"subject not allowed", &Permissions{
+			Subscribe:   &SubjectPermission{Allow: []string{"bar"}},
+			QueueGroups: &SubjectPermission{Allow: []string{"svc"}},
+		}, "foo", "svc", false},
+		{"subject queue not allowed", &Permissions{
+			Subscribe:   &SubjectPermission{Allow: []string{"foo svc"}},
+			QueueGroups: &SubjectPermission{Allow: []string{"svc", "other"}},
+		}, "foo", "other", false},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			_, client, _ := setupClient()
+			defer client.close()
+
+			client.RegisterUser(&User{Permissions: c.perms})
+			client.mu.Lock()
+			ok := client.canSubscribe(c.subject, c.queue)
+			client.mu.Unlock()
+			require_Equal(t, ok, c.ok)
+		})
+	}
+}
+
 func TestClientPubWithQueueSubNoEcho(t *testing.T) {
 	opts := DefaultOptions()
 	s := RunServer(opts)
@@ -3587,3 +3887,228 @@ func TestLogConnectionAuthInfo(t *testing.T) {
 		}
 	})
 }
+
+func TestClientCompression(t *testing.T) {
+	for _, test := range []struct {
+		name       string
+		srvMode    string
+		cliMode    string
+		offered    string
+		compressed string
+	}{
+		{"server off", CompressionOff, CompressionS2Fast, _EMPTY_, _EMPTY_},
+		{"both accept", CompressionAccept, CompressionAccept, CompressionAccept, _EMPTY_},
+		{"client off", CompressionS2Fast, CompressionOff, CompressionS2Fast, _EMPTY_},
+		{"client not supported", CompressionS2Fast, _EMPTY_, CompressionS2Fast, _EMPTY_},
+		{"server accept", CompressionAccept, CompressionS2Best, CompressionAccept, CompressionS2Best},
+		{"server fast", CompressionS2Fast, CompressionAccept, CompressionS2Fast, CompressionS2Fast},
+		{"server auto", CompressionS2Auto, CompressionS2Fast, CompressionS2Auto, CompressionS2Uncompressed},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			o := DefaultOptions()
+			o.Compression.Mode = test.srvMode
+			o.Compression.RTTThresholds = []time.Duration{time.Second}
+			s := RunServer(o)
+			defer s.Shutdown()
+
+			conn, err := net.Dial("tcp", s.Addr().String())
+			require_NoError(t, err)
+			defer conn.Close()
+			conn.SetDeadline(time.Now().Add(5 * time.Second))
+
+			br := bufio.NewReader(conn)
+			line, err := br.ReadString('\n')
+			require_NoError(t, err)
+			var info Info
+			require_NoError(t, json.Unmarshal([]byte(strings.TrimPrefix(line, "INFO ")), &info))
+			require_Equal(t, info.Compression, test.offered)
+
+			// Send the CONNECT and, in the same write, the following protocols
+			// which are compressed if compression is negotiated.
+			connect := fmt.Sprintf("CONNECT {\"verbose\":false,\"protocol\":1,\"compression\":%q}\r\n", test.cliMode)
+			protos := "SUB foo 1\r\nPING\r\n"
+			var buf bytes.Buffer
+			buf.WriteString(connect)
+			var r io.Reader = br
+			if test.compressed != _EMPTY_ {
+				w := s2.NewWriter(&buf)
+				w.Write([]byte(protos))
+				require_NoError(t, w.Flush())
+				r = s2.NewReader(br)
+			} else {
+				buf.WriteString(protos)
+			}
+			_, err = conn.Write(buf.Bytes())
+			require_NoError(t, err)
+
+			cr := bufio.NewReader(r)
+			line, err = cr.ReadString('\n')
+			require_NoError(t, err)
+			require_Equal(t, line, "PONG\r\n")
+
+			nc := natsConnect(t, s.ClientURL())
+			defer nc.Close()
+			natsPub(t, nc, "foo", []byte("hello"))
+			natsFlush(t, nc)
+
+			// Skip the INFO protocol sent in response to the first PING.
+			line, err = cr.ReadString('\n')
+			require_NoError(t, err)
+			require_True(t, strings.HasPrefix(line, "INFO "))
+			line, err = cr.ReadString('\n')
+			require_NoError(t, err)
+			require_Equal(t, line, "MSG foo 1 5\r\n")
+			line, err = cr.ReadString('\n')
+			require_NoError(t, err)
+			require_Equal(t, line, "hello\r\n")
+
+			connz, err := s.Connz(&ConnzOptions{Sort: ByCid})
+			require_NoError(t, err)
+			require_Equal(t, connz.Conns[0].Compression, test.compressed)
+			require_Equal(t, connz.Conns[1].Compression, _EMPTY_)
+		})
+	}
+}
+
+func TestClientDiscardExpiredMsgs(t *testing.T) {
+	s := RunServer(DefaultOptions())
+	defer s.Shutdown()
+
+	cli, srv := net.Pipe()
+	defer cli.Close()
+	defer srv.Close()
+	c := &client{srv: s, kind: CLIENT, nc: srv, out: outbound{mp: math.MaxInt64}}
+	now := time.Now().UnixNano()
+	var expected []byte
+	// Messages of different sizes so that some span several buffers.
+	for i, size := range []int{100, 5000, 70000, 10, 600, 70000, 20} {
+		msg := bytes.Repeat([]byte{byte('a' + i)}, size)
+		start := c.out.nbs
+		c.queueOutbound(msg)
+		switch i % 3 {
+		case 0:
+			// Does not expire.
+			expected = append(expected, msg...)
+		case 1:
+			c.trackExpiringMsg(start, now)
+		case 2:
+			c.trackExpiringMsg(start, now+int64(time.Hour))
+			expected = append(expected, msg...)
+		}
+	}
+	pending := func() []byte {
+		var b []byte
+		for _, buf := range c.out.nb {
+			b = append(b, buf...)
+		}
+		return b
+	}
+
+	c.discardExpiredMsgs(now)
+	require_True(t, bytes.Equal(pending(), expected))
+	require_Equal(t, c.out.pb, int64(len(expected)))
+	require_Equal(t, c.out.nbs, int64(len(expected)))
+	require_Len(t, len(c.out.exp), 2)
+	require_Equal(t, c.expiredMsgs, 2)
+	require_Equal(t, s.expiredMsgs, 2)
+
+	// The offsets of the remaining messages were updated.
+	c.discardExpiredMsgs(now + int64(time.Hour))
+	expected = append(bytes.Repeat([]byte{'a'}, 100), bytes.Repeat([]byte{'d'}, 10)...)
+	expected = append(expected, bytes.Repeat([]byte{'g'}, 20)...)
+	require_True(t, bytes.Equal(pending(), expected))
+	require_Len(t, len(c.out.exp), 0)
+	require_Equal(t, c.expiredMsgs, 4)
+}
+
+func TestClientMsgExpiration(t *testing.T) {
+	s := RunServer(DefaultOptions())
+	defer s.Shutdown()
+
+	for _, test := range []struct {
+		name    string
+		connect string
+		hdr     string
+	}{
+		{"header duration", `{"headers":true,"verbose":false}`, "50ms"},
+		{"header timestamp", `{"headers":true,"verbose":false}`, time.Now().Add(50 * time.Millisecond).Format(time.RFC3339Nano)},
+		{"max msg age", `{"headers":true,"verbose":false,"max_msg_age":50000000}`, _EMPTY_},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			sub, cr, _ := newClientForServer(s)
+			defer sub.close()
+			sub.parseAsync("CONNECT " + test.connect + "\r\nSUB foo 1\r\nPING\r\n")
+			l, err := cr.ReadString('\n')
+			require_NoError(t, err)
+			require_Equal(t, l, "PONG\r\n")
+
+			nc := natsConnect(t, s.ClientURL())
+			defer nc.Close()
+			publish := func(data, expires string) {
+				t.Helper()
+				m := nats.NewMsg("foo")
+				if expires != _EMPTY_ {
+					m.Header.Set(MsgExpires, expires)
+				}
+				m.Data = []byte(data)
+				require_NoError(t, nc.PublishMsg(m))
+				natsFlush(t, nc)
+			}
+
+			// The subscriber does not read, so the first message is being
+			// written while the others are pending.
+			publish("1", _EMPTY_)
+			checkFor(t, time.Second, 10*time.Millisecond, func() error {
+				sub.mu.Lock()
+				defer sub.mu.Unlock()
+				if len(sub.out.nb) > 0 || sub.out.pb == 0 {
+					return fmt.Errorf("first message not being written")
+				}
+				return nil
+			})
+			publish("2", test.hdr)
+			publish("3", "1h")
+			time.Sleep(100 * time.Millisecond)
+
+			// With the maximum age, all pending messages expire.
+			expected := []string{"1"}
+			if test.hdr != _EMPTY_ {
+				expected = append(expected, "3")
+			}
+			var received []string
+			for len(received) < len(expected) {
+				l, err := cr.ReadString('\n')
+				require_NoError(t, err)
+				if !strings.HasPrefix(l, "MSG") && !strings.HasPrefix(l, "HMSG") {
+					continue
+				}
+				args := strings.Fields(l)
+				size, err := strconv.Atoi(args[len(args)-1])
+				require_NoError(t, err)
+				buf := make([]byte, size+2)
+				_, err = io.ReadFull(cr, buf)
+				require_NoError(t, err)
+				if i := bytes.Index(buf, []byte("\r\n\r\n")); i >= 0 {
+					buf = buf[i+4:]
+				}
+				received = append(received, string(bytes.TrimSuffix(buf, []byte("\r\n"))))
+			}
+			require_Equal(t, strings.Join(received, ","), strings.Join(expected, ","))
+			sub.parseAsync("PING\r\n")
+			l, err = cr.ReadString('\n')
+			require_NoError(t, err)
+			require_Equal(t, l, "PONG\r\n")
+
+			sub.mu.Lock()
+			expired := sub.expiredMsgs
+			sub.mu.Unlock()
+			require_True(t, expired >= 1)
+			connz, err := s.Connz(&ConnzOptions{CID: sub.cid})
+			require_NoError(t, err)
+			require_Equal(t, connz.Conns[0].ExpiredMsgs, expired)
+		})
+	}
+	varz, err := s.Varz(nil)
+	require_NoError(t, err)
+	require_True(t, varz.ExpiredMsgs >= 3)
+}
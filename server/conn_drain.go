@@ -0,0 +1,256 @@
+// Copyright 2025 The NATS Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"cmp"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DrainConnsOptions selects the client connections to drain. A connection
+// is selected if it matches all of the given filters, and at least one
+// filter is required.
+type DrainConnsOptions struct {
+	Account string   `json:"account,omitempty"`
+	User    string   `json:"user,omitempty"`
+	Name    string   `json:"name,omitempty"`
+	CIDs    []uint64 `json:"cids,omitempty"`
+	// Maximum number of connections to drain, all selected ones if 0.
+	Max int `json:"max,omitempty"`
+	// Time given to the clients to reconnect elsewhere before their
+	// connection is closed. Defaults to the lame duck grace period.
+	GracePeriod time.Duration `json:"grace_period,omitempty"`
+	// URLs sent to the clients to reconnect to. Defaults to the URLs
+	// of the other servers in the cluster.
+	ConnectURLs []string `json:"connect_urls,omitempty"`
+}
+
+// DrainConnsResponse lists the client connections that are being drained.
+type DrainConnsResponse struct {
+	ID          string             `json:"server_id"`
+	Now         time.Time          `json:"now"`
+	GracePeriod time.Duration      `json:"grace_period"`
+	Conns       []*DrainedConnInfo `json:"connections"`
+}
+
+// DrainedConnInfo identifies a client connection that is being drained.
+type DrainedConnInfo struct {
+	Cid            uint64 `json:"cid"`
+	Account        string `json:"account,omitempty"`
+	AuthorizedUser string `json:"authorized_user,omitempty"`
+	Name           string `json:"name,omitempty"`
+	// True if the client was notified to reconnect elsewhere, otherwise
+	// the connection is simply closed at the end of the grace period.
+	Notified bool `json:"notified"`
+}
+
+// DrainConnections asks the selected client connections to reconnect to
+// other servers, by sending them an INFO protocol with the lame duck mode
+// flag and the URLs to connect to, and closes them after a grace period.
+// Connections that are already draining are not selected again.
+func (s *Server) DrainConnections(opts *DrainConnsOptions) (*DrainConnsResponse, error) {
+	if s == nil {
+		return nil, ErrServerNotRunning
+	}
+	if opts == nil || (opts.Account == _EMPTY_ && opts.User == _EMPTY_ && opts.Name == _EMPTY_ && len(opts.CIDs) == 0) {
+		return nil, errors.New("an account, user, client name or connection id is required")
+	}
+	if opts.Max < 0 || opts.GracePeriod < 0 {
+		return nil, errors.New("max and grace period can not be negative")
+	}
+	return s.drainClients(opts, false), nil
+}
+
+// Drains the client connections selected by the options, only the ones
+// that can be notified to reconnect elsewhere if notifiableOnly is true.
+func (s *Server) drainClients(opts *DrainConnsOptions, notifiableOnly bool) *DrainConnsResponse {
+	gp := opts.GracePeriod
+	if gp == 0 {
+		if gp = s.getOpts().LameDuckGracePeriod; gp < 0 {
+			gp *= -1
+		}
+	}
+
+	s.mu.RLock()
+	info := s.copyInfo()
+	if len(opts.ConnectURLs) > 0 {
+		info.ClientConnectURLs = slices.Clone(opts.ConnectURLs)
+		info.WSConnectURLs = slices.Clone(opts.ConnectURLs)
+	} else {
+		// Only send the URLs of the other servers, if we are allowed to.
+		info.ClientConnectURLs, info.WSConnectURLs = nil, nil
+		if !s.getOpts().Cluster.NoAdvertise {
+			for url := range s.clientConnectURLsMap {
+				info.ClientConnectURLs = append(info.ClientConnectURLs, url)
+			}
+			for url := range s.websocket.connectURLsMap {
+				info.WSConnectURLs = append(info.WSConnectURLs, url)
+			}
+		}
+	}
+	info.LameDuckMode = true
+	clients := make([]*client, 0, len(s.clients))
+	for _, c := range s.clients {
+		clients = append(clients, c)
+	}
+	s.mu.RUnlock()
+
+	// Drain in the order of connections so that the oldest go first.
+	slices.SortFunc(clients, func(i, j *client) int { return cmp.Compare(i.cid, j.cid) })
+
+	dr := &DrainConnsResponse{
+		ID:          s.ID(),
+		Now:         time.Now().UTC(),
+		GracePeriod: gp,
+		Conns:       []*DrainedConnInfo{},
+	}
+	for _, c := range clients {
+		if opts.Max > 0 && len(dr.Conns) >= opts.Max {
+			break
+		}
+		c.mu.Lock()
+		if c.kind != CLIENT || c.dtmr != nil || c.isClosed() || !c.matchesDrainOptions(opts) {
+			c.mu.Unlock()
+			continue
+		}
+		// Clients that do not support async INFO protocols are just closed.
+		notify := !c.isMqtt() && c.opts.Protocol >= ClientProtoInfo && c.flags.isSet(firstPongSent)
+		if notifiableOnly && !notify {
+			c.mu.Unlock()
+			continue
+		}
+		ci := &DrainedConnInfo{Cid: c.cid, AuthorizedUser: c.getRawAuthUser(), Name: c.opts.Name, Notified: notify}
+		if c.acc != nil {
+			ci.Account = c.acc.Name
+		}
+		if notify {
+			c.enqueueProto(c.generateClientInfoJSON(info))
+		}
+		c.dtmr = time.AfterFunc(gp, func() { c.closeConnection(Drained) })
+		c.Noticef("Draining connection, closing in %v", gp)
+		c.mu.Unlock()
+		dr.Conns = append(dr.Conns, ci)
+	}
+	return dr
+}
+
+// Returns true if the client matches all the filters of the options.
+// Lock should be held.
+func (c *client) matchesDrainOptions(opts *DrainConnsOptions) bool {
+	if opts.Account != _EMPTY_ && (c.acc == nil || c.acc.Name != opts.Account) {
+		return false
+	}
+	if opts.User != _EMPTY_ && c.getRawAuthUser() != opts.User {
+		return false
+	}
+	if opts.Name != _EMPTY_ && c.opts.Name != opts.Name {
+		return false
+	}
+	if len(opts.CIDs) > 0 && !slices.Contains(opts.CIDs, c.cid) {
+		return false
+	}
+	return true
+}
+
+// Handles requests to drain client connections of this server.
+func (s *Server) drainConnsRequest(_ *subscription, c *client, _ *Account, subject, reply string, hdr, msg []byte) {
+	if !s.eventsRunning() {
+		return
+	}
+
+	// The request is not filtered, it is sent to this server only. Errors
+	// unmarshalling it are returned by zReq.
+	var req DrainConnsOptions
+	s.zReq(c, reply, hdr, msg, nil, &req, func() (any, error) {
+		return s.DrainConnections(&req)
+	})
+}
+
+// HandleDrainz process HTTP requests to drain client connections.
+// Connections are selected with the "acc", "user", "name" and "cid"
+// (comma separated) query parameters. As for the other monitoring
+// endpoints, access is restricted by the monitoring listener, so with
+// the http host or an https port. Since this changes the state of the
+// server, only POST requests are accepted.
+func (s *Server) HandleDrainz(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	s.httpReqStats[DrainzPath]++
+	s.mu.Unlock()
+
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		w.Write([]byte("drain requests must use the POST method"))
+		return
+	}
+
+	opts, err := decodeDrainConnsOptions(r)
+	var d *DrainConnsResponse
+	if err == nil {
+		d, err = s.DrainConnections(opts)
+	}
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(err.Error()))
+		return
+	}
+	b, err := json.MarshalIndent(d, "", "  ")
+	if err != nil {
+		s.Errorf("Error marshaling response to %s request: %v", DrainzPath, err)
+		return
+	}
+
+	// Handle response
+	ResponseHandler(w, r, b)
+}
+
+// Decodes the options of a drain request from the query parameters.
+func decodeDrainConnsOptions(r *http.Request) (*DrainConnsOptions, error) {
+	q := r.URL.Query()
+	opts := &DrainConnsOptions{
+		Account: q.Get("acc"),
+		User:    q.Get("user"),
+		Name:    q.Get("name"),
+	}
+	if cids := q.Get("cid"); cids != _EMPTY_ {
+		for _, v := range strings.Split(cids, ",") {
+			cid, err := strconv.ParseUint(strings.TrimSpace(v), 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid connection id %q", v)
+			}
+			opts.CIDs = append(opts.CIDs, cid)
+		}
+	}
+	var err error
+	if max := q.Get("max"); max != _EMPTY_ {
+		if opts.Max, err = strconv.Atoi(max); err != nil {
+			return nil, fmt.Errorf("invalid max %q", max)
+		}
+	}
+	if gp := q.Get("grace"); gp != _EMPTY_ {
+		if opts.GracePeriod, err = time.ParseDuration(gp); err != nil {
+			return nil, fmt.Errorf("invalid grace period %q: %v", gp, err)
+		}
+	}
+	if urls := q.Get("urls"); urls != _EMPTY_ {
+		opts.ConnectURLs = strings.Split(urls, ",")
+	}
+	return opts, nil
+}
@@ -0,0 +1,149 @@
+// Copyright 2025 The NATS Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"errors"
+	"math"
+	"time"
+)
+
+const (
+	// Default interval at which connection counts are compared.
+	defaultRebalanceInterval = time.Minute
+	// Default fraction above the cluster average that triggers a rebalance.
+	defaultRebalanceThreshold = 0.2
+	// Default maximum number of clients asked to reconnect per interval.
+	defaultRebalanceMaxPerInterval = 10
+)
+
+func validateRebalanceOptions(o *Options) error {
+	r := &o.Cluster.Rebalance
+	if r.Interval < 0 || r.Threshold < 0 || r.MaxPerInterval < 0 || r.GracePeriod < 0 {
+		return errors.New("cluster rebalance options can not be negative")
+	}
+	return nil
+}
+
+// Returns the interval of the rebalancing, with the default applied.
+func (r *RebalanceOpts) interval() time.Duration {
+	if r.Interval > 0 {
+		return r.Interval
+	}
+	return defaultRebalanceInterval
+}
+
+// Starts the go routine that periodically checks if the client connections
+// of this server should be rebalanced to the other servers of the cluster.
+// It runs as long as the server does, so that rebalancing can be enabled
+// on config reload.
+func (s *Server) startClientRebalancer() {
+	s.startGoRoutine(func() {
+		defer s.grWG.Done()
+
+		interval := s.getOpts().Cluster.Rebalance.interval()
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		var last time.Time
+		for {
+			select {
+			case <-s.quitCh:
+				return
+			case <-ticker.C:
+				ro := s.getOpts().Cluster.Rebalance
+				if ro.Enabled {
+					if n := s.rebalanceClients(&ro, last); n > 0 {
+						last = time.Now()
+					}
+				}
+				if ri := ro.interval(); ri != interval {
+					interval = ri
+					ticker.Reset(interval)
+				}
+			}
+		}
+	})
+}
+
+// Asks clients to reconnect to other servers of the cluster if this server
+// has more connections than the average of the cluster by more than the
+// threshold. The connection counts of the other servers are the ones of
+// their statsz updates, which must have been received since the last
+// rebalance so that the clients that moved are accounted for.
+// Only clients that support being notified to reconnect are selected.
+// Returns the number of clients asked to reconnect.
+func (s *Server) rebalanceClients(ro *RebalanceOpts, last time.Time) int {
+	gp := ro.GracePeriod
+	if gp == 0 {
+		if gp = s.getOpts().LameDuckGracePeriod; gp < 0 {
+			gp *= -1
+		}
+	}
+
+	s.mu.RLock()
+	if s.sys == nil || s.ldm || s.isShuttingDown() {
+		s.mu.RUnlock()
+		return 0
+	}
+	cluster := s.cachedClusterName()
+	var peers, total int
+	for _, su := range s.sys.servers {
+		if su.cluster != cluster {
+			continue
+		}
+		// Wait for updates that account for the clients that moved.
+		if !last.IsZero() && su.ltime.Before(last.Add(gp)) {
+			s.mu.RUnlock()
+			return 0
+		}
+		peers++
+		total += su.conns
+	}
+	// Clients that are draining are not counted.
+	var local int
+	for _, c := range s.clients {
+		c.mu.Lock()
+		if c.kind == CLIENT && c.dtmr == nil {
+			local++
+		}
+		c.mu.Unlock()
+	}
+	s.mu.RUnlock()
+
+	if peers == 0 {
+		return 0
+	}
+	threshold := ro.Threshold
+	if threshold == 0 {
+		threshold = defaultRebalanceThreshold
+	}
+	avg := float64(total+local) / float64(peers+1)
+	if float64(local) <= avg*(1+threshold) {
+		return 0
+	}
+	excess := local - int(math.Ceil(avg))
+	max := ro.MaxPerInterval
+	if max == 0 {
+		max = defaultRebalanceMaxPerInterval
+	}
+	excess = min(excess, max)
+	if excess <= 0 {
+		return 0
+	}
+	dr := s.drainClients(&DrainConnsOptions{Max: excess, GracePeriod: gp}, true)
+	if n := len(dr.Conns); n > 0 {
+		s.Noticef("Rebalancing %d client connections, %d connected for a cluster average of %.1f", n, local, avg)
+	}
+	return len(dr.Conns)
+}
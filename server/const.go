@@ -30,8 +30,9 @@ const (
 	CommandReload = Command("reload")
 
 	// private for now
-	commandLDMode = Command("ldm")
-	commandTerm   = Command("term")
+	commandLDMode  = Command("ldm")
+	commandTerm    = Command("term")
+	commandUpgrade = Command("upgrade")
 )
 
 var (
@@ -236,6 +237,10 @@ const (
 	// time based cleanup of reverse mapping structures.
 	DEFAULT_SERVICE_EXPORT_RESPONSE_THRESHOLD = 2 * time.Minute
 
+	// DEFAULT_SERVICE_EXPORT_CIRCUIT_RESET is the default time the circuit breaker of a
+	// service export stays open before letting a request through to probe the service.
+	DEFAULT_SERVICE_EXPORT_CIRCUIT_RESET = 10 * time.Second
+
 	// DEFAULT_SERVICE_LATENCY_SAMPLING is the default sampling rate for service
 	// latency metrics
 	DEFAULT_SERVICE_LATENCY_SAMPLING = 100
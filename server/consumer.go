@@ -68,6 +68,9 @@ type ConsumerInfo struct {
 	PushBound      bool            `json:"push_bound,omitempty"`
 	Paused         bool            `json:"paused,omitempty"`
 	PauseRemaining time.Duration   `json:"pause_remaining,omitempty"`
+	Replaying      bool            `json:"replaying,omitempty"`
+	// Webhook reports the deliveries of webhook consumers.
+	Webhook *ConsumerWebhookInfo `json:"webhook,omitempty"`
 	// TimeStamp indicates when the info was gathered
 	TimeStamp      time.Time            `json:"ts"`
 	PriorityGroups []PriorityGroupState `json:"priority_groups,omitempty"`
@@ -81,9 +84,10 @@ type consumerInfoClusterResponse struct {
 }
 
 type PriorityGroupState struct {
-	Group          string    `json:"group"`
-	PinnedClientID string    `json:"pinned_client_id,omitempty"`
-	PinnedTS       time.Time `json:"pinned_ts,omitempty"`
+	Group          string           `json:"group"`
+	PinnedClientID string           `json:"pinned_client_id,omitempty"`
+	PinnedTS       time.Time        `json:"pinned_ts,omitempty"`
+	Partitions     map[string][]int `json:"partitions,omitempty"`
 }
 
 type ConsumerConfig struct {
@@ -100,7 +104,9 @@ type ConsumerConfig struct {
 	FilterSubject   string          `json:"filter_subject,omitempty"`
 	FilterSubjects  []string        `json:"filter_subjects,omitempty"`
 	ReplayPolicy    ReplayPolicy    `json:"replay_policy"`
-	RateLimit       uint64          `json:"rate_limit_bps,omitempty"` // Bits per sec
+	ReplaySpeed     float64         `json:"replay_speed,omitempty"`     // Multiplier applied to original timing.
+	ReplayRate      uint64          `json:"replay_rate_msgs,omitempty"` // Msgs per sec while replaying.
+	RateLimit       uint64          `json:"rate_limit_bps,omitempty"`   // Bits per sec
 	SampleFrequency string          `json:"sample_freq,omitempty"`
 	MaxWaiting      int             `json:"max_waiting,omitempty"`
 	MaxAckPending   int             `json:"max_ack_pending,omitempty"`
@@ -116,6 +122,8 @@ type ConsumerConfig struct {
 	DeliverSubject string        `json:"deliver_subject,omitempty"`
 	DeliverGroup   string        `json:"deliver_group,omitempty"`
 	Heartbeat      time.Duration `json:"idle_heartbeat,omitempty"`
+	// Webhook delivers the messages to an HTTP endpoint instead of a client.
+	Webhook *ConsumerWebhook `json:"webhook,omitempty"`
 
 	// Ephemeral inactivity threshold.
 	InactiveThreshold time.Duration `json:"inactive_threshold,omitempty"`
@@ -138,6 +146,7 @@ type ConsumerConfig struct {
 	PriorityGroups []string       `json:"priority_groups,omitempty"`
 	PriorityPolicy PriorityPolicy `json:"priority_policy,omitempty"`
 	PinnedTTL      time.Duration  `json:"priority_timeout,omitempty"`
+	PartitionCount int            `json:"partition_count,omitempty"`
 }
 
 // SequenceInfo has both the consumer and the stream sequence and last activity.
@@ -230,6 +239,8 @@ const (
 	PriorityPinnedClient
 	// Clients with lowest priority will be selected first.
 	PriorityPrioritized
+	// Subject space is partitioned and partitions are assigned across group members.
+	PriorityPartitioned
 )
 
 const (
@@ -237,6 +248,7 @@ const (
 	PriorityOverflowJSONString     = `"overflow"`
 	PriorityPinnedClientJSONString = `"pinned_client"`
 	PriorityPrioritizedJSONString  = `"prioritized"`
+	PriorityPartitionedJSONString  = `"partitioned"`
 )
 
 var (
@@ -244,6 +256,7 @@ var (
 	PriorityOverflowJSONBytes     = []byte(PriorityOverflowJSONString)
 	PriorityPinnedClientJSONBytes = []byte(PriorityPinnedClientJSONString)
 	PriorityPrioritizedJSONBytes  = []byte(PriorityPrioritizedJSONString)
+	PriorityPartitionedJSONBytes  = []byte(PriorityPartitionedJSONString)
 )
 
 func (pp PriorityPolicy) String() string {
@@ -254,6 +267,8 @@ func (pp PriorityPolicy) String() string {
 		return PriorityPinnedClientJSONString
 	case PriorityPrioritized:
 		return PriorityPrioritizedJSONString
+	case PriorityPartitioned:
+		return PriorityPartitionedJSONString
 	default:
 		return PriorityNoneJSONString
 	}
@@ -267,6 +282,8 @@ func (pp PriorityPolicy) MarshalJSON() ([]byte, error) {
 		return PriorityPinnedClientJSONBytes, nil
 	case PriorityPrioritized:
 		return PriorityPrioritizedJSONBytes, nil
+	case PriorityPartitioned:
+		return PriorityPartitionedJSONBytes, nil
 	case PriorityNone:
 		return PriorityNoneJSONBytes, nil
 	default:
@@ -282,6 +299,8 @@ func (pp *PriorityPolicy) UnmarshalJSON(data []byte) error {
 		*pp = PriorityPinnedClient
 	case PriorityPrioritizedJSONString:
 		*pp = PriorityPrioritized
+	case PriorityPartitionedJSONString:
+		*pp = PriorityPartitioned
 	case PriorityNoneJSONString:
 		*pp = PriorityNone
 	default:
@@ -436,6 +455,7 @@ type consumer struct {
 	qgroup            string
 	lss               *lastSeqSkipList
 	rlimit            *rate.Limiter
+	rplimit           *rate.Limiter
 	reqSub            *subscription
 	ackSub            *subscription
 	ackReplyT         string
@@ -483,6 +503,7 @@ type consumer struct {
 	lat               time.Time
 	lwqic             time.Time
 	closed            bool
+	wh                *consumerWebhook // Webhook delivery, only on the leader.
 
 	// Clustered.
 	ca        *consumerAssignment
@@ -519,6 +540,10 @@ type consumer struct {
 	pinnedTtl *time.Timer
 	pinnedTS  time.Time
 
+	// pgrp tracks members and partition assignments when running
+	// in `PriorityPartitioned` mode.
+	pgrp *partitionGroup
+
 	// If standalone/single-server, the offline reason needs to be stored directly in the consumer.
 	// Otherwise, if clustered it will be part of the consumer assignment.
 	offlineReason string
@@ -622,6 +647,9 @@ func setConsumerConfigDefaults(config *ConsumerConfig, streamCfg *StreamConfig,
 		config.PinnedTTL = 0
 	}
 
+	// Webhook consumers deliver to a subject of their own if not specified.
+	setWebhookDeliverSubject(config, streamCfg.Name)
+
 	// Set to default if not specified.
 	if config.DeliverSubject == _EMPTY_ && config.MaxWaiting == 0 {
 		config.MaxWaiting = JSWaitQueueDefaultMax
@@ -668,8 +696,8 @@ func setConsumerConfigDefaults(config *ConsumerConfig, streamCfg *StreamConfig,
 		config.MaxRequestBatch = lim.MaxRequestBatch
 	}
 
-	// set the default value only if pinned policy is used.
-	if config.PriorityPolicy == PriorityPinnedClient && config.PinnedTTL == 0 {
+	// set the default value only if pinned or partitioned policy is used.
+	if (config.PriorityPolicy == PriorityPinnedClient || config.PriorityPolicy == PriorityPartitioned) && config.PinnedTTL == 0 {
 		config.PinnedTTL = JsDefaultPinnedTTL
 	}
 	return nil
@@ -680,7 +708,7 @@ func checkConsumerCfg(
 	config *ConsumerConfig,
 	srvLim *JSLimitOpts,
 	cfg *StreamConfig,
-	_ *Account,
+	acc *Account,
 	accLim *JetStreamAccountLimits,
 	isRecovering bool,
 ) *ApiError {
@@ -710,6 +738,12 @@ func checkConsumerCfg(
 	if _, err := config.ReplayPolicy.MarshalJSON(); err != nil {
 		return NewJSConsumerReplayPolicyInvalidError()
 	}
+	if config.ReplaySpeed < 0 {
+		return NewJSConsumerReplaySpeedInvalidError()
+	}
+	if config.ReplaySpeed > 0 && config.ReplayPolicy != ReplayOriginal {
+		return NewJSConsumerReplaySpeedRequiresOriginalError()
+	}
 
 	// Check not negative AckWait/BackOff
 	for _, backoff := range config.BackOff {
@@ -790,6 +824,10 @@ func checkConsumerCfg(
 		return NewJSConsumerInactiveThresholdExcessError(cfg.ConsumerLimits.InactiveThreshold)
 	}
 
+	if err := checkConsumerWebhook(config, acc, isRecovering); err != nil {
+		return err
+	}
+
 	// Direct need to be non-mapped ephemerals.
 	if config.Direct {
 		if config.DeliverSubject == _EMPTY_ {
@@ -930,6 +968,16 @@ func checkConsumerCfg(
 				return NewJSConsumerInvalidGroupNameError()
 			}
 		}
+		if config.PriorityPolicy == PriorityPartitioned && config.PartitionCount <= 0 {
+			return NewJSConsumerPartitionCountInvalidError()
+		}
+		// Partitions are fenced based on the pending messages of each member.
+		if config.PriorityPolicy == PriorityPartitioned && config.AckPolicy != AckExplicit {
+			return NewJSConsumerPartitionedAckPolicyError()
+		}
+		if config.PriorityPolicy != PriorityPartitioned && config.PartitionCount != 0 {
+			return NewJSConsumerPartitionCountWithoutPolicyError()
+		}
 	} else {
 		// If PriorityPolicy is None or not set, reject if PriorityGroups or PinnedTTL are set
 		if len(config.PriorityGroups) > 0 {
@@ -938,6 +986,9 @@ func checkConsumerCfg(
 		if config.PinnedTTL > 0 {
 			return NewJSConsumerPinnedTTLWithoutPriorityPolicyNoneError()
 		}
+		if config.PartitionCount != 0 {
+			return NewJSConsumerPartitionCountWithoutPolicyError()
+		}
 	}
 
 	// For now don't allow preferred server in placement.
@@ -1288,6 +1339,10 @@ func (mset *stream) addConsumerWithAssignment(config *ConsumerConfig, oname stri
 	if config.RateLimit != 0 {
 		o.setRateLimit(config.RateLimit)
 	}
+	// Check if we have a replay rate set.
+	if config.ReplayRate != 0 {
+		o.setReplayRate(config.ReplayRate)
+	}
 
 	mset.setConsumer(o)
 	mset.mu.Unlock()
@@ -1586,14 +1641,19 @@ func (o *consumer) setLeader(isLeader bool) {
 		// Update the consumer pause tracking.
 		o.updatePauseState(&o.cfg)
 
-		// If we are not in ReplayInstant mode mark us as in replay state until resolved.
-		if o.cfg.ReplayPolicy != ReplayInstant {
+		// If we are not in ReplayInstant mode or have a replay rate set,
+		// mark us as in replay state until resolved.
+		if o.cfg.ReplayPolicy != ReplayInstant || o.cfg.ReplayRate > 0 {
 			o.replay = true
 		}
 
 		// Recreate quit channel.
 		o.qch = make(chan struct{})
 		qch := o.qch
+		// Webhook consumers provide their own interest.
+		if o.cfg.Webhook != nil {
+			o.startWebhookLocked(&o.cfg)
+		}
 		node := o.node
 		if node != nil && o.pch == nil {
 			o.pch = make(chan struct{}, 1)
@@ -1663,12 +1723,14 @@ func (o *consumer) setLeader(isLeader bool) {
 		o.rdq = nil
 		o.rdqi.Empty()
 		o.pending = nil
+		o.pgrp = nil
 		o.resetPendingDeliveries()
 		// ok if they are nil, we protect inside unsubscribe()
 		o.unsubscribe(o.ackSub)
 		o.unsubscribe(o.reqSub)
 		o.unsubscribe(o.fcSub)
 		o.ackSub, o.reqSub, o.fcSub = nil, nil, nil
+		o.stopWebhookLocked()
 		if o.infoSub != nil {
 			o.srv.sysUnsubscribe(o.infoSub)
 			o.infoSub = nil
@@ -2244,6 +2306,28 @@ func (o *consumer) setRateLimit(bps uint64) {
 	o.rlimit = rate.NewLimiter(rl, burst)
 }
 
+// Set the replay rate limiter, used while the consumer is replaying
+// messages already stored in the stream.
+// Lock should be held.
+func (o *consumer) setReplayRate(msgsPerSec uint64) {
+	if msgsPerSec == 0 {
+		o.rplimit = nil
+		return
+	}
+	o.rplimit = rate.NewLimiter(rate.Limit(msgsPerSec), 1)
+}
+
+// Returns the delay between two replayed messages based on the gap between
+// their original timestamps, scaled by the configured replay speed.
+// Lock should be held.
+func (o *consumer) replayDelay(gap int64) time.Duration {
+	delay := time.Duration(gap)
+	if o.cfg.ReplaySpeed > 0 {
+		delay = time.Duration(float64(delay) / o.cfg.ReplaySpeed)
+	}
+	return delay
+}
+
 // Check if new consumer config allowed vs old.
 func (acc *Account) checkNewConsumerConfig(cfg, ncfg *ConsumerConfig) error {
 	if reflect.DeepEqual(cfg, ncfg) {
@@ -2271,6 +2355,9 @@ func (acc *Account) checkNewConsumerConfig(cfg, ncfg *ConsumerConfig) error {
 	if cfg.ReplayPolicy != ncfg.ReplayPolicy {
 		return errors.New("replay policy can not be updated")
 	}
+	if cfg.PartitionCount != ncfg.PartitionCount {
+		return errors.New("partition count can not be updated")
+	}
 	if cfg.Heartbeat != ncfg.Heartbeat {
 		return errors.New("heart beats can not be updated")
 	}
@@ -2329,6 +2416,14 @@ func (o *consumer) updateConfig(cfg *ConsumerConfig) error {
 		}
 	}
 
+	// Webhook, restarted on the new deliver subject if that changed as well.
+	if o.wh != nil && (cfg.DeliverSubject != o.cfg.DeliverSubject || !reflect.DeepEqual(cfg.Webhook, o.cfg.Webhook)) {
+		o.stopWebhookLocked()
+	}
+	if o.isLeader() && o.wh == nil && cfg.Webhook != nil {
+		o.startWebhookLocked(cfg)
+	}
+
 	// DeliverSubject
 	if cfg.DeliverSubject != o.cfg.DeliverSubject {
 		o.updateDeliverSubjectLocked(cfg.DeliverSubject)
@@ -2355,6 +2450,10 @@ func (o *consumer) updateConfig(cfg *ConsumerConfig) error {
 		// We need both locks here so do in Go routine.
 		go o.setRateLimitNeedsLocks()
 	}
+	// Replay Rate
+	if cfg.ReplayRate != o.cfg.ReplayRate {
+		o.setReplayRate(cfg.ReplayRate)
+	}
 	if cfg.SampleFrequency != o.cfg.SampleFrequency {
 		s := strings.TrimSuffix(cfg.SampleFrequency, "%")
 		if sampleFreq, err := strconv.ParseInt(s, 10, 32); err == nil {
@@ -3126,11 +3225,15 @@ func (o *consumer) infoWithSnapAndReply(snap bool, reply string) *ConsumerInfo {
 	priorityGroups := []PriorityGroupState{}
 	// TODO(jrm): when we introduce supporting many priority groups, we need to update assigning `o.currentNuid` for each group.
 	if len(o.cfg.PriorityGroups) > 0 {
-		priorityGroups = append(priorityGroups, PriorityGroupState{
+		pgs := PriorityGroupState{
 			Group:          o.cfg.PriorityGroups[0],
 			PinnedClientID: o.currentPinId,
 			PinnedTS:       o.pinnedTS,
-		})
+		}
+		if o.pgrp != nil {
+			pgs.Partitions = o.pgrp.assignments()
+		}
+		priorityGroups = append(priorityGroups, pgs)
 	}
 
 	cfg := o.cfg
@@ -3151,8 +3254,10 @@ func (o *consumer) infoWithSnapAndReply(snap bool, reply string) *ConsumerInfo {
 		NumRedelivered: len(o.rdc),
 		NumPending:     o.checkNumPending(),
 		PushBound:      o.isPushMode() && o.active,
+		Replaying:      o.replay,
 		TimeStamp:      time.Now().UTC(),
 		PriorityGroups: priorityGroups,
+		Webhook:        o.webhookInfo(),
 	}
 	// Reset redelivered for MaxDeliver 1. Redeliveries are disabled so must not report it (is confusing otherwise).
 	// The state does still keep track of these messages.
@@ -3388,6 +3493,11 @@ func (o *consumer) processAckMsg(sseq, dseq, dc uint64, reply string, doSample b
 		return ackInPlace
 	}
 
+	// If a partition is fenced waiting on its previous owner we may be able to unblock it.
+	if o.pgrp != nil && o.pgrp.isFenced() {
+		needSignal = true
+	}
+
 	// No ack replication, so we set reply to "" so that updateAcks does not
 	// send the reply. The caller will.
 	if ackInPlace {
@@ -3910,11 +4020,17 @@ func (o *consumer) setPinnedTimer(priorityGroup string) {
 
 // Return next waiting request. This will check for expirations but not noWait or interest.
 // That will be handled by processWaiting.
+// If running in `PriorityPartitioned` mode, member is the owner of the message's partition
+// and only requests from that member will be selected.
 // Lock should be held.
-func (o *consumer) nextWaiting(sz int) *waitingRequest {
+func (o *consumer) nextWaiting(sz int, member string) *waitingRequest {
 	if o.waiting == nil || o.waiting.isEmpty() {
 		return nil
 	}
+	partitioned := o.cfg.PriorityPolicy == PriorityPartitioned
+	if partitioned && member == _EMPTY_ {
+		return nil
+	}
 
 	// Check if server needs to assign a new pin id.
 	needNewPin := o.currentPinId == _EMPTY_ && o.cfg.PriorityPolicy == PriorityPinnedClient
@@ -3995,6 +4111,16 @@ func (o *consumer) nextWaiting(sz int) *waitingRequest {
 				}
 			}
 
+			if partitioned && (wr.priorityGroup == nil || wr.priorityGroup.Id != member) {
+				o.waiting.cycle()
+				numCycled++
+				// We're done cycling through the requests.
+				if numCycled >= o.waiting.len() {
+					return nil
+				}
+				continue
+			}
+
 			if o.cfg.PriorityPolicy == PriorityOverflow {
 				if wr.priorityGroup != nil &&
 					// We need to check o.npc+1, because before calling nextWaiting, we do o.npc--
@@ -4166,7 +4292,7 @@ func (o *consumer) processNextMsgRequest(reply string, msg []byte) {
 			sendErr(400, "Bad Request - Not a Overflow Priority consumer")
 		}
 
-		if priorityGroup.Id != _EMPTY_ && o.cfg.PriorityPolicy != PriorityPinnedClient {
+		if priorityGroup.Id != _EMPTY_ && o.cfg.PriorityPolicy != PriorityPinnedClient && o.cfg.PriorityPolicy != PriorityPartitioned {
 			sendErr(400, "Bad Request - Not a Pinned Client Priority consumer")
 		}
 		if priorityGroup.Priority < 0 || priorityGroup.Priority > 9 {
@@ -4201,6 +4327,18 @@ func (o *consumer) processNextMsgRequest(reply string, msg []byte) {
 				return
 			}
 		}
+
+		// Partitioned consumer groups require members to identify themselves.
+		if o.cfg.PriorityPolicy == PriorityPartitioned {
+			if priorityGroup.Id == _EMPTY_ {
+				sendErr(400, "Bad Request - Member Id missing")
+				return
+			}
+			o.registerPartitionMember(priorityGroup.Id)
+		}
+	} else if o.cfg.PriorityPolicy == PriorityPartitioned {
+		sendErr(400, "Bad Request - Priority Group missing")
+		return
 	}
 
 	// If we have the max number of requests already pending try to expire.
@@ -4432,6 +4570,11 @@ func (o *consumer) getNextMsg() (*jsPubMsg, uint64, error) {
 		}
 	}
 
+	// Messages held back for the owner of their partition are already pending.
+	if pmsg, dc := o.nextHeldMsg(); pmsg != nil {
+		return pmsg, dc, nil
+	}
+
 	// Check if we have max pending.
 	if o.maxp > 0 && len(o.pending) >= o.maxp {
 		// maxp only set when ack policy != AckNone and user set MaxAckPending
@@ -4575,6 +4718,11 @@ func (o *consumer) processWaiting(eos bool) (int, int, int, time.Time) {
 		wr = wr.next
 	}
 
+	// Members of a partitioned consumer group without requests expire.
+	if o.pgrp != nil {
+		o.expirePartitionMembers()
+	}
+
 	return expired, wq.len(), brp, fexp
 }
 
@@ -4835,6 +4983,8 @@ func (o *consumer) loopAndGatherMsgs(qch chan struct{}) {
 			delay    time.Duration
 			sz       int
 			wrn, wrb int
+			member   string
+			part     int
 		)
 
 		o.mu.Lock()
@@ -4910,9 +5060,14 @@ func (o *consumer) loopAndGatherMsgs(qch chan struct{}) {
 		// We do not include transport subject here since not generally known on client.
 		sz = len(pmsg.subj) + len(ackReply) + len(pmsg.hdr) + len(pmsg.msg)
 
+		// For partitioned consumer groups only the owner of the partition can receive this message.
+		if o.cfg.PriorityPolicy == PriorityPartitioned {
+			member, part = o.partitionOwner(pmsg.subj)
+		}
+
 		if o.isPushMode() {
 			dsubj = o.dsubj
-		} else if wr := o.nextWaiting(sz); wr != nil {
+		} else if wr := o.nextWaiting(sz, member); wr != nil {
 			wrn, wrb = wr.n, wr.b
 			dsubj = wr.reply
 			if o.cfg.PriorityPolicy == PriorityPinnedClient {
@@ -4935,6 +5090,12 @@ func (o *consumer) loopAndGatherMsgs(qch chan struct{}) {
 			} else if !done && wr.hb > 0 {
 				wr.hbt = time.Now().Add(wr.hb)
 			}
+		} else if o.cfg.PriorityPolicy == PriorityPartitioned && o.holdPartitionMsg(pmsg, part, dc) {
+			// The owner of the partition has no waiting request, so hold the
+			// message back for it and move on to the messages of other partitions.
+			pmsg.returnToPool()
+			o.mu.Unlock()
+			continue
 		} else {
 			// We will redo this one as long as this is not a redelivery.
 			// Need to also test that this is not going backwards since if
@@ -4954,8 +5115,24 @@ func (o *consumer) loopAndGatherMsgs(qch chan struct{}) {
 		}
 
 		// If we are in a replay scenario and have not caught up check if we need to delay here.
-		if o.replay && lts > 0 {
-			if delay = time.Duration(pmsg.ts - lts); delay > time.Millisecond {
+		if o.replay && lts > 0 && o.cfg.ReplayPolicy == ReplayOriginal {
+			if delay = o.replayDelay(pmsg.ts - lts); delay > time.Millisecond {
+				o.mu.Unlock()
+				select {
+				case <-qch:
+					pmsg.returnToPool()
+					return
+				case <-time.After(delay):
+				}
+				o.mu.Lock()
+			}
+		}
+
+		// If we are replaying with a msgs per sec cap make sure we check that here.
+		if o.replay && o.rplimit != nil {
+			now := time.Now()
+			r := o.rplimit.ReserveN(now, 1)
+			if delay = r.DelayFrom(now); delay > 0 {
 				o.mu.Unlock()
 				select {
 				case <-qch:
@@ -4987,6 +5164,11 @@ func (o *consumer) loopAndGatherMsgs(qch chan struct{}) {
 			}
 		}
 
+		// Track which member received this message if partitioned.
+		if member != _EMPTY_ {
+			o.trackPartitionDelivery(pmsg.seq, part, member)
+		}
+
 		// Do actual delivery.
 		o.deliverMsg(dsubj, ackReply, pmsg, dc, rp)
 
@@ -5512,6 +5694,9 @@ func (o *consumer) checkPending() {
 			delete(o.pending, seq)
 			delete(o.rdc, seq)
 			o.removeFromRedeliverQueue(seq)
+			if o.pgrp != nil {
+				o.pgrp.release(seq)
+			}
 			shouldUpdateState = true
 			// Check if we need to move ack floors.
 			if seq > o.asflr {
@@ -5522,6 +5707,10 @@ func (o *consumer) checkPending() {
 			}
 			continue
 		}
+		// Messages held back for the owner of their partition were not sent.
+		if o.pgrp != nil && o.pgrp.isHeld(seq) {
+			continue
+		}
 		elapsed, deadline := now-p.Timestamp, ttl
 		if len(o.cfg.BackOff) > 0 {
 			// This is ok even if o.rdc is nil, we would get dc == 0, which is what we want.
@@ -6043,6 +6232,7 @@ func (o *consumer) stopWithFlags(dflag, sdflag, doSignal, advisory bool) error {
 	mset := o.mset
 	o.mset = nil
 	o.active = false
+	o.stopWebhookLocked()
 	o.unsubscribe(o.ackSub)
 	o.unsubscribe(o.reqSub)
 	o.unsubscribe(o.fcSub)
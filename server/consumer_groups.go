@@ -0,0 +1,379 @@
+// Copyright 2025 The NATS Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"slices"
+	"time"
+)
+
+// partitionGroup tracks the members of a consumer group when a pull consumer
+// runs with the PriorityPartitioned policy. The subject space of the stream is
+// divided into a fixed number of partitions, and each partition is owned by
+// exactly one active member at a time. Members identify themselves with the
+// priority group id of their pull requests.
+//
+// Messages of a partition whose owner has no waiting pull request are held back
+// for it, so that other members keep receiving the messages of their partitions.
+// Held messages are recorded as pending, so they are not lost on a leader change.
+// This requires the explicit ack policy, enforced when checking the config.
+//
+// This state is only kept on the consumer leader. After a leader change, the
+// members re-join with their next pull request.
+type partitionGroup struct {
+	members  map[string]time.Time          // Member id to last time seen.
+	owners   []string                      // Partition to owning member id.
+	fenced   []bool                        // Partition is waiting on its previous owner.
+	inflight map[uint64]*partitionDelivery // Stream sequence to delivery.
+	held     map[int][]uint64              // Partition to sorted stream sequences held back.
+	heldp    map[uint64]int                // Held stream sequence to partition.
+	epoch    uint64                        // Bumped on every rebalance.
+}
+
+// partitionDelivery tracks which member a message of a partition was delivered to.
+type partitionDelivery struct {
+	partition int
+	member    string
+}
+
+func newPartitionGroup(partitions int) *partitionGroup {
+	return &partitionGroup{
+		members:  make(map[string]time.Time),
+		owners:   make([]string, partitions),
+		fenced:   make([]bool, partitions),
+		inflight: make(map[uint64]*partitionDelivery),
+		held:     make(map[int][]uint64),
+		heldp:    make(map[uint64]int),
+	}
+}
+
+// Returns the partition group, creating it if needed.
+// Lock should be held.
+func (o *consumer) partitionGroup() *partitionGroup {
+	if o.pgrp == nil && o.cfg.PriorityPolicy == PriorityPartitioned && o.cfg.PartitionCount > 0 {
+		o.pgrp = newPartitionGroup(o.cfg.PartitionCount)
+	}
+	return o.pgrp
+}
+
+// Returns the partition for the given subject, the same as the
+// "{{partition(n)}}" subject mapping of the full subject.
+func subjectPartition(subject string, partitions int) int {
+	return hashPartition(stringToBytes(subject), partitions)
+}
+
+// Registers or refreshes a member of the partition group.
+// Lock should be held.
+func (o *consumer) registerPartitionMember(member string) {
+	pg := o.partitionGroup()
+	if pg == nil {
+		return
+	}
+	_, ok := pg.members[member]
+	pg.members[member] = time.Now()
+	if !ok {
+		o.rebalancePartitions()
+	}
+}
+
+// Removes members that have not been seen within the consumer's priority
+// timeout and have no waiting requests, rebalancing if needed.
+// Lock should be held.
+func (o *consumer) expirePartitionMembers() {
+	pg := o.pgrp
+	if pg == nil || len(pg.members) == 0 {
+		return
+	}
+	ttl := o.cfg.PinnedTTL
+	if ttl <= 0 {
+		ttl = JsDefaultPinnedTTL
+	}
+	now := time.Now()
+	var changed bool
+	for member, last := range pg.members {
+		if now.Sub(last) < ttl || o.hasWaitingForMember(member) {
+			continue
+		}
+		delete(pg.members, member)
+		changed = true
+	}
+	if changed {
+		o.rebalancePartitions()
+	}
+}
+
+// Returns whether the member has any waiting pull requests with interest.
+// Lock should be held.
+func (o *consumer) hasWaitingForMember(member string) bool {
+	if o.waiting == nil {
+		return false
+	}
+	for wr := o.waiting.head; wr != nil; wr = wr.next {
+		if wr.priorityGroup != nil && wr.priorityGroup.Id == member && wr.acc.sl.HasInterest(wr.interest) {
+			return true
+		}
+	}
+	return false
+}
+
+// Assigns the partitions across the sorted list of active members.
+// A partition that moves to a new member is fenced until the messages
+// delivered to its previous owner have been acknowledged or their
+// ack wait has expired, so each partition is only processed by one member.
+// Lock should be held.
+func (o *consumer) rebalancePartitions() {
+	pg := o.pgrp
+	if pg == nil {
+		return
+	}
+	members := make([]string, 0, len(pg.members))
+	for member := range pg.members {
+		members = append(members, member)
+	}
+	slices.Sort(members)
+
+	pg.epoch++
+	for p := range pg.owners {
+		var owner string
+		if len(members) > 0 {
+			owner = members[p%len(members)]
+		}
+		if pg.owners[p] != owner {
+			pg.owners[p] = owner
+			pg.fenced[p] = true
+		}
+	}
+	o.prunePartitionDeliveries()
+}
+
+// Returns the owning member of the partition for the given subject along with
+// the partition itself. The member will be empty if the partition is not
+// currently assigned or is still fenced.
+// Lock should be held.
+func (o *consumer) partitionOwner(subject string) (string, int) {
+	pg := o.partitionGroup()
+	if pg == nil {
+		return _EMPTY_, 0
+	}
+	p := subjectPartition(subject, len(pg.owners))
+	return o.partitionMember(p), p
+}
+
+// Returns the owning member of the partition, empty if the partition is not
+// currently assigned or is still fenced.
+// Lock should be held.
+func (o *consumer) partitionMember(p int) string {
+	pg := o.pgrp
+	owner := pg.owners[p]
+	if owner == _EMPTY_ {
+		return _EMPTY_
+	}
+	if pg.fenced[p] {
+		if o.partitionHasPriorDeliveries(p, owner) {
+			return _EMPTY_
+		}
+		pg.fenced[p] = false
+	}
+	return owner
+}
+
+// Holds back a message for the owner of its partition, when the owner has no
+// waiting pull request. A message delivered for the first time is recorded as
+// delivered and pending, so it survives a leader change and is redelivered if so.
+// Returns false if the partition has no owner.
+// Lock should be held.
+func (o *consumer) holdPartitionMsg(pmsg *jsPubMsg, p int, dc uint64) bool {
+	pg := o.pgrp
+	if pg == nil || pg.owners[p] == _EMPTY_ {
+		return false
+	}
+	seq := pmsg.seq
+	if _, ok := pg.heldp[seq]; ok {
+		if dc > 1 {
+			o.decDeliveryCount(seq)
+		}
+		return true
+	}
+	if dc == 1 {
+		// With acks of all prior messages, another member could acknowledge it.
+		if _, ok := o.pending[seq]; !ok && o.cfg.AckPolicy == AckExplicit {
+			dseq := o.dseq
+			o.dseq++
+			o.updateDelivered(dseq, seq, dc, pmsg.ts)
+			o.trackPending(seq, dseq)
+		}
+		// Not yet delivered to a member.
+		o.npc++
+	} else {
+		// Adjust back the delivery count of the redelivery.
+		o.decDeliveryCount(seq)
+	}
+	seqs := pg.held[p]
+	i, _ := slices.BinarySearch(seqs, seq)
+	pg.held[p] = slices.Insert(seqs, i, seq)
+	pg.heldp[seq] = p
+	return true
+}
+
+// Returns whether the message is held back for the owner of its partition.
+// Lock should be held.
+func (pg *partitionGroup) isHeld(seq uint64) bool {
+	_, ok := pg.heldp[seq]
+	return ok
+}
+
+// Removes a message from the held back ones.
+// Lock should be held.
+func (pg *partitionGroup) release(seq uint64) {
+	p, ok := pg.heldp[seq]
+	if !ok {
+		return
+	}
+	delete(pg.heldp, seq)
+	seqs := pg.held[p]
+	if i, found := slices.BinarySearch(seqs, seq); found {
+		seqs = slices.Delete(seqs, i, i+1)
+	}
+	if len(seqs) == 0 {
+		delete(pg.held, p)
+	} else {
+		pg.held[p] = seqs
+	}
+}
+
+// Returns the oldest held back message of the partitions whose owner now has
+// a waiting pull request, along with its delivery count.
+// Lock should be held.
+func (o *consumer) nextHeldMsg() (*jsPubMsg, uint64) {
+	pg := o.pgrp
+	if pg == nil || len(pg.held) == 0 {
+		return nil, 0
+	}
+	var ready []int
+	for p := range pg.owners {
+		if len(pg.held[p]) == 0 {
+			continue
+		}
+		if owner := o.partitionMember(p); owner != _EMPTY_ && o.hasWaitingForMember(owner) {
+			ready = append(ready, p)
+		}
+	}
+	for len(ready) > 0 {
+		// Oldest message first, to keep the stream order across partitions.
+		i := slices.IndexFunc(ready, func(p int) bool { return len(pg.held[p]) > 0 })
+		if i < 0 {
+			break
+		}
+		for j, p := range ready {
+			if seqs := pg.held[p]; len(seqs) > 0 && seqs[0] < pg.held[ready[i]][0] {
+				i = j
+			}
+		}
+		seq := pg.held[ready[i]][0]
+		pg.release(seq)
+		// Held back messages that were delivered before are redeliveries.
+		_, delivered := pg.inflight[seq]
+		// Acknowledged or removed in the meantime.
+		if _, ok := o.pending[seq]; !ok && o.cfg.AckPolicy == AckExplicit {
+			if !delivered {
+				o.npc--
+			}
+			continue
+		}
+		pmsg := getJSPubMsgFromPool()
+		if sm, err := o.mset.store.LoadMsg(seq, &pmsg.StoreMsg); sm == nil || err != nil {
+			pmsg.returnToPool()
+			if !delivered {
+				o.npc--
+			}
+			continue
+		}
+		dc := uint64(1)
+		if delivered {
+			dc = o.incDeliveryCount(seq)
+		}
+		return pmsg, dc
+	}
+	return nil, 0
+}
+
+// Returns whether a partition has outstanding deliveries to a member other
+// than its owner that have not been acknowledged and have not yet reached their
+// ack wait.
+// Lock should be held.
+func (o *consumer) partitionHasPriorDeliveries(partition int, owner string) bool {
+	pg := o.pgrp
+	deadline := time.Now().Add(-o.ackWait(0)).UnixNano()
+	for seq, pd := range pg.inflight {
+		p, ok := o.pending[seq]
+		if !ok {
+			delete(pg.inflight, seq)
+			continue
+		}
+		if pd.partition == partition && pd.member != owner && p.Timestamp > deadline {
+			return true
+		}
+	}
+	return false
+}
+
+// Tracks a delivery of a message for a partition to a member.
+// Lock should be held.
+func (o *consumer) trackPartitionDelivery(seq uint64, partition int, member string) {
+	pg := o.pgrp
+	if pg == nil || o.cfg.AckPolicy == AckNone {
+		return
+	}
+	if pd, ok := pg.inflight[seq]; ok {
+		pd.partition, pd.member = partition, member
+	} else {
+		pg.inflight[seq] = &partitionDelivery{partition, member}
+	}
+	// Make sure we do not grow unbounded with acknowledged messages.
+	if len(pg.inflight) > 2*len(o.pending)+1024 {
+		o.prunePartitionDeliveries()
+	}
+}
+
+// Removes tracked deliveries that are no longer pending.
+// Lock should be held.
+func (o *consumer) prunePartitionDeliveries() {
+	pg := o.pgrp
+	for seq := range pg.inflight {
+		if _, ok := o.pending[seq]; !ok {
+			delete(pg.inflight, seq)
+		}
+	}
+}
+
+// Returns whether any partition is fenced.
+// Lock should be held.
+func (pg *partitionGroup) isFenced() bool {
+	return slices.Contains(pg.fenced, true)
+}
+
+// Returns the partitions assigned to each member.
+// Lock should be held.
+func (pg *partitionGroup) assignments() map[string][]int {
+	if len(pg.members) == 0 {
+		return nil
+	}
+	assigned := make(map[string][]int, len(pg.members))
+	for p, owner := range pg.owners {
+		if owner != _EMPTY_ {
+			assigned[owner] = append(assigned[owner], p)
+		}
+	}
+	return assigned
+}
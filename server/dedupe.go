@@ -0,0 +1,250 @@
+// Copyright 2025 The NATS Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"fmt"
+	"hash/maphash"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// DedupeOpts enable the deduplication of the core NATS messages published
+// by the clients of an account. Messages with a Nats-Msg-Id header on one of
+// the subjects are dropped by the server they are published to if a message
+// with the same subject and ID was published within the window.
+// JetStream subjects, and subjects of the account's streams, are not
+// deduplicated, since streams deduplicate them and acknowledge the retries.
+type DedupeOpts struct {
+	// Subject filters, all subjects if empty.
+	Subjects []string `json:"subjects,omitempty"`
+	// Time during which message IDs are remembered.
+	Window time.Duration `json:"window,omitempty"`
+	// Maximum number of message IDs remembered. Once reached, the oldest
+	// ones are forgotten before the end of the window.
+	MaxMsgIds int `json:"max_msg_ids,omitempty"`
+}
+
+// DedupeStats counts the messages of an account dropped as duplicates.
+type DedupeStats struct {
+	Msgs  int64 `json:"msgs"`
+	Bytes int64 `json:"bytes"`
+}
+
+const (
+	// Default deduplication window, same as for streams.
+	defaultDedupeWindow = 2 * time.Minute
+	// Default maximum number of message IDs remembered.
+	defaultDedupeMaxMsgIds = 100_000
+	// Prefix of the JetStream subjects, which are not deduplicated.
+	dedupeJSPrefix = "$JS."
+)
+
+func (do *DedupeOpts) clone() *DedupeOpts {
+	if do == nil {
+		return nil
+	}
+	clone := *do
+	clone.Subjects = slices.Clone(do.Subjects)
+	return &clone
+}
+
+func (do *DedupeOpts) validate() error {
+	for _, subj := range do.Subjects {
+		if !IsValidSubject(subj) {
+			return fmt.Errorf("invalid deduplication subject %q", subj)
+		}
+	}
+	if do.Window < 0 {
+		return fmt.Errorf("deduplication window can not be negative")
+	}
+	if do.MaxMsgIds < 0 {
+		return fmt.Errorf("deduplication max message IDs can not be negative")
+	}
+	return nil
+}
+
+// Remembers the IDs of the messages published within the window in two
+// generations of hashes. The current generation becomes the previous one
+// once it is older than the window or holds half of the maximum number of
+// IDs, which bounds the memory used.
+type msgDeduper struct {
+	opts    DedupeOpts
+	seed    maphash.Seed
+	mu      sync.Mutex
+	cur     map[uint64]int64
+	prev    map[uint64]int64
+	started int64
+}
+
+// Returns a deduper for the options, nil if there are none.
+func newMsgDeduper(do *DedupeOpts) *msgDeduper {
+	if do == nil {
+		return nil
+	}
+	d := &msgDeduper{opts: *do.clone(), seed: maphash.MakeSeed()}
+	if d.opts.Window == 0 {
+		d.opts.Window = defaultDedupeWindow
+	}
+	if d.opts.MaxMsgIds == 0 {
+		d.opts.MaxMsgIds = defaultDedupeMaxMsgIds
+	}
+	return d
+}
+
+// Returns true if the subject is one of the deduplicated ones.
+func (d *msgDeduper) matches(subject string) bool {
+	if strings.HasPrefix(subject, dedupeJSPrefix) {
+		return false
+	}
+	if len(d.opts.Subjects) == 0 {
+		return true
+	}
+	for _, filter := range d.opts.Subjects {
+		if subjectIsSubsetMatch(subject, filter) {
+			return true
+		}
+	}
+	return false
+}
+
+// Returns true if a message with the same subject and ID was seen within
+// the window, otherwise remembers it.
+func (d *msgDeduper) isDuplicate(subject, id []byte, now int64) bool {
+	var h maphash.Hash
+	h.SetSeed(d.seed)
+	h.Write(subject)
+	h.WriteByte(' ')
+	h.Write(id)
+	key := h.Sum64()
+
+	window := int64(d.opts.Window)
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.cur == nil || now-d.started >= window || len(d.cur) >= max(d.opts.MaxMsgIds/2, 1) {
+		// The previous generation is dropped entirely once all its IDs expired.
+		if now-d.started >= 2*window {
+			d.prev = nil
+		} else {
+			d.prev = d.cur
+		}
+		d.cur, d.started = make(map[uint64]int64), now
+	}
+	if ts, ok := d.cur[key]; ok && now-ts < window {
+		return true
+	}
+	if ts, ok := d.prev[key]; ok && now-ts < window {
+		return true
+	}
+	d.cur[key] = now
+	return false
+}
+
+// Updates the deduplication of the account. The message IDs remembered
+// are kept if the options did not change.
+func (a *Account) setDedupe(do *DedupeOpts) {
+	a.dedupe = do.clone()
+	if d := a.ddup.Load(); d != nil && do != nil {
+		if nd := newMsgDeduper(do); nd.opts.Window == d.opts.Window &&
+			nd.opts.MaxMsgIds == d.opts.MaxMsgIds && slices.Equal(nd.opts.Subjects, d.opts.Subjects) {
+			return
+		}
+	}
+	a.ddup.Store(newMsgDeduper(do))
+}
+
+// Counters of the messages dropped as duplicates.
+type dedupeCounters struct {
+	msgs  atomic.Int64
+	bytes atomic.Int64
+}
+
+// Returns the deduplication counters, nil if the account never had deduplication.
+func (a *Account) dedupeStats() *DedupeStats {
+	st := &DedupeStats{Msgs: a.ddStats.msgs.Load(), Bytes: a.ddStats.bytes.Load()}
+	if *st == (DedupeStats{}) && a.ddup.Load() == nil {
+		return nil
+	}
+	return st
+}
+
+// Checks if a message published by the client is a duplicate of one
+// published within the deduplication window of the account, in which
+// case it is dropped.
+func (c *client) checkDuplicateMsg(acc *Account, msg []byte) bool {
+	d := acc.ddup.Load()
+	if d == nil || c.pa.hdr <= 0 {
+		return false
+	}
+	id := sliceHeader(JSMsgId, msg[:c.pa.hdr])
+	if len(id) == 0 || !d.matches(bytesToString(c.pa.subject)) {
+		return false
+	}
+	if !d.isDuplicate(c.pa.subject, id, time.Now().UnixNano()) {
+		return false
+	}
+	// Only checked for duplicates, the stream responds to retries of a
+	// message whose acknowledgment was lost.
+	if acc.subjectBoundToStream(bytesToString(c.pa.subject)) {
+		return false
+	}
+	acc.ddStats.msgs.Add(1)
+	acc.ddStats.bytes.Add(int64(c.pa.size))
+	if c.trace {
+		c.Tracef("Dropping duplicate message %q on %q", id, c.pa.subject)
+	}
+	return true
+}
+
+// Returns true if messages on the subject are stored by a stream of the account.
+func (a *Account) subjectBoundToStream(subject string) bool {
+	s := a.srv
+	if s == nil {
+		return false
+	}
+	bound := func(cfg *StreamConfig) bool {
+		if len(cfg.Subjects) == 0 {
+			return subject == cfg.Name
+		}
+		for _, subj := range cfg.Subjects {
+			if subjectIsSubsetMatch(subject, subj) {
+				return true
+			}
+		}
+		return false
+	}
+	js, cc := s.getJetStreamCluster()
+	if js == nil {
+		return false
+	}
+	if cc != nil {
+		js.mu.RLock()
+		defer js.mu.RUnlock()
+		for _, sa := range cc.streams[a.Name] {
+			if sa.Config != nil && bound(sa.Config) {
+				return true
+			}
+		}
+		return false
+	}
+	for _, mset := range a.streams() {
+		if cfg := mset.config(); bound(&cfg) {
+			return true
+		}
+	}
+	return false
+}
@@ -146,6 +146,12 @@ var (
 	// ErrServiceImportAuthorization is returned when a service import is not authorized.
 	ErrServiceImportAuthorization = errors.New("service import not authorized")
 
+	// ErrServiceCircuitOpen is returned when the circuit breaker of a service export is open.
+	ErrServiceCircuitOpen = errors.New("service circuit breaker open")
+
+	// ErrServiceMaxInflight is returned when a service export has reached its maximum in-flight requests.
+	ErrServiceMaxInflight = errors.New("service maximum in-flight requests exceeded")
+
 	// ErrImportFormsCycle is returned when an import would form a cycle.
 	ErrImportFormsCycle = errors.New("import forms a cycle")
 
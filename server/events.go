@@ -61,6 +61,8 @@ const (
 	shutdownEventSubj         = "$SYS.SERVER.%s.SHUTDOWN"
 	clientKickReqSubj         = "$SYS.REQ.SERVER.%s.KICK"
 	clientLDMReqSubj          = "$SYS.REQ.SERVER.%s.LDM"
+	clientDrainReqSubj        = "$SYS.REQ.SERVER.%s.DRAIN"
+	authCachePurgeReqSubj     = "$SYS.REQ.ACCOUNT.%s.AUTH.CACHE.PURGE"
 	authErrorEventSubj        = "$SYS.SERVER.%s.CLIENT.AUTH.ERR"
 	authErrorAccountEventSubj = "$SYS.ACCOUNT.CLIENT.AUTH.ERR"
 	serverStatsSubj           = "$SYS.SERVER.%s.STATSZ"
@@ -68,6 +70,7 @@ const (
 	serverPingReqSubj         = "$SYS.REQ.SERVER.PING.%s"
 	serverStatsPingReqSubj    = "$SYS.REQ.SERVER.PING"             // use $SYS.REQ.SERVER.PING.STATSZ instead
 	serverReloadReqSubj       = "$SYS.REQ.SERVER.%s.RELOAD"        // with server ID
+	serverKeyRotateReqSubj    = "$SYS.REQ.SERVER.%s.KMS.ROTATE"    // with server ID
 	leafNodeConnectEventSubj  = "$SYS.ACCOUNT.%s.LEAFNODE.CONNECT" // for internal use only
 	remoteLatencyEventSubj    = "$SYS.LATENCY.M2.%s"
 	inboxRespSubj             = "$SYS._INBOX.%s.%s"
@@ -224,6 +227,13 @@ type AccountStat struct {
 	Sent          DataStats `json:"sent"`
 	Received      DataStats `json:"received"`
 	SlowConsumers int64     `json:"slow_consumers"`
+	// ExpiredMsgs counts messages discarded from the pending buffers of
+	// clients because they expired, which are not slow consumer drops.
+	ExpiredMsgs int64 `json:"expired_msgs,omitempty"`
+	// RateLimited counts messages exceeding the account or user rate limits.
+	RateLimited *RateLimitStats `json:"rate_limited,omitempty"`
+	// Deduplicated counts messages dropped as duplicates of previous ones.
+	Deduplicated *DedupeStats `json:"deduplicated,omitempty"`
 }
 
 const AccountNumConnsMsgType = "io.nats.server.advisory.v1.account_connections"
@@ -378,6 +388,7 @@ type ServerStats struct {
 	StaleConnections     int64                 `json:"stale_connections,omitempty"`
 	StaleConnectionStats *StaleConnectionStats `json:"stale_connection_stats,omitempty"`
 	StalledClients       int64                 `json:"stalled_clients,omitempty"`
+	ExpiredMsgs          int64                 `json:"expired_msgs,omitempty"`
 	Routes               []*RouteStat          `json:"routes,omitempty"`
 	Gateways             []*GatewayStat        `json:"gateways,omitempty"`
 	ActiveServers        int                   `json:"active_servers,omitempty"`
@@ -461,6 +472,9 @@ func (pm *pubMsg) returnToPool() {
 type serverUpdate struct {
 	seq   uint64
 	ltime time.Time
+	// Cluster and number of client connections, used for rebalancing.
+	cluster string
+	conns   int
 }
 
 // TypedEvent is a event or advisory sent by the server that has nats type hints
@@ -960,6 +974,7 @@ func (s *Server) sendStatsz(subj string) {
 	}
 	m.Stats.StaleConnections = atomic.LoadInt64(&s.staleConnections)
 	m.Stats.StalledClients = atomic.LoadInt64(&s.stalls)
+	m.Stats.ExpiredMsgs = atomic.LoadInt64(&s.expiredMsgs)
 	stcs := &StaleConnectionStats{
 		Clients:  s.NumStaleConnectionsClients(),
 		Routes:   s.NumStaleConnectionsRoutes(),
@@ -1300,6 +1315,10 @@ func (s *Server) initEventTracking() {
 			optz := &RaftzEventOptions{}
 			s.zReq(c, reply, hdr, msg, &optz.EventFilterOptions, optz, func() (any, error) { return s.Raftz(&optz.RaftzOptions), nil })
 		},
+		"LATENCYZ": func(sub *subscription, c *client, _ *Account, subject, reply string, hdr, msg []byte) {
+			optz := &LatencyzEventOptions{}
+			s.zReq(c, reply, hdr, msg, &optz.EventFilterOptions, optz, func() (any, error) { return s.Latencyz(&optz.LatencyzOptions) })
+		},
 	}
 	profilez := func(_ *subscription, c *client, _ *Account, _, rply string, rmsg []byte) {
 		hdr, msg := c.msgParts(rmsg)
@@ -1420,6 +1439,19 @@ func (s *Server) initEventTracking() {
 				}
 			})
 		},
+		"LATENCYZ": func(sub *subscription, c *client, _ *Account, subject, reply string, hdr, msg []byte) {
+			optz := &LatencyzEventOptions{}
+			s.zReq(c, reply, hdr, msg, &optz.EventFilterOptions, optz, func() (any, error) {
+				if acc, err := extractAccount(subject); err != nil {
+					return nil, err
+				} else if acc == "PING" { // Filter PING subject. Happens for server as well. But wildcards are not used
+					return nil, errSkipZreq
+				} else {
+					optz.Account = acc
+					return s.Latencyz(&optz.LatencyzOptions)
+				}
+			})
+		},
 		"CONNS": s.connsRequest,
 	}
 	for name, req := range monAccSrvc {
@@ -1492,6 +1524,24 @@ func (s *Server) initEventTracking() {
 		s.Errorf("Error setting up client LDM service: %v", err)
 		return
 	}
+	// Client connections drain
+	subject = fmt.Sprintf(clientDrainReqSubj, s.info.ID)
+	if _, err := s.sysSubscribe(subject, s.noInlineCallback(s.drainConnsRequest)); err != nil {
+		s.Errorf("Error setting up client drain service: %v", err)
+		return
+	}
+	// Auth callout cache purge
+	subject = fmt.Sprintf(authCachePurgeReqSubj, "*")
+	if _, err := s.sysSubscribe(subject, s.noInlineCallback(s.authCalloutCachePurgeRequest)); err != nil {
+		s.Errorf("Error setting up auth callout cache purge service: %v", err)
+		return
+	}
+	// JetStream data key rotation
+	subject = fmt.Sprintf(serverKeyRotateReqSubj, s.info.ID)
+	if _, err := s.sysSubscribe(subject, s.noInlineCallback(s.jsKeyRotateRequest)); err != nil {
+		s.Errorf("Error setting up JetStream key rotation service: %v", err)
+		return
+	}
 }
 
 // UserInfo returns basic information to a user about bound account and user permissions.
@@ -1716,6 +1766,9 @@ func (s *Server) remoteServerUpdate(sub *subscription, c *client, _ *Account, su
 	s.mu.Lock()
 	if s.isRunning() && s.eventsEnabled() && ssm.Server.ID != s.info.ID {
 		s.updateRemoteServer(&si)
+		if su := s.sys.servers[si.ID]; su != nil {
+			su.cluster, su.conns = si.Cluster, ssm.Stats.Connections
+		}
 	}
 	s.mu.Unlock()
 
@@ -1763,7 +1816,7 @@ func (s *Server) remoteServerUpdate(sub *subscription, c *client, _ *Account, su
 func (s *Server) updateRemoteServer(si *ServerInfo) {
 	su := s.sys.servers[si.ID]
 	if su == nil {
-		s.sys.servers[si.ID] = &serverUpdate{si.Seq, time.Now()}
+		s.sys.servers[si.ID] = &serverUpdate{seq: si.Seq, ltime: time.Now()}
 		s.processNewServer(si)
 	} else {
 		// Should always be going up.
@@ -2047,6 +2100,12 @@ type RaftzEventOptions struct {
 	RaftzOptions
 }
 
+// In the context of system events, LatencyzEventOptions are options passed to Latencyz
+type LatencyzEventOptions struct {
+	LatencyzOptions
+	EventFilterOptions
+}
+
 // returns true if the request does NOT apply to this server and can be ignored.
 // DO NOT hold the server lock when calling this.
 func (s *Server) filterRequest(fOpts *EventFilterOptions) bool {
@@ -2485,6 +2544,7 @@ func (a *Account) statz() *AccountStat {
 		},
 	}
 	slowConsumers := a.stats.slowConsumers
+	expiredMsgs := a.stats.expiredMsgs
 	a.stats.Unlock()
 
 	return &AccountStat{
@@ -2497,6 +2557,9 @@ func (a *Account) statz() *AccountStat {
 		Received:      received,
 		Sent:          sent,
 		SlowConsumers: slowConsumers,
+		ExpiredMsgs:   expiredMsgs,
+		RateLimited:   a.rateLimitStats(),
+		Deduplicated:  a.dedupeStats(),
 	}
 }
 
@@ -2920,6 +2983,7 @@ func (s *Server) remoteLatencyUpdate(sub *subscription, _ *client, _ *Account, s
 	acc.mu.Unlock()
 
 	// Send the metrics
+	acc.recordServiceLatency(si.se, si.acc.Name, m1)
 	s.sendInternalAccountMsg(acc, lsub, m1)
 }
 
@@ -1663,7 +1663,7 @@ func TestSystemAccountWithGateways(t *testing.T) {
 
 	// If this tests fails with wrong number after 10 seconds we may have
 	// added a new initial subscription for the eventing system.
-	checkExpectedSubs(t, 62, sa)
+	checkExpectedSubs(t, 68, sa)
 
 	// Create a client on B and see if we receive the event
 	urlb := fmt.Sprintf("nats://%s:%d", ob.Host, ob.Port)
@@ -3639,6 +3639,95 @@ func TestServerEventsLDMKick(t *testing.T) {
 	}
 }
 
+func TestServerEventsDrainConns(t *testing.T) {
+	s, opts := runTrustedServer(t)
+	defer s.Shutdown()
+
+	acc, akp := createAccount(s)
+	s.setSystemAccount(acc)
+
+	url := fmt.Sprintf("nats://%s:%d", opts.Host, opts.Port)
+	ncs, err := nats.Connect(url, createUserCreds(t, s, akp))
+	require_NoError(t, err)
+	defer ncs.Close()
+
+	_, akp2 := createAccount(s)
+	ldmed := make(chan string, 10)
+	disconnected := make(chan string, 10)
+	connect := func(name string) *nats.Conn {
+		t.Helper()
+		nc, err := nats.Connect(url, createUserCreds(t, s, akp2), nats.Name(name), nats.NoReconnect(),
+			nats.LameDuckModeHandler(func(nc *nats.Conn) { ldmed <- nc.Opts.Name }),
+			nats.DisconnectErrHandler(func(nc *nats.Conn, _ error) { disconnected <- nc.Opts.Name }))
+		require_NoError(t, err)
+		return nc
+	}
+	nc1 := connect("drained")
+	defer nc1.Close()
+	nc2 := connect("kept")
+	defer nc2.Close()
+
+	drain := func(req *DrainConnsOptions) ServerAPIResponse {
+		t.Helper()
+		b, _ := json.Marshal(req)
+		msg, err := ncs.Request(fmt.Sprintf("$SYS.REQ.SERVER.%s.DRAIN", s.ID()), b, time.Second)
+		require_NoError(t, err)
+		var resp ServerAPIResponse
+		require_NoError(t, json.Unmarshal(msg.Data, &resp))
+		return resp
+	}
+
+	// Invalid requests get an error response.
+	msg, err := ncs.Request(fmt.Sprintf("$SYS.REQ.SERVER.%s.DRAIN", s.ID()), []byte(`{"max":"x"}`), time.Second)
+	require_NoError(t, err)
+	var resp ServerAPIResponse
+	require_NoError(t, json.Unmarshal(msg.Data, &resp))
+	require_True(t, resp.Error != nil)
+	require_Equal(t, resp.Error.Code, http.StatusBadRequest)
+
+	// At least one filter is required.
+	resp = drain(&DrainConnsOptions{GracePeriod: 250 * time.Millisecond})
+	require_True(t, resp.Error != nil)
+	require_Contains(t, resp.Error.Description, "is required")
+
+	resp = drain(&DrainConnsOptions{Name: "drained", GracePeriod: 250 * time.Millisecond})
+	require_True(t, resp.Error == nil)
+	b, _ := json.Marshal(resp.Data)
+	var dr DrainConnsResponse
+	require_NoError(t, json.Unmarshal(b, &dr))
+	require_Equal(t, dr.GracePeriod, 250*time.Millisecond)
+	require_Len(t, len(dr.Conns), 1)
+	require_Equal(t, dr.Conns[0].Name, "drained")
+	require_True(t, dr.Conns[0].Notified)
+
+	// The client is notified, then closed after the grace period.
+	start := time.Now()
+	select {
+	case name := <-ldmed:
+		require_Equal(t, name, "drained")
+	case <-time.After(time.Second):
+		t.Fatal("Timeout waiting for the connection to receive the LDM signal")
+	}
+	select {
+	case name := <-disconnected:
+		require_Equal(t, name, "drained")
+		require_True(t, time.Since(start) >= 200*time.Millisecond)
+	case <-time.After(2 * time.Second):
+		t.Fatal("Timeout waiting for the client to get disconnected")
+	}
+	checkClosedConns(t, s, 1, time.Second)
+	conns := s.closedClients()
+	require_Equal(t, conns[len(conns)-1].Reason, Drained.String())
+
+	// Other connections were not affected.
+	select {
+	case name := <-disconnected:
+		t.Fatalf("Unexpected disconnect of %q", name)
+	case <-time.After(100 * time.Millisecond):
+	}
+	require_True(t, nc2.IsConnected())
+}
+
 func Benchmark_GetHash(b *testing.B) {
 	b.StopTimer()
 	// Get 100 random names
@@ -42,6 +42,7 @@ import (
 	"time"
 
 	"github.com/klauspost/compress/s2"
+	"github.com/klauspost/compress/zstd"
 	"github.com/minio/highwayhash"
 	"github.com/nats-io/nats-server/v2/server/ats"
 	"github.com/nats-io/nats-server/v2/server/avl"
@@ -72,6 +73,10 @@ type FileStoreConfig struct {
 	Cipher StoreCipher
 	// Compression is the algorithm to use when compressing.
 	Compression StoreCompression
+	// CompressionLevel is the level to use when compressing, if supported by the algorithm.
+	CompressionLevel int
+	// CompressionDict is an optional dictionary to use when compressing, if supported by the algorithm.
+	CompressionDict []byte
 
 	// Internal reference to our server.
 	srv *Server
@@ -109,6 +114,7 @@ type StoreCompression uint8
 const (
 	NoCompression StoreCompression = iota
 	S2Compression
+	ZstdCompression
 )
 
 func (alg StoreCompression) String() string {
@@ -117,6 +123,8 @@ func (alg StoreCompression) String() string {
 		return "None"
 	case S2Compression:
 		return "S2"
+	case ZstdCompression:
+		return "Zstd"
 	default:
 		return "Unknown StoreCompression"
 	}
@@ -127,6 +135,8 @@ func (alg StoreCompression) MarshalJSON() ([]byte, error) {
 	switch alg {
 	case S2Compression:
 		str = "s2"
+	case ZstdCompression:
+		str = "zstd"
 	case NoCompression:
 		str = "none"
 	default:
@@ -143,6 +153,8 @@ func (alg *StoreCompression) UnmarshalJSON(b []byte) error {
 	switch str {
 	case "s2":
 		*alg = S2Compression
+	case "zstd":
+		*alg = ZstdCompression
 	case "none":
 		*alg = NoCompression
 	default:
@@ -180,6 +192,8 @@ type fileStore struct {
 	rmcb        StorageRemoveMsgHandler
 	pmsgcb      ProcessJetStreamMsgHandler
 	ageChk      *time.Timer
+	cmpChk      *time.Timer
+	ctombs      *avl.SequenceSet
 	syncTmr     *time.Timer
 	cfg         FileStreamInfo
 	fcfg        FileStoreConfig
@@ -337,6 +351,11 @@ const (
 	JetStreamMetaFileSum = "meta.sum"
 	JetStreamMetaFileKey = "meta.key"
 
+	// Wrapped data key for streams that have their own encryption key.
+	JetStreamStreamKeyFile = "stream.key"
+	// Suffix of a new stream data key while the stream key is being rotated.
+	streamKeyPendingSuffix = ".new"
+
 	// This is the full snapshotted state for the stream.
 	streamStreamStateFile = "index.db"
 
@@ -575,6 +594,9 @@ func newFileStoreWithCreated(fcfg FileStoreConfig, cfg StreamConfig, created tim
 		fs.enforceMsgPerSubjectLimit(false)
 	}
 
+	// Start compaction by subject if configured.
+	fs.startCompactChk()
+
 	// Grab first sequence for check below while we have lock.
 	firstSeq := fs.state.FirstSeq
 	fs.mu.Unlock()
@@ -660,17 +682,25 @@ func (fs *fileStore) UpdateConfig(cfg *StreamConfig) error {
 	old_cfg := fs.cfg
 	// The reference story has changed here, so this full msg block lock
 	// may not be needed.
+	old_fcfg := fs.fcfg
 	fs.lockAllMsgBlocks()
 	fs.cfg = new_cfg
+	fs.setCompressionConfig(cfg)
 	fs.unlockAllMsgBlocks()
 	if err := fs.writeStreamMeta(); err != nil {
 		fs.lockAllMsgBlocks()
 		fs.cfg = old_cfg
+		fs.fcfg = old_fcfg
 		fs.unlockAllMsgBlocks()
 		fs.mu.Unlock()
 		return err
 	}
 
+	// Migrate existing blocks in the background if the compression changed.
+	if fs.fcfg.Compression != old_fcfg.Compression {
+		fs.recompressBlocks()
+	}
+
 	// Create or delete the THW if needed.
 	if cfg.AllowMsgTTL && fs.ttls == nil {
 		fs.recoverTTLState()
@@ -701,6 +731,14 @@ func (fs *fileStore) UpdateConfig(cfg *StreamConfig) error {
 		fs.enforceMsgPerSubjectLimit(true)
 	}
 
+	// Do compaction timer.
+	if fs.cfg.Compaction == nil {
+		clearTimer(&fs.cmpChk)
+		fs.ctombs = nil
+	} else {
+		fs.startCompactChk()
+	}
+
 	if lmb := fs.lmb; lmb != nil {
 		// Enable/disable async flush depending on if it's supported and already initialized.
 		supportsAsyncFlush := !fs.fcfg.SyncAlways && cfg.Replicas > 1
@@ -896,6 +934,129 @@ func (fs *fileStore) setupAEK() error {
 	return nil
 }
 
+// Re-wraps the key file fn, sealed by a key encryption key derived from oldprf,
+// with one derived from prf. The nonce is kept since block ciphers use it too.
+// Files already wrapped for prf are left as is, so this is safe to repeat.
+func rewrapKeyFile(fn, context string, sc StoreCipher, oldprf, prf keyGen) error {
+	ekey, err := os.ReadFile(fn)
+	if err != nil {
+		return err
+	}
+	if len(ekey) < minBlkKeySize {
+		return errBadKeySize
+	}
+	genKEK := func(prf keyGen) (cipher.AEAD, error) {
+		rb, err := prf([]byte(context))
+		if err != nil {
+			return nil, err
+		}
+		return genEncryptionKey(sc, rb)
+	}
+	kek, err := genKEK(prf)
+	if err != nil {
+		return err
+	}
+	ns := kek.NonceSize()
+	nonce := ekey[:ns]
+	if _, err := kek.Open(nil, nonce, ekey[ns:], nil); err == nil {
+		return nil
+	}
+	okek, err := genKEK(oldprf)
+	if err != nil {
+		return err
+	}
+	seed, err := okek.Open(nil, nonce, ekey[ns:], nil)
+	if err != nil {
+		return fmt.Errorf("could not unwrap key file %q: %w", fn, err)
+	}
+	buf := kek.Seal(append(make([]byte, 0, len(ekey)), nonce...), nonce, seed, nil)
+	tmp := fn + blkTmpSuffix
+	if err := writeFileWithSync(tmp, buf, defaultFilePerms); err != nil {
+		return err
+	}
+	return os.Rename(tmp, fn)
+}
+
+// Re-wraps all key files of the stream in sdir, including the ones of its
+// message blocks and consumers, moving them from oldprf to prf.
+func rewrapStreamKeyFiles(sdir, name string, sc StoreCipher, oldprf, prf keyGen) error {
+	err := rewrapKeyFile(filepath.Join(sdir, JetStreamMetaFileKey), name, sc, oldprf, prf)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	mdir := filepath.Join(sdir, msgDir)
+	fis, _ := os.ReadDir(mdir)
+	for _, fi := range fis {
+		var index uint32
+		if !strings.HasSuffix(fi.Name(), ".key") {
+			continue
+		}
+		if n, err := fmt.Sscanf(fi.Name(), keyScan, &index); err != nil || n != 1 {
+			continue
+		}
+		context := fmt.Sprintf("%s:%d", name, index)
+		if err := rewrapKeyFile(filepath.Join(mdir, fi.Name()), context, sc, oldprf, prf); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	odir := filepath.Join(sdir, consumerDir)
+	ofis, _ := os.ReadDir(odir)
+	for _, fi := range ofis {
+		fn := filepath.Join(odir, fi.Name(), JetStreamMetaFileKey)
+		if err := rewrapKeyFile(fn, name+tsep+fi.Name(), sc, oldprf, prf); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+// Moves the stream to a new data key, given wrapped in wkey, from which prf
+// derives the key encryption keys. All key files are re-wrapped while the
+// message blocks are left untouched. The new key is staged next to the current
+// one until all key files are re-wrapped, so an interrupted rotation can be
+// completed on recovery.
+func (fs *fileStore) rotateStreamKey(prf keyGen, wkey []byte) error {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	if fs.prf == nil {
+		return errNoEncryption
+	}
+	sdir, sc := fs.fcfg.StoreDir, fs.fcfg.Cipher
+	fn := filepath.Join(sdir, JetStreamStreamKeyFile)
+	pending := fn + streamKeyPendingSuffix
+	if err := writeFileWithSync(pending, wkey, defaultFilePerms); err != nil {
+		return err
+	}
+	if err := rewrapStreamKeyFiles(sdir, fs.cfg.Name, sc, fs.prf, prf); err != nil {
+		// Move back the ones we already did.
+		rewrapStreamKeyFiles(sdir, fs.cfg.Name, sc, prf, fs.prf)
+		os.Remove(pending)
+		return err
+	}
+	if err := os.Rename(pending, fn); err != nil {
+		return err
+	}
+	fs.prf = prf
+	return nil
+}
+
+// Overwrites the stream data key, if any, before it is removed with the stream.
+// Without it the stream can no longer be decrypted.
+func shredStreamKey(sdir string) {
+	fn := filepath.Join(sdir, JetStreamStreamKeyFile)
+	for _, fn := range []string{fn, fn + streamKeyPendingSuffix} {
+		fi, err := os.Stat(fn)
+		if err != nil {
+			continue
+		}
+		buf := make([]byte, fi.Size())
+		rand.Read(buf)
+		writeFileWithSync(fn, buf, defaultFilePerms)
+		os.Remove(fn)
+	}
+}
+
 // Write out meta and the checksum.
 // Lock should be held.
 func (fs *fileStore) writeStreamMeta() error {
@@ -4311,6 +4472,11 @@ func (fs *fileStore) storeRawMsg(subj string, hdr, msg []byte, seq uint64, ts, t
 		return err
 	}
 
+	// Track tombstones for compaction once the existing ones have been found.
+	if fs.ctombs != nil && isCompactionTombstone(hdr) {
+		fs.ctombs.Insert(seq)
+	}
+
 	// Adjust top level tracking of per subject msg counts.
 	if len(subj) > 0 && fs.psim != nil {
 		index := fs.lmb.index
@@ -5185,7 +5351,7 @@ func (mb *msgBlock) compactWithFloor(floor uint64) {
 
 	// Handle compression
 	if mb.cmp != NoCompression && len(nbuf) > 0 {
-		cbuf, err := mb.cmp.Compress(nbuf)
+		cbuf, err := mb.cmp.compress(nbuf, mb.fs.compressionOpts())
 		if err != nil {
 			return
 		}
@@ -5981,6 +6147,110 @@ func (fs *fileStore) expireMsgs() {
 	}
 }
 
+// Will start the compaction timer if configured.
+// Lock should be held.
+func (fs *fileStore) startCompactChk() {
+	if fs.cmpChk != nil || fs.cfg.Compaction == nil {
+		return
+	}
+	fs.cmpChk = time.AfterFunc(fs.cfg.Compaction.interval(), fs.compactBySubject)
+}
+
+// Will compact messages older than the compaction lag down to the last message
+// per subject. Only the subjects with more than one message in the per subject
+// index are visited. Tombstones are tracked when stored, and removed once they
+// are older than the compaction lag plus the delete retention.
+func (fs *fileStore) compactBySubject() {
+	var smv StoreMsg
+
+	fs.mu.Lock()
+	cc := fs.cfg.Compaction
+	if cc == nil {
+		fs.mu.Unlock()
+		return
+	}
+	// Tombstones stored before the first pass, e.g. before a restart, are found once.
+	scan := fs.ctombs == nil
+	if scan {
+		fs.ctombs = &avl.SequenceSet{}
+	}
+	var subjs []string
+	fs.psim.IterFast(func(subj []byte, psi *psi) bool {
+		if psi.total > 1 {
+			subjs = append(subjs, string(subj))
+		}
+		return true
+	})
+	fs.mu.Unlock()
+
+	if scan {
+		var seq uint64
+		for sm, nseq, _ := fs.LoadNextMsg(fwcs, true, 0, &smv); sm != nil; sm, nseq, _ = fs.LoadNextMsg(fwcs, true, seq+1, &smv) {
+			if seq = nseq; isCompactionTombstone(sm.hdr) {
+				fs.mu.Lock()
+				fs.ctombs.Insert(seq)
+				fs.mu.Unlock()
+			}
+		}
+	}
+
+	// New messages can only move the last sequence up, so a stale one is safe.
+	for _, subj := range subjs {
+		sm, err := fs.LoadLastMsg(subj, &smv)
+		if err != nil || sm == nil {
+			continue
+		}
+		last := sm.seq
+		fs.mu.Lock()
+		seq, _ := fs.firstSeqForSubj(subj)
+		fs.mu.Unlock()
+		for seq > 0 && seq < last {
+			sm, nseq, err := fs.LoadNextMsg(subj, false, seq, &smv)
+			if err != nil || sm == nil || nseq >= last || time.Since(time.Unix(0, sm.ts)) < cc.Lag {
+				break
+			}
+			fs.mu.Lock()
+			fs.removeMsgViaLimits(nseq)
+			fs.mu.Unlock()
+			seq = nseq + 1
+		}
+	}
+
+	fs.mu.RLock()
+	var tombs []uint64
+	if fs.ctombs != nil {
+		fs.ctombs.Range(func(seq uint64) bool {
+			tombs = append(tombs, seq)
+			return true
+		})
+	}
+	fs.mu.RUnlock()
+	for _, seq := range tombs {
+		sm, err := fs.LoadMsg(seq, &smv)
+		if err == nil && sm != nil && isCompactionTombstone(sm.hdr) {
+			if time.Since(time.Unix(0, sm.ts)) < cc.Lag+cc.DeleteRetention {
+				break
+			}
+			fs.mu.Lock()
+			fs.removeMsgViaLimits(seq)
+			fs.mu.Unlock()
+		}
+		fs.mu.Lock()
+		if fs.ctombs != nil {
+			fs.ctombs.Delete(seq)
+		}
+		fs.mu.Unlock()
+	}
+
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	if fs.closed || fs.closing || fs.cfg.Compaction == nil {
+		clearTimer(&fs.cmpChk)
+	} else if fs.cmpChk != nil {
+		fs.cmpChk.Reset(fs.cfg.Compaction.interval())
+	}
+}
+
 func (fs *fileStore) shouldProcessSdm(seq uint64, subj string) (bool, bool) {
 	fs.mu.Lock()
 	defer fs.mu.Unlock()
@@ -6438,6 +6708,40 @@ func (fs *fileStore) writeTombstoneNoFlush(seq uint64, ts int64) error {
 	return lmb.writeTombstoneNoFlush(seq, ts)
 }
 
+// Sets the compression settings from the stream config.
+// Lock should be held for the store and all message blocks.
+func (fs *fileStore) setCompressionConfig(cfg *StreamConfig) {
+	fs.fcfg.Compression = cfg.Compression
+	fs.fcfg.CompressionLevel, fs.fcfg.CompressionDict = 0, nil
+	if co := cfg.CompressionOpts; co != nil {
+		fs.fcfg.CompressionLevel, fs.fcfg.CompressionDict = co.Level, co.Dictionary
+	}
+}
+
+// Rewrites all sealed message blocks in the background with the current
+// compression algorithm. The last block is handled when it is sealed.
+// Lock should be held.
+func (fs *fileStore) recompressBlocks() {
+	if len(fs.blks) < 2 {
+		return
+	}
+	blks := slices.Clone(fs.blks[:len(fs.blks)-1])
+	go func() {
+		for _, mb := range blks {
+			if fs.isClosed() {
+				return
+			}
+			mb.mu.Lock()
+			if !mb.closed {
+				if err := mb.recompressOnDiskIfNeeded(); err != nil && !errors.Is(err, os.ErrNotExist) {
+					fs.warn("Error recompressing message block %d: %v", mb.index, err)
+				}
+			}
+			mb.mu.Unlock()
+		}
+	}()
+}
+
 // Lock should be held.
 func (mb *msgBlock) recompressOnDiskIfNeeded() error {
 	alg := mb.fs.fcfg.Compression
@@ -6466,7 +6770,8 @@ func (mb *msgBlock) recompressOnDiskIfNeeded() error {
 	}
 
 	meta := &CompressionInfo{}
-	if _, err := meta.UnmarshalMetadata(origBuf); err != nil {
+	n, err := meta.UnmarshalMetadata(origBuf)
+	if err != nil {
 		// An error is only returned here if there's a problem with parsing
 		// the metadata. If the file has no metadata at all, no error is
 		// returned and the algorithm defaults to no compression.
@@ -6478,16 +6783,16 @@ func (mb *msgBlock) recompressOnDiskIfNeeded() error {
 		// to ensure we don't do unnecessary work in case something asked us
 		// to recompress an already compressed block with the same algorithm.
 		return nil
-	} else if alg != NoCompression {
+	} else if n > 0 {
 		// The block is already compressed using some algorithm, so we need
 		// to decompress the block using the existing algorithm before we can
-		// recompress it with the new one.
-		if origBuf, err = meta.Algorithm.Decompress(origBuf); err != nil {
+		// recompress it with the new one, or write it out uncompressed.
+		if origBuf, err = meta.Algorithm.decompress(origBuf[n:], mb.fs.compressionOpts()); err != nil {
 			return fmt.Errorf("failed to decompress original block: %w", err)
 		}
 	}
 
-	return mb.atomicOverwriteFile(origBuf, true)
+	return mb.atomicOverwriteFile(origBuf, alg != NoCompression)
 }
 
 // Lock should be held.
@@ -6524,7 +6829,7 @@ func (mb *msgBlock) atomicOverwriteFile(buf []byte, allowCompress bool) error {
 		// The original buffer at this point is uncompressed, so we will now compress
 		// it if needed. Note that if the selected algorithm is NoCompression, the
 		// Compress function will just return the input buffer unmodified.
-		if buf, err = alg.Compress(buf); err != nil {
+		if buf, err = alg.compress(buf, mb.fs.compressionOpts()); err != nil {
 			return errorCleanup(fmt.Errorf("failed to compress block: %w", err))
 		}
 
@@ -6590,7 +6895,7 @@ func (mb *msgBlock) decompressIfNeeded(buf []byte) ([]byte, error) {
 		// are compressed. If by any chance the metadata claims that the
 		// block is uncompressed, then the input slice is just returned
 		// unmodified.
-		return meta.Algorithm.Decompress(buf[n:])
+		return meta.Algorithm.decompress(buf[n:], mb.fs.compressionOpts())
 	}
 }
 
@@ -8745,7 +9050,7 @@ func (fs *fileStore) compact(seq uint64) (uint64, error) {
 			}
 			// Recompress if necessary (smb.cmp contains the algorithm used when
 			// the block was loaded from disk, or defaults to NoCompression if not)
-			if nbuf, err = smb.cmp.Compress(nbuf); err != nil {
+			if nbuf, err = smb.cmp.compress(nbuf, smb.fs.compressionOpts()); err != nil {
 				goto SKIP
 			}
 			<-dios
@@ -9548,6 +9853,7 @@ func (fs *fileStore) closeAllMsgBlocks(sync bool) {
 func (fs *fileStore) Delete(inline bool) error {
 	if fs.isClosed() {
 		// Always attempt to remove since we could have been closed beforehand.
+		shredStreamKey(fs.fcfg.StoreDir)
 		os.RemoveAll(fs.fcfg.StoreDir)
 		// Since we did remove, if we did have anything remaining make sure to
 		// call into any storage updates that had been registered.
@@ -9593,6 +9899,8 @@ func (fs *fileStore) Delete(inline bool) error {
 	if err := os.Remove(filepath.Join(fs.fcfg.StoreDir, JetStreamMetaFile)); err != nil {
 		return err
 	}
+	// If the stream has its own data key, destroy it first.
+	shredStreamKey(fs.fcfg.StoreDir)
 	// Now move into different directory with "." prefix.
 	ndir := filepath.Join(filepath.Dir(fs.fcfg.StoreDir), tsep+filepath.Base(fs.fcfg.StoreDir))
 	if err := os.Rename(fs.fcfg.StoreDir, ndir); err != nil {
@@ -9973,6 +10281,7 @@ func (fs *fileStore) stop(delete, writeState bool) error {
 
 	fs.cancelSyncTimer()
 	fs.cancelAgeChk()
+	clearTimer(&fs.cmpChk)
 
 	// Release the state flusher loop.
 	if fs.qch != nil {
@@ -11547,7 +11856,26 @@ func (c *CompressionInfo) UnmarshalMetadata(b []byte) (int, error) {
 	return 4 + n, nil
 }
 
+// compressionOpts holds the optional settings used when compressing and
+// decompressing blocks with algorithms that support them.
+type compressionOpts struct {
+	level int
+	dict  []byte
+}
+
+// Returns the compression options for this store.
+func (fs *fileStore) compressionOpts() *compressionOpts {
+	if fs.fcfg.CompressionLevel == 0 && len(fs.fcfg.CompressionDict) == 0 {
+		return nil
+	}
+	return &compressionOpts{fs.fcfg.CompressionLevel, fs.fcfg.CompressionDict}
+}
+
 func (alg StoreCompression) Compress(buf []byte) ([]byte, error) {
+	return alg.compress(buf, nil)
+}
+
+func (alg StoreCompression) compress(buf []byte, opts *compressionOpts) ([]byte, error) {
 	if len(buf) < checksumSize {
 		return nil, fmt.Errorf("uncompressed buffer is too short")
 	}
@@ -11559,6 +11887,19 @@ func (alg StoreCompression) Compress(buf []byte) ([]byte, error) {
 		return buf, nil
 	case S2Compression:
 		writer = s2.NewWriter(&output)
+	case ZstdCompression:
+		zopts := []zstd.EOption{zstd.WithEncoderConcurrency(1)}
+		if opts != nil && opts.level > 0 {
+			zopts = append(zopts, zstd.WithEncoderLevel(zstd.EncoderLevelFromZstd(opts.level)))
+		}
+		if opts != nil && len(opts.dict) > 0 {
+			zopts = append(zopts, zstd.WithEncoderDict(opts.dict))
+		}
+		zw, err := zstd.NewWriter(&output, zopts...)
+		if err != nil {
+			return nil, fmt.Errorf("error creating compression writer: %w", err)
+		}
+		writer = zw
 	default:
 		return nil, fmt.Errorf("compression algorithm not known")
 	}
@@ -11588,6 +11929,10 @@ func (alg StoreCompression) Compress(buf []byte) ([]byte, error) {
 }
 
 func (alg StoreCompression) Decompress(buf []byte) ([]byte, error) {
+	return alg.decompress(buf, nil)
+}
+
+func (alg StoreCompression) decompress(buf []byte, opts *compressionOpts) ([]byte, error) {
 	if len(buf) < checksumSize {
 		return nil, fmt.Errorf("compressed buffer is too short")
 	}
@@ -11600,6 +11945,16 @@ func (alg StoreCompression) Decompress(buf []byte) ([]byte, error) {
 		return buf, nil
 	case S2Compression:
 		reader = io.NopCloser(s2.NewReader(input))
+	case ZstdCompression:
+		zopts := []zstd.DOption{zstd.WithDecoderConcurrency(1)}
+		if opts != nil && len(opts.dict) > 0 {
+			zopts = append(zopts, zstd.WithDecoderDicts(opts.dict))
+		}
+		zr, err := zstd.NewReader(input, zopts...)
+		if err != nil {
+			return nil, fmt.Errorf("error creating compression reader: %w", err)
+		}
+		reader = zr.IOReadCloser()
 	default:
 		return nil, fmt.Errorf("compression algorithm not known")
 	}
@@ -58,6 +58,8 @@ func testFileStoreAllPermutations(t *testing.T, fn func(t *testing.T, fcfg FileS
 		{Cipher: AES, Compression: S2Compression},
 		{Cipher: ChaCha, Compression: NoCompression},
 		{Cipher: ChaCha, Compression: S2Compression},
+		{Cipher: NoCipher, Compression: ZstdCompression},
+		{Cipher: AES, Compression: ZstdCompression},
 	} {
 		subtestName := fmt.Sprintf("%s-%s", fcfg.Cipher, fcfg.Compression)
 		t.Run(subtestName, func(t *testing.T) {
@@ -10424,3 +10426,40 @@ func TestFileStoreMessageScheduleEncodeDecode(t *testing.T) {
 		require_Equal(t, sched.seq, nsched.seq)
 	}
 }
+
+func TestFileStoreCompactionTombstonesAfterRestart(t *testing.T) {
+	sd := t.TempDir()
+	cfg := StreamConfig{
+		Name:       "zzz",
+		Subjects:   []string{"kv.>"},
+		Storage:    FileStorage,
+		Compaction: &StreamCompaction{Lag: time.Hour},
+	}
+	fs, err := newFileStore(FileStoreConfig{StoreDir: sd}, cfg)
+	require_NoError(t, err)
+
+	tomb := []byte(fmt.Sprintf("NATS/1.0\r\n%s: %s\r\n\r\n", KVOperation, KVOperationValueDelete))
+	_, _, err = fs.StoreMsg("kv.a", nil, []byte("v1"), 0)
+	require_NoError(t, err)
+	_, _, err = fs.StoreMsg("kv.a", tomb, nil, 0)
+	require_NoError(t, err)
+	_, _, err = fs.StoreMsg("kv.b", nil, []byte("v1"), 0)
+	require_NoError(t, err)
+	require_NoError(t, fs.Stop())
+
+	// The tombstone stored before the restart is found and removed.
+	cfg.Compaction = &StreamCompaction{Lag: 50 * time.Millisecond}
+	fs, err = newFileStore(FileStoreConfig{StoreDir: sd}, cfg)
+	require_NoError(t, err)
+	defer fs.Stop()
+
+	checkFor(t, 2*time.Second, 50*time.Millisecond, func() error {
+		if state := fs.State(); state.Msgs != 1 {
+			return fmt.Errorf("expected 1 msg, got %d", state.Msgs)
+		}
+		return nil
+	})
+	sm, err := fs.LoadLastMsg("kv.b", nil)
+	require_NoError(t, err)
+	require_Equal(t, string(sm.msg), "v1")
+}
@@ -523,7 +523,7 @@ func (s *Server) startGatewayAcceptLoop() {
 
 	s.mu.Lock()
 	hp := net.JoinHostPort(opts.Gateway.Host, strconv.Itoa(port))
-	l, e := natsListen("tcp", hp)
+	l, e := s.listen(gatewayListenerName, hp, true)
 	s.gatewayListenerErr = e
 	if e != nil {
 		s.mu.Unlock()
@@ -3005,7 +3005,7 @@ func (c *client) handleGatewayReply(msg []byte) (processed bool) {
 	pacache = append(pacache, c.pa.subject...)
 	c.pa.pacache = pacache
 
-	acc, r := c.getAccAndResultFromCache()
+	acc, r := c.getAccAndResultFromCache(nil)
 	if acc == nil {
 		typeConn := "routed"
 		if c.kind == GATEWAY {
@@ -3110,7 +3110,7 @@ func (c *client) processInboundGatewayMsg(msg []byte) {
 		return
 	}
 
-	acc, r := c.getAccAndResultFromCache()
+	acc, r := c.getAccAndResultFromCache(msg)
 	if acc == nil {
 		c.Debugf("Unknown account %q for gateway message on subject: %q", c.pa.account, c.pa.subject)
 		c.srv.gatewayHandleAccountNoInterest(c, c.pa.account)
@@ -15,6 +15,7 @@ package server
 
 import (
 	"bytes"
+	"cmp"
 	"crypto/hmac"
 	"crypto/sha256"
 	"encoding/binary"
@@ -252,7 +253,8 @@ func (s *Server) jsKeyGen(jsKey, info string) keyGen {
 }
 
 // Decode the encrypted metafile.
-func (s *Server) decryptMeta(sc StoreCipher, ekey, buf []byte, acc, context string) ([]byte, bool, error) {
+// The stream directory sdir is used to find the stream data key, if any.
+func (s *Server) decryptMeta(sc StoreCipher, ekey, buf []byte, acc, sdir, stream, context string) ([]byte, bool, error) {
 	if len(ekey) < minMetaKeySize {
 		return nil, false, errBadKeySize
 	}
@@ -268,7 +270,11 @@ func (s *Server) decryptMeta(sc StoreCipher, ekey, buf []byte, acc, context stri
 		StoreCipher
 	}
 	var prfs []prfWithCipher
-	if prf := s.jsKeyGen(s.getOpts().JetStreamKey, acc); prf == nil {
+	key, err := s.jsStreamEncryptionKey(acc, sdir, stream, false)
+	if err != nil {
+		return nil, false, err
+	}
+	if prf := s.jsKeyGen(key, acc); prf == nil {
 		return nil, false, errNoEncryption
 	} else {
 		// First of all, try our current encryption keys with both
@@ -443,6 +449,9 @@ func (s *Server) enableJetStream(cfg JetStreamConfig) error {
 	if err := s.initJetStreamEncryption(); err != nil {
 		return err
 	}
+	if err := s.initJetStreamKMS(cfg.StoreDir); err != nil {
+		return err
+	}
 
 	// JetStream is an internal service so we need to make sure we have a system account.
 	// This system account will export the JetStream service endpoints.
@@ -473,9 +482,12 @@ func (s *Server) enableJetStream(cfg JetStreamConfig) error {
 		s.Noticef("  Domain:          %s", cfg.Domain)
 	}
 
-	if ek := opts.JetStreamKey; ek != _EMPTY_ {
+	if s.jsEncryptionEnabled() {
 		s.Noticef("  Encryption:      %s", opts.JetStreamCipher)
 	}
+	if km := s.jsKMS.Load(); km != nil {
+		s.Noticef("  KMS Provider:    %s", cmp.Or(km.opts.Provider, "custom"))
+	}
 	if opts.JetStreamTpm.KeysFile != _EMPTY_ {
 		s.Noticef("  TPM File:        %q, Pcr: %d", opts.JetStreamTpm.KeysFile,
 			opts.JetStreamTpm.Pcr)
@@ -1265,7 +1277,7 @@ func (a *Account) EnableJetStream(limits map[string]JetStreamAccountLimits) erro
 	var ipstreams []*stream
 
 	// Remember if we should be encrypted and what cipher we think we should use.
-	encrypted := s.getOpts().JetStreamKey != _EMPTY_
+	encrypted := s.jsEncryptionEnabled()
 	plaintext := true
 	sc := s.getOpts().JetStreamCipher
 
@@ -1313,6 +1325,12 @@ func (a *Account) EnableJetStream(limits map[string]JetStreamAccountLimits) erro
 		// Track if we are converting ciphers.
 		var convertingCiphers bool
 
+		// Complete any interrupted rotation of the stream key before reading the key files.
+		if _, err := s.jsStreamEncryptionKey(a.Name, mdir, fi.Name(), false); err != nil {
+			s.Warnf("  Error recovering stream encryption key: %v", err)
+			continue
+		}
+
 		// Check if we are encrypted.
 		keyFile := filepath.Join(mdir, JetStreamMetaFileKey)
 		keyBuf, err := os.ReadFile(keyFile)
@@ -1324,7 +1342,7 @@ func (a *Account) EnableJetStream(limits map[string]JetStreamAccountLimits) erro
 			}
 			// Decode the buffer before proceeding.
 			var nbuf []byte
-			nbuf, convertingCiphers, err = s.decryptMeta(sc, keyBuf, buf, a.Name, fi.Name())
+			nbuf, convertingCiphers, err = s.decryptMeta(sc, keyBuf, buf, a.Name, mdir, fi.Name(), fi.Name())
 			if err != nil {
 				s.Warnf("  Error decrypting our stream metafile: %v", err)
 				continue
@@ -1548,7 +1566,7 @@ func (a *Account) EnableJetStream(limits map[string]JetStreamAccountLimits) erro
 				s.Debugf("  Consumer metafile is encrypted, reading encrypted keyfile")
 				// Decode the buffer before proceeding.
 				ctxName := e.mset.name() + tsep + ofi.Name()
-				nbuf, _, err := s.decryptMeta(sc, key, buf, a.Name, ctxName)
+				nbuf, _, err := s.decryptMeta(sc, key, buf, a.Name, filepath.Dir(e.odir), e.mset.name(), ctxName)
 				if err != nil {
 					s.Warnf("  Error decrypting our consumer metafile: %v", err)
 					continue
@@ -101,6 +101,24 @@ const (
 	JSApiStreamPurge  = "$JS.API.STREAM.PURGE.*"
 	JSApiStreamPurgeT = "$JS.API.STREAM.PURGE.%s"
 
+	// JSApiStreamErase is the endpoint to securely erase all messages of a stream
+	// matching a subject filter, and optionally header values.
+	// Will return JSON response.
+	JSApiStreamErase  = "$JS.API.STREAM.ERASE.*"
+	JSApiStreamEraseT = "$JS.API.STREAM.ERASE.%s"
+
+	// JSApiStreamKeyRotate is the endpoint to rotate the encryption key of a stream.
+	// Will return JSON response.
+	JSApiStreamKeyRotate  = "$JS.API.STREAM.KEY.ROTATE.*"
+	JSApiStreamKeyRotateT = "$JS.API.STREAM.KEY.ROTATE.%s"
+
+	// JSApiStreamKeyShred is the endpoint to delete a stream that has an encryption
+	// key of its own. It is a stream delete that is only allowed for such streams,
+	// so that once the key is destroyed with the stream, its data can no longer be
+	// decrypted, even from copies of its files. Will return a stream delete JSON response.
+	JSApiStreamKeyShred  = "$JS.API.STREAM.KEY.SHRED.*"
+	JSApiStreamKeyShredT = "$JS.API.STREAM.KEY.SHRED.%s"
+
 	// JSApiStreamSnapshot is the endpoint to snapshot streams.
 	// Will return a stream of chunks with a nil chunk as EOF to
 	// the deliver subject. Caller should respond to each chunk
@@ -312,6 +330,12 @@ const (
 	// JSAdvisoryStreamBatchAbandonedPre notification that a stream's batch was abandoned.
 	JSAdvisoryStreamBatchAbandonedPre = "$JS.EVENT.ADVISORY.STREAM.BATCH_ABANDONED"
 
+	// JSAdvisoryStreamConnectorErrorPre notification that a stream connector failed to write to its sink.
+	JSAdvisoryStreamConnectorErrorPre = "$JS.EVENT.ADVISORY.STREAM.CONNECTOR_ERROR"
+
+	// JSAdvisoryStreamConnectorLagPre notification that a stream connector is lagging behind.
+	JSAdvisoryStreamConnectorLagPre = "$JS.EVENT.ADVISORY.STREAM.CONNECTOR_LAG"
+
 	// JSAdvisoryConsumerLeaderElectedPre notification that a replicated consumer has elected a leader.
 	JSAdvisoryConsumerLeaderElectedPre = "$JS.EVENT.ADVISORY.CONSUMER.LEADER_ELECTED"
 
@@ -527,6 +551,30 @@ type JSApiStreamPurgeResponse struct {
 
 const JSApiStreamPurgeResponseType = "io.nats.jetstream.api.v1.stream_purge_response"
 
+// JSApiStreamEraseRequest selects the messages to securely erase from a stream.
+// Subject is required and can have wildcards. If Headers are set, only messages
+// having all of the given header values are erased.
+type JSApiStreamEraseRequest struct {
+	Subject string            `json:"filter"`
+	Headers map[string]string `json:"headers,omitempty"`
+}
+
+type JSApiStreamEraseResponse struct {
+	ApiResponse
+	Success bool   `json:"success,omitempty"`
+	Erased  uint64 `json:"erased"`
+}
+
+const JSApiStreamEraseResponseType = "io.nats.jetstream.api.v1.stream_erase_response"
+
+// JSApiStreamKeyRotateResponse is the response to rotating the encryption key of a stream.
+type JSApiStreamKeyRotateResponse struct {
+	ApiResponse
+	Success bool `json:"success,omitempty"`
+}
+
+const JSApiStreamKeyRotateResponseType = "io.nats.jetstream.api.v1.stream_key_rotate_response"
+
 type JSApiConsumerUnpinRequest struct {
 	Group string `json:"group"`
 }
@@ -1001,6 +1049,9 @@ func (s *Server) setJetStreamExportSubs() error {
 		{JSApiStreamInfo, s.jsStreamInfoRequest},
 		{JSApiStreamDelete, s.jsStreamDeleteRequest},
 		{JSApiStreamPurge, s.jsStreamPurgeRequest},
+		{JSApiStreamErase, s.jsStreamEraseRequest},
+		{JSApiStreamKeyRotate, s.jsStreamKeyRotateRequest},
+		{JSApiStreamKeyShred, s.jsStreamKeyShredRequest},
 		{JSApiStreamSnapshot, s.jsStreamSnapshotRequest},
 		{JSApiStreamRestore, s.jsStreamRestoreRequest},
 		{JSApiStreamRemovePeer, s.jsStreamRemovePeerRequest},
@@ -2231,15 +2282,17 @@ func (s *Server) jsStreamInfoRequest(sub *subscription, c *client, a *Account, s
 
 	config := mset.config()
 	resp.StreamInfo = &StreamInfo{
-		Created:    mset.createdTime(),
-		State:      mset.stateWithDetail(details),
-		Config:     *setDynamicStreamMetadata(&config),
-		Domain:     s.getOpts().JetStreamDomain,
-		Cluster:    js.clusterInfo(mset.raftGroup()),
-		Mirror:     mset.mirrorInfo(),
-		Sources:    mset.sourcesInfo(),
-		Alternates: js.streamAlternates(ci, config.Name),
-		TimeStamp:  time.Now().UTC(),
+		Created:     mset.createdTime(),
+		State:       mset.stateWithDetail(details),
+		Config:      *setDynamicStreamMetadata(&config),
+		Domain:      s.getOpts().JetStreamDomain,
+		Cluster:     js.clusterInfo(mset.raftGroup()),
+		Mirror:      mset.mirrorInfo(),
+		Sources:     mset.sourcesInfo(),
+		Alternates:  js.streamAlternates(ci, config.Name),
+		Compression: mset.compressionInfo(),
+		Connectors:  mset.connectorsInfo(),
+		TimeStamp:   time.Now().UTC(),
 	}
 	if clusterWideConsCount > 0 {
 		resp.StreamInfo.State.Consumers = clusterWideConsCount
@@ -3922,6 +3975,356 @@ func (s *Server) jsStreamPurgeRequest(sub *subscription, c *client, _ *Account,
 	s.sendAPIResponse(ci, acc, subject, reply, string(msg), s.jsonResponse(resp))
 }
 
+// Request to securely erase messages from a stream by subject.
+func (s *Server) jsStreamEraseRequest(sub *subscription, c *client, _ *Account, subject, reply string, rmsg []byte) {
+	if c == nil || !s.JetStreamEnabled() {
+		return
+	}
+	ci, acc, hdr, msg, err := s.getRequestInfo(c, rmsg)
+	if err != nil {
+		s.Warnf(badAPIRequestT, msg)
+		return
+	}
+
+	stream := streamNameFromSubject(subject)
+
+	var resp = JSApiStreamEraseResponse{ApiResponse: ApiResponse{Type: JSApiStreamEraseResponseType}}
+	if errorOnRequiredApiLevel(hdr) {
+		resp.Error = NewJSRequiredApiLevelError()
+		s.sendAPIErrResponse(ci, acc, subject, reply, string(msg), s.jsonResponse(&resp))
+		return
+	}
+
+	// If we are in clustered mode we need to be the stream leader to proceed.
+	if s.JetStreamIsClustered() {
+		// Check to make sure the stream is assigned.
+		js, cc := s.getJetStreamCluster()
+		if js == nil || cc == nil {
+			return
+		}
+		if js.isLeaderless() {
+			resp.Error = NewJSClusterNotAvailError()
+			s.sendAPIErrResponse(ci, acc, subject, reply, string(msg), s.jsonResponse(&resp))
+			return
+		}
+
+		js.mu.RLock()
+		isLeader, sa := cc.isLeader(), js.streamAssignment(acc.Name, stream)
+		js.mu.RUnlock()
+
+		if isLeader && sa == nil {
+			// We can't find the stream, so mimic what would be the errors below.
+			if hasJS, doErr := acc.checkJetStream(); !hasJS {
+				if doErr {
+					resp.Error = NewJSNotEnabledForAccountError()
+					s.sendAPIErrResponse(ci, acc, subject, reply, string(msg), s.jsonResponse(&resp))
+				}
+				return
+			}
+			// No stream present.
+			resp.Error = NewJSStreamNotFoundError()
+			s.sendAPIErrResponse(ci, acc, subject, reply, string(msg), s.jsonResponse(&resp))
+			return
+		} else if sa == nil {
+			return
+		}
+
+		// Check to see if we are a member of the group and if the group has no leader.
+		if js.isGroupLeaderless(sa.Group) {
+			resp.Error = NewJSClusterNotAvailError()
+			s.sendAPIErrResponse(ci, acc, subject, reply, string(msg), s.jsonResponse(&resp))
+			return
+		}
+
+		// We have the stream assigned and a leader, so only the stream leader should answer.
+		if !acc.JetStreamIsStreamLeader(stream) {
+			return
+		}
+	}
+
+	if hasJS, doErr := acc.checkJetStream(); !hasJS {
+		if doErr {
+			resp.Error = NewJSNotEnabledForAccountError()
+			s.sendAPIErrResponse(ci, acc, subject, reply, string(msg), s.jsonResponse(&resp))
+		}
+		return
+	}
+	if isEmptyRequest(msg) {
+		resp.Error = NewJSBadRequestError()
+		s.sendAPIErrResponse(ci, acc, subject, reply, string(msg), s.jsonResponse(&resp))
+		return
+	}
+	var req JSApiStreamEraseRequest
+	if err := s.unmarshalRequest(c, acc, subject, msg, &req); err != nil {
+		resp.Error = NewJSInvalidJSONError(err)
+		s.sendAPIErrResponse(ci, acc, subject, reply, string(msg), s.jsonResponse(&resp))
+		return
+	}
+	if !IsValidSubject(req.Subject) {
+		resp.Error = NewJSStreamEraseFilterRequiredError()
+		s.sendAPIErrResponse(ci, acc, subject, reply, string(msg), s.jsonResponse(&resp))
+		return
+	}
+
+	mset, err := acc.lookupStream(stream)
+	if err != nil {
+		resp.Error = NewJSStreamNotFoundError(Unless(err))
+		s.sendAPIErrResponse(ci, acc, subject, reply, string(msg), s.jsonResponse(&resp))
+		return
+	}
+	if mset.cfg.Sealed {
+		resp.Error = NewJSStreamSealedError()
+		s.sendAPIErrResponse(ci, acc, subject, reply, string(msg), s.jsonResponse(&resp))
+		return
+	}
+	if mset.cfg.DenyDelete {
+		resp.Error = NewJSStreamMsgDeleteFailedError(errors.New("message delete not permitted"))
+		s.sendAPIErrResponse(ci, acc, subject, reply, string(msg), s.jsonResponse(&resp))
+		return
+	}
+
+	if s.JetStreamIsClustered() {
+		s.jsClusteredStreamEraseRequest(ci, acc, mset, stream, subject, reply, rmsg, &req)
+		return
+	}
+
+	erased, err := mset.eraseMsgs(&req, mset.lastSeq())
+	if err != nil {
+		resp.Error = NewJSStreamMsgDeleteFailedError(err, Unless(err))
+	} else {
+		resp.Erased = erased
+		resp.Success = true
+	}
+	s.sendAPIResponse(ci, acc, subject, reply, string(msg), s.jsonResponse(resp))
+}
+
+// Request to rotate the encryption key of a stream.
+func (s *Server) jsStreamKeyRotateRequest(sub *subscription, c *client, _ *Account, subject, reply string, rmsg []byte) {
+	if c == nil || !s.JetStreamEnabled() {
+		return
+	}
+	ci, acc, hdr, msg, err := s.getRequestInfo(c, rmsg)
+	if err != nil {
+		s.Warnf(badAPIRequestT, msg)
+		return
+	}
+
+	stream := tokenAt(subject, 6)
+
+	var resp = JSApiStreamKeyRotateResponse{ApiResponse: ApiResponse{Type: JSApiStreamKeyRotateResponseType}}
+	if errorOnRequiredApiLevel(hdr) {
+		resp.Error = NewJSRequiredApiLevelError()
+		s.sendAPIErrResponse(ci, acc, subject, reply, string(msg), s.jsonResponse(&resp))
+		return
+	}
+
+	// If we are in clustered mode we need to be the stream leader to proceed.
+	if s.JetStreamIsClustered() {
+		// Check to make sure the stream is assigned.
+		js, cc := s.getJetStreamCluster()
+		if js == nil || cc == nil {
+			return
+		}
+
+		js.mu.RLock()
+		isLeader, sa := cc.isLeader(), js.streamAssignment(acc.Name, stream)
+		js.mu.RUnlock()
+
+		if isLeader && sa == nil {
+			// We can't find the stream, so mimic what would be the errors below.
+			if hasJS, doErr := acc.checkJetStream(); !hasJS {
+				if doErr {
+					resp.Error = NewJSNotEnabledForAccountError()
+					s.sendAPIErrResponse(ci, acc, subject, reply, string(msg), s.jsonResponse(&resp))
+				}
+				return
+			}
+			// No stream present.
+			resp.Error = NewJSStreamNotFoundError()
+			s.sendAPIErrResponse(ci, acc, subject, reply, string(msg), s.jsonResponse(&resp))
+			return
+		} else if sa == nil {
+			if js.isLeaderless() {
+				resp.Error = NewJSClusterNotAvailError()
+				s.sendAPIErrResponse(ci, acc, subject, reply, string(msg), s.jsonResponse(&resp))
+			}
+			return
+		}
+
+		// Check to see if we are a member of the group and if the group has no leader.
+		if js.isGroupLeaderless(sa.Group) {
+			resp.Error = NewJSClusterNotAvailError()
+			s.sendAPIErrResponse(ci, acc, subject, reply, string(msg), s.jsonResponse(&resp))
+			return
+		}
+
+		// We have the stream assigned and a leader, so only the stream leader should answer.
+		if !acc.JetStreamIsStreamLeader(stream) {
+			if js.isLeaderless() {
+				resp.Error = NewJSClusterNotAvailError()
+				s.sendAPIErrResponse(ci, acc, subject, reply, string(msg), s.jsonResponse(&resp))
+			}
+			return
+		}
+	}
+
+	if hasJS, doErr := acc.checkJetStream(); !hasJS {
+		if doErr {
+			resp.Error = NewJSNotEnabledForAccountError()
+			s.sendAPIErrResponse(ci, acc, subject, reply, string(msg), s.jsonResponse(&resp))
+		}
+		return
+	}
+
+	if !isEmptyRequest(msg) {
+		resp.Error = NewJSNotEmptyRequestError()
+		s.sendAPIErrResponse(ci, acc, subject, reply, string(msg), s.jsonResponse(&resp))
+		return
+	}
+	if !s.jsStreamKeysEnabled() {
+		resp.Error = NewJSStreamKeysNotEnabledError()
+		s.sendAPIErrResponse(ci, acc, subject, reply, string(msg), s.jsonResponse(&resp))
+		return
+	}
+
+	mset, err := acc.lookupStream(stream)
+	if err != nil {
+		resp.Error = NewJSStreamNotFoundError(Unless(err))
+		s.sendAPIErrResponse(ci, acc, subject, reply, string(msg), s.jsonResponse(&resp))
+		return
+	}
+	if mset.config().Storage != FileStorage {
+		resp.Error = NewJSStreamNoEncryptionKeyError()
+		s.sendAPIErrResponse(ci, acc, subject, reply, string(msg), s.jsonResponse(&resp))
+		return
+	}
+
+	if s.JetStreamIsClustered() {
+		s.jsClusteredStreamKeyRotateRequest(ci, acc, mset, stream, subject, reply, rmsg)
+		return
+	}
+
+	if err := mset.rotateEncryptionKey(); err != nil {
+		resp.Error = NewJSStreamGeneralError(err, Unless(err))
+	} else {
+		resp.Success = true
+	}
+	s.sendAPIResponse(ci, acc, subject, reply, string(msg), s.jsonResponse(resp))
+}
+
+// Request to delete a stream that has an encryption key of its own, destroying
+// the key with it. This is a stream delete, so its data can no longer be
+// decrypted, even from copies of its files.
+func (s *Server) jsStreamKeyShredRequest(sub *subscription, c *client, _ *Account, subject, reply string, rmsg []byte) {
+	if c == nil || !s.JetStreamEnabled() {
+		return
+	}
+	ci, acc, hdr, msg, err := s.getRequestInfo(c, rmsg)
+	if err != nil {
+		s.Warnf(badAPIRequestT, msg)
+		return
+	}
+
+	var resp = JSApiStreamDeleteResponse{ApiResponse: ApiResponse{Type: JSApiStreamDeleteResponseType}}
+	if errorOnRequiredApiLevel(hdr) {
+		resp.Error = NewJSRequiredApiLevelError()
+		s.sendAPIErrResponse(ci, acc, subject, reply, string(msg), s.jsonResponse(&resp))
+		return
+	}
+
+	// Determine if we should proceed here when we are in clustered mode.
+	if s.JetStreamIsClustered() {
+		js, cc := s.getJetStreamCluster()
+		if js == nil || cc == nil {
+			return
+		}
+		if js.isLeaderless() {
+			resp.Error = NewJSClusterNotAvailError()
+			s.sendAPIErrResponse(ci, acc, subject, reply, string(msg), s.jsonResponse(&resp))
+			return
+		}
+		// Make sure we are meta leader.
+		if !s.JetStreamIsLeader() {
+			return
+		}
+	}
+
+	if hasJS, doErr := acc.checkJetStream(); !hasJS {
+		if doErr {
+			resp.Error = NewJSNotEnabledForAccountError()
+			s.sendAPIErrResponse(ci, acc, subject, reply, string(msg), s.jsonResponse(&resp))
+		}
+		return
+	}
+
+	if !isEmptyRequest(msg) {
+		resp.Error = NewJSNotEmptyRequestError()
+		s.sendAPIErrResponse(ci, acc, subject, reply, string(msg), s.jsonResponse(&resp))
+		return
+	}
+	// Streams without a key of their own could not be shredded.
+	if !s.jsStreamKeysEnabled() {
+		resp.Error = NewJSStreamKeysNotEnabledError()
+		s.sendAPIErrResponse(ci, acc, subject, reply, string(msg), s.jsonResponse(&resp))
+		return
+	}
+	stream := tokenAt(subject, 6)
+
+	// Clustered. Every replica destroys the key when removing the stream, so
+	// check with the stream leader that the stream has one. The stream's raft
+	// log is encrypted with the server key, it is removed with the stream but
+	// is not covered by the stream key.
+	if s.JetStreamIsClustered() {
+		js, _ := s.getJetStreamCluster()
+		js.mu.RLock()
+		sa := js.streamAssignment(acc.Name, stream)
+		js.mu.RUnlock()
+		if sa == nil {
+			resp.Error = NewJSStreamNotFoundError()
+			s.sendAPIErrResponse(ci, acc, subject, reply, string(msg), s.jsonResponse(&resp))
+			return
+		}
+		if sa.Config.Storage != FileStorage {
+			resp.Error = NewJSStreamNoEncryptionKeyError()
+			s.sendAPIErrResponse(ci, acc, subject, reply, string(msg), s.jsonResponse(&resp))
+			return
+		}
+		si, err := sysRequest[streamInfoClusterResponse](s, clusterStreamInfoT, acc.Name, stream)
+		if err != nil {
+			resp.Error = NewJSStreamDeleteError(err, Unless(err))
+			s.sendAPIErrResponse(ci, acc, subject, reply, string(msg), s.jsonResponse(&resp))
+			return
+		}
+		if !si.StreamKey {
+			resp.Error = NewJSStreamNoEncryptionKeyError()
+			s.sendAPIErrResponse(ci, acc, subject, reply, string(msg), s.jsonResponse(&resp))
+			return
+		}
+		s.jsClusteredStreamDeleteRequest(ci, acc, stream, subject, reply, msg)
+		return
+	}
+
+	mset, err := acc.lookupStream(stream)
+	if err != nil {
+		resp.Error = NewJSStreamNotFoundError(Unless(err))
+		s.sendAPIErrResponse(ci, acc, subject, reply, string(msg), s.jsonResponse(&resp))
+		return
+	}
+	if !mset.hasEncryptionKey() {
+		resp.Error = NewJSStreamNoEncryptionKeyError()
+		s.sendAPIErrResponse(ci, acc, subject, reply, string(msg), s.jsonResponse(&resp))
+		return
+	}
+
+	if err := mset.delete(); err != nil {
+		resp.Error = NewJSStreamDeleteError(err, Unless(err))
+		s.sendAPIErrResponse(ci, acc, subject, reply, string(msg), s.jsonResponse(&resp))
+		return
+	}
+	resp.Success = true
+	s.sendAPIResponse(ci, acc, subject, reply, string(msg), s.jsonResponse(resp))
+}
+
 func (acc *Account) jsNonClusteredStreamLimitsCheck(cfg *StreamConfig) *ApiError {
 	var replicas int
 	if cfg != nil {
@@ -84,7 +84,11 @@ func newBatchStore(mset *stream, batchId string) (StreamStore, error) {
 		bname, storeDir := getBatchStoreDir(mset, batchId)
 		fcfg := FileStoreConfig{AsyncFlush: true, BlockSize: defaultLargeBlockSize, StoreDir: storeDir}
 		s := mset.srv
-		prf := s.jsKeyGen(s.getOpts().JetStreamKey, mset.acc.Name)
+		key, err := s.jsEncryptionKey(mset.acc.Name)
+		if err != nil {
+			return nil, err
+		}
+		prf := s.jsKeyGen(key, mset.acc.Name)
 		if prf != nil {
 			// We are encrypted here, fill in correct cipher selection.
 			fcfg.Cipher = s.getOpts().JetStreamCipher
@@ -119,6 +119,10 @@ const (
 	// Batch stream ops.
 	batchMsgOp
 	batchCommitMsgOp
+	// Stream encryption key rotation.
+	rotateStreamKeyOp
+	// Secure erase of messages by subject.
+	eraseStreamOp
 )
 
 // raftGroups are controlled by the metagroup controller.
@@ -335,6 +339,25 @@ type streamPurge struct {
 	Request *JSApiStreamPurgeRequest `json:"request,omitempty"`
 }
 
+// streamErase is what the stream leader will replicate when erasing messages by subject.
+type streamErase struct {
+	Client  *ClientInfo              `json:"client,omitempty"`
+	Stream  string                   `json:"stream"`
+	LastSeq uint64                   `json:"last_seq"`
+	Subject string                   `json:"subject"`
+	Reply   string                   `json:"reply"`
+	Request *JSApiStreamEraseRequest `json:"request"`
+}
+
+// streamKeyRotate is what the stream leader will replicate when rotating the
+// stream encryption key. Every replica rotates its own key.
+type streamKeyRotate struct {
+	Client  *ClientInfo `json:"client,omitempty"`
+	Stream  string      `json:"stream"`
+	Subject string      `json:"subject"`
+	Reply   string      `json:"reply"`
+}
+
 // streamMsgDelete is what the stream leader will replicate when deleting a message.
 type streamMsgDelete struct {
 	Client  *ClientInfo `json:"client,omitempty"`
@@ -879,11 +902,16 @@ func (js *jetStream) setupMetaGroup() error {
 	syncAlways := js.srv.opts.SyncAlways
 	syncInterval := js.srv.opts.SyncInterval
 	js.srv.optsMu.RUnlock()
+	key, err := s.jsEncryptionKey(_EMPTY_)
+	if err != nil {
+		s.Errorf("Error loading encryption key: %v", err)
+		return err
+	}
 	fs, err := newFileStoreWithCreated(
 		FileStoreConfig{StoreDir: storeDir, BlockSize: defaultMetaFSBlkSize, AsyncFlush: false, SyncAlways: syncAlways, SyncInterval: syncInterval, srv: s},
 		StreamConfig{Name: defaultMetaGroupName, Storage: FileStorage},
 		time.Now().UTC(),
-		s.jsKeyGen(s.getOpts().JetStreamKey, defaultMetaGroupName),
+		s.jsKeyGen(key, defaultMetaGroupName),
 		s.jsKeyGen(s.getOpts().JetStreamOldKey, defaultMetaGroupName),
 	)
 	if err != nil {
@@ -2354,11 +2382,16 @@ retry:
 		syncAlways := js.srv.opts.SyncAlways
 		syncInterval := js.srv.opts.SyncInterval
 		js.srv.optsMu.RUnlock()
+		key, err := s.jsEncryptionKey(_EMPTY_)
+		if err != nil {
+			s.Errorf("Error loading encryption key: %v", err)
+			return nil, err
+		}
 		fs, err := newFileStoreWithCreated(
 			FileStoreConfig{StoreDir: storeDir, BlockSize: defaultMediumBlockSize, AsyncFlush: false, SyncAlways: syncAlways, SyncInterval: syncInterval, srv: s},
 			StreamConfig{Name: rg.Name, Storage: FileStorage, Metadata: labels},
 			time.Now().UTC(),
-			s.jsKeyGen(s.getOpts().JetStreamKey, rg.Name),
+			s.jsKeyGen(key, rg.Name),
 			s.jsKeyGen(s.getOpts().JetStreamOldKey, rg.Name),
 		)
 		if err != nil {
@@ -3435,6 +3468,75 @@ func (js *jetStream) applyStreamEntries(mset *stream, ce *CommittedEntry, isReco
 						s.sendAPIResponse(sp.Client, mset.account(), sp.Subject, sp.Reply, _EMPTY_, s.jsonResponse(resp))
 					}
 				}
+			case eraseStreamOp:
+				se, err := decodeStreamErase(buf[1:])
+				if err != nil {
+					if node := mset.raftNode(); node != nil {
+						s := js.srv
+						s.Errorf("JetStream cluster could not decode erase msg for '%s > %s' [%s]",
+							mset.account(), mset.name(), node.Group())
+					}
+					panic(err.Error())
+				}
+
+				// Only erase what the leader could see, so a replay during server start
+				// does not erase messages stored after the request.
+				s := js.server()
+				erased, err := mset.eraseMsgs(se.Request, se.LastSeq)
+				if err != nil {
+					s.Warnf("JetStream cluster failed to erase messages from stream %q for account %q: %v", se.Stream, se.Client.serviceAccount(), err)
+				}
+
+				js.mu.RLock()
+				isLeader := js.cluster.isStreamLeader(se.Client.serviceAccount(), se.Stream)
+				js.mu.RUnlock()
+
+				if isLeader && !isRecovering {
+					var resp = JSApiStreamEraseResponse{ApiResponse: ApiResponse{Type: JSApiStreamEraseResponseType}}
+					if err != nil {
+						resp.Error = NewJSStreamMsgDeleteFailedError(err, Unless(err))
+						s.sendAPIErrResponse(se.Client, mset.account(), se.Subject, se.Reply, _EMPTY_, s.jsonResponse(resp))
+					} else {
+						resp.Erased = erased
+						resp.Success = true
+						s.sendAPIResponse(se.Client, mset.account(), se.Subject, se.Reply, _EMPTY_, s.jsonResponse(resp))
+					}
+				}
+			case rotateStreamKeyOp:
+				kr, err := decodeStreamKeyRotate(buf[1:])
+				if err != nil {
+					if node := mset.raftNode(); node != nil {
+						s := js.srv
+						s.Errorf("JetStream cluster could not decode key rotate msg for '%s > %s' [%s]",
+							mset.account(), mset.name(), node.Group())
+					}
+					panic(err.Error())
+				}
+				// The keys are local to each server, so a rotation does not need to be replayed.
+				if isRecovering {
+					continue
+				}
+
+				s := js.server()
+				err = mset.rotateEncryptionKey()
+				if err != nil {
+					s.Warnf("JetStream cluster failed to rotate key for stream %q for account %q: %v", kr.Stream, kr.Client.serviceAccount(), err)
+				}
+
+				js.mu.RLock()
+				isLeader := js.cluster.isStreamLeader(kr.Client.serviceAccount(), kr.Stream)
+				js.mu.RUnlock()
+
+				if isLeader {
+					var resp = JSApiStreamKeyRotateResponse{ApiResponse: ApiResponse{Type: JSApiStreamKeyRotateResponseType}}
+					if err != nil {
+						resp.Error = NewJSStreamGeneralError(err, Unless(err))
+						s.sendAPIErrResponse(kr.Client, mset.account(), kr.Subject, kr.Reply, _EMPTY_, s.jsonResponse(resp))
+					} else {
+						resp.Success = true
+						s.sendAPIResponse(kr.Client, mset.account(), kr.Subject, kr.Reply, _EMPTY_, s.jsonResponse(resp))
+					}
+				}
 			default:
 				panic(fmt.Sprintf("JetStream Cluster Unknown group entry op type: %v", op))
 			}
@@ -7454,6 +7556,143 @@ func (s *Server) jsClusteredStreamPurgeRequest(
 	s.sendAPIResponse(ci, acc, subject, reply, string(rmsg), s.jsonResponse(resp))
 }
 
+// Returns whether all the peers are known to support the JetStream API level.
+// Entry ops added with a later API level would not be understood by older
+// peers, so are only proposed once all peers support them.
+func (s *Server) peersSupportApiLevel(peers []string, level int) bool {
+	ourNode := getHash(s.serverName())
+	for _, pn := range peers {
+		if pn == ourNode {
+			continue
+		}
+		v, ok := s.nodeToInfo.Load(pn)
+		if !ok {
+			return false
+		}
+		if ni := v.(nodeInfo); ni.stats == nil || ni.stats.API.Level < level {
+			return false
+		}
+	}
+	return JSApiLevel >= level
+}
+
+func (s *Server) jsClusteredStreamEraseRequest(
+	ci *ClientInfo,
+	acc *Account,
+	mset *stream,
+	stream, subject, reply string,
+	rmsg []byte,
+	req *JSApiStreamEraseRequest,
+) {
+	js, cc := s.getJetStreamCluster()
+	if js == nil || cc == nil {
+		return
+	}
+
+	js.mu.Lock()
+	sa := js.streamAssignment(acc.Name, stream)
+	if sa == nil {
+		resp := JSApiStreamEraseResponse{ApiResponse: ApiResponse{Type: JSApiStreamEraseResponseType}}
+		resp.Error = NewJSStreamNotFoundError()
+		s.sendAPIErrResponse(ci, acc, subject, reply, string(rmsg), s.jsonResponse(&resp))
+		js.mu.Unlock()
+		return
+	}
+
+	if mset == nil {
+		js.mu.Unlock()
+		return
+	}
+
+	// Only messages the leader has stored so far are erased. With none there
+	// is nothing to erase.
+	lseq := mset.lastSeq()
+	var resp = JSApiStreamEraseResponse{ApiResponse: ApiResponse{Type: JSApiStreamEraseResponseType}}
+	if lseq == 0 {
+		js.mu.Unlock()
+		resp.Success = true
+		s.sendAPIResponse(ci, acc, subject, reply, string(rmsg), s.jsonResponse(resp))
+		return
+	}
+
+	// Older peers would not apply the erase, so can not make the guarantee.
+	if n := sa.Group.node; n != nil && !s.peersSupportApiLevel(sa.Group.Peers, 3) {
+		js.mu.Unlock()
+		resp.Error = NewJSStreamPeersNotSupportedError()
+		s.sendAPIErrResponse(ci, acc, subject, reply, string(rmsg), s.jsonResponse(&resp))
+		return
+	}
+
+	if n := sa.Group.node; n != nil {
+		se := &streamErase{Stream: stream, LastSeq: lseq, Subject: subject, Reply: reply, Client: ci, Request: req}
+		n.Propose(encodeStreamErase(se))
+		js.mu.Unlock()
+		return
+	}
+	js.mu.Unlock()
+
+	erased, err := mset.eraseMsgs(req, lseq)
+	if err != nil {
+		resp.Error = NewJSStreamMsgDeleteFailedError(err, Unless(err))
+	} else {
+		resp.Erased = erased
+		resp.Success = true
+	}
+	s.sendAPIResponse(ci, acc, subject, reply, string(rmsg), s.jsonResponse(resp))
+}
+
+func (s *Server) jsClusteredStreamKeyRotateRequest(
+	ci *ClientInfo,
+	acc *Account,
+	mset *stream,
+	stream, subject, reply string,
+	rmsg []byte,
+) {
+	js, cc := s.getJetStreamCluster()
+	if js == nil || cc == nil {
+		return
+	}
+
+	js.mu.Lock()
+	sa := js.streamAssignment(acc.Name, stream)
+	if sa == nil {
+		resp := JSApiStreamKeyRotateResponse{ApiResponse: ApiResponse{Type: JSApiStreamKeyRotateResponseType}}
+		resp.Error = NewJSStreamNotFoundError()
+		s.sendAPIErrResponse(ci, acc, subject, reply, string(rmsg), s.jsonResponse(&resp))
+		js.mu.Unlock()
+		return
+	}
+
+	// Older peers can not apply the rotation.
+	if n := sa.Group.node; n != nil && !s.peersSupportApiLevel(sa.Group.Peers, 3) {
+		js.mu.Unlock()
+		resp := JSApiStreamKeyRotateResponse{ApiResponse: ApiResponse{Type: JSApiStreamKeyRotateResponseType}}
+		resp.Error = NewJSStreamPeersNotSupportedError()
+		s.sendAPIErrResponse(ci, acc, subject, reply, string(rmsg), s.jsonResponse(&resp))
+		return
+	}
+
+	if n := sa.Group.node; n != nil {
+		kr := &streamKeyRotate{Stream: stream, Subject: subject, Reply: reply, Client: ci}
+		n.Propose(encodeStreamKeyRotate(kr))
+		js.mu.Unlock()
+		return
+	}
+	js.mu.Unlock()
+
+	if mset == nil {
+		return
+	}
+
+	var resp = JSApiStreamKeyRotateResponse{ApiResponse: ApiResponse{Type: JSApiStreamKeyRotateResponseType}}
+	if err := mset.rotateEncryptionKey(); err != nil {
+		resp.Error = NewJSStreamGeneralError(err, Unless(err))
+	} else {
+		resp.Success = true
+	}
+	s.sendAPIResponse(ci, acc, subject, reply, string(rmsg), s.jsonResponse(resp))
+}
+
 func (s *Server) jsClusteredStreamRestoreRequest(
 	ci *ClientInfo,
 	acc *Account,
@@ -7844,6 +8083,35 @@ func decodeStreamPurge(buf []byte) (*streamPurge, error) {
 	return &sp, err
 }
 
+func encodeStreamErase(se *streamErase) []byte {
+	var bb bytes.Buffer
+	bb.WriteByte(byte(eraseStreamOp))
+	json.NewEncoder(&bb).Encode(se)
+	return bb.Bytes()
+}
+
+func decodeStreamErase(buf []byte) (*streamErase, error) {
+	var se streamErase
+	err := json.Unmarshal(buf, &se)
+	if err == nil && se.Request == nil {
+		err = errors.New("missing erase request")
+	}
+	return &se, err
+}
+
+func encodeStreamKeyRotate(kr *streamKeyRotate) []byte {
+	var bb bytes.Buffer
+	bb.WriteByte(byte(rotateStreamKeyOp))
+	json.NewEncoder(&bb).Encode(kr)
+	return bb.Bytes()
+}
+
+func decodeStreamKeyRotate(buf []byte) (*streamKeyRotate, error) {
+	var kr streamKeyRotate
+	err := json.Unmarshal(buf, &kr)
+	return &kr, err
+}
+
 func (s *Server) jsClusteredConsumerDeleteRequest(ci *ClientInfo, acc *Account, stream, consumer, subject, reply string, rmsg []byte) {
 	js, cc := s.getJetStreamCluster()
 	if js == nil || cc == nil {
@@ -8159,7 +8427,7 @@ func (s *Server) jsClusteredConsumerRequest(ci *ClientInfo, acc *Account, subjec
 		cfg.MaxAckPending = JsDefaultMaxAckPending
 	}
 
-	if cfg.PriorityPolicy == PriorityPinnedClient && cfg.PinnedTTL == 0 {
+	if (cfg.PriorityPolicy == PriorityPinnedClient || cfg.PriorityPolicy == PriorityPartitioned) && cfg.PinnedTTL == 0 {
 		cfg.PinnedTTL = JsDefaultPinnedTTL
 	}
 
@@ -9673,7 +9941,7 @@ func (mset *stream) processClusterStreamInfoRequest(reply string) {
 		mset.checkClusterInfo(si.Cluster)
 	}
 
-	sysc.sendInternalMsg(reply, _EMPTY_, nil, si)
+	sysc.sendInternalMsg(reply, _EMPTY_, nil, &streamInfoClusterResponse{StreamInfo: *si, StreamKey: mset.hasEncryptionKey()})
 }
 
 // 64MB for now, for the total server. This is max we will blast out if asked to
@@ -16,6 +16,7 @@
 package server
 
 import (
+	"bytes"
 	"context"
 	"encoding/binary"
 	"encoding/json"
@@ -7078,3 +7079,256 @@ func TestJetStreamClusterAccountMaxConnectionsReconnect(t *testing.T) {
 		return nil
 	})
 }
+
+// Makes the server see the peer as supporting up to the JetStream API level,
+// until the peer sends its next statsz.
+func setPeerApiLevel(t *testing.T, s, peer *Server, level int) {
+	t.Helper()
+	node := getHash(peer.Name())
+	v, ok := s.nodeToInfo.Load(node)
+	require_True(t, ok)
+	ni := v.(nodeInfo)
+	require_NotNil(t, ni.stats)
+	stats := *ni.stats
+	stats.API.Level = level
+	ni.stats = &stats
+	s.nodeToInfo.Store(node, ni)
+}
+
+func TestJetStreamClusterStreamEncryptionKeyRotate(t *testing.T) {
+	tmpl := strings.Replace(jsClusterEncryptedTempl, `key: "s3cr3t!"`, `key: "s3cr3t!", stream_keys: true`, 1)
+	c := createJetStreamClusterWithTemplate(t, tmpl, "R3S", 3)
+	defer c.shutdown()
+
+	nc, js := jsClientConnect(t, c.randomServer())
+	defer nc.Close()
+
+	_, err := js.AddStream(&nats.StreamConfig{Name: "TEST", Subjects: []string{"foo"}, Replicas: 3})
+	require_NoError(t, err)
+	for i := 0; i < 10; i++ {
+		_, err = js.Publish("foo", []byte("ok"))
+		require_NoError(t, err)
+	}
+	c.waitOnAllCurrent()
+
+	readKeys := func() map[string][]byte {
+		t.Helper()
+		keys := make(map[string][]byte)
+		for _, s := range c.servers {
+			mset, err := s.globalAccount().lookupStream("TEST")
+			require_NoError(t, err)
+			require_True(t, mset.hasEncryptionKey())
+			fs := mset.store.(*fileStore)
+			buf, err := os.ReadFile(filepath.Join(fs.fcfg.StoreDir, JetStreamStreamKeyFile))
+			require_NoError(t, err)
+			keys[s.Name()] = buf
+		}
+		return keys
+	}
+	before := readKeys()
+
+	rmsg, err := nc.Request(fmt.Sprintf(JSApiStreamKeyRotateT, "TEST"), nil, 5*time.Second)
+	require_NoError(t, err)
+	var resp JSApiStreamKeyRotateResponse
+	require_NoError(t, json.Unmarshal(rmsg.Data, &resp))
+	require_True(t, resp.Error == nil)
+	require_True(t, resp.Success)
+
+	// Every replica rotated its own key.
+	checkFor(t, 5*time.Second, 100*time.Millisecond, func() error {
+		for name, key := range readKeys() {
+			if bytes.Equal(key, before[name]) {
+				return fmt.Errorf("key not rotated on %s", name)
+			}
+		}
+		return nil
+	})
+
+	// A peer with an older API level can not apply the rotation.
+	sl := c.streamLeader(globalAccountName, "TEST")
+	setPeerApiLevel(t, sl, c.randomNonStreamLeader(globalAccountName, "TEST"), 2)
+	rnc := natsConnect(t, sl.ClientURL())
+	defer rnc.Close()
+	rmsg, err = rnc.Request(fmt.Sprintf(JSApiStreamKeyRotateT, "TEST"), nil, 5*time.Second)
+	require_NoError(t, err)
+	resp = JSApiStreamKeyRotateResponse{}
+	require_NoError(t, json.Unmarshal(rmsg.Data, &resp))
+	require_NotNil(t, resp.Error)
+	require_Equal(t, resp.Error.ErrCode, uint16(JSStreamPeersNotSupportedErr))
+
+	// Data is still readable on all replicas after a restart.
+	c.stopAll()
+	c.restartAll()
+	c.waitOnStreamLeader(globalAccountName, "TEST")
+	for _, s := range c.servers {
+		checkFor(t, 5*time.Second, 100*time.Millisecond, func() error {
+			mset, err := s.globalAccount().lookupStream("TEST")
+			if err != nil {
+				return err
+			}
+			if state := mset.state(); state.Msgs != 10 {
+				return fmt.Errorf("expected 10 msgs, got %d", state.Msgs)
+			}
+			return nil
+		})
+	}
+}
+
+func TestJetStreamClusterStreamEraseBySubject(t *testing.T) {
+	c := createJetStreamClusterExplicit(t, "R3S", 3)
+	defer c.shutdown()
+
+	nc, js := jsClientConnect(t, c.randomServer())
+	defer nc.Close()
+
+	_, err := js.AddStream(&nats.StreamConfig{Name: "TEST", Subjects: []string{"user.>"}, Replicas: 3})
+	require_NoError(t, err)
+	for i := 0; i < 10; i++ {
+		_, err = js.Publish(fmt.Sprintf("user.%d", i%2), []byte("ok"))
+		require_NoError(t, err)
+	}
+
+	body, err := json.Marshal(&JSApiStreamEraseRequest{Subject: "user.1"})
+	require_NoError(t, err)
+	rmsg, err := nc.Request(fmt.Sprintf(JSApiStreamEraseT, "TEST"), body, 5*time.Second)
+	require_NoError(t, err)
+	var resp JSApiStreamEraseResponse
+	require_NoError(t, json.Unmarshal(rmsg.Data, &resp))
+	require_True(t, resp.Error == nil)
+	require_Equal(t, resp.Erased, 5)
+
+	// All replicas erased the same messages.
+	checkFor(t, 5*time.Second, 100*time.Millisecond, func() error {
+		for _, s := range c.servers {
+			mset, err := s.globalAccount().lookupStream("TEST")
+			if err != nil {
+				return err
+			}
+			if state := mset.state(); state.Msgs != 5 || state.NumDeleted != 5 {
+				return fmt.Errorf("unexpected state on %s: %+v", s.Name(), state)
+			}
+			if n := mset.store.SubjectsTotals("user.1")["user.1"]; n != 0 {
+				return fmt.Errorf("expected no messages for user.1 on %s, got %d", s.Name(), n)
+			}
+		}
+		return nil
+	})
+}
+
+func TestJetStreamClusterStreamEraseOlderPeer(t *testing.T) {
+	c := createJetStreamClusterExplicit(t, "R3S", 3)
+	defer c.shutdown()
+
+	nc, js := jsClientConnect(t, c.randomServer())
+	defer nc.Close()
+
+	_, err := js.AddStream(&nats.StreamConfig{Name: "TEST", Subjects: []string{"user.>"}, Replicas: 3})
+	require_NoError(t, err)
+	c.waitOnStreamLeader(globalAccountName, "TEST")
+
+	for i := 0; i < 5; i++ {
+		_, err = js.Publish("user.1", []byte("ok"))
+		require_NoError(t, err)
+	}
+
+	// A peer with an older API level would not apply the erase.
+	sl := c.streamLeader(globalAccountName, "TEST")
+	setPeerApiLevel(t, sl, c.randomNonStreamLeader(globalAccountName, "TEST"), 2)
+
+	body, err := json.Marshal(&JSApiStreamEraseRequest{Subject: "user.1"})
+	require_NoError(t, err)
+	rmsg, err := nc.Request(fmt.Sprintf(JSApiStreamEraseT, "TEST"), body, 5*time.Second)
+	require_NoError(t, err)
+	var resp JSApiStreamEraseResponse
+	require_NoError(t, json.Unmarshal(rmsg.Data, &resp))
+	require_NotNil(t, resp.Error)
+	require_Equal(t, resp.Error.ErrCode, uint16(JSStreamPeersNotSupportedErr))
+
+	si, err := js.StreamInfo("TEST")
+	require_NoError(t, err)
+	require_Equal(t, si.State.Msgs, 5)
+}
+
+func TestJetStreamClusterStreamEraseEmptyStream(t *testing.T) {
+	c := createJetStreamClusterExplicit(t, "R3S", 3)
+	defer c.shutdown()
+
+	nc, js := jsClientConnect(t, c.randomServer())
+	defer nc.Close()
+
+	_, err := js.AddStream(&nats.StreamConfig{Name: "TEST", Subjects: []string{"user.>"}, Replicas: 3})
+	require_NoError(t, err)
+	c.waitOnStreamLeader(globalAccountName, "TEST")
+
+	body, err := json.Marshal(&JSApiStreamEraseRequest{Subject: "user.>"})
+	require_NoError(t, err)
+	rmsg, err := nc.Request(fmt.Sprintf(JSApiStreamEraseT, "TEST"), body, 5*time.Second)
+	require_NoError(t, err)
+	var resp JSApiStreamEraseResponse
+	require_NoError(t, json.Unmarshal(rmsg.Data, &resp))
+	require_True(t, resp.Error == nil)
+	require_Equal(t, resp.Erased, 0)
+
+	for i := 0; i < 5; i++ {
+		_, err = js.Publish("user.1", []byte("ok"))
+		require_NoError(t, err)
+	}
+
+	// Messages stored after the erase are kept, also when replaying the log.
+	sl := c.streamLeader(globalAccountName, "TEST")
+	for _, s := range c.servers {
+		if s != sl {
+			s.Shutdown()
+			c.restartServer(s)
+		}
+	}
+	c.waitOnAllCurrent()
+	checkFor(t, 5*time.Second, 100*time.Millisecond, func() error {
+		for _, s := range c.servers {
+			mset, err := s.globalAccount().lookupStream("TEST")
+			if err != nil {
+				return err
+			}
+			if state := mset.state(); state.Msgs != 5 || state.NumDeleted != 0 {
+				return fmt.Errorf("unexpected state on %s: %+v", s.Name(), state)
+			}
+		}
+		return nil
+	})
+}
+
+func TestJetStreamClusterStreamEncryptionKeyShred(t *testing.T) {
+	tmpl := strings.Replace(jsClusterEncryptedTempl, `key: "s3cr3t!"`, `key: "s3cr3t!", stream_keys: true`, 1)
+	c := createJetStreamClusterWithTemplate(t, tmpl, "R3S", 3)
+	defer c.shutdown()
+
+	nc, js := jsClientConnect(t, c.randomServer())
+	defer nc.Close()
+
+	shred := func(stream string) *JSApiStreamDeleteResponse {
+		t.Helper()
+		rmsg, err := nc.Request(fmt.Sprintf(JSApiStreamKeyShredT, stream), nil, 5*time.Second)
+		require_NoError(t, err)
+		var resp JSApiStreamDeleteResponse
+		require_NoError(t, json.Unmarshal(rmsg.Data, &resp))
+		return &resp
+	}
+
+	// Memory streams have no key to shred, and are not deleted.
+	_, err := js.AddStream(&nats.StreamConfig{Name: "MEM", Subjects: []string{"bar"}, Storage: nats.MemoryStorage, Replicas: 3})
+	require_NoError(t, err)
+	resp := shred("MEM")
+	require_True(t, resp.Error != nil)
+	require_Equal(t, resp.Error.ErrCode, uint16(JSStreamNoEncryptionKeyErr))
+	_, err = js.StreamInfo("MEM")
+	require_NoError(t, err)
+
+	_, err = js.AddStream(&nats.StreamConfig{Name: "TEST", Subjects: []string{"foo"}, Replicas: 3})
+	require_NoError(t, err)
+	c.waitOnStreamLeader(globalAccountName, "TEST")
+	resp = shred("TEST")
+	require_True(t, resp.Error == nil)
+	require_True(t, resp.Success)
+	_, err = js.StreamInfo("TEST")
+	require_Error(t, err, nats.ErrStreamNotFound)
+}
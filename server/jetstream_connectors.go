@@ -0,0 +1,914 @@
+// Copyright 2025 The NATS Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/textproto"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/nats-io/nuid"
+)
+
+// Stream connectors deliver the messages of a stream to an external sink from
+// within the stream leader. Progress is tracked by a durable consumer on the
+// stream, so a new leader resumes where the previous one left off. Messages are
+// acknowledged once written to the sink and delivery is at least once.
+
+// StreamConnector delivers the messages of a stream to an external sink.
+// Exactly one sink must be configured.
+type StreamConnector struct {
+	// Name of the connector, also used to name its durable consumer.
+	Name string `json:"name"`
+	// FilterSubject limits the connector to messages matching the subject.
+	FilterSubject string `json:"filter_subject,omitempty"`
+	// MaxLag is the number of pending messages above which a lag advisory is sent.
+	MaxLag uint64 `json:"max_lag,omitempty"`
+
+	File *ConnectorFileSink `json:"file,omitempty"`
+	HTTP *ConnectorHTTPSink `json:"http,omitempty"`
+	Exec *ConnectorExecSink `json:"exec,omitempty"`
+}
+
+// ConnectorFileSink appends records to a local file, rotating it by size or age.
+type ConnectorFileSink struct {
+	// Path of the file, relative to the directory of the stream within the
+	// server's connector directory, "<dir>/<account>/<stream>/<path>".
+	Path string `json:"path"`
+	// Format of the records, "jsonl" or "parquet". Since each record must be
+	// durable before it is acknowledged, Parquet files are only written when
+	// rotated. Until then the records are kept as JSON lines in a file with a
+	// ".pending" suffix, which MaxBytes applies to.
+	Format string `json:"format,omitempty"`
+	// MaxBytes and MaxAge trigger rotation of the file when exceeded.
+	MaxBytes int64         `json:"max_bytes,omitempty"`
+	MaxAge   time.Duration `json:"max_age,omitempty"`
+}
+
+// ConnectorHTTPSink posts each record to a webhook. Any 2xx status is a success.
+type ConnectorHTTPSink struct {
+	URL     string            `json:"url"`
+	Headers map[string]string `json:"headers,omitempty"`
+	Timeout time.Duration     `json:"timeout,omitempty"`
+}
+
+// ConnectorExecSink runs a command for each record, passing it on stdin.
+// A zero exit status is a success.
+type ConnectorExecSink struct {
+	Command string        `json:"command"`
+	Args    []string      `json:"args,omitempty"`
+	Timeout time.Duration `json:"timeout,omitempty"`
+}
+
+// StreamConnectorInfo reports the progress of a stream connector.
+type StreamConnectorInfo struct {
+	Name string `json:"name"`
+	// Delivered is the stream sequence of the last message written to the sink.
+	Delivered uint64 `json:"delivered"`
+	// Lag is the number of messages not yet written to the sink.
+	Lag           uint64     `json:"lag"`
+	Errors        uint64     `json:"errors,omitempty"`
+	LastError     string     `json:"last_error,omitempty"`
+	LastErrorTime *time.Time `json:"last_error_time,omitempty"`
+}
+
+// ConnectorRecord is what connectors write to their sinks, JSON encoded.
+type ConnectorRecord struct {
+	Stream   string              `json:"stream"`
+	Subject  string              `json:"subject"`
+	Sequence uint64              `json:"seq"`
+	Time     time.Time           `json:"time"`
+	Headers  map[string][]string `json:"hdrs,omitempty"`
+	Data     []byte              `json:"data,omitempty"`
+}
+
+const (
+	// Prefix of the durable consumers used by connectors.
+	connectorConsumerPrefix = "connector-"
+	// Deliver subject of the connector's consumer, for stream and connector names.
+	connectorDeliverT = "$JSC.CONNECTOR.%s.%s"
+
+	connectorFormatJSONL   = "jsonl"
+	connectorFormatParquet = "parquet"
+	// Suffix of the file holding the records of a Parquet sink until rotated.
+	connectorPendingSuffix = ".pending"
+
+	// Default timeout for the HTTP and exec sinks.
+	connectorDefaultTimeout = 10 * time.Second
+	// How long we wait for the consumer create response.
+	connectorSetupTimeout = 5 * time.Second
+	// Bounds of the retry backoff for consumer setup and sink errors.
+	connectorRetryMin = 250 * time.Millisecond
+	connectorRetryMax = 30 * time.Second
+)
+
+func (sc *StreamConnector) clone() *StreamConnector {
+	clone := *sc
+	if sc.File != nil {
+		file := *sc.File
+		clone.File = &file
+	}
+	if sc.HTTP != nil {
+		hs := *sc.HTTP
+		if sc.HTTP.Headers != nil {
+			hs.Headers = make(map[string]string, len(sc.HTTP.Headers))
+			for k, v := range sc.HTTP.Headers {
+				hs.Headers[k] = v
+			}
+		}
+		clone.HTTP = &hs
+	}
+	if sc.Exec != nil {
+		es := *sc.Exec
+		es.Args = slices.Clone(sc.Exec.Args)
+		clone.Exec = &es
+	}
+	return &clone
+}
+
+// Returns the timeout of the sink, if it has one.
+func (sc *StreamConnector) timeout() time.Duration {
+	var to time.Duration
+	switch {
+	case sc.HTTP != nil:
+		to = sc.HTTP.Timeout
+	case sc.Exec != nil:
+		to = sc.Exec.Timeout
+	default:
+		return 0
+	}
+	if to == 0 {
+		to = connectorDefaultTimeout
+	}
+	return to
+}
+
+// Checks the connectors of the stream config against what the server permits.
+func (s *Server) checkStreamConnectors(cfg *StreamConfig) error {
+	if cfg.Retention == WorkQueuePolicy {
+		return errors.New("connectors can not be used with work queue retention")
+	}
+	copts := s.getOpts().JetStreamConnectors
+	names := make(map[string]struct{}, len(cfg.Connectors))
+	for _, sc := range cfg.Connectors {
+		if sc == nil {
+			return errors.New("connector can not be empty")
+		}
+		if !isValidName(sc.Name) {
+			return fmt.Errorf("connector name %q is not valid", sc.Name)
+		}
+		if _, ok := names[sc.Name]; ok {
+			return fmt.Errorf("duplicate connector name %q", sc.Name)
+		}
+		names[sc.Name] = struct{}{}
+		if sc.FilterSubject != _EMPTY_ && !IsValidSubject(sc.FilterSubject) {
+			return fmt.Errorf("connector %q filter subject is not valid", sc.Name)
+		}
+
+		var sinks int
+		if fs := sc.File; fs != nil {
+			sinks++
+			if copts.FileDir == _EMPTY_ {
+				return fmt.Errorf("connector %q file sinks are not enabled", sc.Name)
+			}
+			if fs.Path == _EMPTY_ || !filepath.IsLocal(fs.Path) {
+				return fmt.Errorf("connector %q file path must be relative to the stream's connector directory", sc.Name)
+			}
+			switch strings.ToLower(fs.Format) {
+			case _EMPTY_, connectorFormatJSONL, connectorFormatParquet:
+			default:
+				return fmt.Errorf("connector %q unknown file format %q", sc.Name, fs.Format)
+			}
+			if fs.MaxBytes < 0 || fs.MaxAge < 0 {
+				return fmt.Errorf("connector %q file rotation limits must not be negative", sc.Name)
+			}
+		}
+		if hs := sc.HTTP; hs != nil {
+			sinks++
+			if !copts.AllowHTTP {
+				return fmt.Errorf("connector %q HTTP sinks are not enabled", sc.Name)
+			}
+			u, err := url.Parse(hs.URL)
+			if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == _EMPTY_ {
+				return fmt.Errorf("connector %q URL %q is not valid", sc.Name, hs.URL)
+			}
+			if hs.Timeout < 0 {
+				return fmt.Errorf("connector %q timeout must not be negative", sc.Name)
+			}
+		}
+		if es := sc.Exec; es != nil {
+			sinks++
+			if !slices.Contains(copts.ExecCommands, es.Command) {
+				return fmt.Errorf("connector %q command %q is not permitted", sc.Name, es.Command)
+			}
+			if es.Timeout < 0 {
+				return fmt.Errorf("connector %q timeout must not be negative", sc.Name)
+			}
+		}
+		if sinks != 1 {
+			return fmt.Errorf("connector %q must have exactly one sink", sc.Name)
+		}
+	}
+	return nil
+}
+
+// connectorSink writes encoded records to an external system.
+// Sinks are only used from the connector's go routine.
+type connectorSink interface {
+	write(rec []byte) error
+	close() error
+}
+
+// Runtime state of a stream connector.
+type streamConnector struct {
+	mu     sync.Mutex
+	mset   *stream
+	acc    string
+	stream string
+	cfg    *StreamConnector
+	sink   connectorSink
+	cname  string // Durable consumer name.
+	dsubj  string // Deliver subject.
+	msgs   *ipQueue[*connectorMsg]
+	qch    chan struct{}
+
+	closed bool
+	sub    *subscription
+	crSub  *subscription // Pending consumer create response.
+	timer  *time.Timer
+	fails  int // Consecutive consumer setup failures.
+
+	delivered uint64
+	lag       uint64
+	lagging   bool
+	sfails    int // Consecutive sink failures.
+	errs      uint64
+	lerr      string
+	lerrt     time.Time
+}
+
+type connectorMsg struct {
+	subj  string
+	reply string
+	hdr   []byte
+	msg   []byte
+}
+
+// Starts all configured connectors.
+// Lock should be held.
+func (mset *stream) startConnectors() {
+	for _, cfg := range mset.cfg.Connectors {
+		mset.startConnector(cfg)
+	}
+}
+
+// Lock should be held.
+func (mset *stream) startConnector(cfg *StreamConnector) {
+	s := mset.srv
+	sink, err := s.newConnectorSink(mset.acc.Name, mset.cfg.Name, cfg)
+	if err != nil {
+		s.Warnf("Unable to start connector '%s' for stream '%s > %s': %v", cfg.Name, mset.acc.Name, mset.cfg.Name, err)
+		return
+	}
+	sc := &streamConnector{
+		mset:   mset,
+		acc:    mset.acc.Name,
+		stream: mset.cfg.Name,
+		cfg:    cfg.clone(),
+		sink:   sink,
+		cname:  connectorConsumerPrefix + cfg.Name,
+		dsubj:  fmt.Sprintf(connectorDeliverT, mset.cfg.Name, cfg.Name),
+		qch:    make(chan struct{}),
+	}
+	qname := fmt.Sprintf("[ACC:%s] stream '%s' connector '%s' msgs", mset.acc.Name, mset.cfg.Name, cfg.Name)
+	sc.msgs = newIPQueue[*connectorMsg](s, qname)
+
+	sub, err := mset.subscribeInternal(sc.dsubj, sc.deliver)
+	if err != nil {
+		s.Warnf("Unable to start connector '%s' for stream '%s > %s': %v", cfg.Name, mset.acc.Name, mset.cfg.Name, err)
+		sc.msgs.unregister()
+		sink.close()
+		return
+	}
+	sc.sub = sub
+	if mset.connectors == nil {
+		mset.connectors = make(map[string]*streamConnector)
+	}
+	mset.connectors[cfg.Name] = sc
+
+	s.startGoRoutine(func() { sc.processMsgs() },
+		pprofLabels{
+			"type":      "connector",
+			"account":   mset.acc.Name,
+			"stream":    mset.cfg.Name,
+			"connector": cfg.Name,
+		},
+	)
+	sc.setupConsumer()
+}
+
+// Stops all running connectors, their durable consumers are kept.
+// Lock should be held.
+func (mset *stream) stopConnectors() {
+	for name, sc := range mset.connectors {
+		sc.stop()
+		delete(mset.connectors, name)
+	}
+}
+
+// Applies a change of the configured connectors. Connectors that were removed
+// also have their durable consumer deleted.
+// Lock should be held.
+func (mset *stream) updateConnectors(old, cfgs []*StreamConnector) {
+	for _, ocfg := range old {
+		idx := slices.IndexFunc(cfgs, func(c *StreamConnector) bool { return c.Name == ocfg.Name })
+		if idx >= 0 && reflect.DeepEqual(ocfg, cfgs[idx]) {
+			continue
+		}
+		if sc := mset.connectors[ocfg.Name]; sc != nil {
+			sc.stop()
+			delete(mset.connectors, ocfg.Name)
+		}
+		if idx < 0 {
+			subj := fmt.Sprintf(JSApiConsumerDeleteT, mset.cfg.Name, connectorConsumerPrefix+ocfg.Name)
+			mset.outq.send(newJSPubMsg(subj, _EMPTY_, _EMPTY_, nil, nil, nil, 0))
+		}
+	}
+	for _, cfg := range cfgs {
+		if mset.connectors[cfg.Name] == nil {
+			mset.startConnector(cfg)
+		}
+	}
+}
+
+// Returns the state of the running connectors.
+func (mset *stream) connectorsInfo() []*StreamConnectorInfo {
+	mset.mu.RLock()
+	defer mset.mu.RUnlock()
+	if len(mset.connectors) == 0 {
+		return nil
+	}
+	cis := make([]*StreamConnectorInfo, 0, len(mset.connectors))
+	for _, cfg := range mset.cfg.Connectors {
+		if sc := mset.connectors[cfg.Name]; sc != nil {
+			cis = append(cis, sc.info())
+		}
+	}
+	return cis
+}
+
+func (sc *streamConnector) info() *StreamConnectorInfo {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+	ci := &StreamConnectorInfo{
+		Name:      sc.cfg.Name,
+		Delivered: sc.delivered,
+		Lag:       sc.lag,
+		Errors:    sc.errs,
+		LastError: sc.lerr,
+	}
+	if !sc.lerrt.IsZero() {
+		lerrt := sc.lerrt
+		ci.LastErrorTime = &lerrt
+	}
+	return ci
+}
+
+func (sc *streamConnector) stop() {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+	if sc.closed {
+		return
+	}
+	sc.closed = true
+	if sc.timer != nil {
+		sc.timer.Stop()
+		sc.timer = nil
+	}
+	sc.mset.unsubscribe(sc.crSub)
+	sc.mset.unsubscribe(sc.sub)
+	sc.crSub, sc.sub = nil, nil
+	close(sc.qch)
+}
+
+// Creates or updates the durable consumer of the connector.
+func (sc *streamConnector) setupConsumer() {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+	if sc.closed {
+		return
+	}
+	mset, stream := sc.mset, sc.stream
+
+	// Leave enough time for the sink to respond before the message is redelivered.
+	ackWait := max(2*sc.cfg.timeout(), JsAckWaitDefault)
+	req := &CreateConsumerRequest{
+		Stream: stream,
+		Config: ConsumerConfig{
+			Durable:        sc.cname,
+			Description:    fmt.Sprintf("Stream connector %q", sc.cfg.Name),
+			DeliverSubject: sc.dsubj,
+			DeliverPolicy:  DeliverAll,
+			AckPolicy:      AckExplicit,
+			AckWait:        ackWait,
+			MaxDeliver:     -1,
+			// Keeps records in stream order.
+			MaxAckPending: 1,
+			FilterSubject: sc.cfg.FilterSubject,
+		},
+	}
+	b, _ := json.Marshal(req)
+
+	reply := infoReplySubject()
+	crSub, err := mset.subscribeInternal(reply, func(sub *subscription, c *client, _ *Account, subject, reply string, rmsg []byte) {
+		_, msg := c.msgParts(rmsg)
+		var ccr JSApiConsumerCreateResponse
+		err := json.Unmarshal(msg, &ccr)
+		if err == nil && ccr.Error != nil {
+			err = ccr.Error
+		}
+		sc.consumerCreated(sub, err)
+	})
+	if err != nil {
+		sc.retrySetupLocked(err)
+		return
+	}
+	sc.crSub = crSub
+	sc.timer = time.AfterFunc(connectorSetupTimeout, func() {
+		sc.consumerCreated(crSub, errors.New("timeout waiting for consumer create response"))
+	})
+	mset.outq.send(newJSPubMsg(fmt.Sprintf(JSApiDurableCreateT, stream, sc.cname), _EMPTY_, reply, nil, b, nil, 0))
+}
+
+// Handles the outcome of the consumer create request.
+func (sc *streamConnector) consumerCreated(sub *subscription, err error) {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+	if sc.closed || sc.crSub != sub {
+		return
+	}
+	sc.mset.unsubscribe(sub)
+	sc.crSub = nil
+	if sc.timer != nil {
+		sc.timer.Stop()
+		sc.timer = nil
+	}
+	if err != nil {
+		sc.retrySetupLocked(err)
+		return
+	}
+	sc.fails = 0
+}
+
+// Lock should be held.
+func (sc *streamConnector) retrySetupLocked(err error) {
+	sc.mset.srv.Warnf("JetStream error setting up connector '%s' for stream '%s > %s': %v",
+		sc.cfg.Name, sc.acc, sc.stream, err)
+	sc.lerr, sc.lerrt = err.Error(), time.Now().UTC()
+	sc.fails++
+	sc.timer = time.AfterFunc(connectorBackoff(sc.fails), sc.setupConsumer)
+}
+
+// Returns the delay before the next retry after a number of consecutive failures.
+func connectorBackoff(fails int) time.Duration {
+	d := connectorRetryMin
+	for i := 1; i < fails && d < connectorRetryMax; i++ {
+		d *= 2
+	}
+	return min(d, connectorRetryMax)
+}
+
+// Callback for messages delivered by the connector's consumer.
+func (sc *streamConnector) deliver(_ *subscription, c *client, _ *Account, subject, reply string, rmsg []byte) {
+	if !strings.HasPrefix(reply, jsAckPre) {
+		return
+	}
+	hdr, msg := c.msgParts(rmsg)
+	sc.msgs.push(&connectorMsg{subj: subject, reply: reply, hdr: copyBytes(hdr), msg: copyBytes(msg)})
+}
+
+// Writes delivered messages to the sink.
+func (sc *streamConnector) processMsgs() {
+	s := sc.mset.srv
+	defer s.grWG.Done()
+	defer sc.msgs.unregister()
+	defer sc.sink.close()
+
+	for {
+		select {
+		case <-s.quitCh:
+			return
+		case <-sc.qch:
+			return
+		case <-sc.msgs.ch:
+			ms := sc.msgs.pop()
+			for _, m := range ms {
+				select {
+				case <-sc.qch:
+					return
+				default:
+				}
+				sc.processMsg(m)
+			}
+			sc.msgs.recycle(&ms)
+		}
+	}
+}
+
+func (sc *streamConnector) processMsg(m *connectorMsg) {
+	sseq, _, dc, ts, pending := replyInfo(m.reply)
+	if sseq == 0 {
+		return
+	}
+	mset := sc.mset
+
+	// Redelivered after we wrote it, for instance if the ack was lost.
+	sc.mu.Lock()
+	dup := sseq <= sc.delivered
+	sc.mu.Unlock()
+	if dup {
+		sc.ack(m.reply, AckAck)
+		return
+	}
+
+	rec := &ConnectorRecord{
+		Stream:   sc.stream,
+		Subject:  m.subj,
+		Sequence: sseq,
+		Time:     time.Unix(0, ts).UTC(),
+		Headers:  natsHeaderMap(m.hdr),
+		Data:     m.msg,
+	}
+	b, err := json.Marshal(rec)
+	if err == nil {
+		err = sc.sink.write(b)
+	}
+	if err != nil {
+		sc.mu.Lock()
+		sc.sfails++
+		sc.errs++
+		sc.lerr, sc.lerrt = err.Error(), time.Now().UTC()
+		sc.lag = pending + 1
+		delay := connectorBackoff(sc.sfails)
+		sc.mu.Unlock()
+
+		nak, _ := json.Marshal(&ConsumerNakOptions{Delay: delay})
+		sc.ack(m.reply, append(append(slices.Clone(AckNak), ' '), nak...))
+		mset.srv.Warnf("JetStream connector '%s' for stream '%s > %s' failed to write message %d: %v",
+			sc.cfg.Name, sc.acc, sc.stream, sseq, err)
+		mset.sendConnectorErrorAdvisory(sc.cfg.Name, sseq, dc, err)
+		return
+	}
+	sc.ack(m.reply, AckAck)
+
+	sc.mu.Lock()
+	sc.delivered, sc.lag, sc.sfails = sseq, pending, 0
+	var sendLag bool
+	if maxLag := sc.cfg.MaxLag; maxLag > 0 {
+		if pending > maxLag && !sc.lagging {
+			sc.lagging, sendLag = true, true
+		} else if pending <= maxLag {
+			sc.lagging = false
+		}
+	}
+	sc.mu.Unlock()
+
+	if sendLag {
+		mset.sendConnectorLagAdvisory(sc.cfg.Name, pending, sc.cfg.MaxLag)
+	}
+}
+
+func (sc *streamConnector) ack(reply string, body []byte) {
+	sc.mset.outq.send(newJSPubMsg(reply, _EMPTY_, _EMPTY_, nil, body, nil, 0))
+}
+
+// Returns the message headers as a map, nil if there are none.
+func natsHeaderMap(hdr []byte) map[string][]string {
+	if len(hdr) <= len(hdrLine) {
+		return nil
+	}
+	tp := textproto.NewReader(bufio.NewReader(bytes.NewReader(hdr)))
+	tp.ReadLine() // Skip over the version line.
+	h, err := tp.ReadMIMEHeader()
+	if err != nil || len(h) == 0 {
+		return nil
+	}
+	return h
+}
+
+func (mset *stream) sendConnectorErrorAdvisory(connector string, sseq, dc uint64, err error) {
+	s := mset.srv
+	stream, acc := mset.name(), mset.account()
+	subj := JSAdvisoryStreamConnectorErrorPre + "." + stream + "." + connector
+	adv := &JSStreamConnectorErrorAdvisory{
+		TypedEvent: TypedEvent{
+			Type: JSStreamConnectorErrorAdvisoryType,
+			ID:   nuid.Next(),
+			Time: time.Now().UTC(),
+		},
+		Stream:     stream,
+		Domain:     s.getOpts().JetStreamDomain,
+		Connector:  connector,
+		StreamSeq:  sseq,
+		Deliveries: dc,
+		Error:      err.Error(),
+	}
+
+	// Send to the user's account if not the system account.
+	if acc != s.SystemAccount() {
+		s.publishAdvisory(acc, subj, adv)
+	}
+	// Now do system level one. Place account info in adv, and nil account means system.
+	adv.Account = acc.GetName()
+	s.publishAdvisory(nil, subj, adv)
+}
+
+func (mset *stream) sendConnectorLagAdvisory(connector string, lag, maxLag uint64) {
+	s := mset.srv
+	stream, acc := mset.name(), mset.account()
+	subj := JSAdvisoryStreamConnectorLagPre + "." + stream + "." + connector
+	adv := &JSStreamConnectorLagAdvisory{
+		TypedEvent: TypedEvent{
+			Type: JSStreamConnectorLagAdvisoryType,
+			ID:   nuid.Next(),
+			Time: time.Now().UTC(),
+		},
+		Stream:    stream,
+		Domain:    s.getOpts().JetStreamDomain,
+		Connector: connector,
+		Lag:       lag,
+		MaxLag:    maxLag,
+	}
+
+	if acc != s.SystemAccount() {
+		s.publishAdvisory(acc, subj, adv)
+	}
+	adv.Account = acc.GetName()
+	s.publishAdvisory(nil, subj, adv)
+}
+
+// Creates the sink for the connector of the stream.
+func (s *Server) newConnectorSink(account, stream string, cfg *StreamConnector) (connectorSink, error) {
+	switch {
+	case cfg.File != nil:
+		dir := s.getOpts().JetStreamConnectors.FileDir
+		if dir == _EMPTY_ {
+			return nil, errors.New("file sinks are not enabled")
+		}
+		// Streams of different accounts can not write to each other's files.
+		if !filepath.IsLocal(account) || !filepath.IsLocal(stream) || !filepath.IsLocal(cfg.File.Path) ||
+			strings.ContainsAny(account, `/\`) || strings.ContainsAny(stream, `/\`) {
+			return nil, errors.New("file path is not valid")
+		}
+		return &connectorFileSink{
+			path:     filepath.Join(dir, account, stream, cfg.File.Path),
+			parquet:  strings.EqualFold(cfg.File.Format, connectorFormatParquet),
+			maxBytes: cfg.File.MaxBytes,
+			maxAge:   cfg.File.MaxAge,
+		}, nil
+	case cfg.HTTP != nil:
+		return &connectorHTTPSink{
+			url:     cfg.HTTP.URL,
+			headers: cfg.HTTP.Headers,
+			client:  &http.Client{Timeout: cfg.timeout()},
+		}, nil
+	case cfg.Exec != nil:
+		return &connectorExecSink{
+			command: cfg.Exec.Command,
+			args:    cfg.Exec.Args,
+			timeout: cfg.timeout(),
+		}, nil
+	}
+	return nil, errors.New("no sink configured")
+}
+
+// Appends records as JSON lines, rotating the file by renaming it with a
+// timestamp suffix once it exceeds its size or age. For Parquet the lines
+// are appended to the pending file instead, and written as a Parquet file
+// with the timestamp suffix when rotated.
+type connectorFileSink struct {
+	path     string
+	parquet  bool
+	maxBytes int64
+	maxAge   time.Duration
+	f        *os.File
+	size     int64
+	opened   time.Time
+}
+
+func (fs *connectorFileSink) write(rec []byte) error {
+	if fs.f != nil && fs.size > 0 &&
+		((fs.maxBytes > 0 && fs.size+int64(len(rec))+1 > fs.maxBytes) ||
+			(fs.maxAge > 0 && time.Since(fs.opened) > fs.maxAge)) {
+		if err := fs.rotate(); err != nil {
+			return err
+		}
+	}
+	if fs.f == nil {
+		if err := fs.open(); err != nil {
+			return err
+		}
+	}
+	n, err := fs.f.Write(append(rec, '\n'))
+	fs.size += int64(n)
+	if err != nil {
+		return err
+	}
+	// Make sure the record is durable before it is acknowledged.
+	return fs.f.Sync()
+}
+
+func (fs *connectorFileSink) open() error {
+	if err := os.MkdirAll(filepath.Dir(fs.path), defaultDirPerms); err != nil {
+		return err
+	}
+	fn := fs.path
+	if fs.parquet {
+		fn += connectorPendingSuffix
+	}
+	f, err := os.OpenFile(fn, os.O_CREATE|os.O_WRONLY|os.O_APPEND, defaultFilePerms)
+	if err != nil {
+		return err
+	}
+	fi, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+	fs.f, fs.size, fs.opened = f, fi.Size(), time.Now()
+	return nil
+}
+
+func (fs *connectorFileSink) rotate() error {
+	if err := fs.closeFile(); err != nil {
+		return err
+	}
+	if fs.parquet {
+		return fs.writeParquet(fs.rotatedPath())
+	}
+	return os.Rename(fs.path, fs.rotatedPath())
+}
+
+func (fs *connectorFileSink) rotatedPath() string {
+	ext := filepath.Ext(fs.path)
+	return fmt.Sprintf("%s-%s%s", strings.TrimSuffix(fs.path, ext), time.Now().UTC().Format("20060102T150405.000000000Z"), ext)
+}
+
+// Writes the pending records to the Parquet file and removes them.
+func (fs *connectorFileSink) writeParquet(fn string) error {
+	pending := fs.path + connectorPendingSuffix
+	buf, err := os.ReadFile(pending)
+	if err != nil {
+		return err
+	}
+	var recs []*ConnectorRecord
+	for _, line := range bytes.Split(buf, []byte("\n")) {
+		if len(line) == 0 {
+			continue
+		}
+		var rec ConnectorRecord
+		// A partial line was never acknowledged, so is redelivered.
+		if err := json.Unmarshal(line, &rec); err != nil {
+			continue
+		}
+		recs = append(recs, &rec)
+	}
+	if len(recs) > 0 {
+		tmp := fn + ".tmp"
+		f, err := os.OpenFile(tmp, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, defaultFilePerms)
+		if err != nil {
+			return err
+		}
+		w := bufio.NewWriter(f)
+		err = writeConnectorParquet(w, recs)
+		if err == nil {
+			err = w.Flush()
+		}
+		if err == nil {
+			err = f.Sync()
+		}
+		if cerr := f.Close(); err == nil {
+			err = cerr
+		}
+		if err == nil {
+			err = os.Rename(tmp, fn)
+		}
+		if err != nil {
+			os.Remove(tmp)
+			return err
+		}
+	}
+	return os.Remove(pending)
+}
+
+func (fs *connectorFileSink) close() error {
+	if fs.f == nil {
+		return nil
+	}
+	pending := fs.size > 0
+	if err := fs.closeFile(); err != nil {
+		return err
+	}
+	// Make the pending records available as a Parquet file.
+	if fs.parquet && pending {
+		return fs.writeParquet(fs.rotatedPath())
+	}
+	return nil
+}
+
+func (fs *connectorFileSink) closeFile() error {
+	if fs.f == nil {
+		return nil
+	}
+	err := fs.f.Close()
+	fs.f = nil
+	return err
+}
+
+// Posts records to a webhook.
+type connectorHTTPSink struct {
+	url     string
+	headers map[string]string
+	client  *http.Client
+}
+
+func (hs *connectorHTTPSink) write(rec []byte) error {
+	req, err := http.NewRequest(http.MethodPost, hs.url, bytes.NewReader(rec))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range hs.headers {
+		req.Header.Set(k, v)
+	}
+	resp, err := hs.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64*1024))
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("unexpected HTTP status %q", resp.Status)
+	}
+	return nil
+}
+
+func (hs *connectorHTTPSink) close() error {
+	hs.client.CloseIdleConnections()
+	return nil
+}
+
+// Runs a command for each record, passing it on stdin.
+type connectorExecSink struct {
+	command string
+	args    []string
+	timeout time.Duration
+}
+
+func (es *connectorExecSink) write(rec []byte) error {
+	ctx, cancel := context.WithTimeout(context.Background(), es.timeout)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, es.command, es.args...)
+	cmd.Stdin = bytes.NewReader(append(rec, '\n'))
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if out := strings.TrimSpace(stderr.String()); out != _EMPTY_ {
+			const maxOut = 256
+			if len(out) > maxOut {
+				out = out[:maxOut]
+			}
+			return fmt.Errorf("%v: %s", err, out)
+		}
+		return err
+	}
+	return nil
+}
+
+func (es *connectorExecSink) close() error { return nil }
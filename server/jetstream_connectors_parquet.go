@@ -0,0 +1,342 @@
+// Copyright 2025 The NATS Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+// Minimal Parquet writer for the file sinks of stream connectors.
+// Records are written as a single row group, with one uncompressed and
+// plain encoded data page per column. The metadata is thrift compact encoded.
+
+const parquetMagic = "PAR1"
+
+const (
+	parquetTypeInt64     = 2
+	parquetTypeByteArray = 6
+
+	parquetRequired = 0
+	parquetOptional = 1
+
+	parquetConvertedUTF8   = 0
+	parquetConvertedUint64 = 14
+	parquetConvertedJSON   = 19
+
+	parquetEncodingPlain = 0
+	parquetEncodingRLE   = 3
+
+	parquetCodecUncompressed = 0
+	parquetPageData          = 0
+)
+
+// Logical type union members of the schema elements.
+const (
+	parquetLogicalString    = 1
+	parquetLogicalTimestamp = 8
+	parquetLogicalInteger   = 10
+	parquetLogicalJSON      = 12
+
+	parquetTimeUnitNanos = 3
+)
+
+// A column of the connector records.
+type parquetColumn struct {
+	name      string
+	typ       int32
+	optional  bool
+	converted int32 // Converted type, -1 if none.
+	logical   func(w *thriftCompactWriter)
+	values    []byte // Plain encoded values.
+	defs      []bool // Whether each value is present, for optional columns.
+}
+
+func (pc *parquetColumn) appendInt64(v int64) {
+	pc.values = binary.LittleEndian.AppendUint64(pc.values, uint64(v))
+}
+
+func (pc *parquetColumn) appendBytes(b []byte) {
+	if pc.optional {
+		pc.defs = append(pc.defs, b != nil)
+		if b == nil {
+			return
+		}
+	}
+	pc.values = binary.LittleEndian.AppendUint32(pc.values, uint32(len(b)))
+	pc.values = append(pc.values, b...)
+}
+
+// Returns the data page of the column, for the number of rows.
+func (pc *parquetColumn) page(rows int) []byte {
+	var body []byte
+	if pc.optional {
+		// Definition levels are RLE encoded with a bit width of 1, prefixed by their length.
+		var levels []byte
+		for i := 0; i < len(pc.defs); {
+			j := i + 1
+			for j < len(pc.defs) && pc.defs[j] == pc.defs[i] {
+				j++
+			}
+			levels = binary.AppendUvarint(levels, uint64(j-i)<<1)
+			if pc.defs[i] {
+				levels = append(levels, 1)
+			} else {
+				levels = append(levels, 0)
+			}
+			i = j
+		}
+		body = binary.LittleEndian.AppendUint32(body, uint32(len(levels)))
+		body = append(body, levels...)
+	}
+	body = append(body, pc.values...)
+
+	var w thriftCompactWriter
+	w.begin()
+	w.i32Field(1, parquetPageData)
+	w.i32Field(2, int32(len(body)))
+	w.i32Field(3, int32(len(body)))
+	w.structField(5)
+	w.i32Field(1, int32(rows))
+	w.i32Field(2, parquetEncodingPlain)
+	w.i32Field(3, parquetEncodingRLE)
+	w.i32Field(4, parquetEncodingRLE)
+	w.end()
+	w.end()
+	return append(w.buf, body...)
+}
+
+// Writes the records as a Parquet file.
+func writeConnectorParquet(out io.Writer, recs []*ConnectorRecord) error {
+	str := func(w *thriftCompactWriter) {
+		w.structField(parquetLogicalString)
+		w.end()
+	}
+	cols := []*parquetColumn{
+		{name: "stream", typ: parquetTypeByteArray, converted: parquetConvertedUTF8, logical: str},
+		{name: "subject", typ: parquetTypeByteArray, converted: parquetConvertedUTF8, logical: str},
+		{name: "seq", typ: parquetTypeInt64, converted: parquetConvertedUint64, logical: func(w *thriftCompactWriter) {
+			w.structField(parquetLogicalInteger)
+			w.byteField(1, 64)
+			w.boolField(2, false)
+			w.end()
+		}},
+		{name: "time", typ: parquetTypeInt64, converted: -1, logical: func(w *thriftCompactWriter) {
+			w.structField(parquetLogicalTimestamp)
+			w.boolField(1, true)
+			w.structField(2)
+			w.structField(parquetTimeUnitNanos)
+			w.end()
+			w.end()
+			w.end()
+		}},
+		{name: "hdrs", typ: parquetTypeByteArray, optional: true, converted: parquetConvertedJSON, logical: func(w *thriftCompactWriter) {
+			w.structField(parquetLogicalJSON)
+			w.end()
+		}},
+		{name: "data", typ: parquetTypeByteArray, optional: true, converted: -1},
+	}
+	for _, rec := range recs {
+		cols[0].appendBytes([]byte(rec.Stream))
+		cols[1].appendBytes([]byte(rec.Subject))
+		cols[2].appendInt64(int64(rec.Sequence))
+		cols[3].appendInt64(rec.Time.UnixNano())
+		var hdrs []byte
+		if len(rec.Headers) > 0 {
+			var err error
+			if hdrs, err = json.Marshal(rec.Headers); err != nil {
+				return err
+			}
+		}
+		cols[4].appendBytes(hdrs)
+		var data []byte
+		if len(rec.Data) > 0 {
+			data = rec.Data
+		}
+		cols[5].appendBytes(data)
+	}
+
+	if _, err := io.WriteString(out, parquetMagic); err != nil {
+		return err
+	}
+	offset := int64(len(parquetMagic))
+	offsets := make([]int64, len(cols))
+	sizes := make([]int64, len(cols))
+	for i, pc := range cols {
+		page := pc.page(len(recs))
+		if _, err := out.Write(page); err != nil {
+			return err
+		}
+		offsets[i], sizes[i] = offset, int64(len(page))
+		offset += int64(len(page))
+	}
+
+	var w thriftCompactWriter
+	w.begin()
+	w.i32Field(1, 1)
+	w.listField(2, thriftStruct, len(cols)+1)
+	w.begin()
+	w.binaryField(4, []byte("schema"))
+	w.i32Field(5, int32(len(cols)))
+	w.end()
+	for _, pc := range cols {
+		w.begin()
+		w.i32Field(1, pc.typ)
+		if pc.optional {
+			w.i32Field(3, parquetOptional)
+		} else {
+			w.i32Field(3, parquetRequired)
+		}
+		w.binaryField(4, []byte(pc.name))
+		if pc.converted >= 0 {
+			w.i32Field(6, pc.converted)
+		}
+		if pc.logical != nil {
+			w.structField(10)
+			pc.logical(&w)
+			w.end()
+		}
+		w.end()
+	}
+	w.i64Field(3, int64(len(recs)))
+	w.listField(4, thriftStruct, 1)
+	w.begin()
+	w.listField(1, thriftStruct, len(cols))
+	var total int64
+	for i, pc := range cols {
+		w.begin()
+		w.i64Field(2, offsets[i])
+		w.structField(3)
+		w.i32Field(1, pc.typ)
+		w.listField(2, thriftI32, 2)
+		w.appendI32(parquetEncodingPlain)
+		w.appendI32(parquetEncodingRLE)
+		w.listField(3, thriftBinary, 1)
+		w.appendBinary([]byte(pc.name))
+		w.i32Field(4, parquetCodecUncompressed)
+		w.i64Field(5, int64(len(recs)))
+		w.i64Field(6, sizes[i])
+		w.i64Field(7, sizes[i])
+		w.i64Field(9, offsets[i])
+		w.end()
+		w.end()
+		total += sizes[i]
+	}
+	w.i64Field(2, total)
+	w.i64Field(3, int64(len(recs)))
+	w.end()
+	w.binaryField(6, []byte(fmt.Sprintf("nats-server version %s", VERSION)))
+	w.end()
+
+	footer := binary.LittleEndian.AppendUint32(w.buf, uint32(len(w.buf)))
+	footer = append(footer, parquetMagic...)
+	_, err := out.Write(footer)
+	return err
+}
+
+// Thrift compact protocol types.
+const (
+	thriftBoolTrue  = 1
+	thriftBoolFalse = 2
+	thriftByte      = 3
+	thriftI32       = 5
+	thriftI64       = 6
+	thriftBinary    = 8
+	thriftList      = 9
+	thriftStruct    = 12
+)
+
+// Encodes thrift structs with the compact protocol.
+type thriftCompactWriter struct {
+	buf  []byte
+	last []int16 // Last field id of each open struct.
+}
+
+// Begins a struct, for the top level struct and the elements of lists.
+func (w *thriftCompactWriter) begin() {
+	w.last = append(w.last, 0)
+}
+
+// Ends the innermost struct.
+func (w *thriftCompactWriter) end() {
+	w.buf = append(w.buf, 0)
+	w.last = w.last[:len(w.last)-1]
+}
+
+func (w *thriftCompactWriter) field(id int16, typ byte) {
+	last := &w.last[len(w.last)-1]
+	if d := id - *last; d > 0 && d <= 15 {
+		w.buf = append(w.buf, byte(d)<<4|typ)
+	} else {
+		w.buf = append(w.buf, typ)
+		w.buf = binary.AppendVarint(w.buf, int64(id))
+	}
+	*last = id
+}
+
+func (w *thriftCompactWriter) boolField(id int16, v bool) {
+	if v {
+		w.field(id, thriftBoolTrue)
+	} else {
+		w.field(id, thriftBoolFalse)
+	}
+}
+
+func (w *thriftCompactWriter) byteField(id int16, v int8) {
+	w.field(id, thriftByte)
+	w.buf = append(w.buf, byte(v))
+}
+
+func (w *thriftCompactWriter) i32Field(id int16, v int32) {
+	w.field(id, thriftI32)
+	w.appendI32(v)
+}
+
+func (w *thriftCompactWriter) i64Field(id int16, v int64) {
+	w.field(id, thriftI64)
+	w.buf = binary.AppendVarint(w.buf, v)
+}
+
+func (w *thriftCompactWriter) binaryField(id int16, v []byte) {
+	w.field(id, thriftBinary)
+	w.appendBinary(v)
+}
+
+// Begins a struct field, to be ended with end.
+func (w *thriftCompactWriter) structField(id int16) {
+	w.field(id, thriftStruct)
+	w.begin()
+}
+
+// Writes the header of a list field, to be followed by its n elements.
+func (w *thriftCompactWriter) listField(id int16, elem byte, n int) {
+	w.field(id, thriftList)
+	if n < 15 {
+		w.buf = append(w.buf, byte(n)<<4|elem)
+	} else {
+		w.buf = append(w.buf, 0xf0|elem)
+		w.buf = binary.AppendUvarint(w.buf, uint64(n))
+	}
+}
+
+func (w *thriftCompactWriter) appendI32(v int32) {
+	w.buf = binary.AppendVarint(w.buf, int64(v))
+}
+
+func (w *thriftCompactWriter) appendBinary(v []byte) {
+	w.buf = binary.AppendUvarint(w.buf, uint64(len(v)))
+	w.buf = append(w.buf, v...)
+}
@@ -23,7 +23,10 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"math/rand"
+	"net/http"
+	"net/http/httptest"
 	"net/url"
 	"reflect"
 	"runtime"
@@ -10286,3 +10289,398 @@ func TestJetStreamConsumerMaxDeliverUnderflow(t *testing.T) {
 	o.mu.RUnlock()
 	require_Equal(t, maxdc, 0)
 }
+
+func TestJetStreamConsumerReplaySpeedAndRate(t *testing.T) {
+	s := RunBasicJetStreamServer(t)
+	defer s.Shutdown()
+
+	nc := clientConnectToServer(t, s)
+	defer nc.Close()
+
+	mset, err := s.GlobalAccount().addStream(&StreamConfig{Name: "TEST", Subjects: []string{"foo"}})
+	require_NoError(t, err)
+	defer mset.delete()
+
+	// Original gap of 200ms between each message.
+	totalMsgs := 5
+	for i := 0; i < totalMsgs; i++ {
+		sendStreamMsg(t, nc, "foo", "Hello World!")
+		if i < totalMsgs-1 {
+			time.Sleep(200 * time.Millisecond)
+		}
+	}
+
+	// Replay speed requires the original replay policy and must be positive.
+	_, err = mset.addConsumer(&ConsumerConfig{Durable: "A", AckPolicy: AckExplicit, ReplaySpeed: 10})
+	require_Error(t, err, NewJSConsumerReplaySpeedRequiresOriginalError())
+	_, err = mset.addConsumer(&ConsumerConfig{Durable: "A", AckPolicy: AckExplicit, ReplayPolicy: ReplayOriginal, ReplaySpeed: -1})
+	require_Error(t, err, NewJSConsumerReplaySpeedInvalidError())
+
+	checkReplay := func(cfg *ConsumerConfig, minElapsed, maxElapsed time.Duration) {
+		t.Helper()
+		sub := natsSubSync(t, nc, nats.NewInbox())
+		defer sub.Unsubscribe()
+		natsFlush(t, nc)
+
+		cfg.DeliverSubject = sub.Subject
+		o, err := mset.addConsumer(cfg)
+		require_NoError(t, err)
+		defer o.delete()
+		require_True(t, o.info().Replaying)
+
+		start := time.Now()
+		for i := 0; i < totalMsgs; i++ {
+			natsNexMsg(t, sub, time.Second)
+		}
+		elapsed := time.Since(start)
+		if elapsed < minElapsed || elapsed > maxElapsed {
+			t.Fatalf("Expected replay to take between %v and %v, took %v", minElapsed, maxElapsed, elapsed)
+		}
+		checkFor(t, time.Second, 10*time.Millisecond, func() error {
+			if o.info().Replaying {
+				return errors.New("expected replay to be done")
+			}
+			return nil
+		})
+	}
+
+	// Original takes ~800ms, 10x should take ~80ms.
+	checkReplay(&ConsumerConfig{ReplayPolicy: ReplayOriginal, ReplaySpeed: 10}, 60*time.Millisecond, 400*time.Millisecond)
+	// Half speed should take ~1600ms.
+	checkReplay(&ConsumerConfig{ReplayPolicy: ReplayOriginal, ReplaySpeed: 0.5}, 1400*time.Millisecond, 2500*time.Millisecond)
+	// Instant with a rate of 10 msgs/sec should take ~400ms.
+	checkReplay(&ConsumerConfig{ReplayPolicy: ReplayInstant, ReplayRate: 10}, 350*time.Millisecond, 800*time.Millisecond)
+}
+
+func TestJetStreamConsumerPartitionedGroup(t *testing.T) {
+	s := RunBasicJetStreamServer(t)
+	defer s.Shutdown()
+
+	nc, js := jsClientConnect(t, s)
+	defer nc.Close()
+
+	mset, err := s.GlobalAccount().addStream(&StreamConfig{Name: "TEST", Subjects: []string{"orders.*"}})
+	require_NoError(t, err)
+
+	// Partition count is required, and only allowed with the partitioned policy.
+	_, err = mset.addConsumer(&ConsumerConfig{Durable: "C", AckPolicy: AckExplicit, PriorityGroups: []string{"G"}, PriorityPolicy: PriorityPartitioned})
+	require_Error(t, err, NewJSConsumerPartitionCountInvalidError())
+	_, err = mset.addConsumer(&ConsumerConfig{Durable: "C", AckPolicy: AckExplicit, PartitionCount: 4})
+	require_Error(t, err, NewJSConsumerPartitionCountWithoutPolicyError())
+
+	// Partitions are fenced on pending messages, so explicit acks are required.
+	for _, ap := range []AckPolicy{AckAll, AckNone} {
+		_, err = mset.addConsumer(&ConsumerConfig{
+			Durable:        "C",
+			AckPolicy:      ap,
+			PriorityGroups: []string{"G"},
+			PriorityPolicy: PriorityPartitioned,
+			PartitionCount: 4,
+		})
+		require_Error(t, err, NewJSConsumerPartitionedAckPolicyError())
+	}
+
+	// Partitions are the same as the partition() subject mapping.
+	tr, err := NewSubjectTransform("orders.*", "{{partition(4)}}")
+	require_NoError(t, err)
+	for i := 0; i < 10; i++ {
+		subj := fmt.Sprintf("orders.%d", i)
+		require_Equal(t, tr.TransformSubject(subj), strconv.Itoa(subjectPartition(subj, 4)))
+	}
+
+	o, err := mset.addConsumer(&ConsumerConfig{
+		Durable:        "C",
+		AckPolicy:      AckExplicit,
+		PriorityGroups: []string{"G"},
+		PriorityPolicy: PriorityPartitioned,
+		PartitionCount: 4,
+		PinnedTTL:      500 * time.Millisecond,
+	})
+	require_NoError(t, err)
+
+	pull := func(member string, expires time.Duration) *nats.Subscription {
+		t.Helper()
+		sub := natsSubSync(t, nc, nats.NewInbox())
+		req := JSApiConsumerGetNextRequest{Batch: 100, Expires: expires, PriorityGroup: PriorityGroup{Group: "G", Id: member}}
+		reqb, err := json.Marshal(req)
+		require_NoError(t, err)
+		require_NoError(t, nc.PublishRequest("$JS.API.CONSUMER.MSG.NEXT.TEST.C", sub.Subject, reqb))
+		natsFlush(t, nc)
+		return sub
+	}
+	publish := func() {
+		t.Helper()
+		for i := 0; i < 10; i++ {
+			_, err := js.Publish(fmt.Sprintf("orders.%d", i), nil)
+			require_NoError(t, err)
+		}
+	}
+	receive := func(sub *nats.Subscription, n int) []*nats.Msg {
+		t.Helper()
+		var msgs []*nats.Msg
+		for i := 0; i < n; i++ {
+			msgs = append(msgs, natsNexMsg(t, sub, time.Second))
+		}
+		return msgs
+	}
+
+	// Requests without a member id are rejected.
+	sub := pull(_EMPTY_, time.Second)
+	msg := natsNexMsg(t, sub, time.Second)
+	require_Equal(t, msg.Header.Get("Status"), "400")
+
+	// Single member gets all partitions.
+	subA := pull("A", 5*time.Second)
+	publish()
+	msgsA := receive(subA, 10)
+	require_Len(t, len(o.info().PriorityGroups[0].Partitions["A"]), 4)
+
+	// Partitions owned by B after it joins.
+	var subjectsB []string
+	for i := 0; i < 10; i++ {
+		subj := fmt.Sprintf("orders.%d", i)
+		if subjectPartition(subj, 4)%2 == 1 {
+			subjectsB = append(subjectsB, subj)
+		}
+	}
+	require_True(t, len(subjectsB) > 0)
+
+	// Member B joins, it should not get anything for its partitions while A has them pending.
+	subB := pull("B", 5*time.Second)
+	publish()
+	_, err = subB.NextMsg(250 * time.Millisecond)
+	require_Error(t, err, nats.ErrTimeout)
+	partitions := o.info().PriorityGroups[0].Partitions
+	require_Equal(t, fmt.Sprint(partitions["A"]), "[0 2]")
+	require_Equal(t, fmt.Sprint(partitions["B"]), "[1 3]")
+
+	// Once A acknowledges, each partition is handed over as its messages are.
+	for _, m := range msgsA {
+		require_NoError(t, m.AckSync())
+	}
+	var received []string
+	for _, m := range receive(subB, len(subjectsB)) {
+		received = append(received, m.Subject)
+		require_NoError(t, m.AckSync())
+	}
+	slices.Sort(received)
+	require_Equal(t, fmt.Sprint(received), fmt.Sprint(subjectsB))
+	for _, m := range receive(subA, 10-len(subjectsB)) {
+		require_False(t, slices.Contains(subjectsB, m.Subject))
+		require_NoError(t, m.AckSync())
+	}
+
+	// Once B stops pulling it is removed from the group and A takes over.
+	require_NoError(t, subB.Unsubscribe())
+	time.Sleep(750 * time.Millisecond)
+	publish()
+	receive(subA, 10)
+	require_Len(t, len(o.info().PriorityGroups[0].Partitions["A"]), 4)
+}
+
+func TestJetStreamConsumerPartitionedGroupIdleMember(t *testing.T) {
+	s := RunBasicJetStreamServer(t)
+	defer s.Shutdown()
+
+	nc, js := jsClientConnect(t, s)
+	defer nc.Close()
+
+	mset, err := s.GlobalAccount().addStream(&StreamConfig{Name: "TEST", Subjects: []string{"orders.*"}})
+	require_NoError(t, err)
+	_, err = mset.addConsumer(&ConsumerConfig{
+		Durable:        "C",
+		AckPolicy:      AckExplicit,
+		PriorityGroups: []string{"G"},
+		PriorityPolicy: PriorityPartitioned,
+		PartitionCount: 2,
+		PinnedTTL:      time.Minute,
+	})
+	require_NoError(t, err)
+
+	pull := func(member string, batch int, expires time.Duration) *nats.Subscription {
+		t.Helper()
+		sub := natsSubSync(t, nc, nats.NewInbox())
+		req := JSApiConsumerGetNextRequest{Batch: batch, Expires: expires, PriorityGroup: PriorityGroup{Group: "G", Id: member}}
+		reqb, err := json.Marshal(req)
+		require_NoError(t, err)
+		require_NoError(t, nc.PublishRequest("$JS.API.CONSUMER.MSG.NEXT.TEST.C", sub.Subject, reqb))
+		natsFlush(t, nc)
+		return sub
+	}
+
+	var subjectsA, subjectsB []string
+	for i := 0; i < 20; i++ {
+		subj := fmt.Sprintf("orders.%d", i)
+		if subjectPartition(subj, 2) == 0 {
+			subjectsA = append(subjectsA, subj)
+		} else {
+			subjectsB = append(subjectsB, subj)
+		}
+	}
+	require_True(t, len(subjectsA) > 0 && len(subjectsB) > 0)
+
+	// Both members join, B's request then expires while it stays in the group.
+	subA := pull("A", 100, 5*time.Second)
+	subB := pull("B", 1, 100*time.Millisecond)
+	_, err = subB.NextMsg(250 * time.Millisecond)
+	require_NoError(t, err) // Request timeout.
+
+	// Messages of B's partition must not block the ones of A.
+	for i := 0; i < 20; i++ {
+		_, err := js.Publish(fmt.Sprintf("orders.%d", i), nil)
+		require_NoError(t, err)
+	}
+	for _, subj := range subjectsA {
+		m := natsNexMsg(t, subA, time.Second)
+		require_Equal(t, m.Subject, subj)
+		require_NoError(t, m.AckSync())
+	}
+
+	// B receives the held back messages in order once it pulls again.
+	subB = pull("B", 100, 5*time.Second)
+	for _, subj := range subjectsB {
+		m := natsNexMsg(t, subB, time.Second)
+		require_Equal(t, m.Subject, subj)
+		meta, err := m.Metadata()
+		require_NoError(t, err)
+		require_Equal(t, meta.NumDelivered, 1)
+		require_NoError(t, m.AckSync())
+	}
+}
+
+func TestJetStreamConsumerWebhook(t *testing.T) {
+	conf := createConfFile(t, []byte(fmt.Sprintf(`
+		listen: 127.0.0.1:-1
+		jetstream: {
+			store_dir: %q
+			connectors: { allow_http: true }
+		}
+	`, t.TempDir())))
+
+	s, _ := RunServerWithConfig(conf)
+	defer s.Shutdown()
+
+	var (
+		mu       sync.Mutex
+		inflight int
+		maxIn    int
+		failed   bool
+		received = make(map[uint64]http.Header)
+	)
+	hs := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		inflight++
+		maxIn = max(maxIn, inflight)
+		mu.Unlock()
+
+		time.Sleep(20 * time.Millisecond)
+		body, _ := io.ReadAll(r.Body)
+		seq, _ := strconv.ParseUint(r.Header.Get(JSSequence), 10, 64)
+
+		mu.Lock()
+		defer mu.Unlock()
+		inflight--
+		// Fail the first delivery of the second message.
+		if seq == 2 && !failed {
+			failed = true
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		if string(body) != "ORDER" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		received[seq] = r.Header
+	}))
+	defer hs.Close()
+
+	nc, js := jsClientConnect(t, s)
+	defer nc.Close()
+
+	_, err := js.AddStream(&nats.StreamConfig{Name: "TEST", Subjects: []string{"orders.>"}})
+	require_NoError(t, err)
+	for i := 0; i < 10; i++ {
+		m := nats.NewMsg(fmt.Sprintf("orders.%d", i))
+		m.Header.Set("Order", strconv.Itoa(i))
+		m.Data = []byte("ORDER")
+		_, err = js.PublishMsg(m)
+		require_NoError(t, err)
+	}
+
+	addConsumer := func(cfg ConsumerConfig) *JSApiConsumerCreateResponse {
+		t.Helper()
+		req, err := json.Marshal(&CreateConsumerRequest{Stream: "TEST", Config: cfg})
+		require_NoError(t, err)
+		rmsg, err := nc.Request(fmt.Sprintf(JSApiDurableCreateT, "TEST", cfg.Durable), req, time.Second)
+		require_NoError(t, err)
+		var resp JSApiConsumerCreateResponse
+		require_NoError(t, json.Unmarshal(rmsg.Data, &resp))
+		return &resp
+	}
+
+	// Webhooks need explicit acks.
+	resp := addConsumer(ConsumerConfig{Durable: "BAD", AckPolicy: AckNone, Webhook: &ConsumerWebhook{URL: hs.URL}})
+	require_True(t, resp.Error != nil)
+	require_Equal(t, resp.Error.ErrCode, uint16(JSConsumerWebhookInvalidErrF))
+
+	resp = addConsumer(ConsumerConfig{
+		Durable:       "HOOK",
+		AckPolicy:     AckExplicit,
+		MaxAckPending: 2,
+		BackOff:       []time.Duration{time.Second, 100 * time.Millisecond},
+		Webhook:       &ConsumerWebhook{URL: hs.URL, Headers: map[string]string{"Authorization": "Bearer abc"}},
+	})
+	require_True(t, resp.Error == nil)
+	require_Equal(t, resp.Config.DeliverSubject, fmt.Sprintf(webhookDeliverT, "TEST", "HOOK"))
+
+	checkFor(t, 5*time.Second, 100*time.Millisecond, func() error {
+		ci, err := js.ConsumerInfo("TEST", "HOOK")
+		if err != nil {
+			return err
+		}
+		if ci.NumAckPending != 0 || ci.AckFloor.Stream != 10 {
+			return fmt.Errorf("consumer not done: %+v", ci.AckFloor)
+		}
+		return nil
+	})
+
+	mu.Lock()
+	require_Len(t, len(received), 10)
+	for i := 0; i < 10; i++ {
+		hdr := received[uint64(i+1)]
+		require_Equal(t, hdr.Get("Order"), strconv.Itoa(i))
+		require_Equal(t, hdr.Get(JSStream), "TEST")
+		require_Equal(t, hdr.Get(JSSubject), fmt.Sprintf("orders.%d", i))
+		require_Equal(t, hdr.Get("Authorization"), "Bearer abc")
+	}
+	require_Equal(t, received[2].Get(webhookDeliveredHdr), "2")
+	// MaxAckPending bounds the requests in flight.
+	require_True(t, maxIn <= 2)
+	mu.Unlock()
+
+	rmsg, err := nc.Request(fmt.Sprintf(JSApiConsumerInfoT, "TEST", "HOOK"), nil, time.Second)
+	require_NoError(t, err)
+	var ciResp JSApiConsumerInfoResponse
+	require_NoError(t, json.Unmarshal(rmsg.Data, &ciResp))
+	require_True(t, ciResp.Webhook != nil)
+	require_Equal(t, ciResp.Webhook.Delivered, 10)
+	require_Equal(t, ciResp.Webhook.Errors, 1)
+	require_True(t, strings.Contains(ciResp.Webhook.LastError, "503"))
+	require_True(t, ciResp.PushBound)
+
+	// Without the server allowing it, webhooks are rejected.
+	s2 := RunBasicJetStreamServer(t)
+	defer s2.Shutdown()
+	nc2, js2 := jsClientConnect(t, s2)
+	defer nc2.Close()
+	_, err = js2.AddStream(&nats.StreamConfig{Name: "TEST", Subjects: []string{"orders.>"}})
+	require_NoError(t, err)
+	req, err := json.Marshal(&CreateConsumerRequest{Stream: "TEST", Config: ConsumerConfig{Durable: "HOOK", AckPolicy: AckExplicit, Webhook: &ConsumerWebhook{URL: hs.URL}}})
+	require_NoError(t, err)
+	rmsg, err = nc2.Request(fmt.Sprintf(JSApiDurableCreateT, "TEST", "HOOK"), req, time.Second)
+	require_NoError(t, err)
+	var ccResp JSApiConsumerCreateResponse
+	require_NoError(t, json.Unmarshal(rmsg.Data, &ccResp))
+	require_True(t, ccResp.Error != nil)
+	require_Equal(t, ccResp.Error.ErrCode, uint16(JSConsumerWebhookInvalidErrF))
+}
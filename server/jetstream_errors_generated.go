@@ -212,6 +212,15 @@ const (
 	// JSConsumerOverlappingSubjectFilters consumer subject filters cannot overlap
 	JSConsumerOverlappingSubjectFilters ErrorIdentifier = 10138
 
+	// JSConsumerPartitionCountInvalidErr consumer partition count must be positive for partitioned priority policy
+	JSConsumerPartitionCountInvalidErr ErrorIdentifier = 10202
+
+	// JSConsumerPartitionCountWithoutPolicyErr consumer partition count requires partitioned priority policy
+	JSConsumerPartitionCountWithoutPolicyErr ErrorIdentifier = 10203
+
+	// JSConsumerPartitionedAckPolicyErr consumer partitioned priority policy requires explicit ack policy
+	JSConsumerPartitionedAckPolicyErr ErrorIdentifier = 10208
+
 	// JSConsumerPinnedTTLWithoutPriorityPolicyNone PinnedTTL cannot be set when PriorityPolicy is none
 	JSConsumerPinnedTTLWithoutPriorityPolicyNone ErrorIdentifier = 10197
 
@@ -242,6 +251,12 @@ const (
 	// JSConsumerReplayPolicyInvalidErr consumer replay policy invalid
 	JSConsumerReplayPolicyInvalidErr ErrorIdentifier = 10182
 
+	// JSConsumerReplaySpeedInvalidErr consumer replay speed must be positive
+	JSConsumerReplaySpeedInvalidErr ErrorIdentifier = 10200
+
+	// JSConsumerReplaySpeedRequiresOriginalErr consumer replay speed requires replay policy original
+	JSConsumerReplaySpeedRequiresOriginalErr ErrorIdentifier = 10201
+
 	// JSConsumerReplicasExceedsStream consumer config replica count exceeds parent stream
 	JSConsumerReplicasExceedsStream ErrorIdentifier = 10126
 
@@ -266,6 +281,9 @@ const (
 	// JSConsumerWQRequiresExplicitAckErr workqueue stream requires explicit ack
 	JSConsumerWQRequiresExplicitAckErr ErrorIdentifier = 10098
 
+	// JSConsumerWebhookInvalidErrF consumer webhook is invalid: {err}
+	JSConsumerWebhookInvalidErrF ErrorIdentifier = 10207
+
 	// JSConsumerWithFlowControlNeedsHeartbeats consumer with flow control also needs heartbeats
 	JSConsumerWithFlowControlNeedsHeartbeats ErrorIdentifier = 10108
 
@@ -449,6 +467,9 @@ const (
 	// JSStreamDuplicateMessageConflict duplicate message id is in process
 	JSStreamDuplicateMessageConflict ErrorIdentifier = 10158
 
+	// JSStreamEraseFilterRequiredErr stream erase requires a valid subject filter
+	JSStreamEraseFilterRequiredErr ErrorIdentifier = 10206
+
 	// JSStreamExpectedLastSeqPerSubjectInvalid missing sequence for expected last sequence per subject
 	JSStreamExpectedLastSeqPerSubjectInvalid ErrorIdentifier = 10193
 
@@ -479,6 +500,9 @@ const (
 	// JSStreamInvalidExternalDeliverySubjErrF stream external delivery prefix {prefix} must not contain wildcards
 	JSStreamInvalidExternalDeliverySubjErrF ErrorIdentifier = 10024
 
+	// JSStreamKeysNotEnabledErr stream encryption keys not enabled
+	JSStreamKeysNotEnabledErr ErrorIdentifier = 10204
+
 	// JSStreamLimitsErrF General stream limits exceeded error string ({err})
 	JSStreamLimitsErrF ErrorIdentifier = 10053
 
@@ -521,6 +545,9 @@ const (
 	// JSStreamNameExistRestoreFailedErr stream name already in use, cannot restore
 	JSStreamNameExistRestoreFailedErr ErrorIdentifier = 10130
 
+	// JSStreamNoEncryptionKeyErr stream does not have its own encryption key
+	JSStreamNoEncryptionKeyErr ErrorIdentifier = 10205
+
 	// JSStreamNotFoundErr stream not found
 	JSStreamNotFoundErr ErrorIdentifier = 10059
 
@@ -533,6 +560,9 @@ const (
 	// JSStreamOfflineReasonErrF stream is offline: {err}
 	JSStreamOfflineReasonErrF ErrorIdentifier = 10194
 
+	// JSStreamPeersNotSupportedErr operation not supported by all stream peers
+	JSStreamPeersNotSupportedErr ErrorIdentifier = 10209
+
 	// JSStreamPurgeFailedF Generic stream purge failure error string ({err})
 	JSStreamPurgeFailedF ErrorIdentifier = 10110
 
@@ -671,6 +701,9 @@ var (
 		JSConsumerOfflineReasonErrF:                  {Code: 500, ErrCode: 10195, Description: "consumer is offline: {err}"},
 		JSConsumerOnMappedErr:                        {Code: 400, ErrCode: 10092, Description: "consumer direct on a mapped consumer"},
 		JSConsumerOverlappingSubjectFilters:          {Code: 400, ErrCode: 10138, Description: "consumer subject filters cannot overlap"},
+		JSConsumerPartitionCountInvalidErr:           {Code: 400, ErrCode: 10202, Description: "consumer partition count must be positive for partitioned priority policy"},
+		JSConsumerPartitionCountWithoutPolicyErr:     {Code: 400, ErrCode: 10203, Description: "consumer partition count requires partitioned priority policy"},
+		JSConsumerPartitionedAckPolicyErr:            {Code: 400, ErrCode: 10208, Description: "consumer partitioned priority policy requires explicit ack policy"},
 		JSConsumerPinnedTTLWithoutPriorityPolicyNone: {Code: 400, ErrCode: 10197, Description: "PinnedTTL cannot be set when PriorityPolicy is none"},
 		JSConsumerPriorityGroupWithPolicyNone:        {Code: 400, ErrCode: 10196, Description: "consumer can not have priority groups when policy is none"},
 		JSConsumerPriorityPolicyWithoutGroup:         {Code: 400, ErrCode: 10159, Description: "Setting PriorityPolicy requires at least one PriorityGroup to be set"},
@@ -681,6 +714,8 @@ var (
 		JSConsumerPushWithPriorityGroupErr:           {Code: 400, ErrCode: 10178, Description: "priority groups can not be used with push consumers"},
 		JSConsumerReplacementWithDifferentNameErr:    {Code: 400, ErrCode: 10106, Description: "consumer replacement durable config not the same"},
 		JSConsumerReplayPolicyInvalidErr:             {Code: 400, ErrCode: 10182, Description: "consumer replay policy invalid"},
+		JSConsumerReplaySpeedInvalidErr:              {Code: 400, ErrCode: 10200, Description: "consumer replay speed must be positive"},
+		JSConsumerReplaySpeedRequiresOriginalErr:     {Code: 400, ErrCode: 10201, Description: "consumer replay speed requires replay policy original"},
 		JSConsumerReplicasExceedsStream:              {Code: 400, ErrCode: 10126, Description: "consumer config replica count exceeds parent stream"},
 		JSConsumerReplicasShouldMatchStream:          {Code: 400, ErrCode: 10134, Description: "consumer config replicas must match interest retention stream's replicas"},
 		JSConsumerSmallHeartbeatErr:                  {Code: 400, ErrCode: 10083, Description: "consumer idle heartbeat needs to be >= 100ms"},
@@ -689,6 +724,7 @@ var (
 		JSConsumerWQConsumerNotUniqueErr:             {Code: 400, ErrCode: 10100, Description: "filtered consumer not unique on workqueue stream"},
 		JSConsumerWQMultipleUnfilteredErr:            {Code: 400, ErrCode: 10099, Description: "multiple non-filtered consumers not allowed on workqueue stream"},
 		JSConsumerWQRequiresExplicitAckErr:           {Code: 400, ErrCode: 10098, Description: "workqueue stream requires explicit ack"},
+		JSConsumerWebhookInvalidErrF:                 {Code: 400, ErrCode: 10207, Description: "consumer webhook is invalid: {err}"},
 		JSConsumerWithFlowControlNeedsHeartbeats:     {Code: 400, ErrCode: 10108, Description: "consumer with flow control also needs heartbeats"},
 		JSInsufficientResourcesErr:                   {Code: 503, ErrCode: 10023, Description: "insufficient resources"},
 		JSInvalidJSONErr:                             {Code: 400, ErrCode: 10025, Description: "invalid JSON: {err}"},
@@ -750,6 +786,7 @@ var (
 		JSStreamCreateErrF:                           {Code: 500, ErrCode: 10049, Description: "{err}"},
 		JSStreamDeleteErrF:                           {Code: 500, ErrCode: 10050, Description: "{err}"},
 		JSStreamDuplicateMessageConflict:             {Code: 409, ErrCode: 10158, Description: "duplicate message id is in process"},
+		JSStreamEraseFilterRequiredErr:               {Code: 400, ErrCode: 10206, Description: "stream erase requires a valid subject filter"},
 		JSStreamExpectedLastSeqPerSubjectInvalid:     {Code: 400, ErrCode: 10193, Description: "missing sequence for expected last sequence per subject"},
 		JSStreamExpectedLastSeqPerSubjectNotReady:    {Code: 503, ErrCode: 10163, Description: "expected last sequence per subject temporarily unavailable"},
 		JSStreamExternalApiOverlapErrF:               {Code: 400, ErrCode: 10021, Description: "stream external api prefix {prefix} must not overlap with {subject}"},
@@ -760,6 +797,7 @@ var (
 		JSStreamInvalidConfigF:                       {Code: 500, ErrCode: 10052, Description: "{err}"},
 		JSStreamInvalidErr:                           {Code: 500, ErrCode: 10096, Description: "stream not valid"},
 		JSStreamInvalidExternalDeliverySubjErrF:      {Code: 400, ErrCode: 10024, Description: "stream external delivery prefix {prefix} must not contain wildcards"},
+		JSStreamKeysNotEnabledErr:                    {Code: 400, ErrCode: 10204, Description: "stream encryption keys not enabled"},
 		JSStreamLimitsErrF:                           {Code: 500, ErrCode: 10053, Description: "{err}"},
 		JSStreamMaxBytesRequired:                     {Code: 400, ErrCode: 10113, Description: "account requires a stream config to have max bytes set"},
 		JSStreamMaxStreamBytesExceeded:               {Code: 400, ErrCode: 10122, Description: "stream max bytes exceeds account limit max stream bytes"},
@@ -774,10 +812,12 @@ var (
 		JSStreamNameContainsPathSeparatorsErr:        {Code: 400, ErrCode: 10128, Description: "Stream name can not contain path separators"},
 		JSStreamNameExistErr:                         {Code: 400, ErrCode: 10058, Description: "stream name already in use with a different configuration"},
 		JSStreamNameExistRestoreFailedErr:            {Code: 400, ErrCode: 10130, Description: "stream name already in use, cannot restore"},
+		JSStreamNoEncryptionKeyErr:                   {Code: 400, ErrCode: 10205, Description: "stream does not have its own encryption key"},
 		JSStreamNotFoundErr:                          {Code: 404, ErrCode: 10059, Description: "stream not found"},
 		JSStreamNotMatchErr:                          {Code: 400, ErrCode: 10060, Description: "expected stream does not match"},
 		JSStreamOfflineErr:                           {Code: 500, ErrCode: 10118, Description: "stream is offline"},
 		JSStreamOfflineReasonErrF:                    {Code: 500, ErrCode: 10194, Description: "stream is offline: {err}"},
+		JSStreamPeersNotSupportedErr:                 {Code: 400, ErrCode: 10209, Description: "operation not supported by all stream peers"},
 		JSStreamPurgeFailedF:                         {Code: 500, ErrCode: 10110, Description: "{err}"},
 		JSStreamReplicasNotSupportedErr:              {Code: 500, ErrCode: 10074, Description: "replicas > 1 not supported in non-clustered mode"},
 		JSStreamReplicasNotUpdatableErr:              {Code: 400, ErrCode: 10061, Description: "Replicas configuration can not be updated"},
@@ -1587,6 +1627,36 @@ func NewJSConsumerOverlappingSubjectFiltersError(opts ...ErrorOption) *ApiError
 	return ApiErrors[JSConsumerOverlappingSubjectFilters]
 }
 
+// NewJSConsumerPartitionCountInvalidError creates a new JSConsumerPartitionCountInvalidErr error: "consumer partition count must be positive for partitioned priority policy"
+func NewJSConsumerPartitionCountInvalidError(opts ...ErrorOption) *ApiError {
+	eopts := parseOpts(opts)
+	if ae, ok := eopts.err.(*ApiError); ok {
+		return ae
+	}
+
+	return ApiErrors[JSConsumerPartitionCountInvalidErr]
+}
+
+// NewJSConsumerPartitionCountWithoutPolicyError creates a new JSConsumerPartitionCountWithoutPolicyErr error: "consumer partition count requires partitioned priority policy"
+func NewJSConsumerPartitionCountWithoutPolicyError(opts ...ErrorOption) *ApiError {
+	eopts := parseOpts(opts)
+	if ae, ok := eopts.err.(*ApiError); ok {
+		return ae
+	}
+
+	return ApiErrors[JSConsumerPartitionCountWithoutPolicyErr]
+}
+
+// NewJSConsumerPartitionedAckPolicyError creates a new JSConsumerPartitionedAckPolicyErr error: "consumer partitioned priority policy requires explicit ack policy"
+func NewJSConsumerPartitionedAckPolicyError(opts ...ErrorOption) *ApiError {
+	eopts := parseOpts(opts)
+	if ae, ok := eopts.err.(*ApiError); ok {
+		return ae
+	}
+
+	return ApiErrors[JSConsumerPartitionedAckPolicyErr]
+}
+
 // NewJSConsumerPinnedTTLWithoutPriorityPolicyNoneError creates a new JSConsumerPinnedTTLWithoutPriorityPolicyNone error: "PinnedTTL cannot be set when PriorityPolicy is none"
 func NewJSConsumerPinnedTTLWithoutPriorityPolicyNoneError(opts ...ErrorOption) *ApiError {
 	eopts := parseOpts(opts)
@@ -1687,6 +1757,26 @@ func NewJSConsumerReplayPolicyInvalidError(opts ...ErrorOption) *ApiError {
 	return ApiErrors[JSConsumerReplayPolicyInvalidErr]
 }
 
+// NewJSConsumerReplaySpeedInvalidError creates a new JSConsumerReplaySpeedInvalidErr error: "consumer replay speed must be positive"
+func NewJSConsumerReplaySpeedInvalidError(opts ...ErrorOption) *ApiError {
+	eopts := parseOpts(opts)
+	if ae, ok := eopts.err.(*ApiError); ok {
+		return ae
+	}
+
+	return ApiErrors[JSConsumerReplaySpeedInvalidErr]
+}
+
+// NewJSConsumerReplaySpeedRequiresOriginalError creates a new JSConsumerReplaySpeedRequiresOriginalErr error: "consumer replay speed requires replay policy original"
+func NewJSConsumerReplaySpeedRequiresOriginalError(opts ...ErrorOption) *ApiError {
+	eopts := parseOpts(opts)
+	if ae, ok := eopts.err.(*ApiError); ok {
+		return ae
+	}
+
+	return ApiErrors[JSConsumerReplaySpeedRequiresOriginalErr]
+}
+
 // NewJSConsumerReplicasExceedsStreamError creates a new JSConsumerReplicasExceedsStream error: "consumer config replica count exceeds parent stream"
 func NewJSConsumerReplicasExceedsStreamError(opts ...ErrorOption) *ApiError {
 	eopts := parseOpts(opts)
@@ -1773,6 +1863,22 @@ func NewJSConsumerWQRequiresExplicitAckError(opts ...ErrorOption) *ApiError {
 	return ApiErrors[JSConsumerWQRequiresExplicitAckErr]
 }
 
+// NewJSConsumerWebhookInvalidError creates a new JSConsumerWebhookInvalidErrF error: "consumer webhook is invalid: {err}"
+func NewJSConsumerWebhookInvalidError(err error, opts ...ErrorOption) *ApiError {
+	eopts := parseOpts(opts)
+	if ae, ok := eopts.err.(*ApiError); ok {
+		return ae
+	}
+
+	e := ApiErrors[JSConsumerWebhookInvalidErrF]
+	args := e.toReplacerArgs([]interface{}{"{err}", err})
+	return &ApiError{
+		Code:        e.Code,
+		ErrCode:     e.ErrCode,
+		Description: strings.NewReplacer(args...).Replace(e.Description),
+	}
+}
+
 // NewJSConsumerWithFlowControlNeedsHeartbeatsError creates a new JSConsumerWithFlowControlNeedsHeartbeats error: "consumer with flow control also needs heartbeats"
 func NewJSConsumerWithFlowControlNeedsHeartbeatsError(opts ...ErrorOption) *ApiError {
 	eopts := parseOpts(opts)
@@ -2467,6 +2573,16 @@ func NewJSStreamDuplicateMessageConflictError(opts ...ErrorOption) *ApiError {
 	return ApiErrors[JSStreamDuplicateMessageConflict]
 }
 
+// NewJSStreamEraseFilterRequiredError creates a new JSStreamEraseFilterRequiredErr error: "stream erase requires a valid subject filter"
+func NewJSStreamEraseFilterRequiredError(opts ...ErrorOption) *ApiError {
+	eopts := parseOpts(opts)
+	if ae, ok := eopts.err.(*ApiError); ok {
+		return ae
+	}
+
+	return ApiErrors[JSStreamEraseFilterRequiredErr]
+}
+
 // NewJSStreamExpectedLastSeqPerSubjectInvalidError creates a new JSStreamExpectedLastSeqPerSubjectInvalid error: "missing sequence for expected last sequence per subject"
 func NewJSStreamExpectedLastSeqPerSubjectInvalidError(opts ...ErrorOption) *ApiError {
 	eopts := parseOpts(opts)
@@ -2597,6 +2713,16 @@ func NewJSStreamInvalidExternalDeliverySubjError(prefix interface{}, opts ...Err
 	}
 }
 
+// NewJSStreamKeysNotEnabledError creates a new JSStreamKeysNotEnabledErr error: "stream encryption keys not enabled"
+func NewJSStreamKeysNotEnabledError(opts ...ErrorOption) *ApiError {
+	eopts := parseOpts(opts)
+	if ae, ok := eopts.err.(*ApiError); ok {
+		return ae
+	}
+
+	return ApiErrors[JSStreamKeysNotEnabledErr]
+}
+
 // NewJSStreamLimitsError creates a new JSStreamLimitsErrF error: "{err}"
 func NewJSStreamLimitsError(err error, opts ...ErrorOption) *ApiError {
 	eopts := parseOpts(opts)
@@ -2755,6 +2881,16 @@ func NewJSStreamNameExistRestoreFailedError(opts ...ErrorOption) *ApiError {
 	return ApiErrors[JSStreamNameExistRestoreFailedErr]
 }
 
+// NewJSStreamNoEncryptionKeyError creates a new JSStreamNoEncryptionKeyErr error: "stream does not have its own encryption key"
+func NewJSStreamNoEncryptionKeyError(opts ...ErrorOption) *ApiError {
+	eopts := parseOpts(opts)
+	if ae, ok := eopts.err.(*ApiError); ok {
+		return ae
+	}
+
+	return ApiErrors[JSStreamNoEncryptionKeyErr]
+}
+
 // NewJSStreamNotFoundError creates a new JSStreamNotFoundErr error: "stream not found"
 func NewJSStreamNotFoundError(opts ...ErrorOption) *ApiError {
 	eopts := parseOpts(opts)
@@ -2801,6 +2937,16 @@ func NewJSStreamOfflineReasonError(err error, opts ...ErrorOption) *ApiError {
 	}
 }
 
+// NewJSStreamPeersNotSupportedError creates a new JSStreamPeersNotSupportedErr error: "operation not supported by all stream peers"
+func NewJSStreamPeersNotSupportedError(opts ...ErrorOption) *ApiError {
+	eopts := parseOpts(opts)
+	if ae, ok := eopts.err.(*ApiError); ok {
+		return ae
+	}
+
+	return ApiErrors[JSStreamPeersNotSupportedErr]
+}
+
 // NewJSStreamPurgeFailedError creates a new JSStreamPurgeFailedF error: "{err}"
 func NewJSStreamPurgeFailedError(err error, opts ...ErrorOption) *ApiError {
 	eopts := parseOpts(opts)
@@ -266,6 +266,35 @@ type JSStreamBatchAbandonedAdvisory struct {
 	Reason  BatchAbandonReason `json:"reason"`
 }
 
+// JSStreamConnectorErrorAdvisoryType is sent when a stream connector fails to write to its sink.
+const JSStreamConnectorErrorAdvisoryType = "io.nats.jetstream.advisory.v1.stream_connector_error"
+
+// JSStreamConnectorErrorAdvisory indicates that a stream connector failed to write a message.
+type JSStreamConnectorErrorAdvisory struct {
+	TypedEvent
+	Account    string `json:"account,omitempty"`
+	Stream     string `json:"stream"`
+	Domain     string `json:"domain,omitempty"`
+	Connector  string `json:"connector"`
+	StreamSeq  uint64 `json:"stream_seq"`
+	Deliveries uint64 `json:"deliveries"`
+	Error      string `json:"error"`
+}
+
+// JSStreamConnectorLagAdvisoryType is sent when a stream connector's lag exceeds its limit.
+const JSStreamConnectorLagAdvisoryType = "io.nats.jetstream.advisory.v1.stream_connector_lag"
+
+// JSStreamConnectorLagAdvisory indicates that a stream connector is lagging behind.
+type JSStreamConnectorLagAdvisory struct {
+	TypedEvent
+	Account   string `json:"account,omitempty"`
+	Stream    string `json:"stream"`
+	Domain    string `json:"domain,omitempty"`
+	Connector string `json:"connector"`
+	Lag       uint64 `json:"lag"`
+	MaxLag    uint64 `json:"max_lag"`
+}
+
 type BatchAbandonReason string
 
 var (
@@ -0,0 +1,406 @@
+// Copyright 2025 The NATS Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/nats-io/nats-server/v2/server/kms"
+)
+
+const (
+	// JetStreamKMSKeyFile holds a data key wrapped by the KMS. There is one
+	// for the server in the store directory and one in each account directory.
+	JetStreamKMSKeyFile = "kms.key"
+
+	// Default name of the KMS key used to wrap data keys.
+	defaultKMSKeyName = "nats-jetstream"
+)
+
+// jsKMS manages the data keys used for JetStream encryption at rest when
+// the keys are held by an external KMS. Each account has its own data key,
+// which takes the place of the configured JetStream key when deriving the
+// key encryption keys of the account's streams and consumers. The server
+// has a data key of its own for the meta and raft group logs. When stream
+// keys are enabled, the data key of each stream is wrapped by the KMS key
+// of its account as well, and unwrapped by the KMS when the stream is loaded.
+//
+// Data keys are only ever stored wrapped, so rotating the KMS key only
+// requires re-wrapping the data keys and none of the stream data.
+type jsKMS struct {
+	mu   sync.Mutex
+	kp   kms.KeyProvider
+	dir  string
+	opts JSKMSOpts
+	keys map[string]string // Account, or empty for the server, to unwrapped data key.
+	// Serializes the changes to the stream data key files, so that they are
+	// not re-wrapped while a stream is created or its key rotated.
+	smu sync.Mutex
+}
+
+// kmsKeyFile is the on disk form of a wrapped data key.
+type kmsKeyFile struct {
+	Key     string `json:"key"`
+	Wrapped []byte `json:"wrapped"`
+}
+
+// JSKeyRotateRequest is a request to re-wrap the JetStream data keys
+// of this server, optionally rotating the KMS keys first.
+type JSKeyRotateRequest struct {
+	// Account limits the request to one account. The server data key is
+	// only re-wrapped when no account is given.
+	Account string `json:"account,omitempty"`
+	// RotateKey creates a new version of the KMS keys before re-wrapping.
+	RotateKey bool `json:"rotate_key,omitempty"`
+}
+
+// JSKeyRotateResponse reports the data keys that were re-wrapped.
+type JSKeyRotateResponse struct {
+	RotatedKeys []string `json:"rotated_keys,omitempty"`
+	Accounts    []string `json:"accounts,omitempty"`
+	Streams     int      `json:"streams,omitempty"`
+	Server      bool     `json:"server,omitempty"`
+}
+
+// Creates the key provider from the options.
+func newKeyProvider(opts *JSKMSOpts) (kms.KeyProvider, error) {
+	if opts.KeyProvider != nil {
+		return opts.KeyProvider, nil
+	}
+	switch opts.Provider {
+	case "file":
+		return kms.NewFileProvider(opts.KeysFile)
+	case "vault":
+		return kms.NewVaultProvider(kms.VaultOptions{
+			URL:       opts.URL,
+			Token:     opts.Token,
+			Namespace: opts.Namespace,
+			Mount:     opts.Mount,
+		})
+	}
+	return nil, fmt.Errorf("unknown JetStream KMS provider %q", opts.Provider)
+}
+
+// Sets up the KMS if configured, making sure the server data key can be unwrapped.
+func (s *Server) initJetStreamKMS(storeDir string) error {
+	opts := s.getOpts()
+	if opts.JetStreamKMS.Provider == _EMPTY_ && opts.JetStreamKMS.KeyProvider == nil {
+		s.jsKMS.Store(nil)
+		return nil
+	}
+	if opts.JetStreamKey != _EMPTY_ || opts.JetStreamTpm.KeysFile != _EMPTY_ {
+		return fmt.Errorf("JetStream KMS may not be used with an encryption key or TPM options")
+	}
+	kp, err := newKeyProvider(&opts.JetStreamKMS)
+	if err != nil {
+		return err
+	}
+	km := &jsKMS{kp: kp, dir: storeDir, opts: opts.JetStreamKMS, keys: make(map[string]string)}
+	if _, err := km.dataKey(_EMPTY_); err != nil {
+		return fmt.Errorf("could not load JetStream data key from KMS: %w", err)
+	}
+	s.jsKMS.Store(km)
+	return nil
+}
+
+// Returns the key used to derive the key encryption keys for the account,
+// or for the server if the account is empty. The key is empty if encryption
+// is not enabled.
+func (s *Server) jsEncryptionKey(acc string) (string, error) {
+	if km := s.jsKMS.Load(); km != nil {
+		return km.dataKey(acc)
+	}
+	return s.getOpts().JetStreamKey, nil
+}
+
+// Returns whether JetStream encryption at rest is enabled.
+func (s *Server) jsEncryptionEnabled() bool {
+	return s.getOpts().JetStreamKey != _EMPTY_ || s.jsKMS.Load() != nil
+}
+
+// Returns the name of the KMS key used for the account.
+func (km *jsKMS) keyName(acc string) string {
+	if name, ok := km.opts.AccountKeys[acc]; ok && acc != _EMPTY_ {
+		return name
+	}
+	if km.opts.Key != _EMPTY_ {
+		return km.opts.Key
+	}
+	return defaultKMSKeyName
+}
+
+// Returns whether the account name can be used as the name of its directory
+// in the store directory, the same as for the account's streams.
+func isValidKMSAccountName(acc string) bool {
+	return isValidName(acc) && !strings.ContainsAny(acc, `/\`)
+}
+
+// Returns the path of the wrapped data key for the account.
+func (km *jsKMS) keyFile(acc string) string {
+	return filepath.Join(km.dir, acc, JetStreamKMSKeyFile)
+}
+
+// Returns the unwrapped data key for the account, generating and
+// storing a new one if the account does not have one yet.
+func (km *jsKMS) dataKey(acc string) (string, error) {
+	km.mu.Lock()
+	defer km.mu.Unlock()
+
+	if dek, ok := km.keys[acc]; ok {
+		return dek, nil
+	}
+	var dek []byte
+	kf, err := km.readKeyFile(acc)
+	if err == nil {
+		if dek, err = km.kp.Unwrap(kf.Key, kf.Wrapped); err != nil {
+			return _EMPTY_, fmt.Errorf("could not unwrap data key: %w", err)
+		}
+	} else if os.IsNotExist(err) {
+		if dek, err = kms.GenerateDataKey(); err != nil {
+			return _EMPTY_, err
+		}
+		kf = &kmsKeyFile{Key: km.keyName(acc)}
+		if kf.Wrapped, err = km.kp.Wrap(kf.Key, dek); err != nil {
+			return _EMPTY_, fmt.Errorf("could not wrap data key: %w", err)
+		}
+		if err := km.writeKeyFile(acc, kf); err != nil {
+			return _EMPTY_, err
+		}
+	} else {
+		return _EMPTY_, err
+	}
+	km.keys[acc] = string(dek)
+	return km.keys[acc], nil
+}
+
+// Lock should be held.
+func (km *jsKMS) readKeyFile(acc string) (*kmsKeyFile, error) {
+	buf, err := os.ReadFile(km.keyFile(acc))
+	if err != nil {
+		return nil, err
+	}
+	var kf kmsKeyFile
+	if err := json.Unmarshal(buf, &kf); err != nil {
+		return nil, fmt.Errorf("invalid data key file: %w", err)
+	}
+	return &kf, nil
+}
+
+// Writes the wrapped data key, replacing any existing one atomically.
+// Lock should be held.
+func (km *jsKMS) writeKeyFile(acc string, kf *kmsKeyFile) error {
+	buf, err := json.Marshal(kf)
+	if err != nil {
+		return err
+	}
+	fn := km.keyFile(acc)
+	if err := os.MkdirAll(filepath.Dir(fn), defaultDirPerms); err != nil {
+		return err
+	}
+	tmp := fn + blkTmpSuffix
+	if err := os.WriteFile(tmp, buf, defaultFilePerms); err != nil {
+		return err
+	}
+	return os.Rename(tmp, fn)
+}
+
+// Wraps a stream data key with the KMS key of the account, returning the
+// on disk form of the wrapped key.
+func (km *jsKMS) wrapStreamKey(acc string, dek []byte) ([]byte, error) {
+	kf := &kmsKeyFile{Key: km.keyName(acc)}
+	var err error
+	if kf.Wrapped, err = km.kp.Wrap(kf.Key, dek); err != nil {
+		return nil, fmt.Errorf("could not wrap stream key: %w", err)
+	}
+	return json.Marshal(kf)
+}
+
+// Unwraps a stream data key wrapped by the KMS. The returned key file is nil
+// if the stream key is not wrapped by the KMS, but by the account data key.
+func (km *jsKMS) unwrapStreamKey(buf []byte) ([]byte, *kmsKeyFile, error) {
+	var kf kmsKeyFile
+	if err := json.Unmarshal(buf, &kf); err != nil || kf.Key == _EMPTY_ {
+		return nil, nil, nil
+	}
+	dek, err := km.kp.Unwrap(kf.Key, kf.Wrapped)
+	if err != nil {
+		return nil, &kf, fmt.Errorf("could not unwrap stream key: %w", err)
+	}
+	return dek, &kf, nil
+}
+
+// Re-wraps the stream data keys of the account that are wrapped by the KMS,
+// including the ones of an interrupted key rotation, and returns how many
+// streams were.
+// Lock should be held.
+func (km *jsKMS) rewrapStreamKeys(acc string) (int, error) {
+	km.smu.Lock()
+	defer km.smu.Unlock()
+
+	sdirs, err := filepath.Glob(filepath.Join(km.dir, acc, streamsDir, "*"))
+	if err != nil {
+		return 0, err
+	}
+	var n int
+	for _, sdir := range sdirs {
+		fn := filepath.Join(sdir, JetStreamStreamKeyFile)
+		var rewrapped bool
+		for _, fn := range []string{fn, fn + streamKeyPendingSuffix} {
+			buf, err := os.ReadFile(fn)
+			if os.IsNotExist(err) {
+				continue
+			} else if err != nil {
+				return n, err
+			}
+			dek, kf, err := km.unwrapStreamKey(buf)
+			if kf == nil {
+				// Still wrapped by the account data key, this is done when the stream is loaded.
+				continue
+			} else if err != nil {
+				return n, err
+			}
+			if buf, err = km.wrapStreamKey(acc, dek); err != nil {
+				return n, err
+			}
+			tmp := fn + blkTmpSuffix
+			if err := writeFileWithSync(tmp, buf, defaultFilePerms); err != nil {
+				return n, err
+			}
+			if err := os.Rename(tmp, fn); err != nil {
+				return n, err
+			}
+			rewrapped = true
+		}
+		if rewrapped {
+			n++
+		}
+	}
+	return n, nil
+}
+
+// Re-wraps the data keys of the given account, or of the server and all
+// accounts if empty, with the current version of their KMS key. If rotate
+// is set, a new version of the KMS keys is created first. A data key whose
+// account is now configured to use a different KMS key is moved to it.
+// The stream data keys of the accounts are re-wrapped as well.
+func (km *jsKMS) rewrap(acc string, rotate bool) (*JSKeyRotateResponse, error) {
+	km.mu.Lock()
+	defer km.mu.Unlock()
+
+	accounts := []string{acc}
+	if acc == _EMPTY_ {
+		fis, err := os.ReadDir(km.dir)
+		if err != nil {
+			return nil, err
+		}
+		for _, fi := range fis {
+			if !fi.IsDir() {
+				continue
+			}
+			if _, err := os.Stat(km.keyFile(fi.Name())); err == nil {
+				accounts = append(accounts, fi.Name())
+			}
+		}
+	}
+
+	resp := &JSKeyRotateResponse{}
+	if rotate {
+		for _, a := range accounts {
+			if name := km.keyName(a); !slices.Contains(resp.RotatedKeys, name) {
+				if err := km.kp.Rotate(name); err != nil {
+					return resp, fmt.Errorf("could not rotate KMS key %q: %w", name, err)
+				}
+				resp.RotatedKeys = append(resp.RotatedKeys, name)
+			}
+		}
+	}
+
+	for _, a := range accounts {
+		kf, err := km.readKeyFile(a)
+		if os.IsNotExist(err) && a != _EMPTY_ {
+			// Nothing stored for this account yet.
+			continue
+		} else if err != nil {
+			return resp, err
+		}
+		nkf := &kmsKeyFile{Key: km.keyName(a)}
+		if nkf.Key == kf.Key {
+			nkf.Wrapped, err = km.kp.Rewrap(kf.Key, kf.Wrapped)
+		} else {
+			var dek []byte
+			if dek, err = km.kp.Unwrap(kf.Key, kf.Wrapped); err == nil {
+				nkf.Wrapped, err = km.kp.Wrap(nkf.Key, dek)
+			}
+		}
+		if err != nil {
+			return resp, fmt.Errorf("could not re-wrap data key: %w", err)
+		}
+		if err := km.writeKeyFile(a, nkf); err != nil {
+			return resp, err
+		}
+		if a == _EMPTY_ {
+			resp.Server = true
+			continue
+		}
+		resp.Accounts = append(resp.Accounts, a)
+		n, err := km.rewrapStreamKeys(a)
+		resp.Streams += n
+		if err != nil {
+			return resp, fmt.Errorf("could not re-wrap stream keys: %w", err)
+		}
+	}
+	return resp, nil
+}
+
+// RotateJetStreamKeys re-wraps the JetStream data keys with the current
+// version of their KMS keys, rotating the KMS keys first if requested.
+// Stream data is not rewritten.
+func (s *Server) RotateJetStreamKeys(req *JSKeyRotateRequest) (*JSKeyRotateResponse, error) {
+	km := s.jsKMS.Load()
+	if km == nil {
+		return nil, fmt.Errorf("JetStream KMS not configured")
+	}
+	if req.Account != _EMPTY_ {
+		if !isValidKMSAccountName(req.Account) {
+			return nil, fmt.Errorf("invalid account name %q", req.Account)
+		}
+		if _, ok := s.accounts.Load(req.Account); !ok {
+			return nil, ErrMissingAccount
+		}
+	}
+	resp, err := km.rewrap(req.Account, req.RotateKey)
+	if err != nil {
+		s.Warnf("Error rotating JetStream data keys: %v", err)
+	} else {
+		s.Noticef("Rotated JetStream data keys, rotated KMS keys: %v", resp.RotatedKeys)
+	}
+	return resp, err
+}
+
+// Handles requests to rotate the JetStream data keys.
+func (s *Server) jsKeyRotateRequest(_ *subscription, c *client, _ *Account, subject, reply string, hdr, msg []byte) {
+	if !s.eventsRunning() {
+		return
+	}
+	var req JSKeyRotateRequest
+	s.zReq(c, reply, hdr, msg, nil, &req, func() (any, error) {
+		return s.RotateJetStreamKeys(&req)
+	})
+}
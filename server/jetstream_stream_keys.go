@@ -0,0 +1,265 @@
+// Copyright 2025 The NATS Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"crypto/rand"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/nats-io/nats-server/v2/server/kms"
+)
+
+// When stream keys are enabled, every new encrypted file based stream gets
+// its own random data key. The data key takes the place of the account key
+// when deriving the key encryption keys of the stream's blocks and consumers,
+// and is itself stored wrapped by the account key in the stream directory,
+// or by the KMS key of the account when the keys are held by a KMS.
+// Destroying the wrapped data key, which happens whenever the stream is
+// deleted, makes the stream data unrecoverable even if copies of the
+// blocks survive, and rotating it only requires re-wrapping the key files.
+// In clustered mode, the raft log of the stream is encrypted with the server
+// key instead. It is removed along with the stream, but copies of it that
+// survive are not made unrecoverable by destroying the stream key.
+
+// Returns whether streams get their own encryption keys.
+func (s *Server) jsStreamKeysEnabled() bool {
+	return s.getOpts().JetStreamStreamKeys && s.jsEncryptionEnabled()
+}
+
+// Returns the key used to derive the key encryption keys of the stream
+// stored in sdir. This is the stream data key if the stream has one, or the
+// account key otherwise. If create is set and stream keys are enabled, a data
+// key is generated for a stream that has not stored any encrypted state yet.
+// A key rotation that was interrupted is completed here.
+func (s *Server) jsStreamEncryptionKey(acc, sdir, stream string, create bool) (string, error) {
+	key, err := s.jsEncryptionKey(acc)
+	if err != nil || key == _EMPTY_ {
+		return key, err
+	}
+	if km := s.jsKMS.Load(); km != nil {
+		km.smu.Lock()
+		defer km.smu.Unlock()
+	}
+	fn := filepath.Join(sdir, JetStreamStreamKeyFile)
+	skey, err := s.readStreamKey(fn, key, acc, stream)
+	if err != nil && !os.IsNotExist(err) {
+		return _EMPTY_, err
+	}
+
+	pending := fn + streamKeyPendingSuffix
+	if nkey, err := s.readStreamKey(pending, key, acc, stream); err == nil {
+		s.Noticef("Completing encryption key rotation for stream '%s > %s'", acc, stream)
+		oldprf := s.jsKeyGen(key, acc)
+		if skey != _EMPTY_ {
+			oldprf = s.jsKeyGen(skey, acc)
+		}
+		sc := s.getOpts().JetStreamCipher
+		if err := rewrapStreamKeyFiles(sdir, stream, sc, oldprf, s.jsKeyGen(nkey, acc)); err != nil {
+			return _EMPTY_, err
+		}
+		if err := os.Rename(pending, fn); err != nil {
+			return _EMPTY_, err
+		}
+		skey = nkey
+	} else if !os.IsNotExist(err) {
+		return _EMPTY_, err
+	}
+
+	if skey != _EMPTY_ {
+		return skey, nil
+	}
+	if create && s.getOpts().JetStreamStreamKeys {
+		// Existing encrypted streams keep using the account key until rotated.
+		if _, err := os.Stat(filepath.Join(sdir, JetStreamMetaFileKey)); os.IsNotExist(err) {
+			dek, wkey, err := s.genStreamKey(key, acc, stream)
+			if err != nil {
+				return _EMPTY_, err
+			}
+			if err := os.MkdirAll(sdir, defaultDirPerms); err != nil {
+				return _EMPTY_, err
+			}
+			if err := writeFileWithSync(fn, wkey, defaultFilePerms); err != nil {
+				return _EMPTY_, err
+			}
+			return dek, nil
+		}
+	}
+	return key, nil
+}
+
+// Generates a new stream data key, returning it along with its wrapped form.
+func (s *Server) genStreamKey(key, acc, stream string) (string, []byte, error) {
+	dek, err := kms.GenerateDataKey()
+	if err != nil {
+		return _EMPTY_, nil, err
+	}
+	wkey, err := s.wrapStreamKey(key, acc, stream, dek)
+	if err != nil {
+		return _EMPTY_, nil, err
+	}
+	return string(dek), wkey, nil
+}
+
+// Wraps the stream data key with the KMS key of the account if the keys are
+// held by a KMS, or with a key encryption key derived from the account key.
+func (s *Server) wrapStreamKey(key, acc, stream string, dek []byte) ([]byte, error) {
+	if km := s.jsKMS.Load(); km != nil {
+		return km.wrapStreamKey(acc, dek)
+	}
+	prf := s.jsKeyGen(key, acc)
+	if prf == nil {
+		return nil, errNoEncryption
+	}
+	rb, err := prf([]byte(stream + ":" + JetStreamStreamKeyFile))
+	if err != nil {
+		return nil, err
+	}
+	kek, err := genEncryptionKey(s.getOpts().JetStreamCipher, rb)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, kek.NonceSize(), kek.NonceSize()+len(dek)+kek.Overhead())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return kek.Seal(nonce, nonce, dek, nil), nil
+}
+
+// Reads and unwraps the stream data key in fn. Keys wrapped with the previous
+// account key or cipher, or that should be wrapped by the KMS, are re-wrapped
+// with the current ones.
+func (s *Server) readStreamKey(fn, key, acc, stream string) (string, error) {
+	buf, err := os.ReadFile(fn)
+	if err != nil {
+		return _EMPTY_, err
+	}
+	km := s.jsKMS.Load()
+	if km != nil {
+		if dek, kf, err := km.unwrapStreamKey(buf); err != nil {
+			return _EMPTY_, err
+		} else if kf != nil {
+			// Move it to the KMS key the account is now configured with.
+			if kf.Key != km.keyName(acc) {
+				if err := s.storeStreamKey(fn, key, acc, stream, dek); err != nil {
+					return _EMPTY_, err
+				}
+			}
+			return string(dek), nil
+		}
+	}
+	opts := s.getOpts()
+	sc := opts.JetStreamCipher
+	osc := AES
+	if sc == AES {
+		osc = ChaCha
+	}
+	type prfWithCipher struct {
+		keyGen
+		StoreCipher
+	}
+	var prfs []prfWithCipher
+	if prf := s.jsKeyGen(key, acc); prf != nil {
+		prfs = append(prfs, prfWithCipher{prf, sc}, prfWithCipher{prf, osc})
+	}
+	if prf := s.jsKeyGen(opts.JetStreamOldKey, acc); prf != nil {
+		prfs = append(prfs, prfWithCipher{prf, sc}, prfWithCipher{prf, osc})
+	}
+	for i, prf := range prfs {
+		rb, err := prf.keyGen([]byte(stream + ":" + JetStreamStreamKeyFile))
+		if err != nil {
+			continue
+		}
+		kek, err := genEncryptionKey(prf.StoreCipher, rb)
+		if err != nil {
+			continue
+		}
+		ns := kek.NonceSize()
+		if len(buf) < ns {
+			continue
+		}
+		dek, err := kek.Open(nil, buf[:ns], buf[ns:], nil)
+		if err != nil {
+			continue
+		}
+		if i > 0 || km != nil {
+			// Converting keys or ciphers, so store with the current ones.
+			if err := s.storeStreamKey(fn, key, acc, stream, dek); err != nil {
+				return _EMPTY_, err
+			}
+		}
+		return string(dek), nil
+	}
+	return _EMPTY_, fmt.Errorf("unable to recover stream key")
+}
+
+// Wraps and stores the stream data key in fn, replacing it atomically.
+func (s *Server) storeStreamKey(fn, key, acc, stream string, dek []byte) error {
+	wkey, err := s.wrapStreamKey(key, acc, stream, dek)
+	if err != nil {
+		return err
+	}
+	tmp := fn + blkTmpSuffix
+	if err := writeFileWithSync(tmp, wkey, defaultFilePerms); err != nil {
+		return err
+	}
+	return os.Rename(tmp, fn)
+}
+
+// Rotates the data key of the stream. A stream that was using the account key
+// gets its own data key. Only the key files are re-wrapped, message blocks are
+// not rewritten.
+func (mset *stream) rotateEncryptionKey() error {
+	mset.mu.RLock()
+	s, store, acc, stream := mset.srv, mset.store, mset.acc.Name, mset.cfg.Name
+	mset.mu.RUnlock()
+
+	fs, ok := store.(*fileStore)
+	if !ok {
+		return errNoEncryption
+	}
+	key, err := s.jsEncryptionKey(acc)
+	if err != nil {
+		return err
+	}
+	if key == _EMPTY_ {
+		return errNoEncryption
+	}
+	if km := s.jsKMS.Load(); km != nil {
+		km.smu.Lock()
+		defer km.smu.Unlock()
+	}
+	dek, wkey, err := s.genStreamKey(key, acc, stream)
+	if err != nil {
+		return err
+	}
+	if err := fs.rotateStreamKey(s.jsKeyGen(dek, acc), wkey); err != nil {
+		return err
+	}
+	s.Noticef("Rotated encryption key for stream '%s > %s'", acc, stream)
+	return nil
+}
+
+// Returns whether the stream has a data key of its own.
+func (mset *stream) hasEncryptionKey() bool {
+	mset.mu.RLock()
+	fs, ok := mset.store.(*fileStore)
+	mset.mu.RUnlock()
+	if !ok {
+		return false
+	}
+	_, err := os.Stat(filepath.Join(fs.fcfg.StoreDir, JetStreamStreamKeyFile))
+	return err == nil
+}
@@ -19,6 +19,7 @@ import (
 	"bytes"
 	"context"
 	crand "crypto/rand"
+	"encoding/binary"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -27,6 +28,7 @@ import (
 	"math/big"
 	"math/rand"
 	"net/http"
+	"net/http/httptest"
 	"net/url"
 	"os"
 	"path/filepath"
@@ -41,6 +43,7 @@ import (
 	"testing"
 	"time"
 
+	"github.com/klauspost/compress/zstd"
 	"github.com/nats-io/jwt/v2"
 	"github.com/nats-io/nats-server/v2/server/sysmem"
 	"github.com/nats-io/nats.go"
@@ -10123,6 +10126,49 @@ func TestJetStreamInputTransform(t *testing.T) {
 	}
 }
 
+func TestJetStreamInputTransformAndRePublishWithHeaders(t *testing.T) {
+	s := RunBasicJetStreamServer(t)
+	defer s.Shutdown()
+
+	nc, js := jsClientConnect(t, s)
+	defer nc.Close()
+
+	addStream(t, nc, &StreamConfig{
+		Name:     "ORDERS",
+		Subjects: []string{"orders.>"},
+		Storage:  MemoryStorage,
+		SubjectTransform: &SubjectTransformConfig{
+			Source:      "orders.*",
+			Destination: "orders.{{header(Region, none)}}.{{wildcard(1)}}",
+		},
+		RePublish: &RePublish{
+			Source:      "orders.*.*",
+			Destination: "out.{{header(Tier, std)}}.$1.$2",
+		},
+	})
+
+	sub := natsSubSync(t, nc, "out.>")
+	natsFlush(t, nc)
+
+	m := nats.NewMsg("orders.1")
+	m.Header.Set("Region", "eu")
+	m.Header.Set("Tier", "gold")
+	_, err := js.PublishMsg(m)
+	require_NoError(t, err)
+	_, err = js.Publish("orders.2", nil)
+	require_NoError(t, err)
+
+	sm, err := js.GetMsg("ORDERS", 1)
+	require_NoError(t, err)
+	require_Equal(t, sm.Subject, "orders.eu.1")
+	sm, err = js.GetMsg("ORDERS", 2)
+	require_NoError(t, err)
+	require_Equal(t, sm.Subject, "orders.none.2")
+
+	require_Equal(t, natsNexMsg(t, sub, time.Second).Subject, "out.gold.eu.1")
+	require_Equal(t, natsNexMsg(t, sub, time.Second).Subject, "out.std.none.2")
+}
+
 func TestJetStreamOperatorAccounts(t *testing.T) {
 	s, _ := RunServerWithConfig("./configs/js-op.conf")
 	if config := s.JetStreamConfig(); config != nil {
@@ -18360,6 +18406,8 @@ func TestJetStreamStreamConfigClone(t *testing.T) {
 		Sources:          []*StreamSource{&StreamSource{Name: "source"}},
 		SubjectTransform: &SubjectTransformConfig{Source: "source", Destination: "dest"},
 		RePublish:        &RePublish{Source: "source", Destination: "dest", HeadersOnly: false},
+		Compaction:       &StreamCompaction{Lag: time.Hour},
+		CompressionOpts:  &StreamCompressionOpts{Level: 3, Dictionary: []byte("dict")},
 		Metadata:         make(map[string]string),
 	}
 
@@ -18383,6 +18431,12 @@ func TestJetStreamStreamConfigClone(t *testing.T) {
 	clone.RePublish.Source = "diff"
 	require_False(t, reflect.DeepEqual(cfg.RePublish, clone.RePublish))
 
+	clone.Compaction.Lag = time.Minute
+	require_False(t, reflect.DeepEqual(cfg.Compaction, clone.Compaction))
+
+	clone.CompressionOpts.Dictionary[0] = 'D'
+	require_False(t, reflect.DeepEqual(cfg.CompressionOpts, clone.CompressionOpts))
+
 	clone.Metadata["key"] = "value"
 	require_False(t, reflect.DeepEqual(cfg.Metadata, clone.Metadata))
 }
@@ -21959,3 +22013,1033 @@ func TestJetStreamOfflineStreamAndConsumerAfterDowngrade(t *testing.T) {
 	require_Len(t, len(cl.Offline), 1)
 	require_Equal(t, cl.Offline["DowngradeConsumerTest"], offlineReason)
 }
+
+func TestJetStreamStreamCompaction(t *testing.T) {
+	for _, storage := range []StorageType{FileStorage, MemoryStorage} {
+		t.Run(storage.String(), func(t *testing.T) {
+			s := RunBasicJetStreamServer(t)
+			defer s.Shutdown()
+
+			nc, js := jsClientConnect(t, s)
+			defer nc.Close()
+
+			// Invalid configurations.
+			_, err := jsStreamCreate(t, nc, &StreamConfig{
+				Name:       "TEST",
+				Storage:    storage,
+				Subjects:   []string{"kv.>"},
+				Compaction: &StreamCompaction{Lag: -time.Second},
+			})
+			require_Error(t, err, NewJSStreamInvalidConfigError(errors.New("compaction lag must not be negative")))
+			_, err = jsStreamCreate(t, nc, &StreamConfig{
+				Name:       "TEST",
+				Storage:    storage,
+				Subjects:   []string{"kv.>"},
+				Retention:  InterestPolicy,
+				Compaction: &StreamCompaction{Lag: time.Second},
+			})
+			require_Error(t, err, NewJSStreamInvalidConfigError(errors.New("compaction can only use limits retention")))
+
+			_, err = jsStreamCreate(t, nc, &StreamConfig{
+				Name:       "TEST",
+				Storage:    storage,
+				Subjects:   []string{"kv.>"},
+				Compaction: &StreamCompaction{Lag: 500 * time.Millisecond, DeleteRetention: time.Second},
+			})
+			require_NoError(t, err)
+
+			for i := 0; i < 3; i++ {
+				_, err = js.Publish("kv.a", nil)
+				require_NoError(t, err)
+			}
+			for i := 0; i < 2; i++ {
+				_, err = js.Publish("kv.b", nil)
+				require_NoError(t, err)
+			}
+			_, err = js.Publish("kv.c", nil)
+			require_NoError(t, err)
+			m := nats.NewMsg("kv.c")
+			m.Header.Set(KVOperation, string(KVOperationValueDelete))
+			_, err = js.PublishMsg(m)
+			require_NoError(t, err)
+
+			checkMsgs := func(expected uint64) {
+				t.Helper()
+				checkFor(t, 2*time.Second, 50*time.Millisecond, func() error {
+					si, err := js.StreamInfo("TEST")
+					if err != nil {
+						return err
+					}
+					if si.State.Msgs != expected {
+						return fmt.Errorf("expected %d msgs, got %d", expected, si.State.Msgs)
+					}
+					return nil
+				})
+			}
+
+			// Recent messages are kept in full.
+			si, err := js.StreamInfo("TEST")
+			require_NoError(t, err)
+			require_Equal(t, si.State.Msgs, 7)
+
+			// After the lag only the last message per subject, including the tombstone, remains.
+			checkMsgs(3)
+			for _, subj := range []string{"kv.a", "kv.b", "kv.c"} {
+				_, err = js.GetLastMsg("TEST", subj)
+				require_NoError(t, err)
+			}
+
+			// After the delete retention the tombstone is removed as well.
+			checkMsgs(2)
+			_, err = js.GetLastMsg("TEST", "kv.c")
+			require_Error(t, err, nats.ErrMsgNotFound)
+		})
+	}
+}
+
+func TestJetStreamStreamZstdCompression(t *testing.T) {
+	s := RunBasicJetStreamServer(t)
+	defer s.Shutdown()
+
+	nc, js := jsClientConnect(t, s)
+	defer nc.Close()
+
+	payload := func(i int) []byte {
+		return []byte(fmt.Sprintf(`{"id":%d,"region":"eu-west","status":"active","tags":["alpha","beta","gamma"],"note":%q}`,
+			i, strings.Repeat("lorem ipsum dolor sit amet ", 32)))
+	}
+	var samples [][]byte
+	for i := 0; i < 64; i++ {
+		samples = append(samples, payload(i))
+	}
+	dict, err := zstd.BuildDict(zstd.BuildDictOptions{ID: 1, Contents: samples, History: bytes.Join(samples[:8], nil)})
+	require_NoError(t, err)
+
+	// Invalid configurations.
+	cfg := &StreamConfig{
+		Name:            "TEST",
+		Subjects:        []string{"foo"},
+		Storage:         FileStorage,
+		Compression:     ZstdCompression,
+		CompressionOpts: &StreamCompressionOpts{Level: 23},
+	}
+	_, err = jsStreamCreate(t, nc, cfg)
+	require_Error(t, err, NewJSStreamInvalidConfigError(errors.New("zstd compression level must be between 1 and 22")))
+	cfg.Compression, cfg.CompressionOpts.Level = S2Compression, 3
+	_, err = jsStreamCreate(t, nc, cfg)
+	require_Error(t, err, NewJSStreamInvalidConfigError(errors.New("compression level requires zstd compression")))
+	cfg.Compression, cfg.CompressionOpts = ZstdCompression, &StreamCompressionOpts{Dictionary: []byte("bad")}
+	_, err = jsStreamCreate(t, nc, cfg)
+	require_Error(t, err)
+	require_True(t, strings.Contains(err.Error(), "invalid zstd dictionary"))
+
+	// Small blocks so that we get a few sealed ones.
+	cfg.MaxBytes = 120_000
+	cfg.CompressionOpts = &StreamCompressionOpts{Level: 19, Dictionary: dict}
+	_, err = jsStreamCreate(t, nc, cfg)
+	require_NoError(t, err)
+
+	const msgs = 100
+	for i := 0; i < msgs; i++ {
+		_, err = js.Publish("foo", payload(i))
+		require_NoError(t, err)
+	}
+
+	streamInfo := func() *StreamInfo {
+		t.Helper()
+		rmsg, err := nc.Request(fmt.Sprintf(JSApiStreamInfoT, "TEST"), nil, time.Second)
+		require_NoError(t, err)
+		var resp JSApiStreamInfoResponse
+		require_NoError(t, json.Unmarshal(rmsg.Data, &resp))
+		require_True(t, resp.Error == nil)
+		return resp.StreamInfo
+	}
+	checkFor(t, 2*time.Second, 50*time.Millisecond, func() error {
+		ci := streamInfo().Compression
+		if ci == nil || ci.Algorithm != ZstdCompression {
+			return fmt.Errorf("unexpected compression info: %+v", ci)
+		}
+		if ci.Ratio < 2 {
+			return fmt.Errorf("expected a compression ratio of at least 2, got %.2f", ci.Ratio)
+		}
+		return nil
+	})
+
+	// The dictionary can not be changed once set.
+	cfg.CompressionOpts = &StreamCompressionOpts{Level: 19}
+	_, err = jsStreamUpdate(t, nc, cfg)
+	require_Error(t, err, NewJSStreamInvalidConfigError(errors.New("stream configuration update can not change compression dictionary")))
+
+	// Switching the algorithm migrates the existing blocks.
+	mset, err := s.globalAccount().lookupStream("TEST")
+	require_NoError(t, err)
+	fs := mset.store.(*fileStore)
+	checkBlocks := func(alg StoreCompression) {
+		t.Helper()
+		checkFor(t, 2*time.Second, 50*time.Millisecond, func() error {
+			fs.mu.RLock()
+			defer fs.mu.RUnlock()
+			for _, mb := range fs.blks[:len(fs.blks)-1] {
+				mb.mu.RLock()
+				cmp := mb.cmp
+				mb.mu.RUnlock()
+				if cmp != alg {
+					return fmt.Errorf("block %d compressed with %s, expected %s", mb.index, cmp, alg)
+				}
+			}
+			return nil
+		})
+	}
+	checkBlocks(ZstdCompression)
+	cfg.Compression, cfg.CompressionOpts = S2Compression, &StreamCompressionOpts{Dictionary: dict}
+	_, err = jsStreamUpdate(t, nc, cfg)
+	require_NoError(t, err)
+	checkBlocks(S2Compression)
+	require_Equal(t, streamInfo().Compression.Algorithm, S2Compression)
+
+	cfg.Compression = NoCompression
+	_, err = jsStreamUpdate(t, nc, cfg)
+	require_NoError(t, err)
+	checkBlocks(NoCompression)
+	require_True(t, streamInfo().Compression == nil)
+
+	cfg.Compression = ZstdCompression
+	_, err = jsStreamUpdate(t, nc, cfg)
+	require_NoError(t, err)
+	checkBlocks(ZstdCompression)
+
+	// Messages are read back from disk after a restart.
+	sd := s.JetStreamConfig().StoreDir
+	nc.Close()
+	s.Shutdown()
+	s = RunJetStreamServerOnPort(-1, sd)
+	defer s.Shutdown()
+
+	nc, js = jsClientConnect(t, s)
+	defer nc.Close()
+
+	for i := 0; i < msgs; i++ {
+		m, err := js.GetMsg("TEST", uint64(i+1))
+		require_NoError(t, err)
+		require_True(t, bytes.Equal(m.Data, payload(i)))
+	}
+}
+
+func TestJetStreamServerEncryptionKMS(t *testing.T) {
+	storeDir := t.TempDir()
+	keysFile := filepath.Join(t.TempDir(), "keys.json")
+	conf := createConfFile(t, []byte(fmt.Sprintf(`
+		server_name: S22
+		listen: 127.0.0.1:-1
+		jetstream: {
+			store_dir: %q
+			cipher: aes
+			stream_keys: true
+			kms: {
+				provider: file
+				keys_file: %q
+				key: default-key
+				account_keys: { B: b-key }
+			}
+		}
+	`, storeDir, keysFile)))
+
+	s, _ := RunServerWithConfig(conf)
+	defer s.Shutdown()
+
+	nc, js := jsClientConnect(t, s)
+	defer nc.Close()
+
+	_, err := js.AddStream(&nats.StreamConfig{Name: "TEST", Subjects: []string{"foo"}})
+	require_NoError(t, err)
+	msg := []byte("ENCRYPTED PAYLOAD!!")
+	for i := 0; i < 10; i++ {
+		_, err = js.Publish("foo", msg)
+		require_NoError(t, err)
+	}
+
+	// Each account has its own wrapped data key, and no plaintext is stored.
+	readKeyFile := func(acc string) kmsKeyFile {
+		t.Helper()
+		buf, err := os.ReadFile(filepath.Join(storeDir, JetStreamStoreDir, acc, JetStreamKMSKeyFile))
+		require_NoError(t, err)
+		var kf kmsKeyFile
+		require_NoError(t, json.Unmarshal(buf, &kf))
+		return kf
+	}
+	dkG, err := s.jsEncryptionKey(globalAccountName)
+	require_NoError(t, err)
+	dkB, err := s.jsEncryptionKey("B")
+	require_NoError(t, err)
+	require_NotEqual(t, dkG, dkB)
+	kg, kb := readKeyFile(globalAccountName), readKeyFile("B")
+	require_Equal(t, kg.Key, "default-key")
+	require_Equal(t, kb.Key, "b-key")
+	require_True(t, bytes.HasPrefix(kg.Wrapped, []byte("file:v1:")))
+	require_True(t, bytes.HasPrefix(kb.Wrapped, []byte("file:v1:")))
+
+	// The stream data key is wrapped by the KMS key of the account.
+	readStreamKeyFile := func() kmsKeyFile {
+		t.Helper()
+		buf, err := os.ReadFile(filepath.Join(storeDir, JetStreamStoreDir, globalAccountName, streamsDir, "TEST", JetStreamStreamKeyFile))
+		require_NoError(t, err)
+		var kf kmsKeyFile
+		require_NoError(t, json.Unmarshal(buf, &kf))
+		return kf
+	}
+	ks := readStreamKeyFile()
+	require_Equal(t, ks.Key, "default-key")
+	require_True(t, bytes.HasPrefix(ks.Wrapped, []byte("file:v1:")))
+
+	blk := filepath.Join(storeDir, JetStreamStoreDir, globalAccountName, streamsDir, "TEST", msgDir, "1.blk")
+	data, err := os.ReadFile(blk)
+	require_NoError(t, err)
+	require_False(t, bytes.Contains(data, msg))
+
+	restart := func() {
+		t.Helper()
+		nc.Close()
+		s.Shutdown()
+		s, _ = RunServerWithConfig(conf)
+		nc, js = jsClientConnect(t, s)
+
+		si, err := js.StreamInfo("TEST")
+		require_NoError(t, err)
+		require_Equal(t, si.State.Msgs, 10)
+		m, err := js.GetMsg("TEST", 10)
+		require_NoError(t, err)
+		require_True(t, bytes.Equal(m.Data, msg))
+	}
+
+	// Data is recovered after a restart.
+	restart()
+	defer s.Shutdown()
+
+	// Rotate the KMS keys and re-wrap the data keys online.
+	resp, err := s.RotateJetStreamKeys(&JSKeyRotateRequest{RotateKey: true})
+	require_NoError(t, err)
+	require_True(t, resp.Server)
+	slices.Sort(resp.Accounts)
+	require_Equal(t, strings.Join(resp.Accounts, ","), "$G,B")
+	slices.Sort(resp.RotatedKeys)
+	require_Equal(t, strings.Join(resp.RotatedKeys, ","), "b-key,default-key")
+	require_Equal(t, resp.Streams, 1)
+
+	// Account names are validated before being used in a path.
+	for _, acc := range []string{"../B", "B/..", "..", "UNKNOWN"} {
+		_, err = s.RotateJetStreamKeys(&JSKeyRotateRequest{Account: acc})
+		require_Error(t, err)
+	}
+
+	// Only the wrapped keys changed, not the stream data.
+	kg, kb = readKeyFile(globalAccountName), readKeyFile("B")
+	require_True(t, bytes.HasPrefix(kg.Wrapped, []byte("file:v2:")))
+	require_True(t, bytes.HasPrefix(kb.Wrapped, []byte("file:v2:")))
+	require_True(t, bytes.HasPrefix(readStreamKeyFile().Wrapped, []byte("file:v2:")))
+	ndata, err := os.ReadFile(blk)
+	require_NoError(t, err)
+	require_True(t, bytes.Equal(data, ndata))
+
+	// A new stream data key is wrapped by the KMS as well.
+	ks = readStreamKeyFile()
+	mset, err := s.GlobalAccount().lookupStream("TEST")
+	require_NoError(t, err)
+	require_NoError(t, mset.rotateEncryptionKey())
+	nks := readStreamKeyFile()
+	require_Equal(t, nks.Key, "default-key")
+	require_False(t, bytes.Equal(ks.Wrapped, nks.Wrapped))
+
+	restart()
+	nc.Close()
+	s.Shutdown()
+
+	// A configured encryption key can not be combined with a KMS.
+	conf = createConfFile(t, []byte(fmt.Sprintf(`
+		listen: 127.0.0.1:-1
+		jetstream: { store_dir: %q, key: s3cr3t, kms: { provider: file, keys_file: %q } }
+	`, t.TempDir(), keysFile)))
+	opts := LoadConfig(conf)
+	opts.JetStream = false
+	s, err = NewServer(opts)
+	require_NoError(t, err)
+	err = s.EnableJetStream(&JetStreamConfig{StoreDir: opts.StoreDir})
+	require_Error(t, err, errors.New("JetStream KMS may not be used with an encryption key or TPM options"))
+}
+
+func TestJetStreamStreamEncryptionKeys(t *testing.T) {
+	storeDir := t.TempDir()
+	conf := createConfFile(t, []byte(fmt.Sprintf(`
+		listen: 127.0.0.1:-1
+		jetstream: { store_dir: %q, key: s3cr3t, stream_keys: true }
+	`, storeDir)))
+
+	s, _ := RunServerWithConfig(conf)
+	defer s.Shutdown()
+
+	nc, js := jsClientConnect(t, s)
+	defer nc.Close()
+
+	for _, name := range []string{"TEST", "OTHER"} {
+		_, err := js.AddStream(&nats.StreamConfig{Name: name, Subjects: []string{strings.ToLower(name)}})
+		require_NoError(t, err)
+	}
+	msg := []byte("ENCRYPTED PAYLOAD!!")
+	for i := 0; i < 10; i++ {
+		_, err := js.Publish("test", msg)
+		require_NoError(t, err)
+	}
+	_, err := js.AddConsumer("TEST", &nats.ConsumerConfig{Durable: "dlc", AckPolicy: nats.AckExplicitPolicy})
+	require_NoError(t, err)
+
+	// Each stream has its own data key.
+	sdir := filepath.Join(storeDir, JetStreamStoreDir, globalAccountName, streamsDir, "TEST")
+	odir := filepath.Join(storeDir, JetStreamStoreDir, globalAccountName, streamsDir, "OTHER")
+	skey, err := s.jsStreamEncryptionKey(globalAccountName, sdir, "TEST", false)
+	require_NoError(t, err)
+	okey, err := s.jsStreamEncryptionKey(globalAccountName, odir, "OTHER", false)
+	require_NoError(t, err)
+	require_NotEqual(t, skey, okey)
+	require_NotEqual(t, skey, "s3cr3t")
+
+	readFile := func(fn string) []byte {
+		t.Helper()
+		buf, err := os.ReadFile(filepath.Join(sdir, fn))
+		require_NoError(t, err)
+		return buf
+	}
+	blk, blkKey, wkey := readFile("msgs/1.blk"), readFile("msgs/1.key"), readFile(JetStreamStreamKeyFile)
+	require_False(t, bytes.Contains(blk, msg))
+
+	checkStream := func() {
+		t.Helper()
+		si, err := js.StreamInfo("TEST")
+		require_NoError(t, err)
+		require_Equal(t, si.State.Msgs, 10)
+		m, err := js.GetMsg("TEST", 10)
+		require_NoError(t, err)
+		require_True(t, bytes.Equal(m.Data, msg))
+		ci, err := js.ConsumerInfo("TEST", "dlc")
+		require_NoError(t, err)
+		require_Equal(t, ci.NumPending, 10)
+	}
+	restart := func() {
+		t.Helper()
+		nc.Close()
+		s.Shutdown()
+		s, _ = RunServerWithConfig(conf)
+		nc, js = jsClientConnect(t, s)
+		checkStream()
+	}
+
+	rotate := func(stream string) *JSApiStreamKeyRotateResponse {
+		t.Helper()
+		rmsg, err := nc.Request(fmt.Sprintf(JSApiStreamKeyRotateT, stream), nil, time.Second)
+		require_NoError(t, err)
+		var resp JSApiStreamKeyRotateResponse
+		require_NoError(t, json.Unmarshal(rmsg.Data, &resp))
+		return &resp
+	}
+
+	// Rotating only re-wraps the key files.
+	resp := rotate("TEST")
+	require_True(t, resp.Error == nil)
+	require_True(t, resp.Success)
+	require_False(t, bytes.Equal(wkey, readFile(JetStreamStreamKeyFile)))
+	require_False(t, bytes.Equal(blkKey, readFile("msgs/1.key")))
+	require_True(t, bytes.Equal(blk, readFile("msgs/1.blk")))
+	checkStream()
+
+	restart()
+	defer s.Shutdown()
+
+	// An interrupted rotation is completed on recovery.
+	wkey = readFile(JetStreamStreamKeyFile)
+	nc.Close()
+	s.Shutdown()
+	_, nwkey, err := s.genStreamKey("s3cr3t", globalAccountName, "TEST")
+	require_NoError(t, err)
+	require_NoError(t, os.WriteFile(filepath.Join(sdir, JetStreamStreamKeyFile+streamKeyPendingSuffix), nwkey, defaultFilePerms))
+	s, _ = RunServerWithConfig(conf)
+	nc, js = jsClientConnect(t, s)
+	checkStream()
+	require_True(t, bytes.Equal(nwkey, readFile(JetStreamStreamKeyFile)))
+	_, err = os.Stat(filepath.Join(sdir, JetStreamStreamKeyFile+streamKeyPendingSuffix))
+	require_True(t, os.IsNotExist(err))
+	restart()
+
+	// Memory streams have no key.
+	_, err = js.AddStream(&nats.StreamConfig{Name: "MEM", Storage: nats.MemoryStorage})
+	require_NoError(t, err)
+	resp = rotate("MEM")
+	require_True(t, resp.Error != nil)
+	require_Equal(t, resp.Error.ErrCode, uint16(JSStreamNoEncryptionKeyErr))
+
+	// Shredding the key deletes the stream.
+	rmsg, err := nc.Request(fmt.Sprintf(JSApiStreamKeyShredT, "TEST"), nil, time.Second)
+	require_NoError(t, err)
+	var dresp JSApiStreamDeleteResponse
+	require_NoError(t, json.Unmarshal(rmsg.Data, &dresp))
+	require_True(t, dresp.Error == nil)
+	require_True(t, dresp.Success)
+	_, err = js.StreamInfo("TEST")
+	require_Error(t, err, nats.ErrStreamNotFound)
+	checkFor(t, 2*time.Second, 100*time.Millisecond, func() error {
+		if _, err := os.Stat(sdir); !os.IsNotExist(err) {
+			return fmt.Errorf("stream directory still present")
+		}
+		return nil
+	})
+	nc.Close()
+	s.Shutdown()
+
+	// Stream keys require encryption.
+	conf = createConfFile(t, []byte(fmt.Sprintf(`
+		listen: 127.0.0.1:-1
+		jetstream: { store_dir: %q, stream_keys: true }
+	`, t.TempDir())))
+	s, _ = RunServerWithConfig(conf)
+	nc, js = jsClientConnect(t, s)
+	defer nc.Close()
+	_, err = js.AddStream(&nats.StreamConfig{Name: "TEST"})
+	require_NoError(t, err)
+	resp = rotate("TEST")
+	require_True(t, resp.Error != nil)
+	require_Equal(t, resp.Error.ErrCode, uint16(JSStreamKeysNotEnabledErr))
+}
+
+func TestJetStreamStreamEraseBySubject(t *testing.T) {
+	for _, st := range []nats.StorageType{nats.FileStorage, nats.MemoryStorage} {
+		t.Run(st.String(), func(t *testing.T) {
+			s := RunBasicJetStreamServer(t)
+			defer s.Shutdown()
+
+			nc, js := jsClientConnect(t, s)
+			defer nc.Close()
+
+			_, err := js.AddStream(&nats.StreamConfig{Name: "TEST", Subjects: []string{"user.>"}, Storage: st})
+			require_NoError(t, err)
+
+			for _, user := range []string{"alice", "bob"} {
+				for i := 0; i < 5; i++ {
+					m := nats.NewMsg(fmt.Sprintf("user.%s.%d", user, i))
+					m.Data = []byte("SECRET-" + user)
+					if i%2 == 0 {
+						m.Header.Set("Region", "eu")
+					}
+					_, err = js.PublishMsg(m)
+					require_NoError(t, err)
+				}
+			}
+
+			erase := func(req *JSApiStreamEraseRequest) *JSApiStreamEraseResponse {
+				t.Helper()
+				var body []byte
+				if req != nil {
+					body, err = json.Marshal(req)
+					require_NoError(t, err)
+				}
+				rmsg, err := nc.Request(fmt.Sprintf(JSApiStreamEraseT, "TEST"), body, time.Second)
+				require_NoError(t, err)
+				var resp JSApiStreamEraseResponse
+				require_NoError(t, json.Unmarshal(rmsg.Data, &resp))
+				return &resp
+			}
+
+			// A subject filter is required.
+			resp := erase(&JSApiStreamEraseRequest{})
+			require_True(t, resp.Error != nil)
+			require_Equal(t, resp.Error.ErrCode, uint16(JSStreamEraseFilterRequiredErr))
+			resp = erase(nil)
+			require_True(t, resp.Error != nil)
+
+			// Erase by subject and header.
+			resp = erase(&JSApiStreamEraseRequest{Subject: "user.bob.*", Headers: map[string]string{"Region": "eu"}})
+			require_True(t, resp.Error == nil)
+			require_Equal(t, resp.Erased, 3)
+
+			// Erase the rest by subject.
+			resp = erase(&JSApiStreamEraseRequest{Subject: "user.alice.>"})
+			require_True(t, resp.Error == nil)
+			require_Equal(t, resp.Erased, 5)
+
+			si, err := js.StreamInfo("TEST")
+			require_NoError(t, err)
+			require_Equal(t, si.State.Msgs, 2)
+			for _, seq := range []uint64{7, 9} {
+				m, err := js.GetMsg("TEST", seq)
+				require_NoError(t, err)
+				require_Equal(t, string(m.Data), "SECRET-bob")
+			}
+
+			// The payloads were overwritten on disk.
+			if st == nats.FileStorage {
+				mset, err := s.globalAccount().lookupStream("TEST")
+				require_NoError(t, err)
+				fs := mset.store.(*fileStore)
+				checkFor(t, 2*time.Second, 50*time.Millisecond, func() error {
+					buf, err := os.ReadFile(filepath.Join(fs.fcfg.StoreDir, msgDir, "1.blk"))
+					if err != nil {
+						return err
+					}
+					if bytes.Contains(buf, []byte("SECRET-alice")) {
+						return errors.New("erased payload still on disk")
+					}
+					if n := bytes.Count(buf, []byte("SECRET-bob")); n != 2 {
+						return fmt.Errorf("expected 2 remaining payloads, got %d", n)
+					}
+					return nil
+				})
+			}
+		})
+	}
+}
+
+func TestJetStreamStreamConnectors(t *testing.T) {
+	storeDir, fileDir := t.TempDir(), t.TempDir()
+	conf := createConfFile(t, []byte(fmt.Sprintf(`
+		listen: 127.0.0.1:-1
+		jetstream: {
+			store_dir: %q
+			connectors: { file_dir: %q, allow_http: true }
+		}
+	`, storeDir, fileDir)))
+
+	s, _ := RunServerWithConfig(conf)
+	defer s.Shutdown()
+
+	// Webhook that fails the first two requests.
+	var (
+		mu    sync.Mutex
+		calls int
+		seqs  []uint64
+	)
+	hs := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		if calls++; calls <= 2 {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		var rec ConnectorRecord
+		if err := json.NewDecoder(r.Body).Decode(&rec); err != nil || r.Header.Get("X-Token") != "abc" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		seqs = append(seqs, rec.Sequence)
+	}))
+	defer hs.Close()
+
+	nc, js := jsClientConnect(t, s)
+	defer nc.Close()
+
+	addStream := func(cfg *StreamConfig) *JSApiStreamCreateResponse {
+		t.Helper()
+		req, err := json.Marshal(cfg)
+		require_NoError(t, err)
+		rmsg, err := nc.Request(fmt.Sprintf(JSApiStreamCreateT, cfg.Name), req, time.Second)
+		require_NoError(t, err)
+		var resp JSApiStreamCreateResponse
+		require_NoError(t, json.Unmarshal(rmsg.Data, &resp))
+		return &resp
+	}
+
+	// Invalid connectors are rejected.
+	for _, sc := range []*StreamConnector{
+		{Name: "bad", File: &ConnectorFileSink{Path: "../escape.jsonl"}},
+		{Name: "bad", File: &ConnectorFileSink{Path: "/tmp/escape.jsonl"}},
+		{Name: "bad", File: &ConnectorFileSink{Path: "cdc.csv", Format: "csv"}},
+		{Name: "bad", Exec: &ConnectorExecSink{Command: "/bin/sh"}},
+		{Name: "bad", HTTP: &ConnectorHTTPSink{URL: "ftp://example.com"}},
+		{Name: "bad"},
+		{Name: "bad", File: &ConnectorFileSink{Path: "cdc.jsonl"}, HTTP: &ConnectorHTTPSink{URL: hs.URL}},
+	} {
+		resp := addStream(&StreamConfig{Name: "BAD", Subjects: []string{"bad"}, Storage: FileStorage, Connectors: []*StreamConnector{sc}})
+		require_True(t, resp.Error != nil)
+		require_Equal(t, resp.Error.ErrCode, uint16(JSStreamInvalidConfigF))
+	}
+
+	resp := addStream(&StreamConfig{
+		Name:     "ORDERS",
+		Subjects: []string{"orders.>"},
+		Storage:  FileStorage,
+		Connectors: []*StreamConnector{
+			{Name: "file", File: &ConnectorFileSink{Path: "cdc/orders.jsonl", MaxBytes: 1024}},
+			{Name: "http", FilterSubject: "orders.eu", HTTP: &ConnectorHTTPSink{URL: hs.URL, Headers: map[string]string{"X-Token": "abc"}}},
+		},
+	})
+	require_True(t, resp.Error == nil)
+
+	errSub, err := nc.SubscribeSync(JSAdvisoryStreamConnectorErrorPre + ".ORDERS.http")
+	require_NoError(t, err)
+	defer errSub.Unsubscribe()
+	require_NoError(t, nc.Flush())
+
+	publish := func(n int) {
+		t.Helper()
+		for i := 0; i < n; i++ {
+			m := nats.NewMsg("orders.eu")
+			if i%2 == 1 {
+				m.Subject = "orders.us"
+			}
+			m.Header.Set("Order", strconv.Itoa(i))
+			m.Data = []byte("ORDER")
+			_, err := js.PublishMsg(m)
+			require_NoError(t, err)
+		}
+	}
+	publish(10)
+
+	readRecords := func() []*ConnectorRecord {
+		t.Helper()
+		files, err := filepath.Glob(filepath.Join(fileDir, globalAccountName, "ORDERS", "cdc", "orders*.jsonl"))
+		require_NoError(t, err)
+		// Rotated files sort by timestamp, before the current file.
+		slices.Sort(files)
+		var recs []*ConnectorRecord
+		for _, fn := range files {
+			buf, err := os.ReadFile(fn)
+			require_NoError(t, err)
+			for _, line := range bytes.Split(bytes.TrimSpace(buf), []byte("\n")) {
+				var rec ConnectorRecord
+				require_NoError(t, json.Unmarshal(line, &rec))
+				recs = append(recs, &rec)
+			}
+		}
+		return recs
+	}
+
+	connectorsInfo := func() map[string]*StreamConnectorInfo {
+		t.Helper()
+		rmsg, err := nc.Request(fmt.Sprintf(JSApiStreamInfoT, "ORDERS"), nil, time.Second)
+		require_NoError(t, err)
+		var resp JSApiStreamInfoResponse
+		require_NoError(t, json.Unmarshal(rmsg.Data, &resp))
+		require_True(t, resp.Error == nil)
+		cis := make(map[string]*StreamConnectorInfo)
+		for _, ci := range resp.StreamInfo.Connectors {
+			cis[ci.Name] = ci
+		}
+		return cis
+	}
+
+	checkFor(t, 5*time.Second, 100*time.Millisecond, func() error {
+		cis := connectorsInfo()
+		if ci := cis["file"]; ci == nil || ci.Delivered != 10 || ci.Lag != 0 {
+			return fmt.Errorf("file connector not caught up: %+v", ci)
+		}
+		if ci := cis["http"]; ci == nil || ci.Delivered != 9 || ci.Lag != 0 {
+			return fmt.Errorf("http connector not caught up: %+v", ci)
+		}
+		return nil
+	})
+
+	// Records are written in order, and the file was rotated.
+	recs := readRecords()
+	require_Len(t, len(recs), 10)
+	for i, rec := range recs {
+		require_Equal(t, rec.Stream, "ORDERS")
+		require_Equal(t, rec.Sequence, uint64(i+1))
+		require_Equal(t, string(rec.Data), "ORDER")
+		require_Equal(t, rec.Headers["Order"][0], strconv.Itoa(i))
+	}
+	files, err := filepath.Glob(filepath.Join(fileDir, globalAccountName, "ORDERS", "cdc", "orders*.jsonl"))
+	require_NoError(t, err)
+	require_True(t, len(files) > 1)
+
+	// The webhook only got the filtered messages, after recovering from errors.
+	mu.Lock()
+	require_True(t, slices.Equal(seqs, []uint64{1, 3, 5, 7, 9}))
+	mu.Unlock()
+	ci := connectorsInfo()["http"]
+	require_Equal(t, ci.Errors, 2)
+	require_True(t, strings.Contains(ci.LastError, "500"))
+	require_True(t, ci.LastErrorTime != nil)
+
+	msg, err := errSub.NextMsg(time.Second)
+	require_NoError(t, err)
+	var adv JSStreamConnectorErrorAdvisory
+	require_NoError(t, json.Unmarshal(msg.Data, &adv))
+	require_Equal(t, adv.Type, JSStreamConnectorErrorAdvisoryType)
+	require_Equal(t, adv.Connector, "http")
+	require_Equal(t, adv.StreamSeq, 1)
+
+	// Offsets are stored in durable consumers.
+	checkFor(t, 2*time.Second, 100*time.Millisecond, func() error {
+		for _, name := range []string{"file", "http"} {
+			ci, err := js.ConsumerInfo("ORDERS", connectorConsumerPrefix+name)
+			if err != nil {
+				return err
+			}
+			if ci.NumAckPending != 0 || ci.AckFloor.Stream < 9 {
+				return fmt.Errorf("consumer %q not acked: %+v", name, ci.AckFloor)
+			}
+		}
+		return nil
+	})
+
+	// After a restart the connectors resume where they left off.
+	nc.Close()
+	s.Shutdown()
+	s, _ = RunServerWithConfig(conf)
+	defer s.Shutdown()
+
+	nc, js = jsClientConnect(t, s)
+	defer nc.Close()
+
+	publish(2)
+	checkFor(t, 5*time.Second, 100*time.Millisecond, func() error {
+		if ci := connectorsInfo()["file"]; ci == nil || ci.Delivered != 12 {
+			return fmt.Errorf("file connector not caught up: %+v", ci)
+		}
+		return nil
+	})
+	recs = readRecords()
+	require_Len(t, len(recs), 12)
+	for i, rec := range recs {
+		require_Equal(t, rec.Sequence, uint64(i+1))
+	}
+
+	// Removing a connector deletes its consumer.
+	cfg := &StreamConfig{
+		Name:       "ORDERS",
+		Subjects:   []string{"orders.>"},
+		Storage:    FileStorage,
+		Connectors: []*StreamConnector{{Name: "file", File: &ConnectorFileSink{Path: "cdc/orders.jsonl", MaxBytes: 1024}}},
+	}
+	req, err := json.Marshal(cfg)
+	require_NoError(t, err)
+	rmsg, err := nc.Request(fmt.Sprintf(JSApiStreamUpdateT, "ORDERS"), req, time.Second)
+	require_NoError(t, err)
+	var uresp JSApiStreamUpdateResponse
+	require_NoError(t, json.Unmarshal(rmsg.Data, &uresp))
+	require_True(t, uresp.Error == nil)
+	checkFor(t, 2*time.Second, 100*time.Millisecond, func() error {
+		if _, err := js.ConsumerInfo("ORDERS", connectorConsumerPrefix+"http"); err == nil {
+			return errors.New("expected consumer to be deleted")
+		}
+		return nil
+	})
+}
+
+func TestJetStreamStreamConnectorsParquet(t *testing.T) {
+	storeDir, fileDir := t.TempDir(), t.TempDir()
+	conf := createConfFile(t, []byte(fmt.Sprintf(`
+		listen: 127.0.0.1:-1
+		jetstream: {
+			store_dir: %q
+			connectors: { file_dir: %q }
+		}
+	`, storeDir, fileDir)))
+
+	s, _ := RunServerWithConfig(conf)
+	defer s.Shutdown()
+
+	nc, js := jsClientConnect(t, s)
+	defer nc.Close()
+
+	_, err := jsStreamCreate(t, nc, &StreamConfig{
+		Name:       "ORDERS",
+		Subjects:   []string{"orders.>"},
+		Storage:    FileStorage,
+		Connectors: []*StreamConnector{{Name: "file", File: &ConnectorFileSink{Path: "cdc/orders.parquet", Format: "parquet", MaxBytes: 1024}}},
+	})
+	require_NoError(t, err)
+
+	for i := 0; i < 20; i++ {
+		m := nats.NewMsg(fmt.Sprintf("orders.%d", i))
+		if i%2 == 0 {
+			m.Header.Set("Order", strconv.Itoa(i))
+			m.Data = []byte("ORDER")
+		}
+		_, err := js.PublishMsg(m)
+		require_NoError(t, err)
+	}
+	mset, err := s.globalAccount().lookupStream("ORDERS")
+	require_NoError(t, err)
+	checkFor(t, 5*time.Second, 100*time.Millisecond, func() error {
+		if ci := mset.connectorsInfo(); len(ci) != 1 || ci[0].Delivered != 20 {
+			return fmt.Errorf("file connector not caught up: %+v", ci)
+		}
+		return nil
+	})
+
+	// The rotated records are in Parquet files, the others are pending.
+	dir := filepath.Join(fileDir, globalAccountName, "ORDERS", "cdc")
+	files, err := filepath.Glob(filepath.Join(dir, "orders-*.parquet"))
+	require_NoError(t, err)
+	require_True(t, len(files) > 0)
+	_, err = os.Stat(filepath.Join(dir, "orders.parquet"+connectorPendingSuffix))
+	require_NoError(t, err)
+
+	// Pending records are written when the connector stops.
+	nc.Close()
+	s.Shutdown()
+	_, err = os.Stat(filepath.Join(dir, "orders.parquet"+connectorPendingSuffix))
+	require_True(t, os.IsNotExist(err))
+
+	files, err = filepath.Glob(filepath.Join(dir, "orders-*.parquet"))
+	require_NoError(t, err)
+	slices.Sort(files)
+	var recs []*ConnectorRecord
+	for _, fn := range files {
+		recs = append(recs, readConnectorParquet(t, fn)...)
+	}
+	require_Len(t, len(recs), 20)
+	for i, rec := range recs {
+		require_Equal(t, rec.Stream, "ORDERS")
+		require_Equal(t, rec.Subject, fmt.Sprintf("orders.%d", i))
+		require_Equal(t, rec.Sequence, uint64(i+1))
+		require_True(t, time.Since(rec.Time) < time.Minute)
+		if i%2 == 0 {
+			require_Equal(t, string(rec.Data), "ORDER")
+			require_Equal(t, rec.Headers["Order"][0], strconv.Itoa(i))
+		} else {
+			require_True(t, rec.Data == nil)
+			require_True(t, rec.Headers == nil)
+		}
+	}
+}
+
+// Reads the records of a connector Parquet file, decoding its thrift compact
+// encoded metadata and the plain encoded pages of its columns.
+func readConnectorParquet(t *testing.T, fn string) []*ConnectorRecord {
+	t.Helper()
+	buf, err := os.ReadFile(fn)
+	require_NoError(t, err)
+	require_True(t, len(buf) > 12)
+	require_Equal(t, string(buf[:4]), parquetMagic)
+	require_Equal(t, string(buf[len(buf)-4:]), parquetMagic)
+	flen := int(binary.LittleEndian.Uint32(buf[len(buf)-8:]))
+	footer := buf[len(buf)-8-flen : len(buf)-8]
+
+	// Decodes a thrift struct into its fields by id, returning the bytes read.
+	var readStruct func(b []byte) (map[int16]any, int)
+	readValue := func(b []byte, typ byte) (any, int) {
+		switch typ {
+		case thriftBoolTrue:
+			return true, 0
+		case thriftBoolFalse:
+			return false, 0
+		case thriftByte:
+			return int64(int8(b[0])), 1
+		case thriftI32, thriftI64:
+			v, n := binary.Varint(b)
+			return v, n
+		case thriftBinary:
+			l, n := binary.Uvarint(b)
+			return b[n : n+int(l)], n + int(l)
+		case thriftStruct:
+			return readStruct(b)
+		}
+		t.Fatalf("unexpected thrift type %d", typ)
+		return nil, 0
+	}
+	readStruct = func(b []byte) (map[int16]any, int) {
+		fields := make(map[int16]any)
+		var id int16
+		for i := 0; ; {
+			h := b[i]
+			i++
+			if h == 0 {
+				return fields, i
+			}
+			typ := h & 0x0f
+			if d := h >> 4; d != 0 {
+				id += int16(d)
+			} else {
+				v, n := binary.Varint(b[i:])
+				id, i = int16(v), i+n
+			}
+			if typ != thriftList {
+				v, n := readValue(b[i:], typ)
+				fields[id], i = v, i+n
+				continue
+			}
+			lh := b[i]
+			i++
+			size, elem := int(lh>>4), lh&0x0f
+			if size == 15 {
+				v, n := binary.Uvarint(b[i:])
+				size, i = int(v), i+n
+			}
+			list := make([]any, size)
+			for j := range list {
+				v, n := readValue(b[i:], elem)
+				list[j], i = v, i+n
+			}
+			fields[id] = list
+		}
+	}
+
+	meta, n := readStruct(footer)
+	require_Equal(t, n, len(footer))
+	rows := int(meta[3].(int64))
+	optional := make(map[string]bool)
+	for _, se := range meta[2].([]any)[1:] {
+		se := se.(map[int16]any)
+		optional[string(se[4].([]byte))] = se[3].(int64) == parquetOptional
+	}
+	recs := make([]*ConnectorRecord, rows)
+	for i := range recs {
+		recs[i] = &ConnectorRecord{}
+	}
+	rgs := meta[4].([]any)
+	require_Len(t, len(rgs), 1)
+	for _, cc := range rgs[0].(map[int16]any)[1].([]any) {
+		md := cc.(map[int16]any)[3].(map[int16]any)
+		name := string(md[3].([]any)[0].([]byte))
+		require_Equal(t, md[4].(int64), parquetCodecUncompressed)
+		off := int(md[9].(int64))
+		ph, n := readStruct(buf[off:])
+		require_Equal(t, ph[5].(map[int16]any)[1].(int64), int64(rows))
+		page := buf[off+n : off+n+int(ph[3].(int64))]
+
+		defined := make([]bool, 0, rows)
+		if optional[name] {
+			l := int(binary.LittleEndian.Uint32(page))
+			levels := page[4 : 4+l]
+			for len(levels) > 0 {
+				h, n := binary.Uvarint(levels)
+				require_Equal(t, h&1, 0)
+				for j := 0; j < int(h>>1); j++ {
+					defined = append(defined, levels[n] == 1)
+				}
+				levels = levels[n+1:]
+			}
+			page = page[4+l:]
+		} else {
+			for j := 0; j < rows; j++ {
+				defined = append(defined, true)
+			}
+		}
+		require_Len(t, len(defined), rows)
+		for j, rec := range recs {
+			if !defined[j] {
+				continue
+			}
+			var v []byte
+			if md[1].(int64) == parquetTypeInt64 {
+				v, page = page[:8], page[8:]
+			} else {
+				l := binary.LittleEndian.Uint32(page)
+				v, page = page[4:4+l], page[4+l:]
+			}
+			switch name {
+			case "stream":
+				rec.Stream = string(v)
+			case "subject":
+				rec.Subject = string(v)
+			case "seq":
+				rec.Sequence = binary.LittleEndian.Uint64(v)
+			case "time":
+				rec.Time = time.Unix(0, int64(binary.LittleEndian.Uint64(v)))
+			case "hdrs":
+				require_NoError(t, json.Unmarshal(v, &rec.Headers))
+			case "data":
+				rec.Data = v
+			}
+		}
+		require_Len(t, len(page), 0)
+	}
+	return recs
+}
@@ -17,7 +17,7 @@ import "strconv"
 
 const (
 	// JSApiLevel is the maximum supported JetStream API level for this server.
-	JSApiLevel int = 2
+	JSApiLevel int = 3
 
 	JSRequiredLevelMetadataKey = "_nats.req.level"
 	JSServerVersionMetadataKey = "_nats.ver"
@@ -77,6 +77,21 @@ func setStaticStreamMetadata(cfg *StreamConfig) {
 		requires(2)
 	}
 
+	// Compaction by subject was added in v2.13 and requires API level 3.
+	if cfg.Compaction != nil {
+		requires(3)
+	}
+
+	// Zstd compression and its options were added in v2.13 and require API level 3.
+	if cfg.Compression == ZstdCompression || cfg.CompressionOpts != nil {
+		requires(3)
+	}
+
+	// Stream connectors were added in v2.13 and require API level 3.
+	if len(cfg.Connectors) > 0 {
+		requires(3)
+	}
+
 	cfg.Metadata[JSRequiredLevelMetadataKey] = strconv.Itoa(requiredApiLevel)
 }
 
@@ -153,6 +168,21 @@ func setStaticConsumerMetadata(cfg *ConsumerConfig) {
 		requires(1)
 	}
 
+	// Partitioned consumer groups were added in v2.13 and require API level 3.
+	if cfg.PriorityPolicy == PriorityPartitioned || cfg.PartitionCount > 0 {
+		requires(3)
+	}
+
+	// Replay speed and rate were added in v2.13 and require API level 3.
+	if cfg.ReplaySpeed > 0 || cfg.ReplayRate > 0 {
+		requires(3)
+	}
+
+	// Webhook delivery was added in v2.13 and requires API level 3.
+	if cfg.Webhook != nil {
+		requires(3)
+	}
+
 	cfg.Metadata[JSRequiredLevelMetadataKey] = strconv.Itoa(requiredApiLevel)
 }
 
@@ -101,6 +101,26 @@ func TestJetStreamSetStaticStreamMetadata(t *testing.T) {
 			cfg:              &StreamConfig{AllowMsgSchedules: true},
 			expectedMetadata: metadataAtLevel("2"),
 		},
+		{
+			desc:             "Compaction",
+			cfg:              &StreamConfig{Compaction: &StreamCompaction{Lag: time.Hour}},
+			expectedMetadata: metadataAtLevel("3"),
+		},
+		{
+			desc:             "ZstdCompression",
+			cfg:              &StreamConfig{Compression: ZstdCompression},
+			expectedMetadata: metadataAtLevel("3"),
+		},
+		{
+			desc:             "CompressionOpts",
+			cfg:              &StreamConfig{Compression: ZstdCompression, CompressionOpts: &StreamCompressionOpts{Level: 19}},
+			expectedMetadata: metadataAtLevel("3"),
+		},
+		{
+			desc:             "Connectors",
+			cfg:              &StreamConfig{Connectors: []*StreamConnector{{Name: "cdc", HTTP: &ConnectorHTTPSink{URL: "http://127.0.0.1"}}}},
+			expectedMetadata: metadataAtLevel("3"),
+		},
 	} {
 		t.Run(test.desc, func(t *testing.T) {
 			setStaticStreamMetadata(test.cfg)
@@ -243,6 +263,26 @@ func TestJetStreamSetStaticConsumerMetadata(t *testing.T) {
 			cfg:              &ConsumerConfig{PriorityPolicy: PriorityPinnedClient, PriorityGroups: []string{"a"}},
 			expectedMetadata: metadataAtLevel("1"),
 		},
+		{
+			desc:             "Partitioned",
+			cfg:              &ConsumerConfig{PriorityPolicy: PriorityPartitioned, PriorityGroups: []string{"a"}, PartitionCount: 4},
+			expectedMetadata: metadataAtLevel("3"),
+		},
+		{
+			desc:             "ReplaySpeed",
+			cfg:              &ConsumerConfig{ReplayPolicy: ReplayOriginal, ReplaySpeed: 10},
+			expectedMetadata: metadataAtLevel("3"),
+		},
+		{
+			desc:             "ReplayRate",
+			cfg:              &ConsumerConfig{ReplayRate: 100},
+			expectedMetadata: metadataAtLevel("3"),
+		},
+		{
+			desc:             "Webhook",
+			cfg:              &ConsumerConfig{Webhook: &ConsumerWebhook{URL: "http://127.0.0.1"}},
+			expectedMetadata: metadataAtLevel("3"),
+		},
 	} {
 		t.Run(test.desc, func(t *testing.T) {
 			setStaticConsumerMetadata(test.cfg)
@@ -0,0 +1,336 @@
+// Copyright 2025 The NATS Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/nats-io/nuid"
+)
+
+// A webhook consumer is a push consumer whose messages are posted to an HTTP
+// endpoint by the consumer leader. The leader subscribes to the consumer's
+// deliver subject itself, so the usual push consumer machinery applies: a 2xx
+// response acks the message, anything else naks it with a delay taken from
+// BackOff, and MaxAckPending bounds the number of requests in flight.
+
+// ConsumerWebhook is the HTTP endpoint a push consumer delivers to.
+type ConsumerWebhook struct {
+	URL     string            `json:"url"`
+	Headers map[string]string `json:"headers,omitempty"`
+	Timeout time.Duration     `json:"timeout,omitempty"`
+}
+
+// ConsumerWebhookInfo reports the deliveries of a webhook consumer.
+type ConsumerWebhookInfo struct {
+	Delivered     uint64     `json:"delivered"`
+	Errors        uint64     `json:"errors,omitempty"`
+	LastError     string     `json:"last_error,omitempty"`
+	LastErrorTime *time.Time `json:"last_error_time,omitempty"`
+}
+
+const (
+	// Deliver subject for webhook consumers that do not set one, for stream and consumer names.
+	webhookDeliverT = "$JSC.WEBHOOK.%s.%s"
+
+	// Headers added to webhook requests.
+	webhookConsumerHdr  = "Nats-Consumer"
+	webhookDeliveredHdr = "Nats-Num-Delivered"
+)
+
+func (wh *ConsumerWebhook) clone() *ConsumerWebhook {
+	clone := *wh
+	if wh.Headers != nil {
+		clone.Headers = make(map[string]string, len(wh.Headers))
+		for k, v := range wh.Headers {
+			clone.Headers[k] = v
+		}
+	}
+	return &clone
+}
+
+// Sets the deliver subject of a webhook consumer if none was given.
+func setWebhookDeliverSubject(config *ConsumerConfig, stream string) {
+	if config.Webhook == nil || config.DeliverSubject != _EMPTY_ {
+		return
+	}
+	name := config.Name
+	if name == _EMPTY_ {
+		name = config.Durable
+	}
+	if name == _EMPTY_ {
+		name = nuid.Next()
+	}
+	config.DeliverSubject = fmt.Sprintf(webhookDeliverT, stream, name)
+}
+
+// Checks the webhook of a consumer config.
+func checkConsumerWebhook(config *ConsumerConfig, acc *Account, isRecovering bool) *ApiError {
+	wh := config.Webhook
+	if wh == nil {
+		return nil
+	}
+	if !isRecovering && acc != nil && acc.srv != nil && !acc.srv.getOpts().JetStreamConnectors.AllowHTTP {
+		return NewJSConsumerWebhookInvalidError(errors.New("webhooks are not enabled"))
+	}
+	u, err := url.Parse(wh.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == _EMPTY_ {
+		return NewJSConsumerWebhookInvalidError(fmt.Errorf("URL %q is not valid", wh.URL))
+	}
+	if wh.Timeout < 0 {
+		return NewJSConsumerWebhookInvalidError(errors.New("timeout must not be negative"))
+	}
+	if config.AckPolicy != AckExplicit {
+		return NewJSConsumerWebhookInvalidError(errors.New("explicit ack policy required"))
+	}
+	if config.DeliverGroup != _EMPTY_ || config.FlowControl || config.Heartbeat > 0 {
+		return NewJSConsumerWebhookInvalidError(errors.New("deliver group, flow control and heartbeats are not supported"))
+	}
+	return nil
+}
+
+// Runtime state of a webhook consumer, only on the leader.
+type consumerWebhook struct {
+	mu     sync.Mutex
+	cfg    *ConsumerWebhook
+	client *http.Client
+	sub    *subscription
+	msgs   *ipQueue[*webhookMsg]
+	sem    chan struct{}
+	qch    chan struct{}
+
+	delivered uint64
+	errs      uint64
+	lerr      string
+	lerrt     time.Time
+}
+
+type webhookMsg struct {
+	subj  string
+	reply string
+	hdr   []byte
+	msg   []byte
+}
+
+// Starts delivering to the webhook of the given config.
+// Lock should be held.
+func (o *consumer) startWebhookLocked(cfg *ConsumerConfig) {
+	if cfg.Webhook == nil || o.wh != nil {
+		return
+	}
+	if !o.srv.getOpts().JetStreamConnectors.AllowHTTP {
+		o.srv.Warnf("JetStream consumer '%s > %s > %s' webhooks are not enabled", o.acc.Name, o.stream, o.name)
+		return
+	}
+	timeout := cfg.Webhook.Timeout
+	if timeout == 0 {
+		timeout = connectorDefaultTimeout
+	}
+	// The consumer limits the messages in flight to MaxAckPending.
+	concurrency := cfg.MaxAckPending
+	if concurrency <= 0 {
+		concurrency = JsDefaultMaxAckPending
+	}
+	wh := &consumerWebhook{
+		cfg:    cfg.Webhook.clone(),
+		client: &http.Client{Timeout: timeout},
+		sem:    make(chan struct{}, concurrency),
+		qch:    make(chan struct{}),
+	}
+	qname := fmt.Sprintf("[ACC:%s] consumer '%s > %s' webhook msgs", o.acc.Name, o.stream, o.name)
+	wh.msgs = newIPQueue[*webhookMsg](o.srv, qname)
+
+	sub, err := o.subscribeInternal(cfg.DeliverSubject, func(_ *subscription, c *client, _ *Account, subject, reply string, rmsg []byte) {
+		// Nothing to do for status messages, only for messages we can ack.
+		if reply == _EMPTY_ {
+			return
+		}
+		hdr, msg := c.msgParts(rmsg)
+		wh.msgs.push(&webhookMsg{subj: subject, reply: reply, hdr: copyBytes(hdr), msg: copyBytes(msg)})
+	})
+	if err != nil {
+		o.srv.Warnf("JetStream consumer '%s > %s > %s' unable to start webhook: %v", o.acc.Name, o.stream, o.name, err)
+		wh.msgs.unregister()
+		return
+	}
+	wh.sub = sub
+	o.wh = wh
+	o.srv.startGoRoutine(func() { o.processWebhookMsgs(wh) },
+		pprofLabels{
+			"type":     "webhook",
+			"account":  o.acc.Name,
+			"stream":   o.stream,
+			"consumer": o.name,
+		},
+	)
+}
+
+// Lock should be held.
+func (o *consumer) stopWebhookLocked() {
+	wh := o.wh
+	if wh == nil {
+		return
+	}
+	o.wh = nil
+	o.unsubscribe(wh.sub)
+	close(wh.qch)
+}
+
+// Hands off delivered messages to the webhook, no more than the concurrency at a time.
+func (o *consumer) processWebhookMsgs(wh *consumerWebhook) {
+	s := o.srv
+	defer s.grWG.Done()
+	defer wh.msgs.unregister()
+
+	// Cancel in flight requests when stopped.
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var wg sync.WaitGroup
+	defer wg.Wait()
+
+	for {
+		select {
+		case <-s.quitCh:
+			return
+		case <-wh.qch:
+			return
+		case <-wh.msgs.ch:
+			ms := wh.msgs.pop()
+			for _, m := range ms {
+				select {
+				case wh.sem <- struct{}{}:
+				case <-wh.qch:
+					return
+				case <-s.quitCh:
+					return
+				}
+				wg.Add(1)
+				go func(m *webhookMsg) {
+					defer func() {
+						<-wh.sem
+						wg.Done()
+					}()
+					o.deliverWebhookMsg(ctx, wh, m)
+				}(m)
+			}
+			wh.msgs.recycle(&ms)
+		}
+	}
+}
+
+// Posts the message to the webhook and acks or naks it based on the response.
+func (o *consumer) deliverWebhookMsg(ctx context.Context, wh *consumerWebhook, m *webhookMsg) {
+	sseq, _, dc, ts, _ := replyInfo(m.reply)
+	if sseq == 0 {
+		return
+	}
+	err := wh.post(ctx, o.stream, o.name, m, sseq, dc, ts)
+	if ctx.Err() != nil {
+		// Stopped, the message will be redelivered once the ack wait expires.
+		return
+	}
+	if err == nil {
+		wh.mu.Lock()
+		wh.delivered++
+		wh.mu.Unlock()
+		o.outq.send(newJSPubMsg(m.reply, _EMPTY_, _EMPTY_, nil, AckAck, nil, 0))
+		return
+	}
+
+	wh.mu.Lock()
+	wh.errs++
+	wh.lerr, wh.lerrt = err.Error(), time.Now().UTC()
+	wh.mu.Unlock()
+
+	nak, _ := json.Marshal(&ConsumerNakOptions{Delay: o.webhookBackoff(dc)})
+	o.outq.send(newJSPubMsg(m.reply, _EMPTY_, _EMPTY_, nil, append(append(slices.Clone(AckNak), ' '), nak...), nil, 0))
+	o.srv.Debugf("JetStream consumer '%s > %s > %s' webhook failed for message %d: %v", o.acc.Name, o.stream, o.name, sseq, err)
+}
+
+// Returns the delay before a failed delivery is retried, using BackOff if set.
+func (o *consumer) webhookBackoff(dc uint64) time.Duration {
+	o.mu.RLock()
+	backoff := o.cfg.BackOff
+	o.mu.RUnlock()
+	if len(backoff) > 0 {
+		return backoff[min(int(dc), len(backoff))-1]
+	}
+	return connectorBackoff(int(dc))
+}
+
+func (wh *consumerWebhook) post(ctx context.Context, stream, consumer string, m *webhookMsg, sseq, dc uint64, ts int64) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, wh.cfg.URL, bytes.NewReader(m.msg))
+	if err != nil {
+		return err
+	}
+	for k, vs := range natsHeaderMap(m.hdr) {
+		for _, v := range vs {
+			req.Header.Add(k, v)
+		}
+	}
+	if req.Header.Get("Content-Type") == _EMPTY_ {
+		req.Header.Set("Content-Type", "application/octet-stream")
+	}
+	req.Header.Set(JSStream, stream)
+	req.Header.Set(webhookConsumerHdr, consumer)
+	req.Header.Set(JSSubject, m.subj)
+	req.Header.Set(JSSequence, strconv.FormatUint(sseq, 10))
+	req.Header.Set(JSTimeStamp, time.Unix(0, ts).UTC().Format(time.RFC3339Nano))
+	req.Header.Set(webhookDeliveredHdr, strconv.FormatUint(dc, 10))
+	for k, v := range wh.cfg.Headers {
+		req.Header.Set(k, v)
+	}
+	resp, err := wh.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64*1024))
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("unexpected HTTP status %q", resp.Status)
+	}
+	return nil
+}
+
+// Lock should be held.
+func (o *consumer) webhookInfo() *ConsumerWebhookInfo {
+	wh := o.wh
+	if wh == nil {
+		return nil
+	}
+	wh.mu.Lock()
+	defer wh.mu.Unlock()
+	wi := &ConsumerWebhookInfo{
+		Delivered: wh.delivered,
+		Errors:    wh.errs,
+		LastError: wh.lerr,
+	}
+	if !wh.lerrt.IsZero() {
+		lerrt := wh.lerrt
+		wi.LastErrorTime = &lerrt
+	}
+	return wi
+}
@@ -1287,6 +1287,86 @@ func TestJWTAccountLimitsMaxPayloadButServerOverrides(t *testing.T) {
 	}
 }
 
+func TestJWTAccountRateLimits(t *testing.T) {
+	fooAC := newJWTTestAccountClaims()
+	fooAC.Tags.Add("rate_msgs_in:10", "rate_bytes_out:1K")
+	s, fooKP, c, _ := setupJWTTestWitAccountClaims(t, fooAC, "+OK")
+	defer s.Shutdown()
+	defer c.close()
+
+	fooPub, _ := fooKP.PublicKey()
+	fooAcc, _ := s.LookupAccount(fooPub)
+	fooAcc.mu.RLock()
+	rl := fooAcc.rateLimits
+	fooAcc.mu.RUnlock()
+	require_Equal(t, *rl, RateLimits{MsgsIn: 10, BytesOut: 1024})
+
+	sc, scr, cs := createClient(t, s, fooKP)
+	defer sc.close()
+	sc.parseAsync(cs)
+	expectPong(t, scr)
+	sc.parseAsync("SUB foo 1\r\nPING\r\n")
+	expectPong(t, scr)
+
+	pc, pcr, cs := createClient(t, s, fooKP)
+	defer pc.close()
+	pc.parseAsync(cs)
+	expectPong(t, pcr)
+
+	// Only the burst of the inbound limit is accepted.
+	pc.parseAsync(strings.Repeat("PUB foo 2\r\nok\r\n", 20) + "PING\r\n")
+	var errs int64
+	for l, _ := pcr.ReadString('\n'); !strings.HasPrefix(l, "PONG"); l, _ = pcr.ReadString('\n') {
+		require_Equal(t, l, "-ERR 'Rate Limit Exceeded'\r\n")
+		errs++
+	}
+	var msgs int64
+	sc.parseAsync("PING\r\n")
+	for l, _ := scr.ReadString('\n'); !strings.HasPrefix(l, "PONG"); l, _ = scr.ReadString('\n') {
+		if strings.HasPrefix(l, "MSG ") {
+			msgs++
+		}
+	}
+	st := fooAcc.statz().RateLimited
+	require_True(t, msgs >= 10 && errs > 0)
+	require_Equal(t, msgs+errs, 20)
+	require_Equal(t, st.InMsgs, errs)
+
+	// Messages larger than the outbound bytes limit are never delivered.
+	time.Sleep(time.Second)
+	pc.parseAsync(fmt.Sprintf("PUB foo 2048\r\n%s\r\nPING\r\n", strings.Repeat("A", 2048)))
+	expectPong(t, pcr)
+	sc.parseAsync("PING\r\n")
+	expectPong(t, scr)
+	require_Equal(t, fooAcc.statz().RateLimited.OutMsgs, 1)
+
+	// The user limit disconnects the client.
+	nkp, _ := nkeys.CreateUser()
+	pub, _ := nkp.PublicKey()
+	nuc := jwt.NewUserClaims(pub)
+	nuc.Tags.Add("rate_msgs_in:2", "rate_action:disconnect")
+	ujwt, err := nuc.Encode(fooKP)
+	require_NoError(t, err)
+	dc, dcr, l := newClientForServer(s)
+	defer dc.close()
+	var info nonceInfo
+	json.Unmarshal([]byte(l[5:]), &info)
+	sigraw, _ := nkp.Sign([]byte(info.Nonce))
+	sig := base64.RawURLEncoding.EncodeToString(sigraw)
+	dc.parseAsync(fmt.Sprintf("CONNECT {\"jwt\":%q,\"sig\":\"%s\"}\r\nPING\r\n", ujwt, sig))
+	expectPong(t, dcr)
+	dc.parseAsync(strings.Repeat("PUB bar 2\r\nok\r\n", 5))
+	l, _ = dcr.ReadString('\n')
+	require_Equal(t, l, "-ERR 'Rate Limit Exceeded'\r\n")
+	checkFor(t, time.Second, 50*time.Millisecond, func() error {
+		if !dc.isClosed() {
+			return fmt.Errorf("client not closed")
+		}
+		return nil
+	})
+	require_Equal(t, fooAcc.statz().RateLimited.Disconnects, 1)
+}
+
 func TestJWTAccountLimitsMaxConns(t *testing.T) {
 	fooAC := newJWTTestAccountClaims()
 	fooAC.Limits.Conn = 8
@@ -4363,6 +4443,128 @@ func TestJWTTemplates(t *testing.T) {
 	require_Contains(t, err.Error(), "generated invalid subject")
 }
 
+func TestJWTQueueGroupPermissions(t *testing.T) {
+	kp, _ := nkeys.CreateAccount()
+	aPub, _ := kp.PublicKey()
+	ukp, _ := nkeys.CreateUser()
+	upub, _ := ukp.PublicKey()
+	uclaim := newJWTTestUserClaims()
+	uclaim.Name = "svc1"
+	uclaim.Subject = upub
+	uclaim.Tags.Add("group:dev")
+	acc := NewAccount(aPub)
+
+	// Queue groups qualify the subscribe permissions of the user.
+	uclaim.Sub.Allow.Add("orders.> Svc.>")
+	uclaim.Sub.Deny.Add("> Svc.PROD")
+	ujwt, err := uclaim.Encode(kp)
+	require_NoError(t, err)
+	uclaim, err = jwt.DecodeUserClaims(ujwt)
+	require_NoError(t, err)
+	nu := buildInternalNkeyUser(uclaim, nil, acc)
+	require_True(t, nu.Permissions != nil && nu.Permissions.Subscribe != nil)
+	require_Equal(t, strings.Join(nu.Permissions.Subscribe.Allow, ","), "orders.> Svc.>")
+	require_Equal(t, strings.Join(nu.Permissions.Subscribe.Deny, ","), "> Svc.PROD")
+
+	// Templates can be used for queue qualified subscribe permissions.
+	uclaim.SetScoped(true)
+	uclaim.IssuerAccount = aPub
+	lim := jwt.UserPermissionLimits{}
+	lim.Sub.Allow.Add("orders.> {{name()}}", "> {{tag(group)}}.workers", "> {{tag(NOT_THERE)}}.workers")
+	lim.Sub.Deny.Add("> {{name()}}.prod")
+	resLim, err := processUserPermissionsTemplate(lim, uclaim, acc)
+	require_NoError(t, err)
+	require_Len(t, len(resLim.Sub.Allow), 2)
+	require_True(t, resLim.Sub.Allow.Contains("orders.> svc1"))
+	require_True(t, resLim.Sub.Allow.Contains("> dev.workers"))
+	require_Len(t, len(resLim.Sub.Deny), 1)
+	require_True(t, resLim.Sub.Deny.Contains("> svc1.prod"))
+}
+
+func TestJWTQueueGroupPermissionsReload(t *testing.T) {
+	sysKp, syspub := createKey(t)
+	sysJwt := encodeClaim(t, jwt.NewAccountClaims(syspub), syspub)
+	sysCreds := newUser(t, sysKp)
+
+	_, aExpPub := createKey(t)
+	accClaim := jwt.NewAccountClaims(aExpPub)
+	aSignScopedKp, aSignScopedPub := createKey(t)
+	signer := jwt.NewUserScope()
+	signer.Key = aSignScopedPub
+	signer.Template.Sub.Allow.Add("orders.> {{name()}}", "_INBOX.>")
+	accClaim.SigningKeys.AddScopedSigner(signer)
+	accJwt := encodeClaim(t, accClaim, aExpPub)
+
+	ukp, _ := nkeys.CreateUser()
+	seed, _ := ukp.Seed()
+	upub, _ := ukp.PublicKey()
+	uclaim := newJWTTestUserClaims()
+	uclaim.Name = "svc1"
+	uclaim.Subject = upub
+	uclaim.SetScoped(true)
+	uclaim.IssuerAccount = aExpPub
+	ujwt, err := uclaim.Encode(aSignScopedKp)
+	require_NoError(t, err)
+	creds := genCredsFile(t, ujwt, seed)
+
+	conf := createConfFile(t, []byte(fmt.Sprintf(`
+		listen: 127.0.0.1:-1
+		operator: %s
+		system_account: %s
+		resolver: {
+			type: full
+			dir: '%s'
+		}
+	`, ojwt, syspub, t.TempDir())))
+	s, _ := RunServerWithConfig(conf)
+	defer s.Shutdown()
+
+	require_Equal(t, updateJwt(t, s.ClientURL(), sysCreds, sysJwt, 1), 1)
+	require_Equal(t, updateJwt(t, s.ClientURL(), sysCreds, accJwt, 1), 1)
+
+	errCh := make(chan error, 10)
+	closed := make(chan struct{})
+	nc := natsConnect(t, s.ClientURL(), nats.UserCredentials(creds),
+		nats.ErrorHandler(func(_ *nats.Conn, _ *nats.Subscription, err error) {
+			errCh <- err
+		}),
+		nats.ClosedHandler(func(_ *nats.Conn) { close(closed) }), nats.NoReconnect())
+	defer nc.Close()
+
+	// The template names the queue group the user can join.
+	sub := natsQueueSubSync(t, nc, "orders.new", "svc1")
+	natsQueueSubSync(t, nc, "orders.new", "svc2")
+	natsFlush(t, nc)
+	select {
+	case err := <-errCh:
+		require_Contains(t, err.Error(), `using queue "svc2"`)
+	case <-time.After(time.Second):
+		t.Fatal("Expected a permissions violation")
+	}
+
+	// The queue subscription is still allowed after a reload.
+	require_NoError(t, s.Reload())
+	natsPub(t, nc, "orders.new", []byte("order"))
+	natsNexMsg(t, sub, time.Second)
+	select {
+	case err := <-errCh:
+		t.Fatalf("Unexpected error: %v", err)
+	default:
+	}
+
+	// Changing the template of the signing key disconnects its users,
+	// which have to reconnect with the new permissions.
+	signer.Template.Sub.Allow = jwt.StringList{"orders.> {{name()}}.v2", "_INBOX.>"}
+	accClaim.SigningKeys.AddScopedSigner(signer)
+	accJwt = encodeClaim(t, accClaim, aExpPub)
+	require_Equal(t, updateJwt(t, s.ClientURL(), sysCreds, accJwt, 1), 1)
+	select {
+	case <-closed:
+	case <-time.After(2 * time.Second):
+		t.Fatal("Expected the user to be disconnected")
+	}
+}
+
 func TestJWTInLineTemplates(t *testing.T) {
 	kp, _ := nkeys.CreateAccount()
 	aPub, _ := kp.PublicKey()
@@ -0,0 +1,227 @@
+// Copyright 2025 The NATS Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kms
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+)
+
+// filePrefix is the prefix of keys wrapped by the file provider,
+// followed by the version of the key used, e.g. "file:v2:".
+const filePrefix = "file:v"
+
+// FileProvider is a key provider that keeps its keys in a local JSON file.
+// It is meant for development and for deployments where the keys file is
+// kept on a separate, protected volume. The file has the form:
+//
+//	{"keys": {"name": ["<base64 key v1>", "<base64 key v2>"]}}
+//
+// The last version of a key is used for wrapping, all versions can unwrap.
+// Unknown keys are created on first use.
+type FileProvider struct {
+	mu   sync.Mutex
+	path string
+}
+
+type fileKeys struct {
+	Keys map[string][]string `json:"keys"`
+}
+
+// NewFileProvider returns a file based key provider for the keys file at path.
+// The file is created when the first key is.
+func NewFileProvider(path string) (*FileProvider, error) {
+	fp := &FileProvider{path: path}
+	if _, err := fp.load(); err != nil {
+		return nil, err
+	}
+	return fp, nil
+}
+
+// Lock should be held.
+func (fp *FileProvider) load() (*fileKeys, error) {
+	fk := &fileKeys{Keys: make(map[string][]string)}
+	buf, err := os.ReadFile(fp.path)
+	if os.IsNotExist(err) {
+		return fk, nil
+	} else if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(buf, fk); err != nil {
+		return nil, fmt.Errorf("kms: error parsing keys file: %w", err)
+	}
+	if fk.Keys == nil {
+		fk.Keys = make(map[string][]string)
+	}
+	return fk, nil
+}
+
+// Lock should be held.
+func (fp *FileProvider) store(fk *fileKeys) error {
+	buf, err := json.MarshalIndent(fk, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := fp.path + ".tmp"
+	if err := os.MkdirAll(filepath.Dir(fp.path), 0750); err != nil {
+		return err
+	}
+	if err := os.WriteFile(tmp, buf, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, fp.path)
+}
+
+// Returns the versions of the named key, creating the key if needed.
+// Lock should be held.
+func (fp *FileProvider) versions(name string, create bool) ([]string, error) {
+	fk, err := fp.load()
+	if err != nil {
+		return nil, err
+	}
+	if versions := fk.Keys[name]; len(versions) > 0 {
+		return versions, nil
+	}
+	if !create {
+		return nil, ErrKeyNotFound
+	}
+	if err := fp.addVersion(fk, name); err != nil {
+		return nil, err
+	}
+	return fk.Keys[name], nil
+}
+
+// Lock should be held.
+func (fp *FileProvider) addVersion(fk *fileKeys, name string) error {
+	key, err := GenerateDataKey()
+	if err != nil {
+		return err
+	}
+	fk.Keys[name] = append(fk.Keys[name], base64.StdEncoding.EncodeToString(key))
+	return fp.store(fk)
+}
+
+func fileAEAD(encoded string) (cipher.AEAD, error) {
+	key, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, fmt.Errorf("kms: invalid key in keys file: %w", err)
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// Wrap implements KeyProvider.
+func (fp *FileProvider) Wrap(name string, dek []byte) ([]byte, error) {
+	fp.mu.Lock()
+	defer fp.mu.Unlock()
+	versions, err := fp.versions(name, true)
+	if err != nil {
+		return nil, err
+	}
+	return fp.wrap(name, versions, dek)
+}
+
+func (fp *FileProvider) wrap(name string, versions []string, dek []byte) ([]byte, error) {
+	aead, err := fileAEAD(versions[len(versions)-1])
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	sealed := aead.Seal(nonce, nonce, dek, []byte(name))
+	wrapped := fmt.Appendf(nil, "%s%d:", filePrefix, len(versions))
+	return base64.StdEncoding.AppendEncode(wrapped, sealed), nil
+}
+
+// Unwrap implements KeyProvider.
+func (fp *FileProvider) Unwrap(name string, wrapped []byte) ([]byte, error) {
+	fp.mu.Lock()
+	defer fp.mu.Unlock()
+	versions, err := fp.versions(name, false)
+	if err != nil {
+		return nil, err
+	}
+	return fp.unwrap(name, versions, wrapped)
+}
+
+func (fp *FileProvider) unwrap(name string, versions []string, wrapped []byte) ([]byte, error) {
+	if !bytes.HasPrefix(wrapped, []byte(filePrefix)) {
+		return nil, ErrInvalidWrappedKey
+	}
+	rest := wrapped[len(filePrefix):]
+	i := bytes.IndexByte(rest, ':')
+	if i < 0 {
+		return nil, ErrInvalidWrappedKey
+	}
+	version, err := strconv.Atoi(string(rest[:i]))
+	if err != nil || version < 1 {
+		return nil, ErrInvalidWrappedKey
+	}
+	if version > len(versions) {
+		return nil, fmt.Errorf("kms: version %d of key %q not found", version, name)
+	}
+	sealed, err := base64.StdEncoding.DecodeString(string(rest[i+1:]))
+	if err != nil {
+		return nil, ErrInvalidWrappedKey
+	}
+	aead, err := fileAEAD(versions[version-1])
+	if err != nil {
+		return nil, err
+	}
+	ns := aead.NonceSize()
+	if len(sealed) < ns {
+		return nil, ErrInvalidWrappedKey
+	}
+	return aead.Open(nil, sealed[:ns], sealed[ns:], []byte(name))
+}
+
+// Rewrap implements KeyProvider.
+func (fp *FileProvider) Rewrap(name string, wrapped []byte) ([]byte, error) {
+	fp.mu.Lock()
+	defer fp.mu.Unlock()
+	versions, err := fp.versions(name, false)
+	if err != nil {
+		return nil, err
+	}
+	dek, err := fp.unwrap(name, versions, wrapped)
+	if err != nil {
+		return nil, err
+	}
+	return fp.wrap(name, versions, dek)
+}
+
+// Rotate implements KeyProvider.
+func (fp *FileProvider) Rotate(name string) error {
+	fp.mu.Lock()
+	defer fp.mu.Unlock()
+	fk, err := fp.load()
+	if err != nil {
+		return err
+	}
+	return fp.addVersion(fk, name)
+}
@@ -0,0 +1,59 @@
+// Copyright 2025 The NATS Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package kms provides key providers used for envelope encryption of
+// JetStream data at rest. A key provider holds the key encryption keys and
+// is used to wrap and unwrap the data keys that the server stores on disk.
+package kms
+
+import (
+	"crypto/rand"
+	"errors"
+	"fmt"
+)
+
+// DataKeySize is the size of the data keys generated by GenerateDataKey.
+const DataKeySize = 32
+
+// KeyProvider wraps and unwraps data keys with named keys held by an
+// external key management service. Implementations must be safe for
+// concurrent use.
+type KeyProvider interface {
+	// Wrap encrypts a data key with the current version of the named key.
+	Wrap(name string, dek []byte) ([]byte, error)
+	// Unwrap decrypts a data key previously wrapped with the named key.
+	Unwrap(name string, wrapped []byte) ([]byte, error)
+	// Rewrap re-encrypts a wrapped data key with the current version of the
+	// named key. The data key itself does not change.
+	Rewrap(name string, wrapped []byte) ([]byte, error)
+	// Rotate creates a new version of the named key, used for subsequent wraps.
+	Rotate(name string) error
+}
+
+var (
+	// ErrKeyNotFound is returned when the named key does not exist.
+	ErrKeyNotFound = errors.New("kms: key not found")
+	// ErrInvalidWrappedKey is returned when a wrapped key can not be decoded.
+	ErrInvalidWrappedKey = errors.New("kms: invalid wrapped key")
+)
+
+// GenerateDataKey returns a new random data key.
+func GenerateDataKey() ([]byte, error) {
+	dek := make([]byte, DataKeySize)
+	if n, err := rand.Read(dek); err != nil {
+		return nil, err
+	} else if n != DataKeySize {
+		return nil, fmt.Errorf("kms: not enough random bytes read (%d != %d)", n, DataKeySize)
+	}
+	return dek, nil
+}
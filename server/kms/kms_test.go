@@ -0,0 +1,260 @@
+// Copyright 2025 The NATS Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kms
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// vaultStandIn implements the subset of the Vault transit API used by the
+// VaultProvider, so it can be tested without a Vault server.
+type vaultStandIn struct {
+	mu    sync.Mutex
+	token string
+	keys  map[string][]cipher.AEAD
+}
+
+func newVaultStandIn(t *testing.T, token string) *httptest.Server {
+	vs := &vaultStandIn{token: token, keys: make(map[string][]cipher.AEAD)}
+	ts := httptest.NewServer(vs)
+	t.Cleanup(ts.Close)
+	return ts
+}
+
+func (vs *vaultStandIn) addVersion(name string) {
+	key := make([]byte, 32)
+	rand.Read(key)
+	block, _ := aes.NewCipher(key)
+	aead, _ := cipher.NewGCM(block)
+	vs.keys[name] = append(vs.keys[name], aead)
+}
+
+func (vs *vaultStandIn) encrypt(name string, pt []byte) string {
+	versions := vs.keys[name]
+	aead := versions[len(versions)-1]
+	nonce := make([]byte, aead.NonceSize())
+	rand.Read(nonce)
+	ct := aead.Seal(nonce, nonce, pt, nil)
+	return fmt.Sprintf("vault:v%d:%s", len(versions), base64.StdEncoding.EncodeToString(ct))
+}
+
+func (vs *vaultStandIn) decrypt(name, ct string) ([]byte, error) {
+	parts := strings.SplitN(ct, ":", 3)
+	if len(parts) != 3 || parts[0] != "vault" {
+		return nil, errors.New("invalid ciphertext")
+	}
+	version, err := strconv.Atoi(strings.TrimPrefix(parts[1], "v"))
+	if err != nil || version < 1 || version > len(vs.keys[name]) {
+		return nil, errors.New("invalid key version")
+	}
+	buf, err := base64.StdEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, err
+	}
+	aead := vs.keys[name][version-1]
+	ns := aead.NonceSize()
+	return aead.Open(nil, buf[:ns], buf[ns:], nil)
+}
+
+func (vs *vaultStandIn) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	vs.mu.Lock()
+	defer vs.mu.Unlock()
+
+	fail := func(code int, err string) {
+		w.WriteHeader(code)
+		json.NewEncoder(w).Encode(map[string][]string{"errors": {err}})
+	}
+	if r.Header.Get("X-Vault-Token") != vs.token {
+		fail(http.StatusForbidden, "permission denied")
+		return
+	}
+	tokens := strings.Split(strings.TrimPrefix(r.URL.Path, "/v1/transit/"), "/")
+	if len(tokens) == 3 && tokens[0] == "keys" && tokens[2] == "rotate" {
+		if _, ok := vs.keys[tokens[1]]; !ok {
+			fail(http.StatusNotFound, "key not found")
+			return
+		}
+		vs.addVersion(tokens[1])
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	if len(tokens) != 2 {
+		fail(http.StatusNotFound, "unsupported path")
+		return
+	}
+	var req vaultRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		fail(http.StatusBadRequest, err.Error())
+		return
+	}
+	op, name := tokens[0], tokens[1]
+	var resp vaultResponse
+	switch op {
+	case "encrypt":
+		if _, ok := vs.keys[name]; !ok {
+			vs.addVersion(name)
+		}
+		pt, err := base64.StdEncoding.DecodeString(req.Plaintext)
+		if err != nil {
+			fail(http.StatusBadRequest, err.Error())
+			return
+		}
+		resp.Data.Ciphertext = vs.encrypt(name, pt)
+	case "decrypt", "rewrap":
+		if _, ok := vs.keys[name]; !ok {
+			fail(http.StatusBadRequest, "encryption key not found")
+			return
+		}
+		pt, err := vs.decrypt(name, req.Ciphertext)
+		if err != nil {
+			fail(http.StatusBadRequest, err.Error())
+			return
+		}
+		if op == "decrypt" {
+			resp.Data.Plaintext = base64.StdEncoding.EncodeToString(pt)
+		} else {
+			resp.Data.Ciphertext = vs.encrypt(name, pt)
+		}
+	default:
+		fail(http.StatusNotFound, "unsupported path")
+		return
+	}
+	json.NewEncoder(w).Encode(&resp)
+}
+
+func testKeyProvider(t *testing.T, kp KeyProvider) {
+	t.Helper()
+
+	dek, err := GenerateDataKey()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	wrapped, err := kp.Wrap("acc", dek)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if bytes.Contains(wrapped, dek) {
+		t.Fatalf("Wrapped key should not contain the data key")
+	}
+	unwrapped, err := kp.Unwrap("acc", wrapped)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if !bytes.Equal(dek, unwrapped) {
+		t.Fatalf("Expected unwrapped key to match the data key")
+	}
+
+	// A data key wrapped for one key can not be unwrapped with another.
+	if _, err := kp.Wrap("other", dek); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if _, err := kp.Unwrap("other", wrapped); err == nil {
+		t.Fatalf("Expected an error unwrapping with another key")
+	}
+
+	// After a rotation the old wrapped key still unwraps, and a rewrap
+	// produces a new wrapped key for the same data key.
+	if err := kp.Rotate("acc"); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if unwrapped, err = kp.Unwrap("acc", wrapped); err != nil || !bytes.Equal(dek, unwrapped) {
+		t.Fatalf("Expected old wrapped key to unwrap after rotation: %v", err)
+	}
+	rewrapped, err := kp.Rewrap("acc", wrapped)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if bytes.Equal(rewrapped, wrapped) {
+		t.Fatalf("Expected a new wrapped key")
+	}
+	if !bytes.Contains(rewrapped, []byte(":v2:")) {
+		t.Fatalf("Expected the rewrapped key to use the new version, got %q", rewrapped)
+	}
+	if unwrapped, err = kp.Unwrap("acc", rewrapped); err != nil || !bytes.Equal(dek, unwrapped) {
+		t.Fatalf("Expected rewrapped key to unwrap: %v", err)
+	}
+
+	if _, err := kp.Unwrap("acc", []byte("garbage")); err == nil {
+		t.Fatalf("Expected an error unwrapping an invalid key")
+	}
+}
+
+func TestFileProvider(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "keys.json")
+	fp, err := NewFileProvider(path)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	testKeyProvider(t, fp)
+
+	// Unknown keys are not created when unwrapping.
+	if _, err := fp.Unwrap("missing", []byte("file:v1:AAAA")); !errors.Is(err, ErrKeyNotFound) {
+		t.Fatalf("Expected key not found, got %v", err)
+	}
+
+	// Keys are persisted.
+	dek, _ := GenerateDataKey()
+	wrapped, err := fp.Wrap("acc", dek)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	fp2, err := NewFileProvider(path)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if unwrapped, err := fp2.Unwrap("acc", wrapped); err != nil || !bytes.Equal(dek, unwrapped) {
+		t.Fatalf("Expected to unwrap with a reloaded provider: %v", err)
+	}
+}
+
+func TestVaultProvider(t *testing.T) {
+	ts := newVaultStandIn(t, "s3cr3t")
+
+	vp, err := NewVaultProvider(VaultOptions{URL: ts.URL, Token: "s3cr3t"})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	testKeyProvider(t, vp)
+
+	if err := vp.Rotate("missing"); !errors.Is(err, ErrKeyNotFound) {
+		t.Fatalf("Expected key not found, got %v", err)
+	}
+
+	// Bad credentials are reported.
+	vp, err = NewVaultProvider(VaultOptions{URL: ts.URL, Token: "bad"})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if _, err := vp.Wrap("acc", []byte("key")); err == nil || !strings.Contains(err.Error(), "permission denied") {
+		t.Fatalf("Expected permission denied error, got %v", err)
+	}
+
+	if _, err := NewVaultProvider(VaultOptions{URL: "not a url"}); err == nil {
+		t.Fatalf("Expected an error for an invalid url")
+	}
+}
@@ -0,0 +1,178 @@
+// Copyright 2025 The NATS Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kms
+
+import (
+	"bytes"
+	"crypto/tls"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// DefaultVaultMount is the default mount path of the transit secrets engine.
+const DefaultVaultMount = "transit"
+
+// VaultOptions configure a VaultProvider.
+type VaultOptions struct {
+	// URL of the Vault server, e.g. https://vault.example.com:8200.
+	URL string
+	// Token used to authenticate with Vault.
+	Token string
+	// Namespace is the optional Vault Enterprise namespace.
+	Namespace string
+	// Mount is the mount path of the transit secrets engine.
+	Mount string
+	// TLSConfig is used for https URLs, if set.
+	TLSConfig *tls.Config
+	// Timeout for each request to Vault.
+	Timeout time.Duration
+}
+
+// VaultProvider is a key provider using the HashiCorp Vault transit secrets
+// engine, or any service implementing the same HTTP API.
+type VaultProvider struct {
+	base  string
+	opts  VaultOptions
+	httpc *http.Client
+}
+
+// NewVaultProvider returns a key provider for the Vault transit engine.
+func NewVaultProvider(opts VaultOptions) (*VaultProvider, error) {
+	u, err := url.Parse(opts.URL)
+	if err != nil || u.Host == "" {
+		return nil, fmt.Errorf("kms: invalid vault url %q", opts.URL)
+	}
+	if opts.Mount == "" {
+		opts.Mount = DefaultVaultMount
+	}
+	if opts.Timeout <= 0 {
+		opts.Timeout = 10 * time.Second
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if opts.TLSConfig != nil {
+		transport.TLSClientConfig = opts.TLSConfig
+	}
+	return &VaultProvider{
+		base:  fmt.Sprintf("%s/v1/%s", strings.TrimSuffix(opts.URL, "/"), strings.Trim(opts.Mount, "/")),
+		opts:  opts,
+		httpc: &http.Client{Transport: transport, Timeout: opts.Timeout},
+	}, nil
+}
+
+type vaultRequest struct {
+	Plaintext  string `json:"plaintext,omitempty"`
+	Ciphertext string `json:"ciphertext,omitempty"`
+}
+
+type vaultResponse struct {
+	Data struct {
+		Plaintext  string `json:"plaintext,omitempty"`
+		Ciphertext string `json:"ciphertext,omitempty"`
+	} `json:"data"`
+	Errors []string `json:"errors,omitempty"`
+}
+
+// Sends a request to the transit engine and decodes the response, if any.
+func (vp *VaultProvider) do(path string, req *vaultRequest) (*vaultResponse, error) {
+	var body io.Reader
+	if req != nil {
+		b, err := json.Marshal(req)
+		if err != nil {
+			return nil, err
+		}
+		body = bytes.NewReader(b)
+	}
+	hreq, err := http.NewRequest(http.MethodPost, vp.base+path, body)
+	if err != nil {
+		return nil, err
+	}
+	hreq.Header.Set("Content-Type", "application/json")
+	if vp.opts.Token != "" {
+		hreq.Header.Set("X-Vault-Token", vp.opts.Token)
+	}
+	if vp.opts.Namespace != "" {
+		hreq.Header.Set("X-Vault-Namespace", vp.opts.Namespace)
+	}
+	hresp, err := vp.httpc.Do(hreq)
+	if err != nil {
+		return nil, fmt.Errorf("kms: vault request failed: %w", err)
+	}
+	defer hresp.Body.Close()
+
+	var resp vaultResponse
+	if hresp.StatusCode != http.StatusNoContent {
+		if err := json.NewDecoder(io.LimitReader(hresp.Body, 1<<20)).Decode(&resp); err != nil && err != io.EOF {
+			return nil, fmt.Errorf("kms: invalid vault response: %w", err)
+		}
+	}
+	if hresp.StatusCode == http.StatusNotFound {
+		return nil, ErrKeyNotFound
+	}
+	if hresp.StatusCode < 200 || hresp.StatusCode > 299 {
+		if len(resp.Errors) > 0 {
+			return nil, fmt.Errorf("kms: vault error (%d): %s", hresp.StatusCode, strings.Join(resp.Errors, "; "))
+		}
+		return nil, fmt.Errorf("kms: vault error (%d)", hresp.StatusCode)
+	}
+	return &resp, nil
+}
+
+// Wrap implements KeyProvider.
+func (vp *VaultProvider) Wrap(name string, dek []byte) ([]byte, error) {
+	resp, err := vp.do("/encrypt/"+url.PathEscape(name), &vaultRequest{Plaintext: base64.StdEncoding.EncodeToString(dek)})
+	if err != nil {
+		return nil, err
+	}
+	if resp.Data.Ciphertext == "" {
+		return nil, ErrInvalidWrappedKey
+	}
+	return []byte(resp.Data.Ciphertext), nil
+}
+
+// Unwrap implements KeyProvider.
+func (vp *VaultProvider) Unwrap(name string, wrapped []byte) ([]byte, error) {
+	resp, err := vp.do("/decrypt/"+url.PathEscape(name), &vaultRequest{Ciphertext: string(wrapped)})
+	if err != nil {
+		return nil, err
+	}
+	dek, err := base64.StdEncoding.DecodeString(resp.Data.Plaintext)
+	if err != nil || len(dek) == 0 {
+		return nil, ErrInvalidWrappedKey
+	}
+	return dek, nil
+}
+
+// Rewrap implements KeyProvider.
+func (vp *VaultProvider) Rewrap(name string, wrapped []byte) ([]byte, error) {
+	resp, err := vp.do("/rewrap/"+url.PathEscape(name), &vaultRequest{Ciphertext: string(wrapped)})
+	if err != nil {
+		return nil, err
+	}
+	if resp.Data.Ciphertext == "" {
+		return nil, ErrInvalidWrappedKey
+	}
+	return []byte(resp.Data.Ciphertext), nil
+}
+
+// Rotate implements KeyProvider.
+func (vp *VaultProvider) Rotate(name string) error {
+	_, err := vp.do("/keys/"+url.PathEscape(name)+"/rotate", nil)
+	return err
+}
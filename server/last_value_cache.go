@@ -0,0 +1,227 @@
+// Copyright 2025 The NATS Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"bytes"
+	"container/list"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"sync"
+
+	"github.com/nats-io/nats-server/v2/server/stree"
+)
+
+// LastValueCacheOpts enable the caching of the last message published on
+// each subject matching the filters of an account. Subscriptions of clients
+// connecting with the "last_values" option first receive the cached message
+// of each matching subject, then live messages.
+// Messages are cached by the servers that receive them.
+type LastValueCacheOpts struct {
+	// Subject filters of the cached subjects.
+	Subjects []string `json:"subjects"`
+	// Maximum number of subjects cached, the least recently updated
+	// subjects are evicted once reached.
+	MaxSubjects int `json:"max_subjects,omitempty"`
+	// Maximum size of the cached messages.
+	MaxBytes int64 `json:"max_bytes,omitempty"`
+}
+
+const (
+	// Default maximum number of subjects in a last value cache.
+	defaultLastValueMaxSubjects = 10_000
+	// Default maximum size of the messages in a last value cache.
+	defaultLastValueMaxBytes = 64 * 1024 * 1024
+)
+
+func (lo *LastValueCacheOpts) clone() *LastValueCacheOpts {
+	if lo == nil {
+		return nil
+	}
+	clone := *lo
+	clone.Subjects = slices.Clone(lo.Subjects)
+	return &clone
+}
+
+func (lo *LastValueCacheOpts) validate() error {
+	if len(lo.Subjects) == 0 {
+		return errors.New("last value cache requires at least one subject")
+	}
+	for _, subj := range lo.Subjects {
+		if !IsValidSubject(subj) {
+			return fmt.Errorf("invalid last value cache subject %q", subj)
+		}
+	}
+	if lo.MaxSubjects < 0 || lo.MaxBytes < 0 {
+		return errors.New("last value cache limits can not be negative")
+	}
+	return nil
+}
+
+// Last message published on a subject.
+type lastValue struct {
+	subject string
+	hdr     int
+	msg     []byte
+	elem    *list.Element
+}
+
+// Last messages of the subjects of an account, bounded by the number of
+// subjects and their size. Eviction is in order of last update.
+type lastValueCache struct {
+	opts  LastValueCacheOpts
+	mu    sync.Mutex
+	msgs  *stree.SubjectTree[*lastValue]
+	lru   list.List
+	bytes int64
+}
+
+// Returns a cache for the options, nil if there are none.
+func newLastValueCache(lo *LastValueCacheOpts) *lastValueCache {
+	if lo == nil {
+		return nil
+	}
+	lvc := &lastValueCache{opts: *lo.clone(), msgs: stree.NewSubjectTree[*lastValue]()}
+	if lvc.opts.MaxSubjects == 0 {
+		lvc.opts.MaxSubjects = defaultLastValueMaxSubjects
+	}
+	if lvc.opts.MaxBytes == 0 {
+		lvc.opts.MaxBytes = defaultLastValueMaxBytes
+	}
+	return lvc
+}
+
+// Returns true if the subject is cached.
+func (lvc *lastValueCache) matches(subject string) bool {
+	for _, filter := range lvc.opts.Subjects {
+		if subjectIsSubsetMatch(subject, filter) {
+			return true
+		}
+	}
+	return false
+}
+
+// Stores a copy of the message, with its headers and trailing CR_LF, as
+// the last value of the subject.
+func (lvc *lastValueCache) store(subject []byte, hdr int, msg []byte) {
+	if int64(len(msg)) > lvc.opts.MaxBytes || !lvc.matches(bytesToString(subject)) {
+		return
+	}
+	lvc.mu.Lock()
+	defer lvc.mu.Unlock()
+	if lv, ok := lvc.msgs.Find(subject); ok {
+		lvc.bytes += int64(len(msg) - len((*lv).msg))
+		(*lv).hdr, (*lv).msg = max(hdr, 0), append((*lv).msg[:0], msg...)
+		lvc.lru.MoveToBack((*lv).elem)
+	} else {
+		lv := &lastValue{subject: string(subject), hdr: max(hdr, 0), msg: bytes.Clone(msg)}
+		lv.elem = lvc.lru.PushBack(lv)
+		lvc.msgs.Insert(subject, lv)
+		lvc.bytes += int64(len(msg))
+	}
+	for lvc.msgs.Size() > lvc.opts.MaxSubjects || lvc.bytes > lvc.opts.MaxBytes {
+		lv := lvc.lru.Remove(lvc.lru.Front()).(*lastValue)
+		lvc.msgs.Delete([]byte(lv.subject))
+		lvc.bytes -= int64(len(lv.msg))
+	}
+}
+
+// Updates the last value cache of the account. The cached messages are
+// kept if the options did not change.
+func (a *Account) setLastValueCache(lo *LastValueCacheOpts) {
+	a.lastValues = lo.clone()
+	if lvc := a.lvc.Load(); lvc != nil && lo != nil {
+		if nlvc := newLastValueCache(lo); nlvc.opts.MaxSubjects == lvc.opts.MaxSubjects &&
+			nlvc.opts.MaxBytes == lvc.opts.MaxBytes && slices.Equal(nlvc.opts.Subjects, lvc.opts.Subjects) {
+			return
+		}
+	}
+	a.lvc.Store(newLastValueCache(lo))
+}
+
+// Caches the message being processed as the last value of its subject,
+// if the account caches it.
+func (c *client) cacheLastValue(acc *Account, msg []byte) {
+	if lvc := acc.lvc.Load(); lvc != nil {
+		lvc.store(c.pa.subject, c.pa.hdr, msg)
+	}
+}
+
+// Creates a subscription that first receives the last values of the
+// matching subjects. The subscription is created while holding the cache
+// lock, so that no newer message can be delivered before a cached one.
+func (c *client) processSubWithLastValues(subject, queue, bsid []byte, qw int32, noForward bool) {
+	var lvc *lastValueCache
+	if acc := c.acc; acc != nil {
+		lvc = acc.lvc.Load()
+	}
+	if lvc == nil {
+		c.processSubEx(subject, queue, bsid, qw, nil, noForward, false, false)
+		return
+	}
+	lvc.mu.Lock()
+	defer lvc.mu.Unlock()
+	sub, err := c.processSubEx(subject, queue, bsid, qw, nil, noForward, false, false)
+	if err != nil || sub == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var n int
+	lvc.msgs.Match(subject, func(_ []byte, lv **lastValue) {
+		if c.deliverLastValue(sub, *lv) {
+			n++
+		}
+	})
+	if n > 0 {
+		c.flushSignal()
+	}
+	if c.trace {
+		c.Tracef("Delivered %d last values on %q", n, subject)
+	}
+}
+
+// Queues a cached message to the subscription.
+// Lock should be held.
+func (c *client) deliverLastValue(sub *subscription, lv *lastValue) bool {
+	if c.isClosed() || (c.mperms != nil && c.checkDenySub(lv.subject)) {
+		return false
+	}
+	// Same as msgHeader(), with the cached subject and sizes.
+	msg, mh := lv.msg, c.msgb[1:msgHeadProtoLen]
+	if lv.hdr > 0 && c.headers {
+		mh = c.msgb[:msgHeadProtoLen]
+		mh[0] = 'H'
+	} else if lv.hdr > 0 {
+		msg = msg[lv.hdr:]
+	}
+	mh = append(mh, lv.subject...)
+	mh = append(mh, ' ')
+	mh = append(mh, sub.sid...)
+	mh = append(mh, ' ')
+	if lv.hdr > 0 && c.headers {
+		mh = strconv.AppendInt(mh, int64(lv.hdr), 10)
+		mh = append(mh, ' ')
+	}
+	mh = strconv.AppendInt(mh, int64(len(msg)-LEN_CR_LF), 10)
+	mh = append(mh, _CRLF_...)
+
+	c.outMsgs++
+	c.outBytes += int64(len(msg) - LEN_CR_LF)
+	c.queueOutbound(mh)
+	c.queueOutbound(msg)
+	return true
+}
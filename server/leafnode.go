@@ -732,7 +732,7 @@ func (s *Server) startLeafNodeAcceptLoop() {
 
 	s.mu.Lock()
 	hp := net.JoinHostPort(opts.LeafNode.Host, strconv.Itoa(port))
-	l, e := natsListen("tcp", hp)
+	l, e := s.listen(leafListenerName, hp, true)
 	s.leafNodeListenerErr = e
 	if e != nil {
 		s.mu.Unlock()
@@ -2908,6 +2908,9 @@ func (c *client) processInboundLeafMsg(msg []byte) {
 		return
 	}
 
+	// Cache the message if the account keeps the last values of the subject.
+	c.cacheLastValue(acc, msg)
+
 	// Match the subscriptions. We will use our own L1 map if
 	// it's still valid, avoiding contention on the shared sublist.
 	var r *SublistResult
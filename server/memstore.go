@@ -43,6 +43,8 @@ type memStore struct {
 	rmcb        StorageRemoveMsgHandler
 	pmsgcb      ProcessJetStreamMsgHandler
 	ageChk      *time.Timer
+	cmpChk      *time.Timer
+	ctombs      avl.SequenceSet
 	consumers   int
 	receivedAny bool
 	ttls        *thw.HashWheel
@@ -75,6 +77,8 @@ func newMemStore(cfg *StreamConfig) (*memStore, error) {
 			return nil, err
 		}
 	}
+	// Start compaction by subject if configured.
+	ms.startCompactChk()
 
 	// Register with access time service.
 	ats.Register()
@@ -114,6 +118,21 @@ func (ms *memStore) UpdateConfig(cfg *StreamConfig) error {
 		ms.ageChk.Stop()
 		ms.ageChk = nil
 	}
+	// Do compaction timer.
+	if ms.cfg.Compaction == nil {
+		clearTimer(&ms.cmpChk)
+		ms.ctombs.Empty()
+	} else {
+		// Track the existing tombstones if compaction was just enabled.
+		if ms.cmpChk == nil {
+			for seq, sm := range ms.msgs {
+				if isCompactionTombstone(sm.hdr) {
+					ms.ctombs.Insert(seq)
+				}
+			}
+		}
+		ms.startCompactChk()
+	}
 	// Make sure to update MaxMsgsPer
 	if cfg.MaxMsgsPer < -1 {
 		cfg.MaxMsgsPer = -1
@@ -267,6 +286,10 @@ func (ms *memStore) storeRawMsg(subj string, hdr, msg []byte, seq uint64, ts, tt
 	}
 	sm.msg = sm.buf[len(hdr):]
 	ms.msgs[seq] = sm
+	// Track tombstones for compaction.
+	if ms.cfg.Compaction != nil && isCompactionTombstone(hdr) {
+		ms.ctombs.Insert(seq)
+	}
 	ms.state.Msgs++
 	ms.state.Bytes += memStoreMsgSize(subj, hdr, msg)
 	ms.state.LastSeq = seq
@@ -1122,6 +1145,80 @@ func (ms *memStore) cancelAgeChk() {
 	}
 }
 
+// Will start the compaction timer if configured.
+// Lock should be held.
+func (ms *memStore) startCompactChk() {
+	if ms.cmpChk != nil || ms.cfg.Compaction == nil {
+		return
+	}
+	ms.cmpChk = time.AfterFunc(ms.cfg.Compaction.interval(), ms.compactBySubject)
+}
+
+// Will compact messages older than the compaction lag down to the last message
+// per subject. Only the subjects with more than one message in the per subject
+// index are visited. Tombstones are tracked when stored, and removed once they
+// are older than the compaction lag plus the delete retention.
+func (ms *memStore) compactBySubject() {
+	var smv StoreMsg
+
+	ms.mu.Lock()
+	cc := ms.cfg.Compaction
+	if cc == nil {
+		ms.mu.Unlock()
+		return
+	}
+	// New messages can only move the last sequence up, so a stale one is safe.
+	lasts := make(map[string]uint64)
+	ms.fss.IterFast(func(subj []byte, ss *SimpleState) bool {
+		if ss.Msgs > 1 {
+			lasts[string(subj)] = ss.Last
+		}
+		return true
+	})
+	var tombs []uint64
+	ms.ctombs.Range(func(seq uint64) bool {
+		tombs = append(tombs, seq)
+		return true
+	})
+	ms.mu.Unlock()
+
+	for subj, last := range lasts {
+		for seq := uint64(0); seq < last; {
+			sm, nseq, err := ms.LoadNextMsg(subj, false, seq, &smv)
+			if err != nil || sm == nil || nseq >= last || time.Since(time.Unix(0, sm.ts)) < cc.Lag {
+				break
+			}
+			ms.mu.Lock()
+			ms.removeMsg(nseq, false)
+			ms.mu.Unlock()
+			seq = nseq + 1
+		}
+	}
+
+	for _, seq := range tombs {
+		sm, err := ms.LoadMsg(seq, &smv)
+		if err == nil && sm != nil && isCompactionTombstone(sm.hdr) {
+			if time.Since(time.Unix(0, sm.ts)) < cc.Lag+cc.DeleteRetention {
+				break
+			}
+			ms.mu.Lock()
+			ms.removeMsg(seq, false)
+			ms.mu.Unlock()
+		}
+		ms.mu.Lock()
+		ms.ctombs.Delete(seq)
+		ms.mu.Unlock()
+	}
+
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	if ms.msgs == nil || ms.cfg.Compaction == nil {
+		clearTimer(&ms.cmpChk)
+	} else if ms.cmpChk != nil {
+		ms.cmpChk.Reset(ms.cfg.Compaction.interval())
+	}
+}
+
 // Will expire msgs that are too old.
 func (ms *memStore) expireMsgs() {
 	var smv StoreMsg
@@ -2057,6 +2154,7 @@ func (ms *memStore) Stop() error {
 		ms.ageChk.Stop()
 		ms.ageChk = nil
 	}
+	clearTimer(&ms.cmpChk)
 	ms.msgs = nil
 	ms.mu.Unlock()
 
@@ -130,6 +130,7 @@ type ConnInfo struct {
 	InBytes        int64          `json:"in_bytes"`
 	OutBytes       int64          `json:"out_bytes"`
 	Stalls         int64          `json:"stalls,omitempty"`
+	ExpiredMsgs    int64          `json:"expired_msgs,omitempty"`
 	NumSubs        uint32         `json:"subscriptions"`
 	Name           string         `json:"name,omitempty"`
 	Lang           string         `json:"lang,omitempty"`
@@ -148,6 +149,7 @@ type ConnInfo struct {
 	Tags           jwt.TagList    `json:"tags,omitempty"`
 	MQTTClient     string         `json:"mqtt_client,omitempty"` // This is the MQTT client id
 	Proxy          *ProxyInfo     `json:"proxy,omitempty"`
+	Compression    string         `json:"compression,omitempty"`
 
 	// Internal
 	rtt int64 // For fast sorting
@@ -579,7 +581,11 @@ func (ci *ConnInfo) fill(client *client, nc net.Conn, now time.Time, auth bool)
 	ci.InMsgs = atomic.LoadInt64(&client.inMsgs)
 	ci.InBytes = atomic.LoadInt64(&client.inBytes)
 	ci.Stalls = atomic.LoadInt64(&client.stalls)
+	ci.ExpiredMsgs = atomic.LoadInt64(&client.expiredMsgs)
 	ci.Proxy = createProxyInfo(client)
+	if client.kind == CLIENT && needsCompression(client.compression) {
+		ci.Compression = client.compression
+	}
 
 	// If the connection is gone, too bad, we won't set TLSVersion and TLSCipher.
 	// Exclude clients that are still doing handshake so we don't block in
@@ -1264,6 +1270,7 @@ type Varz struct {
 	SlowConsumers         int64                  `json:"slow_consumers"`                    // SlowConsumers is the total count of clients that were disconnected since start due to being slow consumers
 	StaleConnections      int64                  `json:"stale_connections"`                 // StaleConnections is the total count of stale connections that were detected
 	StalledClients        int64                  `json:"stalled_clients"`                   // StalledClients is the total number of times that clients have been stalled.
+	ExpiredMsgs           int64                  `json:"expired_msgs,omitempty"`            // ExpiredMsgs is the number of messages discarded from the pending buffers of clients because they expired
 	Subscriptions         uint32                 `json:"subscriptions"`                     // Subscriptions is the count of active subscriptions
 	HTTPReqStats          map[string]uint64      `json:"http_req_stats"`                    // HTTPReqStats is the number of requests each HTTP endpoint received
 	ConfigLoadTime        time.Time              `json:"config_load_time"`                  // ConfigLoadTime is the time the configuration was loaded or reloaded
@@ -1501,6 +1508,7 @@ func (s *Server) HandleRoot(w http.ResponseWriter, r *http.Request) {
 	<a href=.%s>LeafNodes<span class="endpoint"> %s</span></a>
 	<a href=.%s>Gateways<span class="endpoint"> %s</span></a>
 	<a href=.%s>Raft Groups<span class="endpoint"> %s</span></a>
+	<a href=.%s>Service Latency<span class="endpoint"> %s</span></a>
 	<a href=.%s class=last>Health Probe<span class="endpoint"> %s</span></a>
     <a href=https://docs.nats.io/running-a-nats-service/nats_admin/monitoring class="help">Help</a>
   </body>
@@ -1517,6 +1525,7 @@ func (s *Server) HandleRoot(w http.ResponseWriter, r *http.Request) {
 		s.basePath(LeafzPath), LeafzPath,
 		s.basePath(GatewayzPath), GatewayzPath,
 		s.basePath(RaftzPath), RaftzPath,
+		s.basePath(LatencyzPath), LatencyzPath,
 		s.basePath(HealthzPath), HealthzPath,
 	)
 }
@@ -1814,6 +1823,7 @@ func (s *Server) updateVarzRuntimeFields(v *Varz, forceUpdate bool, pcpu float64
 	v.OutBytes = atomic.LoadInt64(&s.outBytes)
 	v.SlowConsumers = atomic.LoadInt64(&s.slowConsumers)
 	v.StalledClients = atomic.LoadInt64(&s.stalls)
+	v.ExpiredMsgs = atomic.LoadInt64(&s.expiredMsgs)
 	v.SlowConsumersStats = &SlowConsumersStats{
 		Clients:  s.NumSlowConsumersClients(),
 		Routes:   s.NumSlowConsumersRoutes(),
@@ -2494,6 +2504,73 @@ func (s *Server) HandleAccountStatz(w http.ResponseWriter, r *http.Request) {
 	ResponseHandler(w, r, b)
 }
 
+// Latencyz represents the latency histograms of tracked service exports.
+type Latencyz struct {
+	ID         string                     `json:"server_id"`
+	Now        time.Time                  `json:"now"`
+	Histograms []*ServiceLatencyHistogram `json:"latency_histograms"`
+}
+
+// LatencyzOptions are options passed to service latency requests.
+type LatencyzOptions struct {
+	// Account is the exporting account, all accounts if empty.
+	Account string `json:"account,omitempty"`
+	// Service is the subject of the service export, all exports if empty.
+	Service string `json:"service,omitempty"`
+}
+
+// Latencyz returns a Latencyz structure containing the rolling latency
+// histograms of service exports with latency tracking.
+func (s *Server) Latencyz(opts *LatencyzOptions) (*Latencyz, error) {
+	if opts == nil {
+		opts = &LatencyzOptions{}
+	}
+	lz := &Latencyz{
+		ID:         s.ID(),
+		Now:        time.Now().UTC(),
+		Histograms: []*ServiceLatencyHistogram{},
+	}
+	if opts.Account != _EMPTY_ {
+		acc, ok := s.accounts.Load(opts.Account)
+		if !ok {
+			return nil, fmt.Errorf("account %q not found", opts.Account)
+		}
+		lz.Histograms = append(lz.Histograms, acc.(*Account).serviceLatencyHistograms(opts.Service)...)
+		return lz, nil
+	}
+	s.accounts.Range(func(key, a any) bool {
+		lz.Histograms = append(lz.Histograms, a.(*Account).serviceLatencyHistograms(opts.Service)...)
+		return true
+	})
+	slices.SortStableFunc(lz.Histograms, func(i, j *ServiceLatencyHistogram) int { return cmp.Compare(i.Account, j.Account) })
+	return lz, nil
+}
+
+// HandleLatencyz process HTTP requests for service latency histograms.
+func (s *Server) HandleLatencyz(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	s.httpReqStats[LatencyzPath]++
+	s.mu.Unlock()
+
+	l, err := s.Latencyz(&LatencyzOptions{
+		Account: r.URL.Query().Get("acc"),
+		Service: r.URL.Query().Get("service"),
+	})
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(err.Error()))
+		return
+	}
+	b, err := json.MarshalIndent(l, "", "  ")
+	if err != nil {
+		s.Errorf("Error marshaling response to %s request: %v", LatencyzPath, err)
+		return
+	}
+
+	// Handle response
+	ResponseHandler(w, r, b)
+}
+
 // ResponseHandler handles responses for monitoring routes.
 func ResponseHandler(w http.ResponseWriter, r *http.Request, data []byte) {
 	handleResponse(http.StatusOK, w, r, data)
@@ -2593,6 +2670,10 @@ func (reason ClosedState) String() string {
 		return "Proxy Not Trusted"
 	case ProxyRequired:
 		return "Proxy Required"
+	case RateLimitExceeded:
+		return "Rate Limit Exceeded"
+	case Drained:
+		return "Drained"
 	}
 
 	return "Unknown State"
@@ -2626,8 +2707,13 @@ type ExtImport struct {
 
 type ExtExport struct {
 	jwt.Export
-	ApprovedAccounts []string             `json:"approved_accounts,omitempty"`
-	RevokedAct       map[string]time.Time `json:"revoked_activations,omitempty"`
+	ApprovedAccounts  []string                   `json:"approved_accounts,omitempty"`
+	RevokedAct        map[string]time.Time       `json:"revoked_activations,omitempty"`
+	LatencyHistograms []*ServiceLatencyHistogram `json:"latency_histograms,omitempty"`
+	Inflight          int                        `json:"inflight,omitempty"`
+	MaxInflight       int                        `json:"max_inflight,omitempty"`
+	Timeout           time.Duration              `json:"timeout,omitempty"`
+	CircuitOpen       bool                       `json:"circuit_open,omitempty"`
 }
 
 type ExtVrIssues struct {
@@ -2660,6 +2746,7 @@ type AccountInfo struct {
 	RevokedUser map[string]time.Time `json:"revoked_user,omitempty"`
 	Sublist     *SublistStats        `json:"sublist_stats,omitempty"`
 	Responses   map[string]ExtImport `json:"responses,omitempty"`
+	Queues      []*QueuePolicy       `json:"queue_policies,omitempty"`
 }
 
 type Accountz struct {
@@ -2770,6 +2857,7 @@ func (s *Server) accountInfo(accName string) (*AccountInfo, error) {
 		return rev
 	}
 	exports := []ExtExport{}
+	hists := a.serviceLatencyHistograms(_EMPTY_)
 	for k, v := range a.exports.services {
 		e := ExtExport{
 			Export: jwt.Export{
@@ -2780,8 +2868,15 @@ func (s *Server) accountInfo(accName string) (*AccountInfo, error) {
 		}
 		if v != nil {
 			e.Latency = newExtServiceLatency(v.latency)
+			for _, h := range hists {
+				if h.Service == k {
+					e.LatencyHistograms = append(e.LatencyHistograms, h)
+				}
+			}
 			e.TokenReq = v.tokenReq
 			e.ResponseType = jwt.ResponseType(v.respType.String())
+			e.Inflight, e.MaxInflight, e.Timeout = v.inflight, v.maxInflight, v.timeout
+			e.CircuitOpen = v.cb.isOpen()
 			for name := range v.approved {
 				e.ApprovedAccounts = append(e.ApprovedAccounts, name)
 			}
@@ -2845,13 +2940,16 @@ func (s *Server) accountInfo(accName string) (*AccountInfo, error) {
 		} else {
 			src = m.src
 			for _, d := range m.dests {
-				dests = append(dests, &MapDest{d.tr.dest, d.weight, _EMPTY_})
+				dests = append(dests, &MapDest{Subject: d.tr.dest, Weight: d.weight})
 			}
 			for c, cd := range m.cdests {
 				for _, d := range cd {
-					dests = append(dests, &MapDest{d.tr.dest, d.weight, c})
+					dests = append(dests, &MapDest{Subject: d.tr.dest, Weight: d.weight, Cluster: c})
 				}
 			}
+			for _, hd := range m.hdests {
+				dests = append(dests, &MapDest{Subject: hd.tr.dest, Cluster: hd.cluster, Headers: hd.hdrs})
+			}
 		}
 		mappings[src] = dests
 	}
@@ -2877,6 +2975,7 @@ func (s *Server) accountInfo(accName string) (*AccountInfo, error) {
 		RevokedUser: collectRevocations(a.usersRevoked),
 		Sublist:     a.sl.Stats(),
 		Responses:   responses,
+		Queues:      a.QueuePolicies(),
 	}, nil
 }
 
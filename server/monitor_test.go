@@ -6340,3 +6340,51 @@ func TestMonitorVarzMetadata(t *testing.T) {
 		t.Fatalf("expected: %v, got: %v", expected, v.Metadata)
 	}
 }
+
+func TestMonitorDrainz(t *testing.T) {
+	s := runMonitorServerWithAccounts()
+	defer s.Shutdown()
+
+	ncA := natsConnect(t, s.ClientURL(), nats.UserInfo("a", "a"), nats.NoReconnect())
+	defer ncA.Close()
+	ncB := natsConnect(t, s.ClientURL(), nats.UserInfo("b", "b"), nats.NoReconnect())
+	defer ncB.Close()
+
+	url := fmt.Sprintf("http://127.0.0.1:%d%s", s.MonitorAddr().Port, DrainzPath)
+	post := func(query string, status int) []byte {
+		t.Helper()
+		resp, err := http.Post(url+query, "", nil)
+		require_NoError(t, err)
+		defer resp.Body.Close()
+		body, err := io.ReadAll(resp.Body)
+		require_NoError(t, err)
+		require_Equal(t, resp.StatusCode, status)
+		return body
+	}
+
+	// Only POST requests are accepted, and a filter is required.
+	readBodyEx(t, url+"?acc=A", http.StatusMethodNotAllowed, textPlain)
+	post(_EMPTY_, http.StatusBadRequest)
+	post("?cid=x", http.StatusBadRequest)
+	post("?acc=A&grace=x", http.StatusBadRequest)
+
+	var dr DrainConnsResponse
+	require_NoError(t, json.Unmarshal(post("?acc=A&grace=50ms", http.StatusOK), &dr))
+	require_Equal(t, dr.GracePeriod, 50*time.Millisecond)
+	require_Len(t, len(dr.Conns), 1)
+	require_Equal(t, dr.Conns[0].Account, "A")
+	require_Equal(t, dr.Conns[0].AuthorizedUser, "a")
+
+	// A connection being drained is not selected again.
+	require_NoError(t, json.Unmarshal(post("?acc=A", http.StatusOK), &dr))
+	require_Len(t, len(dr.Conns), 0)
+
+	checkFor(t, 2*time.Second, 15*time.Millisecond, func() error {
+		if ncA.IsConnected() {
+			return fmt.Errorf("connection of account A still connected")
+		}
+		return nil
+	})
+	require_True(t, ncB.IsConnected())
+	require_Equal(t, s.NumClients(), 1)
+}
@@ -514,7 +514,7 @@ func (s *Server) startMQTT() {
 	hp := net.JoinHostPort(o.Host, strconv.Itoa(port))
 	s.mu.Lock()
 	s.mqtt.sessmgr.sessions = make(map[string]*mqttAccountSessionManager)
-	hl, err = net.Listen("tcp", hp)
+	hl, err = s.listen(mqttListenerName, hp, false)
 	s.mqtt.listenerErr = err
 	if err != nil {
 		s.mu.Unlock()
@@ -3652,7 +3652,7 @@ func (c *client) mqttParseConnect(r *mqttReader, hasMappings bool) (byte, *mqttC
 			// For selectMappedSubject to work, we need to have c.pa.subject set.
 			// If there is a change, c.pa.mapped will be set after the call.
 			c.pa.subject = cp.will.subject
-			if changed := c.selectMappedSubject(); changed {
+			if changed := c.selectMappedSubject(nil); changed {
 				// We need to keep track of the NATS subject/mapped in the `cp` structure.
 				cp.will.subject = c.pa.subject
 				cp.will.mapped = c.pa.mapped
@@ -4001,7 +4001,7 @@ func (c *client) mqttParsePub(r *mqttReader, pl int, pp *mqttPublish, hasMapping
 		// For selectMappedSubject to work, we need to have c.pa.subject set.
 		// If there is a change, c.pa.mapped will be set after the call.
 		c.pa.subject = pp.subject
-		if changed := c.selectMappedSubject(); changed {
+		if changed := c.selectMappedSubject(nil); changed {
 			// We need to keep track of the NATS subject/mapped in the `pp` structure.
 			pp.subject = c.pa.subject
 			pp.mapped = c.pa.mapped
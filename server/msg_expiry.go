@@ -0,0 +1,174 @@
+// Copyright 2025 The NATS Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"math"
+	"net"
+	"sync/atomic"
+	"time"
+)
+
+// MsgExpires is the header set by publishers to have core NATS messages
+// discarded if they could not be written to a subscriber in time. The
+// value is either a RFC 3339 timestamp or a duration, e.g. "500ms", from
+// the moment the message is queued for the subscriber.
+const MsgExpires = "Nats-Expires"
+
+// A message in the pending buffer of a client that expires. The offsets
+// are the ones of the message in "nb".
+type outExpiry struct {
+	start    int64
+	end      int64
+	deadline int64
+}
+
+// Returns the time, in Unix nanoseconds, at which a message delivered to
+// this client expires, or 0 if it does not. The expiration is the one of
+// the MsgExpires header, or the maximum age set by the client in CONNECT,
+// whichever comes first.
+// Lock should be held.
+func (c *client) msgDeadline(hdr []byte) int64 {
+	var v []byte
+	if len(hdr) > 0 {
+		v = sliceHeader(MsgExpires, hdr)
+	}
+	if len(v) == 0 && c.opts.MaxMsgAge <= 0 {
+		return 0
+	}
+	now := time.Now()
+	var deadline int64
+	if c.opts.MaxMsgAge > 0 {
+		deadline = now.Add(c.opts.MaxMsgAge).UnixNano()
+	}
+	if len(v) == 0 {
+		return deadline
+	}
+	var exp int64
+	if d, err := time.ParseDuration(bytesToString(v)); err == nil {
+		exp = now.Add(d).UnixNano()
+	} else if t, err := time.Parse(time.RFC3339Nano, bytesToString(v)); err == nil {
+		exp = t.UnixNano()
+	} else {
+		return deadline
+	}
+	if deadline == 0 || exp < deadline {
+		deadline = exp
+	}
+	return deadline
+}
+
+// Records that the bytes of "nb" from the given offset to its end are a
+// message that expires at the deadline.
+// Lock should be held.
+func (c *client) trackExpiringMsg(start, deadline int64) {
+	if c.out.nbs <= start {
+		return
+	}
+	if len(c.out.exp) == 0 || deadline < c.out.nexp {
+		c.out.nexp = deadline
+	}
+	c.out.exp = append(c.out.exp, outExpiry{start, c.out.nbs, deadline})
+}
+
+// Clears the expiring messages, once "nb" is handed over for writing.
+// Lock should be held.
+func (c *client) resetExpiringMsgs() {
+	c.out.nbs = 0
+	c.out.exp = c.out.exp[:0]
+	c.out.nexp = 0
+}
+
+// Removes the messages that expired from the pending buffer. Messages
+// that are being written are not affected.
+// Lock should be held.
+func (c *client) discardExpiredMsgs(now int64) {
+	if len(c.out.exp) == 0 || now < c.out.nexp {
+		return
+	}
+	// Split the expired messages from the others, whose offsets are
+	// shifted by the size of the expired messages before them.
+	var drops []outExpiry
+	var removed int64
+	remaining, nexp := c.out.exp[:0], int64(math.MaxInt64)
+	for _, e := range c.out.exp {
+		if e.deadline <= now {
+			drops = append(drops, e)
+			removed += e.end - e.start
+			continue
+		}
+		e.start, e.end = e.start-removed, e.end-removed
+		remaining = append(remaining, e)
+		nexp = min(nexp, e.deadline)
+	}
+	c.out.exp, c.out.nexp = remaining, nexp
+	if len(drops) == 0 {
+		return
+	}
+
+	// Copy what is kept into new buffers, as queueOutbound() does.
+	var nb net.Buffers
+	keep := func(b []byte) {
+		for len(b) > 0 {
+			if len(nb) > 0 {
+				last := &nb[len(nb)-1]
+				if free := min(cap(*last)-len(*last), len(b)); free > 0 {
+					*last = append(*last, b[:free]...)
+					b = b[free:]
+					continue
+				}
+			}
+			nbuf := nbPoolGet(len(b))
+			n := copy(nbuf[:cap(nbuf)], b)
+			nb = append(nb, nbuf[:n])
+			b = b[n:]
+		}
+	}
+	var pos int64
+	d := 0
+	for _, buf := range c.out.nb {
+		for len(buf) > 0 {
+			if d < len(drops) && pos >= drops[d].start {
+				n := min(int64(len(buf)), drops[d].end-pos)
+				buf, pos = buf[n:], pos+n
+				if pos == drops[d].end {
+					d++
+				}
+				continue
+			}
+			n := int64(len(buf))
+			if d < len(drops) {
+				n = min(n, drops[d].start-pos)
+			}
+			keep(buf[:n])
+			buf, pos = buf[n:], pos+n
+		}
+	}
+	for _, buf := range c.out.nb {
+		nbPoolPut(buf)
+	}
+	c.out.nb, c.out.nbs, c.out.pb = nb, c.out.nbs-removed, c.out.pb-removed
+
+	discarded := int64(len(drops))
+	atomic.AddInt64(&c.expiredMsgs, discarded)
+	atomic.AddInt64(&c.srv.expiredMsgs, discarded)
+	if c.acc != nil {
+		c.acc.stats.Lock()
+		c.acc.stats.expiredMsgs += discarded
+		c.acc.stats.Unlock()
+	}
+	if c.trace {
+		c.Tracef("Discarded %d expired messages, %d bytes", discarded, removed)
+	}
+}
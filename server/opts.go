@@ -37,6 +37,7 @@ import (
 	"github.com/nats-io/nats-server/v2/conf"
 	"github.com/nats-io/nats-server/v2/server/certidp"
 	"github.com/nats-io/nats-server/v2/server/certstore"
+	"github.com/nats-io/nats-server/v2/server/kms"
 	"github.com/nats-io/nkeys"
 )
 
@@ -83,6 +84,7 @@ type ClusterOpts struct {
 	Compression       CompressionOpts   `json:"-"`
 	PingInterval      time.Duration     `json:"-"`
 	MaxPingsOut       int               `json:"-"`
+	Rebalance         RebalanceOpts     `json:"-"`
 
 	// Not exported (used in tests)
 	resolver netResolver
@@ -105,6 +107,25 @@ type CompressionOpts struct {
 	RTTThresholds []time.Duration
 }
 
+// RebalanceOpts are options for the automatic rebalancing of client
+// connections across the servers of a cluster.
+type RebalanceOpts struct {
+	Enabled bool
+	// Interval at which the number of connections of the servers is
+	// compared, defaults to a minute.
+	Interval time.Duration
+	// Fraction above the average number of connections of the cluster
+	// above which clients are asked to reconnect to other servers,
+	// defaults to 0.2.
+	Threshold float64
+	// Maximum number of clients asked to reconnect per interval,
+	// defaults to 10.
+	MaxPerInterval int
+	// Time given to the clients to reconnect before their connection
+	// is closed. Defaults to the lame duck grace period.
+	GracePeriod time.Duration
+}
+
 // GatewayOpts are options for gateways.
 // NOTE: This structure is no longer used for monitoring endpoints
 // and json tags are deprecated and may be removed in the future.
@@ -279,6 +300,39 @@ type JSTpmOpts struct {
 	Pcr         int
 }
 
+// JSKMSOpts configure envelope encryption of JetStream data at rest with
+// keys held by an external key management service. Data keys are generated
+// per account, wrapped by the KMS and stored alongside the account's data.
+type JSKMSOpts struct {
+	// Provider is the key provider to use, "file" or "vault".
+	Provider string
+	// KeysFile is the keys file used by the file provider.
+	KeysFile string
+	// URL, Token, Namespace and Mount configure the vault provider.
+	URL       string
+	Token     string
+	Namespace string
+	Mount     string
+	// Key is the name of the KMS key used to wrap data keys.
+	Key string
+	// AccountKeys overrides the name of the KMS key for specific accounts.
+	AccountKeys map[string]string
+	// KeyProvider allows a custom key provider to be used when embedding.
+	KeyProvider kms.KeyProvider
+}
+
+// JSConnectorOpts control which sinks stream connectors may write to.
+// Connectors run inside the server, so sinks are disabled unless permitted.
+type JSConnectorOpts struct {
+	// FileDir is the directory file sinks write to, within a directory per
+	// account and stream. File sinks are disabled if empty.
+	FileDir string
+	// AllowHTTP permits HTTP webhook sinks and webhook consumers.
+	AllowHTTP bool
+	// ExecCommands lists the commands exec sinks are allowed to run.
+	ExecCommands []string
+}
+
 // AuthCallout option used to map external AuthN to NATS based AuthZ.
 type AuthCallout struct {
 	// Must be a public account Nkey.
@@ -293,6 +347,26 @@ type AuthCallout struct {
 	// AllowedAccounts that will be delegated to the auth service.
 	// If empty then all accounts will be delegated.
 	AllowedAccounts []string
+	// CacheTTL is how long authorization responses are cached, unless the
+	// response JWT has an expiration, in which case it is cached until then.
+	// Responses are not cached if neither is set.
+	CacheTTL time.Duration
+	// CacheMaxEntries is the maximum number of cached authorization responses.
+	CacheMaxEntries int
+	// MaxPending is the maximum number of concurrent requests to the auth
+	// service, unlimited if zero. Connections wait up to the authorization
+	// timeout for a request to complete.
+	MaxPending int
+	// FailOpen allows the use of expired cached responses when the auth
+	// service does not respond in time, until the user JWT expires.
+	FailOpen bool
+	// AuthorizeSubjects enables requests to the auth service, on the
+	// AuthCalloutSubjectsSubject, when users it authorized publish or
+	// subscribe to subjects their permissions do not allow. Requests are
+	// sent from the read loop of the client, which stalls for up to 250ms
+	// the first time each such subject is used. Not supported in operator
+	// mode.
+	AuthorizeSubjects bool
 }
 
 // Options block for nats-server.
@@ -356,9 +430,12 @@ type Options struct {
 	JetStreamKey               string        `json:"-"`
 	JetStreamOldKey            string        `json:"-"`
 	JetStreamCipher            StoreCipher   `json:"-"`
+	JetStreamStreamKeys        bool          `json:"-"`
 	JetStreamUniqueTag         string
 	JetStreamLimits            JSLimitOpts
 	JetStreamTpm               JSTpmOpts
+	JetStreamKMS               JSKMSOpts
+	JetStreamConnectors        JSConnectorOpts
 	JetStreamMaxCatchup        int64
 	JetStreamRequestQueueLimit int64
 	StreamMaxBufferedMsgs      int               `json:"-"`
@@ -369,10 +446,12 @@ type Options struct {
 	JsAccDefaultDomain         map[string]string `json:"-"` // account to domain name mapping
 	Websocket                  WebsocketOpts     `json:"-"`
 	MQTT                       MQTTOpts          `json:"-"`
+	Compression                CompressionOpts   `json:"-"`
 	ProfPort                   int               `json:"-"`
 	ProfBlockRate              int               `json:"-"`
 	PidFile                    string            `json:"-"`
 	PortsFileDir               string            `json:"-"`
+	UpgradeSocket              string            `json:"-"`
 	LogFile                    string            `json:"-"`
 	LogSizeLimit               int64             `json:"-"`
 	LogMaxFiles                int64             `json:"-"`
@@ -411,6 +490,10 @@ type Options struct {
 	// MaxTracedMsgLen is the maximum printable length for traced messages.
 	MaxTracedMsgLen int `json:"-"`
 
+	// ServiceLatencyWindows are the rolling windows of the latency histograms
+	// kept for service exports with latency tracking.
+	ServiceLatencyWindows []time.Duration `json:"-"`
+
 	// Operating a trusted NATS server
 	TrustedKeys              []string              `json:"-"`
 	TrustedOperators         []*jwt.OperatorClaims `json:"-"`
@@ -1231,6 +1314,11 @@ func (o *Options) processConfigFileLine(k string, v any, errors *[]error, warnin
 		o.MaxPayload = int32(v.(int64))
 	case "max_pending":
 		o.MaxPending = v.(int64)
+	case "compression":
+		if err := parseCompression(&o.Compression, CompressionS2Auto, tk, k, v); err != nil {
+			*errors = append(*errors, err)
+			return
+		}
 	case "max_connections", "max_conn":
 		o.MaxConn = int(v.(int64))
 	case "max_traced_msg_len":
@@ -1615,6 +1703,19 @@ func (o *Options) processConfigFileLine(k string, v any, errors *[]error, warnin
 		return
 	case "no_system_account", "no_system", "no_sys_acc":
 		o.NoSystemAccount = v.(bool)
+	case "service_latency_windows":
+		windows, ok := v.([]any)
+		if !ok {
+			windows = []any{tk}
+		}
+		o.ServiceLatencyWindows = nil
+		for _, w := range windows {
+			wtk, wv := unwrapValue(w, &lt)
+			o.ServiceLatencyWindows = append(o.ServiceLatencyWindows, parseDuration(k, wtk, wv, errors, warnings))
+		}
+		if err := validateServiceLatencyWindows(o.ServiceLatencyWindows); err != nil {
+			*errors = append(*errors, &configErr{tk, err.Error()})
+		}
 	case "no_header_support":
 		o.NoHeaderSupport = v.(bool)
 	case "trusted", "trusted_keys":
@@ -1980,6 +2081,11 @@ func parseCluster(v any, opts *Options, errors *[]error, warnings *[]error) erro
 			}
 		case "ping_max":
 			opts.Cluster.MaxPingsOut = int(mv.(int64))
+		case "rebalance":
+			if err := parseClusterRebalance(&opts.Cluster.Rebalance, tk, mv, errors, warnings); err != nil {
+				*errors = append(*errors, err)
+				continue
+			}
 		default:
 			if !tk.IsUsedVariable() {
 				err := &unknownConfigFieldErr{
@@ -2020,6 +2126,8 @@ func parseCompression(c *CompressionOpts, chosenModeForOn string, tk token, mk s
 			case "mode":
 				c.Mode = mv.(string)
 			case "rtt_thresholds", "thresholds", "rtts", "rtt":
+				// The configuration may be processed more than once.
+				c.RTTThresholds = nil
 				for _, iv := range mv.([]any) {
 					_, mv := unwrapValue(iv, &lt)
 					dur, err := time.ParseDuration(mv.(string))
@@ -2040,6 +2148,57 @@ func parseCompression(c *CompressionOpts, chosenModeForOn string, tk token, mk s
 	return nil
 }
 
+// parseClusterRebalance parses the options of the rebalancing of client connections.
+// e.g.
+// rebalance: true
+// rebalance: {interval: "1m", threshold: 0.2, max_per_interval: 10, grace_period: "10s"}
+func parseClusterRebalance(r *RebalanceOpts, tk token, mv any, errors *[]error, warnings *[]error) (retErr error) {
+	var lt token
+	defer convertPanicToError(&lt, &retErr)
+
+	switch mv := mv.(type) {
+	case bool:
+		r.Enabled = mv
+	case map[string]any:
+		r.Enabled = true
+		for mk, mv := range mv {
+			tk, mv := unwrapValue(mv, &lt)
+			switch strings.ToLower(mk) {
+			case "enabled", "enable":
+				r.Enabled = mv.(bool)
+			case "interval":
+				r.Interval = parseDuration("interval", tk, mv, errors, warnings)
+			case "threshold":
+				switch v := mv.(type) {
+				case float64:
+					r.Threshold = v
+				case int64:
+					r.Threshold = float64(v)
+				case string:
+					pct, err := strconv.ParseFloat(strings.TrimSuffix(v, "%"), 64)
+					if err != nil || !strings.HasSuffix(v, "%") {
+						return &configErr{tk, fmt.Sprintf("invalid threshold %q", v)}
+					}
+					r.Threshold = pct / 100
+				default:
+					return &configErr{tk, fmt.Sprintf("threshold should be a number or a percentage, got %T", mv)}
+				}
+			case "max_per_interval", "max":
+				r.MaxPerInterval = int(mv.(int64))
+			case "grace_period":
+				r.GracePeriod = parseDuration("grace_period", tk, mv, errors, warnings)
+			default:
+				if !tk.IsUsedVariable() {
+					return &configErr{tk, fmt.Sprintf("unknown field %q parsing rebalance", mk)}
+				}
+			}
+		}
+	default:
+		return &configErr{tk, fmt.Sprintf("field \"rebalance\" should be a boolean or a structure, got %T", mv)}
+	}
+	return nil
+}
+
 func parseURLs(a []any, typ string, warnings *[]error) (urls []*url.URL, errors []error) {
 	urls = make([]*url.URL, 0, len(a))
 	var lt token
@@ -2456,6 +2615,130 @@ func parseJetStreamTPM(v interface{}, opts *Options, errors *[]error) error {
 	return nil
 }
 
+// Parse the JetStream KMS options.
+func parseJetStreamKMS(v any, opts *Options, errors *[]error) error {
+	var lt token
+	tk, v := unwrapValue(v, &lt)
+
+	opts.JetStreamKMS = JSKMSOpts{}
+
+	vv, ok := v.(map[string]any)
+	if !ok {
+		return &configErr{tk, fmt.Sprintf("Expected a map to define JetStream KMS options, got %T", v)}
+	}
+	for mk, mv := range vv {
+		tk, mv = unwrapValue(mv, &lt)
+		switch strings.ToLower(mk) {
+		case "provider":
+			opts.JetStreamKMS.Provider = strings.ToLower(mv.(string))
+		case "keys_file":
+			opts.JetStreamKMS.KeysFile = mv.(string)
+		case "url":
+			opts.JetStreamKMS.URL = mv.(string)
+		case "token":
+			opts.JetStreamKMS.Token = mv.(string)
+		case "namespace":
+			opts.JetStreamKMS.Namespace = mv.(string)
+		case "mount":
+			opts.JetStreamKMS.Mount = mv.(string)
+		case "key":
+			opts.JetStreamKMS.Key = mv.(string)
+		case "account_keys":
+			am, ok := mv.(map[string]any)
+			if !ok {
+				return &configErr{tk, fmt.Sprintf("Expected a map of account keys, got %T", mv)}
+			}
+			opts.JetStreamKMS.AccountKeys = make(map[string]string, len(am))
+			for acc, key := range am {
+				_, key = unwrapValue(key, &lt)
+				name, ok := key.(string)
+				if !ok {
+					return &configErr{tk, fmt.Sprintf("Expected a key name for account %q, got %T", acc, key)}
+				}
+				opts.JetStreamKMS.AccountKeys[acc] = name
+			}
+		case "cipher":
+			if err := setJetStreamEkCipher(opts, mv, tk); err != nil {
+				return err
+			}
+		default:
+			if !tk.IsUsedVariable() {
+				err := &unknownConfigFieldErr{
+					field: mk,
+					configErr: configErr{
+						token: tk,
+					},
+				}
+				*errors = append(*errors, err)
+				continue
+			}
+		}
+	}
+	switch opts.JetStreamKMS.Provider {
+	case "file":
+		if opts.JetStreamKMS.KeysFile == _EMPTY_ {
+			return &configErr{tk, "JetStream KMS file provider requires a keys_file"}
+		}
+	case "vault":
+		if opts.JetStreamKMS.URL == _EMPTY_ {
+			return &configErr{tk, "JetStream KMS vault provider requires a url"}
+		}
+	default:
+		return &configErr{tk, fmt.Sprintf("Unknown JetStream KMS provider: %q", opts.JetStreamKMS.Provider)}
+	}
+	return nil
+}
+
+// Parse the JetStream stream connector options.
+func parseJetStreamConnectors(v any, opts *Options, errors *[]error) error {
+	var lt token
+	tk, v := unwrapValue(v, &lt)
+
+	opts.JetStreamConnectors = JSConnectorOpts{}
+
+	vv, ok := v.(map[string]any)
+	if !ok {
+		return &configErr{tk, fmt.Sprintf("Expected a map to define JetStream connector options, got %T", v)}
+	}
+	for mk, mv := range vv {
+		tk, mv = unwrapValue(mv, &lt)
+		switch strings.ToLower(mk) {
+		case "file_dir", "dir":
+			opts.JetStreamConnectors.FileDir = mv.(string)
+		case "allow_http":
+			opts.JetStreamConnectors.AllowHTTP = mv.(bool)
+		case "exec_commands", "exec":
+			switch cmds := mv.(type) {
+			case string:
+				opts.JetStreamConnectors.ExecCommands = []string{cmds}
+			case []any:
+				for _, c := range cmds {
+					_, c = unwrapValue(c, &lt)
+					cmd, ok := c.(string)
+					if !ok {
+						return &configErr{tk, fmt.Sprintf("Expected exec command to be a string, got %T", c)}
+					}
+					opts.JetStreamConnectors.ExecCommands = append(opts.JetStreamConnectors.ExecCommands, cmd)
+				}
+			default:
+				return &configErr{tk, fmt.Sprintf("Expected a list of exec commands, got %T", mv)}
+			}
+		default:
+			if !tk.IsUsedVariable() {
+				err := &unknownConfigFieldErr{
+					field: mk,
+					configErr: configErr{
+						token: tk,
+					},
+				}
+				*errors = append(*errors, err)
+				continue
+			}
+		}
+	}
+	return nil
+}
+
 func setJetStreamEkCipher(opts *Options, mv interface{}, tk token) error {
 	switch strings.ToLower(mv.(string)) {
 	case "chacha", "chachapoly":
@@ -2538,6 +2821,8 @@ func parseJetStream(v any, opts *Options, errors *[]error, warnings *[]error) er
 				if err := setJetStreamEkCipher(opts, mv, tk); err != nil {
 					return err
 				}
+			case "stream_keys", "stream_encryption_keys":
+				opts.JetStreamStreamKeys = mv.(bool)
 			case "extension_hint":
 				opts.JetStreamExtHint = mv.(string)
 			case "limits":
@@ -2548,6 +2833,14 @@ func parseJetStream(v any, opts *Options, errors *[]error, warnings *[]error) er
 				if err := parseJetStreamTPM(tk, opts, errors); err != nil {
 					return err
 				}
+			case "kms":
+				if err := parseJetStreamKMS(tk, opts, errors); err != nil {
+					return err
+				}
+			case "connectors":
+				if err := parseJetStreamConnectors(tk, opts, errors); err != nil {
+					return err
+				}
 			case "unique_tag":
 				opts.JetStreamUniqueTag = strings.ToLower(strings.TrimSpace(mv.(string)))
 			case "max_outstanding_catchup":
@@ -3109,6 +3402,15 @@ type export struct {
 	rthr time.Duration
 	tPos uint
 	atrc bool // allow_trace
+	pol  *servicePolicy
+}
+
+// Policies protecting a service export.
+type servicePolicy struct {
+	maxInflight int
+	timeout     time.Duration
+	cbFailures  int
+	cbReset     time.Duration
 }
 
 type importStream struct {
@@ -3185,6 +3487,18 @@ func parseAccountMapDest(v any, tk token, errors *[]error) (*MapDest, *configErr
 			}
 		case "cluster":
 			mdest.Cluster = dmv.(string)
+		case "headers", "header":
+			hm, ok := dmv.(map[string]any)
+			if !ok {
+				err := &configErr{tk, "Expected headers of a mapping destination to be a map"}
+				*errors = append(*errors, err)
+				return nil, err
+			}
+			mdest.Headers = make(map[string]string, len(hm))
+			for hk, hv := range hm {
+				_, hv = unwrapValue(hv, &lt)
+				mdest.Headers[hk] = fmt.Sprint(hv)
+			}
 		default:
 			err := &configErr{tk, fmt.Sprintf("Unknown field %q for mapping destination", k)}
 			*errors = append(*errors, err)
@@ -3192,7 +3506,8 @@ func parseAccountMapDest(v any, tk token, errors *[]error) (*MapDest, *configErr
 		}
 	}
 
-	if !sw {
+	// Destinations selected by headers take no weight.
+	if !sw && len(mdest.Headers) == 0 {
 		err := &configErr{tk, fmt.Sprintf("Missing weight for mapping destination %q", mdest.Subject)}
 		*errors = append(*errors, err)
 		return nil, err
@@ -3295,6 +3610,190 @@ func parseAccountLimits(mv any, acc *Account, errors *[]error) error {
 	return nil
 }
 
+// parseAccountQueuePolicies is called to parse the queue group policies of an account.
+// e.g.
+// {subject: "rpc.>", queue: "workers", policy: least_outstanding}
+// {subject: "orders.*", policy: consistent_hash, hash_token: 2}
+func parseAccountQueuePolicies(mv any, acc *Account, errors *[]error) error {
+	var lt token
+	defer convertPanicToErrorList(&lt, errors)
+
+	tk, v := unwrapValue(mv, &lt)
+	ql, ok := v.([]any)
+	if !ok {
+		return &configErr{tk, fmt.Sprintf("Expected queue policies to be an array, got %T", v)}
+	}
+	for _, qv := range ql {
+		tk, qv := unwrapValue(qv, &lt)
+		qm, ok := qv.(map[string]any)
+		if !ok {
+			*errors = append(*errors, &configErr{tk, fmt.Sprintf("Expected queue policy to be a map/struct, got %T", qv)})
+			continue
+		}
+		qp := &QueuePolicy{}
+		for k, v := range qm {
+			tk, v := unwrapValue(v, &lt)
+			switch strings.ToLower(k) {
+			case "subject":
+				qp.Subject = v.(string)
+			case "queue", "queue_group":
+				qp.Queue = v.(string)
+			case "policy", "type":
+				t, err := parseQueuePolicyType(v.(string))
+				if err != nil {
+					*errors = append(*errors, &configErr{tk, err.Error()})
+					continue
+				}
+				qp.Type = t
+			case "hash_header", "header":
+				qp.HashHeader = v.(string)
+			case "hash_token", "token":
+				qp.HashToken = int(v.(int64))
+			default:
+				if !tk.IsUsedVariable() {
+					err := &configErr{tk, fmt.Sprintf("Unknown field %q parsing queue policy", k)}
+					*errors = append(*errors, err)
+				}
+			}
+		}
+		if err := acc.AddQueuePolicy(qp); err != nil {
+			*errors = append(*errors, &configErr{tk, fmt.Sprintf("Error adding queue policy for %q: %v", qp.Subject, err)})
+		}
+	}
+	return nil
+}
+
+// Parses core NATS rate limits of an account or user.
+func parseRateLimits(mv any, errors *[]error) (*RateLimits, error) {
+	var lt token
+	defer convertPanicToErrorList(&lt, errors)
+
+	tk, v := unwrapValue(mv, &lt)
+	rm, ok := v.(map[string]any)
+	if !ok {
+		return nil, &configErr{tk, fmt.Sprintf("Expected rate limits to be a map/struct, got %+v", v)}
+	}
+
+	rl := &RateLimits{}
+	for k, v := range rm {
+		tk, mv = unwrapValue(v, &lt)
+		var n int64
+		var err error
+		switch strings.ToLower(k) {
+		case "msgs_in", "msgs_out", "bytes_in", "bytes_out":
+			if n, err = getStorageSize(mv); err != nil {
+				*errors = append(*errors, &configErr{tk, fmt.Sprintf("Invalid rate limit %q: %v", k, err)})
+				continue
+			}
+		}
+		switch strings.ToLower(k) {
+		case "msgs_in":
+			rl.MsgsIn = n
+		case "msgs_out":
+			rl.MsgsOut = n
+		case "bytes_in":
+			rl.BytesIn = n
+		case "bytes_out":
+			rl.BytesOut = n
+		case "action":
+			rl.Action = RateLimitAction(strings.ToLower(mv.(string)))
+		default:
+			if !tk.IsUsedVariable() {
+				err := &configErr{tk, fmt.Sprintf("Unknown field %q parsing rate limits", k)}
+				*errors = append(*errors, err)
+			}
+		}
+	}
+	if err := rl.validate(); err != nil {
+		return nil, &configErr{tk, err.Error()}
+	}
+	return rl, nil
+}
+
+// Parses the deduplication of the core NATS messages of an account.
+// e.g.
+// {subjects: ["orders.>"], window: "1m", max_msg_ids: 10000}
+func parseAccountDedupe(mv any, errors *[]error, warnings *[]error) (*DedupeOpts, error) {
+	var lt token
+	defer convertPanicToErrorList(&lt, errors)
+
+	tk, v := unwrapValue(mv, &lt)
+	dm, ok := v.(map[string]any)
+	if !ok {
+		return nil, &configErr{tk, fmt.Sprintf("Expected deduplication to be a map/struct, got %+v", v)}
+	}
+
+	do := &DedupeOpts{}
+	for k, v := range dm {
+		tk, mv = unwrapValue(v, &lt)
+		switch strings.ToLower(k) {
+		case "subjects", "subject":
+			subjects, err := parsePermSubjects(tk, errors)
+			if err != nil {
+				return nil, err
+			}
+			do.Subjects = subjects
+		case "window", "duplicate_window":
+			do.Window = parseDuration(k, tk, mv, errors, warnings)
+		case "max_msg_ids", "max_ids":
+			do.MaxMsgIds = int(mv.(int64))
+		default:
+			if !tk.IsUsedVariable() {
+				err := &configErr{tk, fmt.Sprintf("Unknown field %q parsing deduplication", k)}
+				*errors = append(*errors, err)
+			}
+		}
+	}
+	if err := do.validate(); err != nil {
+		return nil, &configErr{tk, err.Error()}
+	}
+	return do, nil
+}
+
+// Parses the last value cache of the core NATS subjects of an account.
+// e.g.
+// {subjects: ["telemetry.>"], max_subjects: 1000, max_bytes: 1MB}
+func parseAccountLastValueCache(mv any, errors *[]error) (*LastValueCacheOpts, error) {
+	var lt token
+	defer convertPanicToErrorList(&lt, errors)
+
+	tk, v := unwrapValue(mv, &lt)
+	lm, ok := v.(map[string]any)
+	if !ok {
+		return nil, &configErr{tk, fmt.Sprintf("Expected last value cache to be a map/struct, got %+v", v)}
+	}
+
+	lo := &LastValueCacheOpts{}
+	for k, v := range lm {
+		tk, mv = unwrapValue(v, &lt)
+		switch strings.ToLower(k) {
+		case "subjects", "subject":
+			subjects, err := parsePermSubjects(tk, errors)
+			if err != nil {
+				return nil, err
+			}
+			lo.Subjects = subjects
+		case "max_subjects":
+			lo.MaxSubjects = int(mv.(int64))
+		case "max_bytes":
+			s, err := getStorageSize(mv)
+			if err != nil {
+				return nil, &configErr{tk, fmt.Sprintf("last value cache %s %v", k, err)}
+			}
+			lo.MaxBytes = s
+		default:
+			if !tk.IsUsedVariable() {
+				err := &configErr{tk, fmt.Sprintf("Unknown field %q parsing last value cache", k)}
+				*errors = append(*errors, err)
+			}
+		}
+	}
+	if err := lo.validate(); err != nil {
+		return nil, &configErr{tk, err.Error()}
+	}
+	return lo, nil
+}
+
 func parseAccountMsgTrace(mv any, topKey string, acc *Account) error {
 	processDest := func(tk token, k string, v any) error {
 		td, ok := v.(string)
@@ -3487,6 +3986,32 @@ func parseAccounts(v any, opts *Options, errors *[]error, warnings *[]error) err
 						*errors = append(*errors, err)
 						continue
 					}
+				case "rate_limits":
+					rl, err := parseRateLimits(tk, errors)
+					if err != nil {
+						*errors = append(*errors, err)
+						continue
+					}
+					acc.rateLimits = rl
+				case "dedupe", "deduplication":
+					do, err := parseAccountDedupe(tk, errors, warnings)
+					if err != nil {
+						*errors = append(*errors, err)
+						continue
+					}
+					acc.dedupe = do
+				case "last_value_cache", "last_values":
+					lo, err := parseAccountLastValueCache(tk, errors)
+					if err != nil {
+						*errors = append(*errors, err)
+						continue
+					}
+					acc.lastValues = lo
+				case "queue_policies":
+					if err := parseAccountQueuePolicies(tk, acc, errors); err != nil {
+						*errors = append(*errors, err)
+						continue
+					}
 				case "msg_trace", "trace_dest":
 					if err := parseAccountMsgTrace(tk, k, acc); err != nil {
 						*errors = append(*errors, err)
@@ -3636,6 +4161,24 @@ func parseAccounts(v any, opts *Options, errors *[]error, warnings *[]error) err
 				continue
 			}
 		}
+
+		if pol := service.pol; pol != nil {
+			if err := service.acc.SetServiceExportMaxInflight(service.sub, pol.maxInflight); err != nil {
+				msg := fmt.Sprintf("Error adding service export max in-flight for %q: %v", service.sub, err)
+				*errors = append(*errors, &configErr{tk, msg})
+				continue
+			}
+			if err := service.acc.SetServiceExportTimeout(service.sub, pol.timeout); err != nil {
+				msg := fmt.Sprintf("Error adding service export timeout for %q: %v", service.sub, err)
+				*errors = append(*errors, &configErr{tk, msg})
+				continue
+			}
+			if err := service.acc.SetServiceExportCircuitBreaker(service.sub, pol.cbFailures, pol.cbReset); err != nil {
+				msg := fmt.Sprintf("Error adding service export circuit breaker for %q: %v", service.sub, err)
+				*errors = append(*errors, &configErr{tk, msg})
+				continue
+			}
+		}
 	}
 	for _, stream := range importStreams {
 		ta := am[stream.an]
@@ -3817,6 +4360,8 @@ func parseExportStreamOrService(v any, errors *[]error) (*export, *export, error
 		atrc       bool
 		atrcSeen   bool
 		atrcToken  token
+		pol        *servicePolicy
+		polToken   token
 	)
 	defer convertPanicToErrorList(&lt, errors)
 
@@ -3987,6 +4532,41 @@ func parseExportStreamOrService(v any, errors *[]error) (*export, *export, error
 			if curService != nil {
 				curService.atrc = atrc
 			}
+		case "max_inflight", "max_in_flight":
+			if pol == nil {
+				pol = &servicePolicy{}
+			}
+			polToken = tk
+			mi, ok := mv.(int64)
+			if !ok || mi < 0 {
+				*errors = append(*errors, &configErr{tk, fmt.Sprintf("Expected max in-flight to be a positive number, got %v", mv)})
+				continue
+			}
+			pol.maxInflight = int(mi)
+		case "timeout":
+			if pol == nil {
+				pol = &servicePolicy{}
+			}
+			polToken = tk
+			mvs, ok := mv.(string)
+			if !ok {
+				*errors = append(*errors, &configErr{tk, fmt.Sprintf("Expected timeout to be a parseable time duration, got %T", mv)})
+				continue
+			}
+			var err error
+			if pol.timeout, err = time.ParseDuration(mvs); err != nil {
+				*errors = append(*errors, &configErr{tk, fmt.Sprintf("Expected timeout to be a parseable time duration, got %q", mvs)})
+				continue
+			}
+		case "circuit_breaker":
+			if pol == nil {
+				pol = &servicePolicy{}
+			}
+			polToken = tk
+			if err := parseServiceCircuitBreaker(tk, mv, pol); err != nil {
+				*errors = append(*errors, err)
+				continue
+			}
 		default:
 			if !tk.IsUsedVariable() {
 				err := &unknownConfigFieldErr{
@@ -4004,10 +4584,56 @@ func parseExportStreamOrService(v any, errors *[]error) (*export, *export, error
 	}
 	if curService != nil {
 		curService.tPos = accTokPos
+		curService.pol = pol
+	}
+	if curStream != nil && polToken != nil {
+		*errors = append(*errors, &configErr{polToken, "Detected service policy directive on non-service"})
 	}
 	return curStream, curService, nil
 }
 
+// parseServiceCircuitBreaker parses the circuit breaker of a service export.
+// e.g.
+// circuit_breaker: {failures: 5, reset: "30s"}
+func parseServiceCircuitBreaker(root token, v any, pol *servicePolicy) (retErr error) {
+	var lt token
+	defer convertPanicToError(&lt, &retErr)
+
+	cb, ok := v.(map[string]any)
+	if !ok {
+		return &configErr{root, fmt.Sprintf("Expected circuit breaker to be a map, got %T", v)}
+	}
+	for k, v := range cb {
+		tk, v := unwrapValue(v, &lt)
+		switch strings.ToLower(k) {
+		case "failures", "max_failures":
+			n, ok := v.(int64)
+			if !ok || n < 1 {
+				return &configErr{tk, fmt.Sprintf("Expected circuit breaker failures to be a positive number, got %v", v)}
+			}
+			pol.cbFailures = int(n)
+		case "reset", "reset_timeout":
+			vs, ok := v.(string)
+			if !ok {
+				return &configErr{tk, fmt.Sprintf("Expected circuit breaker reset to be a parseable time duration, got %T", v)}
+			}
+			reset, err := time.ParseDuration(vs)
+			if err != nil || reset < 0 {
+				return &configErr{tk, fmt.Sprintf("Expected circuit breaker reset to be a parseable time duration, got %q", vs)}
+			}
+			pol.cbReset = reset
+		default:
+			if !tk.IsUsedVariable() {
+				return &unknownConfigFieldErr{field: k, configErr: configErr{token: tk}}
+			}
+		}
+	}
+	if pol.cbFailures == 0 {
+		return &configErr{root, "Circuit breaker requires failures to be set"}
+	}
+	return nil
+}
+
 // parseServiceLatency returns a latency config block.
 func parseServiceLatency(root token, v any) (l *serviceLatency, retErr error) {
 	var lt token
@@ -4388,6 +5014,14 @@ func parseUsers(mv any, errors *[]error) ([]*NkeyUser, []*User, error) {
 			case "proxy_required":
 				nkey.ProxyRequired = v.(bool)
 				user.ProxyRequired = v.(bool)
+			case "rate_limits":
+				rl, err := parseRateLimits(tk, errors)
+				if err != nil {
+					*errors = append(*errors, err)
+					continue
+				}
+				nkey.RateLimits = rl
+				user.RateLimits = rl
 			default:
 				if !tk.IsUsedVariable() {
 					err := &unknownConfigFieldErr{
@@ -4492,6 +5126,27 @@ func parseAuthCallout(mv any, errors *[]error) (*AuthCallout, error) {
 				_, uv = unwrapValue(uv, &lt)
 				ac.AllowedAccounts = append(ac.AllowedAccounts, uv.(string))
 			}
+		case "cache_ttl", "ttl":
+			ttl, err := time.ParseDuration(mv.(string))
+			if err != nil {
+				return nil, &configErr{tk, fmt.Sprintf("Error parsing callout %s: %v", k, err)}
+			}
+			ac.CacheTTL = ttl
+		case "cache_max_entries", "max_cache_entries":
+			ac.CacheMaxEntries = int(mv.(int64))
+		case "max_pending", "max_concurrent_requests":
+			ac.MaxPending = int(mv.(int64))
+		case "authorize_subjects", "subject_authorization":
+			ac.AuthorizeSubjects = mv.(bool)
+		case "failure_policy":
+			switch strings.ToLower(mv.(string)) {
+			case "open":
+				ac.FailOpen = true
+			case "closed":
+				ac.FailOpen = false
+			default:
+				return nil, &configErr{tk, fmt.Sprintf("Expected callout failure policy to be \"open\" or \"closed\", got %q", mv)}
+			}
 		default:
 			if !tk.IsUsedVariable() {
 				err := &configErr{tk, fmt.Sprintf("Unknown field %q parsing authorization callout", k)}
@@ -4510,6 +5165,9 @@ func parseAuthCallout(mv any, errors *[]error) (*AuthCallout, error) {
 	if len(ac.AuthUsers) == 0 {
 		return nil, &configErr{tk, "Authorization callouts require authorized users to be specified"}
 	}
+	if ac.CacheTTL < 0 || ac.CacheMaxEntries < 0 || ac.MaxPending < 0 {
+		return nil, &configErr{tk, "Authorization callout cache and pending limits can not be negative"}
+	}
 	return ac, nil
 }
 
@@ -4548,6 +5206,19 @@ func parseUserPermissions(mv any, errors *[]error) (*Permissions, error) {
 				continue
 			}
 			p.Subscribe = perms
+		case "queue", "queues", "queue_groups":
+			perms, err := parseVariablePermissions(mv, errors)
+			if err != nil {
+				*errors = append(*errors, err)
+				continue
+			}
+			if perms != nil {
+				if err := checkPermQueueArray(append(perms.Allow, perms.Deny...)); err != nil {
+					*errors = append(*errors, &configErr{tk, err.Error()})
+					continue
+				}
+			}
+			p.QueueGroups = perms
 		case "publish_allow_responses", "allow_responses":
 			rp := &ResponsePermission{
 				MaxMsgs: DEFAULT_ALLOW_RESPONSE_MAX_MSGS,
@@ -4745,6 +5416,26 @@ func checkPermSubjectArray(sa []string) error {
 	return nil
 }
 
+// Returns true if this is a valid permission subject, which can be
+// qualified by a queue group.
+func isValidPermSubject(s string) bool {
+	if IsValidSubject(s) {
+		return true
+	}
+	elements := strings.Fields(s)
+	return len(elements) == 2 && IsValidSubject(elements[0]) && IsValidSubject(elements[1])
+}
+
+// Helper function to validate queue group names in permissions.
+func checkPermQueueArray(qa []string) error {
+	for _, q := range qa {
+		if len(strings.Fields(q)) != 1 || !IsValidSubject(q) {
+			return fmt.Errorf("queue group %q is not valid", q)
+		}
+	}
+	return nil
+}
+
 // PrintTLSHelpAndDie prints TLS usage and exits.
 func PrintTLSHelpAndDie() {
 	fmt.Printf("%s", tlsUsage)
@@ -5926,8 +6617,9 @@ func ConfigureOptions(fs *flag.FlagSet, args []string, printVersion, printHelp,
 	fs.StringVar(&configFile, "c", _EMPTY_, "Configuration file.")
 	fs.StringVar(&configFile, "config", _EMPTY_, "Configuration file.")
 	fs.BoolVar(&opts.CheckConfig, "t", false, "Check configuration and exit.")
-	fs.StringVar(&signal, "sl", "", "Send signal to nats-server process (ldm, stop, quit, term, reopen, reload).")
-	fs.StringVar(&signal, "signal", "", "Send signal to nats-server process (ldm, stop, quit, term, reopen, reload).")
+	fs.StringVar(&signal, "sl", "", "Send signal to nats-server process (ldm, stop, quit, term, reopen, reload, upgrade).")
+	fs.StringVar(&signal, "signal", "", "Send signal to nats-server process (ldm, stop, quit, term, reopen, reload, upgrade).")
+	fs.StringVar(&opts.UpgradeSocket, "upgrade", _EMPTY_, "Unix socket to take over the listeners of the upgraded process, which can not have JetStream enabled.")
 	fs.StringVar(&opts.PidFile, "P", "", "File to store process pid.")
 	fs.StringVar(&opts.PidFile, "pid", "", "File to store process pid.")
 	fs.StringVar(&opts.PortsFileDir, "ports_file_dir", "", "Creates a ports file in the specified directory (<executable_name>_<pid>.ports).")
@@ -742,6 +742,41 @@ func TestAuthorizationConfig(t *testing.T) {
 
 // Test highly depends on contents of the config file listed below. Any changes to that file
 // may very well break this test.
+func TestQueueGroupPermissionsConfig(t *testing.T) {
+	conf := createConfFile(t, []byte(`
+	authorization {
+		users = [
+			{user: svc, password: pwd, permissions: {queue_groups: {allow: ["prod.workers", "dev.>"]}}}
+			{user: app, password: pwd, permissions: {subscribe: ">", queue_groups: {deny: "prod.>"}}}
+		]
+	}
+	`))
+	opts, err := ProcessConfigFile(conf)
+	require_NoError(t, err)
+	require_Len(t, len(opts.Users), 2)
+	for _, u := range opts.Users {
+		qp := u.Permissions.QueueGroups
+		require_True(t, qp != nil)
+		switch u.Username {
+		case "svc":
+			require_Equal(t, strings.Join(qp.Allow, ","), "prod.workers,dev.>")
+			require_Len(t, len(qp.Deny), 0)
+		case "app":
+			require_Len(t, len(qp.Allow), 0)
+			require_Equal(t, strings.Join(qp.Deny, ","), "prod.>")
+		}
+	}
+
+	conf = createConfFile(t, []byte(`
+	authorization {
+		users = [{user: svc, password: pwd, permissions: {queue_groups: {allow: ["foo bar"]}}}]
+	}
+	`))
+	_, err = ProcessConfigFile(conf)
+	require_Error(t, err)
+	require_Contains(t, err.Error(), "queue group \"foo bar\" is not valid")
+}
+
 func TestNewStyleAuthorizationConfig(t *testing.T) {
 	opts, err := ProcessConfigFile("./configs/new_style_authorization.conf")
 	if err != nil {
@@ -1882,6 +1917,27 @@ func TestParseServiceLatency(t *testing.T) {
 	}
 }
 
+func TestParseServiceLatencyWindows(t *testing.T) {
+	conf := createConfFile(t, []byte(`service_latency_windows: ["30s", "2m", "1h"]`))
+	opts, err := ProcessConfigFile(conf)
+	require_NoError(t, err)
+	require_True(t, reflect.DeepEqual(opts.ServiceLatencyWindows, []time.Duration{30 * time.Second, 2 * time.Minute, time.Hour}))
+
+	conf = createConfFile(t, []byte(`service_latency_windows: "10m"`))
+	opts, err = ProcessConfigFile(conf)
+	require_NoError(t, err)
+	require_True(t, reflect.DeepEqual(opts.ServiceLatencyWindows, []time.Duration{10 * time.Minute}))
+
+	for _, bad := range []string{
+		`service_latency_windows: ["1ms"]`,
+		`service_latency_windows: ["1x"]`,
+		`service_latency_windows: ["1m", "2m", "3m", "4m", "5m", "6m", "7m", "8m", "9m"]`,
+	} {
+		_, err = ProcessConfigFile(createConfFile(t, []byte(bad)))
+		require_Error(t, err)
+	}
+}
+
 func TestParseExport(t *testing.T) {
 	conf := `
 		port: -1
@@ -3106,6 +3162,56 @@ func TestReadOperatorAssertVersionFail(t *testing.T) {
 	}
 }
 
+func TestClusterRebalanceConfig(t *testing.T) {
+	conf := createConfFile(t, []byte(`
+	cluster {
+		name: "abc"
+		port: -1
+		rebalance {
+			interval: "30s"
+			threshold: "25%"
+			max_per_interval: 5
+			grace_period: "5s"
+		}
+	}
+	`))
+	opts, err := ProcessConfigFile(conf)
+	require_NoError(t, err)
+	require_Equal(t, opts.Cluster.Rebalance, RebalanceOpts{
+		Enabled:        true,
+		Interval:       30 * time.Second,
+		Threshold:      0.25,
+		MaxPerInterval: 5,
+		GracePeriod:    5 * time.Second,
+	})
+
+	conf = createConfFile(t, []byte(`cluster { name: "abc", port: -1, rebalance: true }`))
+	opts, err = ProcessConfigFile(conf)
+	require_NoError(t, err)
+	require_Equal(t, opts.Cluster.Rebalance, RebalanceOpts{Enabled: true})
+
+	for _, test := range []struct {
+		name string
+		cfg  string
+		err  string
+	}{
+		{"bad threshold", `rebalance: {threshold: "x"}`, "invalid threshold"},
+		{"unknown field", `rebalance: {bogus: 1}`, "unknown field"},
+		{"bad type", `rebalance: "yes"`, "should be a boolean or a structure"},
+		{"negative", `rebalance: {max_per_interval: -1}`, "can not be negative"},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			conf := createConfFile(t, []byte(fmt.Sprintf(`cluster { name: "abc", port: -1, %s }`, test.cfg)))
+			opts, err := ProcessConfigFile(conf)
+			if err == nil {
+				err = validateOptions(opts)
+			}
+			require_Error(t, err)
+			require_Contains(t, err.Error(), test.err)
+		})
+	}
+}
+
 func TestClusterNameAndGatewayNameConflict(t *testing.T) {
 	conf := createConfFile(t, []byte(`
 		listen: 127.0.0.1:-1
@@ -3921,3 +4027,32 @@ func TestOptionsProxyRequired(t *testing.T) {
 	require_NoError(t, err)
 	checkUsersAndNkeys(o.LeafNode.Users, false, nil)
 }
+
+func TestClientCompressionConfig(t *testing.T) {
+	for _, test := range []struct {
+		name     string
+		cfg      string
+		expected CompressionOpts
+	}{
+		{"mode", `compression: s2_best`, CompressionOpts{Mode: CompressionS2Best}},
+		{"enabled", `compression: true`, CompressionOpts{Mode: CompressionS2Auto, RTTThresholds: defaultCompressionS2AutoRTTThresholds}},
+		{"disabled", `compression: false`, CompressionOpts{Mode: CompressionOff}},
+		{"auto thresholds", `compression: {mode: auto, rtt_thresholds: ["20ms", "40ms"]}`,
+			CompressionOpts{Mode: CompressionS2Auto, RTTThresholds: []time.Duration{20 * time.Millisecond, 40 * time.Millisecond}}},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			conf := createConfFile(t, []byte("listen: 127.0.0.1:-1\n"+test.cfg))
+			s, o := RunServerWithConfig(conf)
+			defer s.Shutdown()
+			if !reflect.DeepEqual(o.Compression, test.expected) {
+				t.Fatalf("Expected compression %+v, got %+v", test.expected, o.Compression)
+			}
+		})
+	}
+
+	conf := createConfFile(t, []byte(`compression: "bad"`))
+	o, err := ProcessConfigFile(conf)
+	require_NoError(t, err)
+	_, err = NewServer(o)
+	require_Contains(t, err.Error(), "unsupported compression mode")
+}
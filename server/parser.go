@@ -495,7 +495,11 @@ func (c *client) parse(buf []byte) error {
 			}
 			// Check for mappings.
 			if (c.kind == CLIENT || c.kind == LEAF) && c.in.flags.isSet(hasMappings) {
-				changed := c.selectMappedSubject()
+				var hdr []byte
+				if c.pa.hdr > 0 {
+					hdr = c.msgBuf[:c.pa.hdr]
+				}
+				changed := c.selectMappedSubject(hdr)
 				if changed {
 					if trace {
 						c.traceInOp("MAPPING", []byte(fmt.Sprintf("%s -> %s", c.pa.mapped, c.pa.subject)))
@@ -950,6 +954,14 @@ func (c *client) parse(buf []byte) error {
 				c.mu.Lock()
 				authSet = c.awaitingAuth()
 				c.mu.Unlock()
+				// If compression was negotiated, the rest is compressed and
+				// will be parsed once decompressed by the readLoop.
+				if c.in.flags.isSet(switchToCompression) {
+					if i+1 < len(buf) {
+						c.in.cpre = append([]byte(nil), buf[i+1:]...)
+					}
+					return nil
+				}
 			default:
 				if c.argBuf != nil {
 					c.argBuf = append(c.argBuf, b)
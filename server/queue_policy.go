@@ -0,0 +1,475 @@
+// Copyright 2025 The NATS Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"math"
+	"slices"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/nats-io/nats-server/v2/internal/fastrand"
+)
+
+// QueuePolicyType determines how a member of a queue group is selected
+// to receive a message.
+type QueuePolicyType int
+
+const (
+	// QueueRandom selects a member at random, preferring local members.
+	// This is the default.
+	QueueRandom QueuePolicyType = iota
+	// QueueLeastOutstanding selects the local member with the least
+	// requests that it has not yet responded to.
+	QueueLeastOutstanding
+	// QueueWeighted selects a member at random in proportion to its
+	// weight, set in the SUB protocol. Members across the cluster are
+	// selected in proportion to the sum of their weights.
+	QueueWeighted
+	// QueueConsistentHash selects a member from the value of a header or
+	// of a subject token, so that messages with the same value go to the
+	// same member as long as the members of the group do not change.
+	QueueConsistentHash
+)
+
+// Maximum weight of a queue subscription.
+const maxQueueWeight = 1000
+
+// Time after which a request that was not responded to is no
+// longer considered outstanding.
+var queueOutstandingExpiration = DEFAULT_ALLOW_RESPONSE_EXPIRATION
+
+// String returns the string representation of a queue policy type.
+func (qt QueuePolicyType) String() string {
+	switch qt {
+	case QueueRandom:
+		return "random"
+	case QueueLeastOutstanding:
+		return "least_outstanding"
+	case QueueWeighted:
+		return "weighted"
+	case QueueConsistentHash:
+		return "consistent_hash"
+	default:
+		return "unknown queue policy"
+	}
+}
+
+// MarshalJSON marshals a queue policy type as a string.
+func (qt QueuePolicyType) MarshalJSON() ([]byte, error) {
+	return json.Marshal(qt.String())
+}
+
+// UnmarshalJSON unmarshals a queue policy type from a string.
+func (qt *QueuePolicyType) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+	t, err := parseQueuePolicyType(s)
+	if err != nil {
+		return err
+	}
+	*qt = t
+	return nil
+}
+
+func parseQueuePolicyType(s string) (QueuePolicyType, error) {
+	switch strings.ToLower(s) {
+	case "random":
+		return QueueRandom, nil
+	case "least_outstanding", "least_outstanding_requests":
+		return QueueLeastOutstanding, nil
+	case "weighted":
+		return QueueWeighted, nil
+	case "consistent_hash", "hash":
+		return QueueConsistentHash, nil
+	default:
+		return 0, fmt.Errorf("unknown queue policy %q", s)
+	}
+}
+
+// QueuePolicy determines how messages on a subject are distributed
+// to the members of a queue group.
+type QueuePolicy struct {
+	// Subject the policy applies to, can contain wildcards.
+	Subject string `json:"subject"`
+	// Queue group the policy applies to, all queue groups if empty.
+	Queue string          `json:"queue,omitempty"`
+	Type  QueuePolicyType `json:"policy"`
+	// For consistent hashing, the header whose value is hashed,
+	// or the position of the subject token that is hashed.
+	HashHeader string `json:"hash_header,omitempty"`
+	HashToken  int    `json:"hash_token,omitempty"`
+}
+
+func (qp *QueuePolicy) validate() error {
+	if !IsValidSubject(qp.Subject) {
+		return fmt.Errorf("queue policy subject %q is not valid", qp.Subject)
+	}
+	if strings.ContainsAny(qp.Queue, " \t\r\n") {
+		return fmt.Errorf("queue policy queue group %q is not valid", qp.Queue)
+	}
+	switch qp.Type {
+	case QueueRandom, QueueLeastOutstanding, QueueWeighted:
+		if qp.HashHeader != _EMPTY_ || qp.HashToken != 0 {
+			return fmt.Errorf("queue policy %q can not have a hash header or token", qp.Type)
+		}
+	case QueueConsistentHash:
+		if (qp.HashHeader == _EMPTY_) == (qp.HashToken == 0) {
+			return fmt.Errorf("queue policy %q requires either a hash header or token", qp.Type)
+		}
+		// The token must be in the subject, and not the full wildcard.
+		tokens := strings.Split(qp.Subject, tsep)
+		if qp.HashToken < 0 || qp.HashToken > len(tokens) || (qp.HashToken > 0 && tokens[qp.HashToken-1] == fwcs) {
+			return fmt.Errorf("queue policy hash token %d is out of range for subject %q", qp.HashToken, qp.Subject)
+		}
+	default:
+		return fmt.Errorf("unknown queue policy type %d", qp.Type)
+	}
+	return nil
+}
+
+// AddQueuePolicy sets how messages on a subject are distributed to the members
+// of a queue group, replacing the policy for the same subject and queue group.
+func (a *Account) AddQueuePolicy(qp *QueuePolicy) error {
+	if qp == nil {
+		return fmt.Errorf("queue policy can not be nil")
+	}
+	if err := qp.validate(); err != nil {
+		return err
+	}
+	ncp := *qp
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	var qps []*QueuePolicy
+	if cur := a.qpols.Load(); cur != nil {
+		qps = slices.DeleteFunc(slices.Clone(*cur), func(p *QueuePolicy) bool {
+			return p.Subject == qp.Subject && p.Queue == qp.Queue
+		})
+	}
+	qps = append(qps, &ncp)
+	a.qpols.Store(&qps)
+	return nil
+}
+
+// RemoveQueuePolicy removes the policy for the subject and queue group.
+// Returns false if there was none.
+func (a *Account) RemoveQueuePolicy(subject, queue string) bool {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	cur := a.qpols.Load()
+	if cur == nil {
+		return false
+	}
+	qps := slices.DeleteFunc(slices.Clone(*cur), func(p *QueuePolicy) bool {
+		return p.Subject == subject && p.Queue == queue
+	})
+	if len(qps) == len(*cur) {
+		return false
+	}
+	if len(qps) == 0 {
+		a.qpols.Store(nil)
+	} else {
+		a.qpols.Store(&qps)
+	}
+	return true
+}
+
+// QueuePolicies returns the queue policies of the account.
+func (a *Account) QueuePolicies() []*QueuePolicy {
+	cur := a.qpols.Load()
+	if cur == nil {
+		return nil
+	}
+	qps := make([]*QueuePolicy, 0, len(*cur))
+	for _, qp := range *cur {
+		cqp := *qp
+		qps = append(qps, &cqp)
+	}
+	return qps
+}
+
+// Returns the queue policy for a message subject and queue group, if any.
+// A policy for the specific queue group takes precedence over one for all
+// queue groups.
+func (a *Account) queuePolicy(subject string, queue []byte) *QueuePolicy {
+	cur := a.qpols.Load()
+	if cur == nil {
+		return nil
+	}
+	var match *QueuePolicy
+	for _, qp := range *cur {
+		if qp.Queue != _EMPTY_ && qp.Queue != bytesToString(queue) {
+			continue
+		}
+		if !matchLiteral(subject, qp.Subject) {
+			continue
+		}
+		if qp.Queue != _EMPTY_ {
+			return qp
+		}
+		if match == nil {
+			match = qp
+		}
+	}
+	return match
+}
+
+// Returns the weight of a local subscription, which is the interest it
+// accounts for towards routes, gateways and leafnodes.
+func (sub *subscription) weight() int32 {
+	if len(sub.queue) > 0 && sub.qw > 1 {
+		return sub.qw
+	}
+	return 1
+}
+
+// Returns the weight of a queue subscription for the weighted and hash
+// policies. Members that are only used when no other member is available,
+// like those across leafnodes, have no weight.
+func queueSubWeight(sub *subscription) int32 {
+	switch sub.client.kind {
+	case LEAF:
+		return 0
+	case ROUTER:
+		if len(sub.origin) > 0 {
+			return 0
+		}
+		return max(sub.qw, 1)
+	default:
+		return sub.weight()
+	}
+}
+
+// Returns the index of the queue subscription from which the delivery
+// should be attempted, based on the policy, or -1 to select at random.
+func (c *client) selectQueueSub(qp *QueuePolicy, qsubs []*subscription, subject, msg []byte) int {
+	switch qp.Type {
+	case QueueLeastOutstanding:
+		return c.selectLeastOutstandingQueueSub(qsubs)
+	case QueueWeighted:
+		return c.selectWeightedQueueSub(qsubs)
+	case QueueConsistentHash:
+		var key []byte
+		if qp.HashHeader != _EMPTY_ {
+			if c.pa.hdr > 0 && c.pa.hdr <= len(msg) {
+				key = sliceHeader(qp.HashHeader, msg[:c.pa.hdr])
+			}
+		} else {
+			key = subjectToken(subject, qp.HashToken)
+		}
+		if len(key) == 0 {
+			return -1
+		}
+		return c.selectHashedQueueSub(qsubs, key)
+	}
+	return -1
+}
+
+// Returns the local member with the least outstanding requests. Members
+// across routes or leafnodes are only selected when there is no local one.
+func (c *client) selectLeastOutstandingQueueSub(qsubs []*subscription) int {
+	lqs := len(qsubs)
+	if lqs == 0 {
+		return -1
+	}
+	sel, least := -1, int32(math.MaxInt32)
+	start := int(fastrand.Uint32() % uint32(lqs))
+	var now int64
+	for i := 0; i < lqs; i++ {
+		idx := (start + i) % lqs
+		sub := qsubs[idx]
+		if kind := sub.client.kind; kind == ROUTER || kind == LEAF {
+			continue
+		}
+		out := atomic.LoadInt32(&sub.out)
+		// Requests of a member that were not responded to in time no longer count.
+		if out > 0 {
+			if now == 0 {
+				now = time.Now().UnixNano()
+			}
+			if exp := sub.client.outexp.Load(); exp > 0 && exp <= now {
+				sub.client.mu.Lock()
+				sub.client.pruneOutstandingRequests()
+				sub.client.mu.Unlock()
+				out = atomic.LoadInt32(&sub.out)
+			}
+		}
+		if out < least {
+			sel, least = idx, out
+		}
+	}
+	return sel
+}
+
+// Returns a member selected at random in proportion to its weight.
+func (c *client) selectWeightedQueueSub(qsubs []*subscription) int {
+	var total uint32
+	for _, sub := range qsubs {
+		total += uint32(queueSubWeight(sub))
+	}
+	if total == 0 {
+		return -1
+	}
+	r := fastrand.Uint32() % total
+	for i, sub := range qsubs {
+		w := uint32(queueSubWeight(sub))
+		if r < w {
+			return i
+		}
+		r -= w
+	}
+	return -1
+}
+
+// Returns the member selected by weighted rendezvous hashing of the key.
+// Servers are selected first, using the weights of their members, such
+// that all servers in the cluster agree on the server for a key. Then
+// the member is selected among the ones of that server.
+func (c *client) selectHashedQueueSub(qsubs []*subscription, key []byte) int {
+	h := fnv.New64a()
+	score := func(id string, sid []byte, w int32) float64 {
+		h.Reset()
+		h.Write(key)
+		h.Write([]byte(id))
+		h.Write(sid)
+		// Scale to (0,1) to compute the weighted score.
+		u := (float64(h.Sum64()>>11) + 0.5) / (1 << 53)
+		return float64(w) / -math.Log(u)
+	}
+
+	var local int32
+	sel, best := -1, 0.0
+	for i, sub := range qsubs {
+		w := queueSubWeight(sub)
+		if w == 0 {
+			continue
+		}
+		if sub.client.kind == ROUTER {
+			if s := score(sub.client.route.remoteID, nil, w); s > best {
+				sel, best = i, s
+			}
+			continue
+		}
+		local += w
+	}
+	if local > 0 && score(c.srv.ID(), nil, local) > best {
+		sel, best = -1, 0
+		var _cid [8]byte
+		for i, sub := range qsubs {
+			if kind := sub.client.kind; kind == ROUTER || kind == LEAF {
+				continue
+			}
+			binary.BigEndian.PutUint64(_cid[:], sub.client.cid)
+			if s := score(string(_cid[:]), sub.sid, sub.weight()); s > best {
+				sel, best = i, s
+			}
+		}
+	}
+	return sel
+}
+
+// Returns the token of the subject at the given position, starting at 1.
+func subjectToken(subject []byte, pos int) []byte {
+	for i := 1; pos > 0; i++ {
+		idx := bytes.IndexByte(subject, btsep)
+		if i == pos {
+			if idx < 0 {
+				return subject
+			}
+			return subject[:idx]
+		}
+		if idx < 0 {
+			break
+		}
+		subject = subject[idx+1:]
+	}
+	return nil
+}
+
+// Records a request delivered to a member of a queue group with the least
+// outstanding requests policy, until the member responds to it.
+// Lock should be held.
+func (c *client) trackOutstandingRequest(sub *subscription, reply []byte) {
+	if c.outreqs == nil {
+		c.outreqs = make(map[string]*outstandingRequest)
+	}
+	if _, ok := c.outreqs[string(reply)]; ok {
+		return
+	}
+	or := &outstandingRequest{sub: sub, reply: string(reply), ts: time.Now().UnixNano()}
+	c.outreqs[or.reply] = or
+	c.outreqq = append(c.outreqq, or)
+	atomic.AddInt32(&sub.out, 1)
+	if len(c.outreqq) == 1 {
+		c.outexp.Store(or.ts + int64(queueOutstandingExpiration))
+	}
+	if len(c.outreqq) > replyPermLimit {
+		c.pruneOutstandingRequests()
+	}
+}
+
+// Called when the client publishes a message to check if this is the
+// response to an outstanding request.
+// Lock should be held.
+func (c *client) checkOutstandingRequest(subject []byte) {
+	if or := c.outreqs[string(subject)]; or != nil {
+		delete(c.outreqs, string(subject))
+		atomic.AddInt32(&or.sub.out, -1)
+		c.pruneOutstandingRequests()
+	}
+}
+
+// Removes the oldest requests that have been responded to, or have not
+// been responded to in a long time. Requests are kept in the order they
+// were delivered, so this stops at the first one still outstanding.
+// Lock should be held.
+func (c *client) pruneOutstandingRequests() {
+	mints := time.Now().UnixNano() - int64(queueOutstandingExpiration)
+	var n int
+	for _, or := range c.outreqq {
+		if c.outreqs[or.reply] == or {
+			if or.ts > mints {
+				break
+			}
+			delete(c.outreqs, or.reply)
+			atomic.AddInt32(&or.sub.out, -1)
+		}
+		n++
+	}
+	if n == 0 {
+		return
+	}
+	clear(c.outreqq[:n])
+	if c.outreqq = c.outreqq[n:]; len(c.outreqq) > 0 {
+		c.outexp.Store(c.outreqq[0].ts + int64(queueOutstandingExpiration))
+	} else {
+		c.outreqq = nil
+		c.outexp.Store(0)
+	}
+}
+
+// A request delivered to a queue subscription not yet responded to.
+type outstandingRequest struct {
+	sub   *subscription
+	reply string
+	ts    int64
+}
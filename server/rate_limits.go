@@ -0,0 +1,294 @@
+// Copyright 2025 The NATS Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/nats-io/jwt/v2"
+	"golang.org/x/time/rate"
+)
+
+// RateLimitAction is what happens to a client exceeding its rate limits.
+type RateLimitAction string
+
+const (
+	// RateLimitDrop drops the message and sends an -ERR to the publisher.
+	RateLimitDrop RateLimitAction = "drop"
+	// RateLimitStall delays the publisher until the message fits in the limits.
+	RateLimitStall RateLimitAction = "stall"
+	// RateLimitDisconnect closes the connection of the client.
+	RateLimitDisconnect RateLimitAction = "disconnect"
+)
+
+// RateLimits limit the core NATS message rates of an account or user, per second.
+// Inbound limits apply to messages published by clients, outbound limits to
+// messages delivered to them. Zero means unlimited.
+type RateLimits struct {
+	MsgsIn   int64           `json:"msgs_in,omitempty"`
+	BytesIn  int64           `json:"bytes_in,omitempty"`
+	MsgsOut  int64           `json:"msgs_out,omitempty"`
+	BytesOut int64           `json:"bytes_out,omitempty"`
+	Action   RateLimitAction `json:"action,omitempty"`
+}
+
+// RateLimitStats counts the messages that exceeded the rate limits of an account.
+type RateLimitStats struct {
+	InMsgs      int64 `json:"in_msgs"`
+	InBytes     int64 `json:"in_bytes"`
+	OutMsgs     int64 `json:"out_msgs"`
+	OutBytes    int64 `json:"out_bytes"`
+	Stalls      int64 `json:"stalls"`
+	Disconnects int64 `json:"disconnects"`
+}
+
+const (
+	// Longest a publisher is stalled for a single message, above that the message is dropped.
+	rateLimitMaxStall = 500 * time.Millisecond
+
+	errRateLimitExceeded = "Rate Limit Exceeded"
+
+	// Prefix of the JWT tags that set rate limits, e.g. "rate_msgs_in:1000".
+	rateLimitTagPrefix = "rate_"
+)
+
+func (rl *RateLimits) clone() *RateLimits {
+	if rl == nil {
+		return nil
+	}
+	clone := *rl
+	return &clone
+}
+
+func (rl *RateLimits) validate() error {
+	if rl.MsgsIn < 0 || rl.BytesIn < 0 || rl.MsgsOut < 0 || rl.BytesOut < 0 {
+		return fmt.Errorf("rate limits must not be negative")
+	}
+	switch rl.Action {
+	case _EMPTY_, RateLimitDrop, RateLimitStall, RateLimitDisconnect:
+	default:
+		return fmt.Errorf("unknown rate limit action %q", rl.Action)
+	}
+	return nil
+}
+
+// Enforces RateLimits with token buckets that allow a burst of one second.
+// Messages larger than the bytes per second limit are never allowed.
+type rateLimiter struct {
+	action   RateLimitAction
+	msgsIn   *rate.Limiter
+	bytesIn  *rate.Limiter
+	msgsOut  *rate.Limiter
+	bytesOut *rate.Limiter
+}
+
+// Returns a limiter for the rate limits, nil if they are unlimited.
+func newRateLimiter(rl *RateLimits) *rateLimiter {
+	if rl == nil || (rl.MsgsIn == 0 && rl.BytesIn == 0 && rl.MsgsOut == 0 && rl.BytesOut == 0) {
+		return nil
+	}
+	limiter := func(n int64) *rate.Limiter {
+		if n <= 0 {
+			return nil
+		}
+		return rate.NewLimiter(rate.Limit(n), int(n))
+	}
+	action := rl.Action
+	if action == _EMPTY_ {
+		action = RateLimitDrop
+	}
+	return &rateLimiter{
+		action:   action,
+		msgsIn:   limiter(rl.MsgsIn),
+		bytesIn:  limiter(rl.BytesIn),
+		msgsOut:  limiter(rl.MsgsOut),
+		bytesOut: limiter(rl.BytesOut),
+	}
+}
+
+// Reserves a message of the given size. Returns how long to wait before it
+// fits in the limits, and false if it does not fit or the wait is too long.
+func reserveRate(ml, bl *rate.Limiter, size int, wait bool) (time.Duration, bool) {
+	now := time.Now()
+	var delay time.Duration
+	var mr, br *rate.Reservation
+	if ml != nil {
+		if mr = ml.ReserveN(now, 1); !mr.OK() {
+			return 0, false
+		}
+		delay = mr.DelayFrom(now)
+	}
+	if bl != nil {
+		if br = bl.ReserveN(now, size); !br.OK() {
+			if mr != nil {
+				mr.CancelAt(now)
+			}
+			return 0, false
+		}
+		delay = max(delay, br.DelayFrom(now))
+	}
+	if delay == 0 || (wait && delay <= rateLimitMaxStall) {
+		return delay, true
+	}
+	if mr != nil {
+		mr.CancelAt(now)
+	}
+	if br != nil {
+		br.CancelAt(now)
+	}
+	return delay, false
+}
+
+// Parses rate limits from JWT tags, returning nil if there are none.
+func rateLimitsFromTags(tags jwt.TagList) (*RateLimits, error) {
+	var rl *RateLimits
+	for _, tag := range tags {
+		k, v, ok := strings.Cut(tag, ":")
+		if !ok || !strings.HasPrefix(k, rateLimitTagPrefix) {
+			continue
+		}
+		if rl == nil {
+			rl = &RateLimits{}
+		}
+		if k == "rate_action" {
+			rl.Action = RateLimitAction(v)
+			continue
+		}
+		n, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			if n, err = getStorageSize(strings.ToUpper(strings.TrimSuffix(strings.ToLower(v), "b"))); err != nil {
+				return nil, fmt.Errorf("invalid rate limit tag %q: %v", tag, err)
+			}
+		}
+		switch k {
+		case "rate_msgs_in":
+			rl.MsgsIn = n
+		case "rate_bytes_in":
+			rl.BytesIn = n
+		case "rate_msgs_out":
+			rl.MsgsOut = n
+		case "rate_bytes_out":
+			rl.BytesOut = n
+		default:
+			return nil, fmt.Errorf("unknown rate limit tag %q", tag)
+		}
+	}
+	if rl != nil {
+		if err := rl.validate(); err != nil {
+			return nil, err
+		}
+	}
+	return rl, nil
+}
+
+// Counters of the messages that exceeded the account's rate limits.
+type rateLimitCounters struct {
+	inMsgs      atomic.Int64
+	inBytes     atomic.Int64
+	outMsgs     atomic.Int64
+	outBytes    atomic.Int64
+	stalls      atomic.Int64
+	disconnects atomic.Int64
+}
+
+// Returns the rate limit counters, nil if the account never had rate limits.
+func (a *Account) rateLimitStats() *RateLimitStats {
+	c := &a.rlStats
+	st := &RateLimitStats{
+		InMsgs:      c.inMsgs.Load(),
+		InBytes:     c.inBytes.Load(),
+		OutMsgs:     c.outMsgs.Load(),
+		OutBytes:    c.outBytes.Load(),
+		Stalls:      c.stalls.Load(),
+		Disconnects: c.disconnects.Load(),
+	}
+	if *st == (RateLimitStats{}) && a.rlim.Load() == nil {
+		return nil
+	}
+	return st
+}
+
+// Checks the inbound rate limits of the client's user and account for a
+// published message. Stalls the client if needed, and returns false if the
+// message must be dropped.
+func (c *client) checkInboundRateLimits(acc *Account, size int) bool {
+	for _, rl := range [2]*rateLimiter{c.rlim.Load(), acc.rlim.Load()} {
+		if rl == nil {
+			continue
+		}
+		delay, ok := reserveRate(rl.msgsIn, rl.bytesIn, size, rl.action == RateLimitStall)
+		if ok {
+			if delay > 0 {
+				acc.rlStats.stalls.Add(1)
+				time.Sleep(delay)
+			}
+			continue
+		}
+		if rl.action == RateLimitDisconnect {
+			// Messages following the one that closed the connection
+			// may still be in the read buffer.
+			c.mu.Lock()
+			closed := c.isClosed()
+			c.mu.Unlock()
+			if closed {
+				return false
+			}
+		}
+		acc.rlStats.inMsgs.Add(1)
+		acc.rlStats.inBytes.Add(int64(size))
+		if rl.action == RateLimitDisconnect {
+			acc.rlStats.disconnects.Add(1)
+			c.sendErrAndErr(errRateLimitExceeded)
+			c.closeConnection(RateLimitExceeded)
+		} else {
+			c.sendErr(errRateLimitExceeded)
+		}
+		return false
+	}
+	return true
+}
+
+// Checks the outbound rate limits of the client's user and account for a
+// message delivered to it. Stalling does not apply to deliveries, so the
+// message is dropped instead.
+// Lock should be held.
+func (c *client) checkOutboundRateLimits(size int) bool {
+	acc := c.acc
+	if acc == nil {
+		return true
+	}
+	for _, rl := range [2]*rateLimiter{c.rlim.Load(), acc.rlim.Load()} {
+		if rl == nil || (rl.msgsOut == nil && rl.bytesOut == nil) {
+			continue
+		}
+		if _, ok := reserveRate(rl.msgsOut, rl.bytesOut, size, false); ok {
+			continue
+		}
+		if rl.action == RateLimitDisconnect && c.isClosed() {
+			return false
+		}
+		acc.rlStats.outMsgs.Add(1)
+		acc.rlStats.outBytes.Add(int64(size))
+		if rl.action == RateLimitDisconnect {
+			acc.rlStats.disconnects.Add(1)
+			c.markConnAsClosed(RateLimitExceeded)
+		}
+		return false
+	}
+	return true
+}
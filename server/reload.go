@@ -659,6 +659,30 @@ func (m *maxPayloadOption) Apply(server *Server) {
 	server.Noticef("Reloaded: max_payload = %d", m.newValue)
 }
 
+// compressionOption implements the option interface for the `compression`
+// setting of client connections.
+type compressionOption struct {
+	noopOption
+	newValue CompressionOpts
+}
+
+// Apply the setting by updating the compression mode offered to new clients.
+// Existing clients keep the compression mode that they negotiated.
+func (c *compressionOption) Apply(server *Server) {
+	mode := c.newValue.Mode
+	if !needsCompression(mode) {
+		mode = CompressionOff
+	}
+	server.mu.Lock()
+	if mode == CompressionOff {
+		server.info.Compression = _EMPTY_
+	} else {
+		server.info.Compression = mode
+	}
+	server.mu.Unlock()
+	server.Noticef("Reloaded: compression = %s", mode)
+}
+
 // pingIntervalOption implements the option interface for the `ping_interval`
 // setting.
 type pingIntervalOption struct {
@@ -1251,6 +1275,8 @@ func imposeOrder(value any) error {
 		slices.SortFunc(value, func(i, j *url.URL) int { return cmp.Compare(i.String(), j.String()) })
 	case []string:
 		slices.Sort(value)
+	case []time.Duration:
+		slices.Sort(value)
 	case []*jwt.OperatorClaims:
 		slices.SortFunc(value, func(i, j *jwt.OperatorClaims) int { return cmp.Compare(i.Issuer, j.Issuer) })
 	case GatewayOpts:
@@ -1259,10 +1285,13 @@ func imposeOrder(value any) error {
 		slices.Sort(value.AllowedOrigins)
 	case string, bool, uint8, uint16, int, int32, int64, time.Duration, float64, nil, LeafNodeOpts, ClusterOpts, *tls.Config, PinnedCertSet,
 		*URLAccResolver, *MemAccResolver, *DirAccResolver, *CacheDirAccResolver, Authentication, MQTTOpts, jwt.TagList,
-		*OCSPConfig, map[string]string, JSLimitOpts, StoreCipher, *OCSPResponseCacheConfig, *ProxiesConfig:
+		*OCSPConfig, map[string]string, JSLimitOpts, StoreCipher, *OCSPResponseCacheConfig, *ProxiesConfig, CompressionOpts:
 		// explicitly skipped types
 	case *AuthCallout:
 	case JSTpmOpts:
+	case JSKMSOpts:
+	case JSConnectorOpts:
+		slices.Sort(value.ExecCommands)
 	default:
 		// this will fail during unit tests
 		return fmt.Errorf("OnReload, sort or explicitly skip type: %s",
@@ -1407,6 +1436,16 @@ func (s *Server) diffOptions(newOpts *Options) ([]option, error) {
 			diffOpts = append(diffOpts, &maxControlLineOption{newValue: newValue.(int32)})
 		case "maxpayload":
 			diffOpts = append(diffOpts, &maxPayloadOption{newValue: newValue.(int32)})
+		case "compression":
+			oldCompression, newCompression := oldValue.(CompressionOpts), newValue.(CompressionOpts)
+			if newCompression.Mode != _EMPTY_ {
+				if err := validateAndNormalizeCompressionOption(&newCompression, CompressionS2Auto); err != nil {
+					return nil, err
+				}
+			}
+			if !compressOptsEqual(&oldCompression, &newCompression) {
+				diffOpts = append(diffOpts, &compressionOption{newValue: newCompression})
+			}
 		case "pinginterval":
 			diffOpts = append(diffOpts, &pingIntervalOption{newValue: newValue.(time.Duration)})
 		case "maxpingsout":
@@ -14,6 +14,7 @@
 package server
 
 import (
+	"bufio"
 	"bytes"
 	"crypto/tls"
 	"encoding/base64"
@@ -2667,6 +2668,54 @@ func TestConfigReloadClusterPermsOldServer(t *testing.T) {
 	check(t)
 }
 
+func TestConfigReloadQueueGroupPermissions(t *testing.T) {
+	template := `
+	listen: "127.0.0.1:-1"
+	authorization {
+		users = [{user: svc, password: pwd, permissions: {queue_groups: {allow: %s}}}]
+	}
+	`
+	conf := createConfFile(t, []byte(fmt.Sprintf(template, `["prod.>", "dev.>"]`)))
+	s, _ := RunServerWithConfig(conf)
+	defer s.Shutdown()
+
+	errCh := make(chan error, 10)
+	nc := natsConnect(t, s.ClientURL(), nats.UserInfo("svc", "pwd"),
+		nats.ErrorHandler(func(_ *nats.Conn, _ *nats.Subscription, err error) {
+			errCh <- err
+		}))
+	defer nc.Close()
+
+	natsQueueSubSync(t, nc, "foo", "prod.workers")
+	natsQueueSubSync(t, nc, "foo", "dev.workers")
+	natsSubSync(t, nc, "foo")
+	natsQueueSubSync(t, nc, "foo", "other")
+	natsFlush(t, nc)
+	select {
+	case err := <-errCh:
+		require_Contains(t, err.Error(), `using queue "other"`)
+	case <-time.After(time.Second):
+		t.Fatal("Expected a permissions violation")
+	}
+	numSubs := s.NumSubscriptions()
+
+	// The queue subscription that is no longer allowed is removed.
+	changeCurrentConfigContentWithNewContent(t, conf, []byte(fmt.Sprintf(template, `"dev.>"`)))
+	require_NoError(t, s.Reload())
+	select {
+	case err := <-errCh:
+		require_Contains(t, err.Error(), "foo")
+	case <-time.After(time.Second):
+		t.Fatal("Expected a permissions violation")
+	}
+	checkFor(t, time.Second, 15*time.Millisecond, func() error {
+		if n := s.NumSubscriptions(); n != numSubs-1 {
+			return fmt.Errorf("Expected %d subscriptions, got %d", numSubs-1, n)
+		}
+		return nil
+	})
+}
+
 func TestConfigReloadAccountUsers(t *testing.T) {
 	conf := createConfFile(t, []byte(`
 	listen: "127.0.0.1:-1"
@@ -6392,3 +6441,40 @@ func TestConfigReloadNoPanicOnShutdown(t *testing.T) {
 		wg.Wait()
 	}
 }
+
+func TestConfigReloadClientCompression(t *testing.T) {
+	conf := createConfFile(t, []byte(`
+	listen: "127.0.0.1:-1"
+	compression: s2_fast
+	`))
+	s, _ := RunServerWithConfig(conf)
+	defer s.Shutdown()
+
+	checkInfo := func(expected string) {
+		t.Helper()
+		conn, err := net.Dial("tcp", s.Addr().String())
+		require_NoError(t, err)
+		defer conn.Close()
+		conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+		line, err := bufio.NewReader(conn).ReadString('\n')
+		require_NoError(t, err)
+		var info Info
+		require_NoError(t, json.Unmarshal([]byte(strings.TrimPrefix(line, "INFO ")), &info))
+		require_Equal(t, info.Compression, expected)
+	}
+	checkInfo(CompressionS2Fast)
+
+	changeCurrentConfigContentWithNewContent(t, conf, []byte(`
+	listen: "127.0.0.1:-1"
+	compression: off
+	`))
+	require_NoError(t, s.Reload())
+	checkInfo(_EMPTY_)
+
+	changeCurrentConfigContentWithNewContent(t, conf, []byte(`
+	listen: "127.0.0.1:-1"
+	compression: auto
+	`))
+	require_NoError(t, s.Reload())
+	checkInfo(CompressionS2Auto)
+}
@@ -479,7 +479,7 @@ func (c *client) processInboundRoutedMsg(msg []byte) {
 		return
 	}
 
-	acc, r := c.getAccAndResultFromCache()
+	acc, r := c.getAccAndResultFromCache(msg)
 	if acc == nil {
 		c.Debugf("Unknown account %q for routed message on subject: %q", c.pa.account, c.pa.subject)
 		return
@@ -2706,7 +2706,7 @@ func (s *Server) startRouteAcceptLoop() {
 	}
 
 	hp := net.JoinHostPort(opts.Cluster.Host, strconv.Itoa(port))
-	l, e := natsListen("tcp", hp)
+	l, e := s.listen(routeListenerName, hp, true)
 	s.routeListenerErr = e
 	if e != nil {
 		s.mu.Unlock()
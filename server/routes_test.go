@@ -20,6 +20,7 @@ import (
 	"crypto/tls"
 	"encoding/json"
 	"fmt"
+	"io"
 	"math/rand"
 	"net"
 	"net/http"
@@ -1386,6 +1387,115 @@ func TestRouteClusterNameConflictBetweenStaticAndDynamic(t *testing.T) {
 	checkClusterFormed(t, s1, s2)
 }
 
+func TestRouteQueuePolicies(t *testing.T) {
+	o1 := DefaultOptions()
+	s1 := RunServer(o1)
+	defer s1.Shutdown()
+
+	o2 := DefaultOptions()
+	o2.Routes = RoutesFromStr(fmt.Sprintf("nats://127.0.0.1:%d", o1.Cluster.Port))
+	s2 := RunServer(o2)
+	defer s2.Shutdown()
+
+	checkClusterFormed(t, s1, s2)
+
+	for _, s := range []*Server{s1, s2} {
+		gacc := s.GlobalAccount()
+		require_NoError(t, gacc.AddQueuePolicy(&QueuePolicy{Subject: "work", Type: QueueWeighted}))
+		require_NoError(t, gacc.AddQueuePolicy(&QueuePolicy{Subject: "key.*", Type: QueueConsistentHash, HashToken: 2}))
+	}
+
+	// Members with weights sent in the SUB protocol.
+	newMember := func(s *Server, subs string) *testAsyncClient {
+		t.Helper()
+		c, cr, _ := newClientForServer(s)
+		go io.Copy(io.Discard, cr)
+		require_NoError(t, c.parse([]byte("CONNECT {}\r\n"+subs)))
+		return c
+	}
+	c1 := newMember(s1, "SUB work workers 1 3\r\nSUB work workers 2 5\r\nSUB key.* workers 3\r\nSUB key.* workers 4\r\n")
+	defer c1.close()
+
+	// The other server knows of the sum of the weights.
+	checkRoutedQueueWeight := func(s *Server, subj string, expected int32) {
+		t.Helper()
+		checkFor(t, 2*time.Second, 15*time.Millisecond, func() error {
+			r := s.GlobalAccount().sl.Match(subj)
+			for _, qsubs := range r.qsubs {
+				for _, qsub := range qsubs {
+					if qsub.client.kind != ROUTER {
+						continue
+					}
+					if qw := atomic.LoadInt32(&qsub.qw); qw != expected {
+						return fmt.Errorf("Expected queue weight of %d, got %d", expected, qw)
+					}
+					return nil
+				}
+			}
+			return fmt.Errorf("No routed queue sub for %q", subj)
+		})
+	}
+	checkRoutedQueueWeight(s2, "work", 8)
+	checkRoutedQueueWeight(s2, "key.x", 2)
+
+	// The weight is removed with the subscription.
+	require_NoError(t, c1.parse([]byte("UNSUB 2\r\n")))
+	checkRoutedQueueWeight(s2, "work", 3)
+
+	c2 := newMember(s2, "SUB key.* workers 3\r\nSUB key.* workers 4\r\n")
+	defer c2.close()
+	checkRoutedQueueWeight(s1, "key.x", 2)
+
+	// Messages with the same key go to the same member, whichever
+	// server they are published to.
+	nc1 := natsConnect(t, s1.ClientURL())
+	defer nc1.Close()
+	nc2 := natsConnect(t, s2.ClientURL())
+	defer nc2.Close()
+
+	// Returns the number of messages received by each member.
+	received := func() map[string]int64 {
+		m := make(map[string]int64)
+		for i, c := range []*testAsyncClient{c1, c2} {
+			c.mu.Lock()
+			for _, sid := range []string{"3", "4"} {
+				m[fmt.Sprintf("s%d-%s", i+1, sid)] = c.subs[sid].nm
+			}
+			c.mu.Unlock()
+		}
+		return m
+	}
+	// Returns the member that received the message.
+	receiver := func(before map[string]int64) string {
+		t.Helper()
+		var r string
+		checkFor(t, time.Second, 5*time.Millisecond, func() error {
+			for k, n := range received() {
+				if n != before[k] {
+					r = k
+					return nil
+				}
+			}
+			return fmt.Errorf("Message not received")
+		})
+		return r
+	}
+	used := map[string]struct{}{}
+	for i := range 20 {
+		subj := fmt.Sprintf("key.%d", i)
+		before := received()
+		natsPub(t, nc1, subj, []byte("msg"))
+		r := receiver(before)
+		before = received()
+		natsPub(t, nc2, subj, []byte("msg"))
+		require_Equal(t, receiver(before), r)
+		used[r] = struct{}{}
+	}
+	if len(used) < 3 {
+		t.Fatalf("Expected keys to be spread across members, got %v", used)
+	}
+}
+
 type testRouteResolver struct{}
 
 func (r *testRouteResolver) LookupHost(ctx context.Context, host string) ([]string, error) {
@@ -3798,6 +3908,47 @@ func TestRoutePoolBadAuthNoRunawayCreateRoute(t *testing.T) {
 	checkClusterFormed(t, s1, s2)
 }
 
+func TestRouteClientRebalance(t *testing.T) {
+	orgHBInterval := eventsHBInterval
+	eventsHBInterval = 100 * time.Millisecond
+	defer func() { eventsHBInterval = orgHBInterval }()
+
+	o1 := DefaultOptions()
+	o1.Cluster.Rebalance = RebalanceOpts{
+		Enabled:        true,
+		Interval:       50 * time.Millisecond,
+		MaxPerInterval: 2,
+		GracePeriod:    100 * time.Millisecond,
+	}
+	s1 := RunServer(o1)
+	defer s1.Shutdown()
+
+	o2 := DefaultOptions()
+	o2.Routes = RoutesFromStr(fmt.Sprintf("nats://127.0.0.1:%d", o1.Cluster.Port))
+	s2 := RunServer(o2)
+	defer s2.Shutdown()
+
+	checkClusterFormed(t, s1, s2)
+
+	// All clients connect to the first server.
+	for range 10 {
+		nc := natsConnect(t, s1.ClientURL(), nats.MaxReconnects(-1), nats.ReconnectWait(10*time.Millisecond))
+		defer nc.Close()
+	}
+
+	checkFor(t, 10*time.Second, 50*time.Millisecond, func() error {
+		n1, n2 := s1.NumClients(), s2.NumClients()
+		if n1+n2 != 10 || n1 > 6 || n2 < 4 {
+			return fmt.Errorf("Clients not rebalanced: %d - %d", n1, n2)
+		}
+		return nil
+	})
+	// Connections are not moved back and forth once balanced.
+	time.Sleep(500 * time.Millisecond)
+	n1, n2 := s1.NumClients(), s2.NumClients()
+	require_True(t, n1+n2 == 10 && n1 <= 6 && n2 >= 4)
+}
+
 func TestRouteCompressionOptions(t *testing.T) {
 	org := testDefaultClusterCompression
 	testDefaultClusterCompression = _EMPTY_
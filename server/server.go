@@ -176,6 +176,7 @@ type Server struct {
 	kp                  nkeys.KeyPair
 	xkp                 nkeys.KeyPair
 	xpub                string
+	acCache             *authCalloutCache
 	info                Info
 	configFile          string
 	optsMu              sync.RWMutex
@@ -188,6 +189,7 @@ type Server struct {
 	sys                 *internal
 	sysAcc              atomic.Pointer[Account]
 	js                  atomic.Pointer[jetStream]
+	jsKMS               atomic.Pointer[jsKMS]
 	isMetaLeader        atomic.Bool
 	jsClustered         atomic.Bool
 	accounts            sync.Map
@@ -277,6 +279,9 @@ type Server struct {
 	ldm   bool
 	ldmCh chan bool
 
+	// Listener handoff on binary upgrade.
+	upgrade upgradeState
+
 	// Trusted public operator keys.
 	trustedKeys []string
 	// map of trusted keys to operator setting StrictSigningKeyUsage
@@ -404,6 +409,7 @@ type stats struct {
 	slowConsumers    int64
 	staleConnections int64
 	stalls           int64
+	expiredMsgs      int64
 }
 
 // scStats includes the total and per connection counters of Slow Consumers.
@@ -758,6 +764,9 @@ func NewServer(opts *Options) (*Server, error) {
 	if tlsReq && !info.TLSRequired {
 		info.TLSAvailable = true
 	}
+	if needsCompression(opts.Compression.Mode) {
+		info.Compression = opts.Compression.Mode
+	}
 
 	now := time.Now()
 
@@ -779,6 +788,7 @@ func NewServer(opts *Options) (*Server, error) {
 		rateLimitLoggingCh: make(chan time.Duration, 1),
 		leafNodeEnabled:    opts.LeafNode.Port != 0 || len(opts.LeafNode.Remotes) > 0,
 		syncOutSem:         make(chan struct{}, maxConcurrentSyncRequests),
+		acCache:            newAuthCalloutCache(opts.AuthCallout),
 	}
 
 	// Delayed API response queue. Create regardless if JetStream is configured
@@ -1138,6 +1148,11 @@ func validateOptions(o *Options) error {
 	if o.ServerName != _EMPTY_ && strings.Contains(o.ServerName, " ") {
 		return errors.New("server name cannot contain spaces")
 	}
+	if o.Compression.Mode != _EMPTY_ {
+		if err := validateAndNormalizeCompressionOption(&o.Compression, CompressionS2Auto); err != nil {
+			return err
+		}
+	}
 	// Check that the trust configuration is correct.
 	if err := validateTrustedOperators(o); err != nil {
 		return err
@@ -1170,6 +1185,12 @@ func validateOptions(o *Options) error {
 	if err := validateJetStreamOptions(o); err != nil {
 		return err
 	}
+	if err := validateServiceLatencyWindows(o.ServiceLatencyWindows); err != nil {
+		return err
+	}
+	if err := validateRebalanceOptions(o); err != nil {
+		return err
+	}
 	// Finally check websocket options.
 	return validateWebsocketOptions(o)
 }
@@ -2279,6 +2300,11 @@ func (s *Server) Start() {
 
 	s.startRateLimitLogExpiration()
 
+	// Rebalancing of client connections across the cluster.
+	if opts.Cluster.Port != 0 {
+		s.startClientRebalancer()
+	}
+
 	// Pprof http endpoint for the profiler.
 	if opts.ProfPort != 0 {
 		s.StartProfiler()
@@ -2314,6 +2340,9 @@ func (s *Server) Start() {
 	if hasOperators && opts.SystemAccount == _EMPTY_ {
 		s.Warnf("Trusted Operators should utilize a System Account")
 	}
+	if len(opts.TrustedKeys) > 0 && opts.AuthCallout != nil && opts.AuthCallout.AuthorizeSubjects {
+		s.Warnf("Subject authorization by the auth callout service is not supported in operator mode and is disabled")
+	}
 	if opts.MaxPayload > MAX_PAYLOAD_MAX_SIZE {
 		s.Warnf("Maximum payloads over %v are generally discouraged and could lead to poor performance",
 			friendlyBytes(int64(MAX_PAYLOAD_MAX_SIZE)))
@@ -2329,6 +2358,14 @@ func (s *Server) Start() {
 		s.checkResolvePreloads()
 	}
 
+	// Take over the listeners of the process that is being upgraded.
+	if opts.UpgradeSocket != _EMPTY_ {
+		if err := s.receiveListeners(opts.UpgradeSocket); err != nil {
+			s.Fatalf("Can't take over listeners from %q: %v", opts.UpgradeSocket, err)
+			return
+		}
+	}
+
 	// Log the pid to a file.
 	if opts.PidFile != _EMPTY_ {
 		if err := s.logPid(); err != nil {
@@ -2536,6 +2573,11 @@ func (s *Server) Start() {
 
 	// Bring OSCP Response cache online after accept loop started in anticipation of NATS-enabled cache types
 	s.startOCSPResponseCache()
+
+	// Let the upgraded process know once we accept connections.
+	if opts.UpgradeSocket != _EMPTY_ {
+		s.startGoRoutine(s.completeUpgrade)
+	}
 }
 
 func (s *Server) isShuttingDown() bool {
@@ -2819,13 +2861,13 @@ func (s *Server) AcceptLoop(clr chan struct{}) {
 // getServerListener returns a network listener for the given host-port address.
 // If the Server already has an active listener (s.listener), it returns that listener
 // along with any previous error (s.listenerErr). Otherwise, it creates and returns
-// a new TCP listener on the specified address using natsListen.
+// a new TCP listener on the specified address, or the one inherited on a binary upgrade.
 func (s *Server) getServerListener(hp string) (net.Listener, error) {
 	if s.listener != nil {
 		return s.listener, s.listenerErr
 	}
 
-	return natsListen("tcp", hp)
+	return s.listen(clientListenerName, hp, true)
 }
 
 // InProcessConn returns an in-process connection to the server,
@@ -3006,6 +3048,8 @@ const (
 	HealthzPath      = "/healthz"
 	IPQueuesPath     = "/ipqueuesz"
 	RaftzPath        = "/raftz"
+	LatencyzPath     = "/latencyz"
+	DrainzPath       = "/drainz"
 )
 
 func (s *Server) basePath(p string) string {
@@ -3074,15 +3118,16 @@ func (s *Server) startMonitoring(secure bool) error {
 			config.GetConfigForClient = s.getMonitoringTLSConfig
 			config.ClientAuth = tls.NoClientCert
 		}
-		httpListener, err = tls.Listen("tcp", hp, config)
-
+		if httpListener, err = s.listen(monitorListenerName, hp, false); err == nil {
+			httpListener = tls.NewListener(httpListener, config)
+		}
 	} else {
 		port = opts.HTTPPort
 		if port == -1 {
 			port = 0
 		}
 		hp = net.JoinHostPort(opts.HTTPHost, strconv.Itoa(port))
-		httpListener, err = net.Listen("tcp", hp)
+		httpListener, err = s.listen(monitorListenerName, hp, false)
 	}
 
 	if err != nil {
@@ -3124,6 +3169,10 @@ func (s *Server) startMonitoring(secure bool) error {
 	mux.HandleFunc(s.basePath(IPQueuesPath), s.HandleIPQueuesz)
 	// Raftz
 	mux.HandleFunc(s.basePath(RaftzPath), s.HandleRaftz)
+	// Latencyz
+	mux.HandleFunc(s.basePath(LatencyzPath), s.HandleLatencyz)
+	// Drainz
+	mux.HandleFunc(s.basePath(DrainzPath), s.HandleDrainz)
 
 	// Do not set a WriteTimeout because it could cause cURL/browser
 	// to return empty response or unable to display page if the
@@ -3272,6 +3321,9 @@ func (s *Server) createClientEx(conn net.Conn, inProcess bool) *client {
 	// Initialize
 	c.initClient()
 
+	// Compression mode offered to the client, negotiated on CONNECT.
+	c.compression = info.Compression
+
 	c.Debugf("Client connection created")
 
 	// Save info.TLSRequired value since we may neeed to change it back and forth.
@@ -0,0 +1,271 @@
+// Copyright 2025 The NATS Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"cmp"
+	"fmt"
+	"math"
+	"slices"
+	"sync"
+	"time"
+)
+
+// ServiceLatencyStats holds the latency percentiles of a service export,
+// as seen by an importing account, over a rolling window.
+type ServiceLatencyStats struct {
+	Window time.Duration `json:"window"`
+	Count  uint64        `json:"count"`
+	Errors uint64        `json:"errors"`
+	P50    time.Duration `json:"p50"`
+	P90    time.Duration `json:"p90"`
+	P99    time.Duration `json:"p99"`
+	Max    time.Duration `json:"max"`
+}
+
+// ServiceLatencyHistogram holds the rolling latency statistics of a service
+// export for one of the accounts importing it.
+type ServiceLatencyHistogram struct {
+	Account  string                 `json:"account"`
+	Service  string                 `json:"service"`
+	Importer string                 `json:"importer"`
+	Windows  []*ServiceLatencyStats `json:"windows"`
+}
+
+const (
+	// Buckets grow by a quarter power of two starting at 1µs,
+	// which covers up to roughly 12 days.
+	latencyHistBuckets = 160
+	// Number of slots each rolling window is divided into.
+	latencyHistSlots = 12
+	// Maximum number of windows that can be configured.
+	maxServiceLatencyWindows = 8
+)
+
+// Windows used when none are configured.
+var defaultServiceLatencyWindows = []time.Duration{time.Minute, 5 * time.Minute, 15 * time.Minute}
+
+// Identifies the histogram of a service export for an importing account.
+type serviceLatencyKey struct {
+	service  string
+	importer string
+}
+
+type latencySlot struct {
+	start   int64 // unix nanoseconds
+	count   uint64
+	errors  uint64
+	max     time.Duration
+	buckets [latencyHistBuckets]uint32
+}
+
+type latencyWindow struct {
+	window time.Duration
+	slots  [latencyHistSlots]latencySlot
+}
+
+// Rolling latency histogram of a service export for an importing account.
+// Measurements are those of service latency tracking, so are subject to
+// the sampling of the export.
+type latencyHistogram struct {
+	mu      sync.Mutex
+	windows []*latencyWindow
+}
+
+func newLatencyHistogram(windows []time.Duration) *latencyHistogram {
+	if len(windows) == 0 {
+		windows = defaultServiceLatencyWindows
+	}
+	h := &latencyHistogram{windows: make([]*latencyWindow, 0, len(windows))}
+	for _, w := range windows {
+		h.windows = append(h.windows, &latencyWindow{window: w})
+	}
+	return h
+}
+
+func validateServiceLatencyWindows(windows []time.Duration) error {
+	if len(windows) > maxServiceLatencyWindows {
+		return fmt.Errorf("at most %d service latency windows can be configured", maxServiceLatencyWindows)
+	}
+	for _, w := range windows {
+		if w < latencyHistSlots*time.Millisecond {
+			return fmt.Errorf("service latency window %v is too small", w)
+		}
+	}
+	return nil
+}
+
+// Returns the bucket of a latency. Bucket b holds latencies
+// up to 2^(b/4) microseconds.
+func latencyBucket(d time.Duration) int {
+	us := float64(d) / float64(time.Microsecond)
+	if us <= 1 {
+		return 0
+	}
+	return min(int(math.Ceil(4*math.Log2(us))), latencyHistBuckets-1)
+}
+
+// Returns the largest latency held by a bucket.
+func latencyBucketBound(b int) time.Duration {
+	return time.Duration(math.Exp2(float64(b)/4) * float64(time.Microsecond))
+}
+
+// Returns the slot of the window for the given time, resetting it if stale.
+func (w *latencyWindow) slot(now int64) *latencySlot {
+	sd := int64(w.window / latencyHistSlots)
+	start := now - now%sd
+	slot := &w.slots[(now/sd)%latencyHistSlots]
+	if slot.start != start {
+		*slot = latencySlot{start: start}
+	}
+	return slot
+}
+
+func (h *latencyHistogram) record(now time.Time, d time.Duration, failed bool) {
+	ts := now.UnixNano()
+	b := latencyBucket(d)
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for _, w := range h.windows {
+		slot := w.slot(ts)
+		if failed {
+			slot.errors++
+			continue
+		}
+		slot.count++
+		slot.buckets[b]++
+		slot.max = max(slot.max, d)
+	}
+}
+
+// Returns the statistics of all windows, and false if there
+// was no measurement in any of them.
+func (h *latencyHistogram) stats(now time.Time) ([]*ServiceLatencyStats, bool) {
+	ts := now.UnixNano()
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	var active bool
+	stats := make([]*ServiceLatencyStats, 0, len(h.windows))
+	for _, w := range h.windows {
+		st := &ServiceLatencyStats{Window: w.window}
+		var buckets [latencyHistBuckets]uint64
+		for i := range w.slots {
+			slot := &w.slots[i]
+			if slot.start == 0 || ts-slot.start >= int64(w.window) {
+				continue
+			}
+			st.Count += slot.count
+			st.Errors += slot.errors
+			st.Max = max(st.Max, slot.max)
+			for b, n := range slot.buckets {
+				buckets[b] += uint64(n)
+			}
+		}
+		if st.Count > 0 {
+			st.P50 = percentile(&buckets, st.Count, 0.50, st.Max)
+			st.P90 = percentile(&buckets, st.Count, 0.90, st.Max)
+			st.P99 = percentile(&buckets, st.Count, 0.99, st.Max)
+		}
+		if st.Count > 0 || st.Errors > 0 {
+			active = true
+		}
+		stats = append(stats, st)
+	}
+	return stats, active
+}
+
+// Returns the upper bound of the bucket holding the given percentile,
+// never more than the largest latency measured.
+func percentile(buckets *[latencyHistBuckets]uint64, count uint64, p float64, maxLatency time.Duration) time.Duration {
+	rank := uint64(math.Ceil(p * float64(count)))
+	var seen uint64
+	for b, n := range buckets {
+		if seen += n; seen >= rank {
+			return min(latencyBucketBound(b), maxLatency)
+		}
+	}
+	return maxLatency
+}
+
+// Records a service latency measurement of a service export of this account
+// for the importing account.
+func (a *Account) recordServiceLatency(se *serviceExport, importer string, sl *ServiceLatency) {
+	if se == nil || importer == _EMPTY_ {
+		return
+	}
+	a.mu.RLock()
+	var service string
+	for subj, ea := range a.exports.services {
+		if ea == se {
+			service = subj
+			break
+		}
+	}
+	s := a.srv
+	a.mu.RUnlock()
+	if service == _EMPTY_ {
+		return
+	}
+
+	key := serviceLatencyKey{service, importer}
+	a.lhmu.Lock()
+	h := a.lhists[key]
+	if h == nil {
+		var windows []time.Duration
+		if s != nil {
+			windows = s.getOpts().ServiceLatencyWindows
+		}
+		h = newLatencyHistogram(windows)
+		if a.lhists == nil {
+			a.lhists = make(map[serviceLatencyKey]*latencyHistogram)
+		}
+		a.lhists[key] = h
+	}
+	a.lhmu.Unlock()
+
+	h.record(time.Now(), sl.TotalLatency, sl.Status != 200)
+}
+
+// Returns the latency histograms of the service exports of this account,
+// optionally for a single export. Histograms without measurements in any
+// of their windows are removed.
+func (a *Account) serviceLatencyHistograms(service string) []*ServiceLatencyHistogram {
+	now := time.Now()
+	a.lhmu.Lock()
+	defer a.lhmu.Unlock()
+	var hists []*ServiceLatencyHistogram
+	for key, h := range a.lhists {
+		stats, active := h.stats(now)
+		if !active {
+			delete(a.lhists, key)
+			continue
+		}
+		if service != _EMPTY_ && key.service != service {
+			continue
+		}
+		hists = append(hists, &ServiceLatencyHistogram{
+			Account:  a.Name,
+			Service:  key.service,
+			Importer: key.importer,
+			Windows:  stats,
+		})
+	}
+	slices.SortFunc(hists, func(i, j *ServiceLatencyHistogram) int {
+		if c := cmp.Compare(i.Service, j.Service); c != 0 {
+			return c
+		}
+		return cmp.Compare(i.Importer, j.Importer)
+	})
+	return hists
+}
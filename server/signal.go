@@ -40,7 +40,7 @@ func (s *Server) handleSignals() {
 	}
 	c := make(chan os.Signal, 1)
 
-	signal.Notify(c, syscall.SIGINT, syscall.SIGTERM, syscall.SIGUSR1, syscall.SIGUSR2, syscall.SIGHUP)
+	signal.Notify(c, syscall.SIGINT, syscall.SIGTERM, syscall.SIGUSR1, syscall.SIGUSR2, syscall.SIGHUP, syscall.SIGTTIN)
 
 	go func() {
 		for {
@@ -73,6 +73,13 @@ func (s *Server) handleSignals() {
 					if err := s.Reload(); err != nil {
 						s.Errorf("Failed to reload server configuration: %s", err)
 					}
+				case syscall.SIGTTIN:
+					// Binary upgrade.
+					go func() {
+						if err := s.upgradeBinary(); err != nil {
+							s.Errorf("Failed to upgrade server: %v", err)
+						}
+					}()
 				}
 			case <-s.quitCh:
 				return
@@ -156,6 +163,8 @@ func CommandToSignal(command Command) (syscall.Signal, error) {
 		return syscall.SIGUSR2, nil
 	case commandTerm:
 		return syscall.SIGTERM, nil
+	case commandUpgrade:
+		return syscall.SIGTTIN, nil
 	default:
 		return 0, fmt.Errorf("unknown signal %q", command)
 	}
@@ -18,9 +18,11 @@ package server
 import (
 	"errors"
 	"fmt"
+	"net"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"reflect"
 	"strings"
 	"syscall"
 	"testing"
@@ -513,3 +515,140 @@ func TestSignalTermHasSuccessfulExit(t *testing.T) {
 	err := cmd.Run()
 	require_NoError(t, err)
 }
+
+func TestProcessSignalUpgrade(t *testing.T) {
+	killBefore := kill
+	called := false
+	kill = func(pid int, signal syscall.Signal) error {
+		called = true
+		if pid != 123 {
+			t.Fatalf("pid is incorrect.\nexpected: 123\ngot: %d", pid)
+		}
+		if signal != syscall.SIGTTIN {
+			t.Fatalf("signal is incorrect.\nexpected: sigttin\ngot: %v", signal)
+		}
+		return nil
+	}
+	defer func() {
+		kill = killBefore
+	}()
+
+	if err := ProcessSignal(commandUpgrade, "123"); err != nil {
+		t.Fatalf("ProcessSignal failed: %v", err)
+	}
+
+	if !called {
+		t.Fatal("Expected kill to be called")
+	}
+}
+
+func TestUpgradeArgs(t *testing.T) {
+	for _, test := range []struct {
+		args     []string
+		expected []string
+	}{
+		{[]string{"-c", "nats.conf"}, []string{"--upgrade", "new.sock", "-c", "nats.conf"}},
+		{[]string{"--upgrade", "old.sock", "-c", "nats.conf"}, []string{"--upgrade", "new.sock", "-c", "nats.conf"}},
+		{[]string{"-c", "nats.conf", "-upgrade=old.sock"}, []string{"--upgrade", "new.sock", "-c", "nats.conf"}},
+	} {
+		if args := upgradeArgs(test.args, "new.sock"); !reflect.DeepEqual(args, test.expected) {
+			t.Fatalf("Expected args %q, got %q", test.expected, args)
+		}
+	}
+}
+
+func TestUpgradeListenerHandoff(t *testing.T) {
+	o1 := DefaultOptions()
+	o1.LameDuckDuration = time.Second
+	o1.LameDuckGracePeriod = -100 * time.Millisecond
+	s1 := RunServer(o1)
+	defer s1.Shutdown()
+
+	nc := natsConnect(t, s1.ClientURL())
+	defer nc.Close()
+
+	path := filepath.Join(t.TempDir(), "upgrade.sock")
+	ln, err := net.ListenUnix("unix", &net.UnixAddr{Name: path, Net: "unix"})
+	require_NoError(t, err)
+	defer ln.Close()
+
+	errCh := make(chan error, 1)
+	go func() { errCh <- s1.handoffListeners(ln) }()
+
+	o2 := DefaultOptions()
+	o2.Port = s1.Addr().(*net.TCPAddr).Port
+	o2.UpgradeSocket = path
+	s2 := RunServer(o2)
+	defer s2.Shutdown()
+
+	select {
+	case err := <-errCh:
+		require_NoError(t, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("Handoff did not complete")
+	}
+	require_Equal(t, s2.Addr().String(), s1.Addr().String())
+	require_Equal(t, s2.MonitorAddr().String(), s1.MonitorAddr().String())
+	require_Equal(t, s2.ClusterAddr().String(), s1.ClusterAddr().String())
+
+	go s1.lameDuckMode()
+
+	// Once the old server stops accepting, new clients connect to the new one.
+	checkFor(t, 2*time.Second, 50*time.Millisecond, func() error {
+		nc2, err := nats.Connect(s2.ClientURL())
+		if err != nil {
+			return err
+		}
+		defer nc2.Close()
+		if id := nc2.ConnectedServerId(); id != s2.ID() {
+			return fmt.Errorf("connected to %q, not the new server", id)
+		}
+		return nil
+	})
+
+	// The client of the old server is asked to reconnect and lands on the new one.
+	checkFor(t, 5*time.Second, 50*time.Millisecond, func() error {
+		if id := nc.ConnectedServerId(); id != s2.ID() {
+			return fmt.Errorf("client still connected to %q", id)
+		}
+		return nil
+	})
+}
+
+func TestUpgradeInheritedListenerPortChanged(t *testing.T) {
+	s1 := RunServer(DefaultOptions())
+	defer s1.Shutdown()
+
+	path := filepath.Join(t.TempDir(), "upgrade.sock")
+	ln, err := net.ListenUnix("unix", &net.UnixAddr{Name: path, Net: "unix"})
+	require_NoError(t, err)
+	defer ln.Close()
+
+	errCh := make(chan error, 1)
+	go func() { errCh <- s1.handoffListeners(ln) }()
+
+	// The client port is not the one of the upgraded server.
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require_NoError(t, err)
+	port := l.Addr().(*net.TCPAddr).Port
+	l.Close()
+
+	o2 := DefaultOptions()
+	o2.Port = port
+	o2.UpgradeSocket = path
+	s2 := RunServer(o2)
+	defer s2.Shutdown()
+
+	require_NoError(t, <-errCh)
+	require_Equal(t, s2.Addr().(*net.TCPAddr).Port, port)
+	require_Equal(t, s2.ClusterAddr().String(), s1.ClusterAddr().String())
+}
+
+func TestUpgradeRefusedWithJetStream(t *testing.T) {
+	s := RunBasicJetStreamServer(t)
+	defer s.Shutdown()
+
+	err := s.upgradeBinary()
+	require_Error(t, err)
+	require_Contains(t, err.Error(), "JetStream")
+}
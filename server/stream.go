@@ -34,6 +34,7 @@ import (
 	"time"
 
 	"github.com/klauspost/compress/s2"
+	"github.com/klauspost/compress/zstd"
 	"github.com/nats-io/nats-server/v2/server/gsl"
 	"github.com/nats-io/nuid"
 )
@@ -119,6 +120,16 @@ type StreamConfig struct {
 	// AllowMsgSchedules allows the scheduling of messages.
 	AllowMsgSchedules bool `json:"allow_msg_schedules,omitempty"`
 
+	// Compaction enables log compaction by subject, keeping the full history
+	// for recent messages and only the last message per subject for older ones.
+	Compaction *StreamCompaction `json:"compaction,omitempty"`
+
+	// CompressionOpts holds additional settings for the compression algorithm.
+	CompressionOpts *StreamCompressionOpts `json:"compression_opts,omitempty"`
+
+	// Connectors deliver the stream's messages to external sinks.
+	Connectors []*StreamConnector `json:"connectors,omitempty"`
+
 	// Metadata is additional metadata for the Stream.
 	Metadata map[string]string `json:"metadata,omitempty"`
 }
@@ -150,6 +161,21 @@ func (cfg *StreamConfig) clone() *StreamConfig {
 		rePublish := *cfg.RePublish
 		clone.RePublish = &rePublish
 	}
+	if cfg.Compaction != nil {
+		compaction := *cfg.Compaction
+		clone.Compaction = &compaction
+	}
+	if cfg.CompressionOpts != nil {
+		opts := *cfg.CompressionOpts
+		opts.Dictionary = slices.Clone(cfg.CompressionOpts.Dictionary)
+		clone.CompressionOpts = &opts
+	}
+	if len(cfg.Connectors) > 0 {
+		clone.Connectors = make([]*StreamConnector, len(cfg.Connectors))
+		for i, sc := range cfg.Connectors {
+			clone.Connectors[i] = sc.clone()
+		}
+	}
 	if cfg.Metadata != nil {
 		clone.Metadata = make(map[string]string, len(cfg.Metadata))
 		for k, v := range cfg.Metadata {
@@ -164,6 +190,55 @@ type StreamConsumerLimits struct {
 	MaxAckPending     int           `json:"max_ack_pending,omitempty"`
 }
 
+// StreamCompressionOpts holds the settings for Zstd compression of a stream.
+type StreamCompressionOpts struct {
+	// Level is the Zstd compression level, from 1 to 22. Zero selects the default level.
+	Level int `json:"level,omitempty"`
+	// Dictionary is an optional Zstd dictionary, trained on representative messages.
+	// It can not be changed once the stream has been created.
+	Dictionary []byte `json:"dictionary,omitempty"`
+}
+
+// StreamCompressionInfo reports the compression achieved by a stream's store.
+type StreamCompressionInfo struct {
+	Algorithm   StoreCompression `json:"algorithm"`
+	Bytes       uint64           `json:"bytes"`
+	StoredBytes uint64           `json:"stored_bytes"`
+	Ratio       float64          `json:"ratio"`
+}
+
+// StreamCompaction configures log compaction by subject for a stream.
+type StreamCompaction struct {
+	// Lag is the age after which messages are compacted to the last message per subject.
+	Lag time.Duration `json:"lag"`
+	// DeleteRetention is how long tombstones are kept after they become eligible for compaction.
+	DeleteRetention time.Duration `json:"delete_retention,omitempty"`
+}
+
+const (
+	// Minimum and maximum interval between background compaction passes.
+	minCompactionInterval = 250 * time.Millisecond
+	maxCompactionInterval = time.Minute
+)
+
+// interval returns how often the background compaction pass should run.
+func (cc *StreamCompaction) interval() time.Duration {
+	return min(max(cc.Lag/2, minCompactionInterval), maxCompactionInterval)
+}
+
+// isCompactionTombstone returns whether the headers indicate this message is a
+// tombstone, meaning a KV delete or purge marker, or a subject delete marker.
+func isCompactionTombstone(hdr []byte) bool {
+	if len(hdr) == 0 {
+		return false
+	}
+	if len(sliceHeader(JSMarkerReason, hdr)) > 0 {
+		return true
+	}
+	op := sliceHeader(KVOperation, hdr)
+	return bytes.Equal(op, KVOperationValueDelete) || bytes.Equal(op, KVOperationValuePurge)
+}
+
 // SubjectTransformConfig is for applying a subject transform (to matching messages) before doing anything else when a new message is received
 type SubjectTransformConfig struct {
 	Source      string `json:"src"`
@@ -224,6 +299,10 @@ type StreamInfo struct {
 	Mirror     *StreamSourceInfo   `json:"mirror,omitempty"`
 	Sources    []*StreamSourceInfo `json:"sources,omitempty"`
 	Alternates []StreamAlternate   `json:"alternates,omitempty"`
+	// Compression reports the compression achieved for file based streams.
+	Compression *StreamCompressionInfo `json:"compression,omitempty"`
+	// Connectors reports the progress of the stream's connectors.
+	Connectors []*StreamConnectorInfo `json:"connectors,omitempty"`
 	// TimeStamp indicates when the info was gathered
 	TimeStamp time.Time `json:"ts"`
 }
@@ -233,6 +312,7 @@ type StreamInfo struct {
 type streamInfoClusterResponse struct {
 	StreamInfo
 	OfflineReason string `json:"offline_reason,omitempty"` // Reporting when a stream is offline.
+	StreamKey     bool   `json:"stream_key,omitempty"`     // The stream has an encryption key of its own.
 }
 
 type StreamAlternate struct {
@@ -375,6 +455,9 @@ type stream struct {
 	sourcesConsumerSetup *time.Timer
 	smsgs                *ipQueue[*inMsg] // Intra-process queue for all incoming sourced messages.
 
+	// Running connectors, only on the leader.
+	connectors map[string]*streamConnector
+
 	// Indicates we have direct consumers.
 	directs int
 
@@ -507,8 +590,9 @@ const (
 
 // Headers for published KV messages.
 var (
-	KVOperation           = "KV-Operation"
-	KVOperationValuePurge = []byte("PURGE")
+	KVOperation            = "KV-Operation"
+	KVOperationValuePurge  = []byte("PURGE")
+	KVOperationValueDelete = []byte("DEL")
 )
 
 // Headers for scheduled messages.
@@ -826,6 +910,9 @@ func (a *Account) addStreamWithAssignment(config *StreamConfig, fsConfig *FileSt
 	fsCfg.SyncInterval = s.getOpts().SyncInterval
 	fsCfg.SyncAlways = s.getOpts().SyncAlways
 	fsCfg.Compression = config.Compression
+	if co := config.CompressionOpts; co != nil {
+		fsCfg.CompressionLevel, fsCfg.CompressionDict = co.Level, co.Dictionary
+	}
 	// Async flushing is only allowed if the stream has a sync log backing it.
 	fsCfg.AsyncFlush = !fsCfg.SyncAlways && config.Replicas > 1
 
@@ -1603,6 +1690,43 @@ func (s *Server) checkStreamCfg(config *StreamConfig, acc *Account, pedantic boo
 		}
 	}
 
+	if co := cfg.CompressionOpts; co != nil {
+		// A dictionary is kept when moving away from zstd, since it is
+		// still needed to read back the blocks that were compressed with it.
+		if cfg.Compression != ZstdCompression && co.Level != 0 {
+			return StreamConfig{}, NewJSStreamInvalidConfigError(fmt.Errorf("compression level requires zstd compression"))
+		}
+		if co.Level < 0 || co.Level > 22 {
+			return StreamConfig{}, NewJSStreamInvalidConfigError(fmt.Errorf("zstd compression level must be between 1 and 22"))
+		}
+		if len(co.Dictionary) > 0 {
+			if _, err := zstd.InspectDictionary(co.Dictionary); err != nil {
+				return StreamConfig{}, NewJSStreamInvalidConfigError(fmt.Errorf("invalid zstd dictionary: %w", err))
+			}
+		}
+	}
+
+	if cc := cfg.Compaction; cc != nil {
+		if cc.Lag < 0 {
+			return StreamConfig{}, NewJSStreamInvalidConfigError(fmt.Errorf("compaction lag must not be negative"))
+		}
+		if cc.DeleteRetention < 0 {
+			return StreamConfig{}, NewJSStreamInvalidConfigError(fmt.Errorf("compaction delete retention must not be negative"))
+		}
+		if cfg.Retention != LimitsPolicy {
+			return StreamConfig{}, NewJSStreamInvalidConfigError(fmt.Errorf("compaction can only use limits retention"))
+		}
+		if cfg.AllowMsgCounter {
+			return StreamConfig{}, NewJSStreamInvalidConfigError(fmt.Errorf("counter stream cannot use compaction"))
+		}
+	}
+
+	if len(cfg.Connectors) > 0 {
+		if err := s.checkStreamConnectors(&cfg); err != nil {
+			return StreamConfig{}, NewJSStreamInvalidConfigError(err)
+		}
+	}
+
 	getStream := func(streamName string) (bool, StreamConfig) {
 		var exists bool
 		var cfg StreamConfig
@@ -2000,6 +2124,25 @@ func (mset *stream) fileStoreConfig() (FileStoreConfig, error) {
 	return fs.fileStoreConfig(), nil
 }
 
+// Returns the compression achieved by the store, if compressed.
+func (mset *stream) compressionInfo() *StreamCompressionInfo {
+	mset.mu.RLock()
+	cfg, store := mset.cfg, mset.store
+	mset.mu.RUnlock()
+	if store == nil || cfg.Storage != FileStorage || cfg.Compression == NoCompression {
+		return nil
+	}
+	stored, bytes, err := store.Utilization()
+	if err != nil {
+		return nil
+	}
+	ci := &StreamCompressionInfo{Algorithm: cfg.Compression, Bytes: bytes, StoredBytes: stored}
+	if stored > 0 {
+		ci.Ratio = float64(bytes) / float64(stored)
+	}
+	return ci
+}
+
 // Do not hold jsAccount or jetStream lock
 func (jsa *jsAccount) configUpdateCheck(old, new *StreamConfig, s *Server, pedantic bool) (*StreamConfig, error) {
 	cfg, apiErr := s.checkStreamCfg(new, jsa.acc(), pedantic)
@@ -2019,6 +2162,17 @@ func (jsa *jsAccount) configUpdateCheck(old, new *StreamConfig, s *Server, pedan
 	if cfg.Storage != old.Storage {
 		return nil, NewJSStreamInvalidConfigError(fmt.Errorf("stream configuration update can not change storage type"))
 	}
+	// Blocks already compressed with a dictionary can only be read back with it.
+	var oldDict, newDict []byte
+	if old.CompressionOpts != nil {
+		oldDict = old.CompressionOpts.Dictionary
+	}
+	if cfg.CompressionOpts != nil {
+		newDict = cfg.CompressionOpts.Dictionary
+	}
+	if !bytes.Equal(oldDict, newDict) {
+		return nil, NewJSStreamInvalidConfigError(fmt.Errorf("stream configuration update can not change compression dictionary"))
+	}
 	// Can only change retention from limits to interest or back, not to/from work queue for now.
 	if cfg.Retention != old.Retention {
 		if old.Retention == WorkQueuePolicy || cfg.Retention == WorkQueuePolicy {
@@ -2410,6 +2564,11 @@ func (mset *stream) updateWithAdvisory(config *StreamConfig, sendAdvisory bool,
 	mset.cfg = *cfg
 	mset.cfgMu.Unlock()
 
+	// Connectors only run while the stream is active.
+	if mset.active && !reflect.DeepEqual(ocfg.Connectors, cfg.Connectors) {
+		mset.updateConnectors(ocfg.Connectors, cfg.Connectors)
+	}
+
 	// If we're changing retention and haven't errored because of consumer
 	// replicas by now, whip through and update the consumer retention.
 	if ocfg.Retention != cfg.Retention {
@@ -2584,6 +2743,62 @@ func (mset *stream) eraseMsg(seq uint64) (bool, error) {
 	return removed, err
 }
 
+// eraseMsgs will securely erase all messages matching the subject filter and
+// headers of the request, overwriting their data with random data. Only messages
+// up to lastSeq are considered, so nothing is erased if it is 0. Returns the number
+// of erased messages.
+func (mset *stream) eraseMsgs(req *JSApiStreamEraseRequest, lastSeq uint64) (uint64, error) {
+	if mset.closed.Load() {
+		return 0, errStreamClosed
+	}
+	mset.mu.RLock()
+	store := mset.store
+	mset.mu.RUnlock()
+
+	var smv StoreMsg
+	var erased uint64
+	if lastSeq == 0 {
+		return 0, nil
+	}
+	wc := subjectHasWildcard(req.Subject)
+	for seq := uint64(0); ; seq++ {
+		sm, nseq, err := store.LoadNextMsg(req.Subject, wc, seq, &smv)
+		if err == ErrStoreEOF {
+			break
+		} else if err != nil {
+			return erased, err
+		}
+		if nseq > lastSeq {
+			break
+		}
+		seq = nseq
+		if !matchHeaders(sm.hdr, req.Headers) {
+			continue
+		}
+		removed, err := store.EraseMsg(seq)
+		if err != nil && err != ErrStoreMsgNotFound {
+			return erased, err
+		}
+		if removed {
+			erased++
+			mset.mu.Lock()
+			mset.clearAllPreAcks(seq)
+			mset.mu.Unlock()
+		}
+	}
+	return erased, nil
+}
+
+// Returns whether the headers contain all the given header values.
+func matchHeaders(hdr []byte, headers map[string]string) bool {
+	for k, v := range headers {
+		if string(getHeader(k, hdr)) != v {
+			return false
+		}
+	}
+	return true
+}
+
 // Are we a mirror?
 func (mset *stream) isMirror() bool {
 	mset.cfgMu.RLock()
@@ -2863,7 +3078,7 @@ func (mset *stream) processInboundMirrorMsg(m *inMsg) bool {
 			if tr == nil {
 				continue
 			} else {
-				tsubj, err := tr.Match(m.subj)
+				tsubj, err := tr.MatchWithHeader(m.subj, m.hdr)
 				if err == nil {
 					m.subj = tsubj
 					break
@@ -3843,7 +4058,7 @@ func (mset *stream) processInboundSourceMsg(si *sourceInfo, m *inMsg) bool {
 			if tr == nil {
 				continue
 			} else {
-				tsubj, err := tr.Match(m.subj)
+				tsubj, err := tr.MatchWithHeader(m.subj, hdr)
 				if err == nil {
 					m.subj = tsubj
 					break
@@ -4225,6 +4440,9 @@ func (mset *stream) subscribeToStream() error {
 			return err
 		}
 	}
+	if len(mset.cfg.Connectors) > 0 {
+		mset.startConnectors()
+	}
 
 	mset.active = true
 	return nil
@@ -4340,6 +4558,9 @@ func (mset *stream) unsubscribeToStream(stopping, shuttingDown bool) error {
 	if len(mset.sources) > 0 {
 		mset.stopSourceConsumers()
 	}
+	if len(mset.connectors) > 0 {
+		mset.stopConnectors()
+	}
 	// Clear batching state.
 	mset.deleteInflightBatches(shuttingDown)
 
@@ -4464,7 +4685,12 @@ func (mset *stream) setupStore(fsCfg *FileStoreConfig) error {
 		mset.store = ms
 	case FileStorage:
 		s := mset.srv
-		prf := s.jsKeyGen(s.getOpts().JetStreamKey, mset.acc.Name)
+		key, err := s.jsStreamEncryptionKey(mset.acc.Name, fsCfg.StoreDir, mset.cfg.Name, true)
+		if err != nil {
+			mset.mu.Unlock()
+			return err
+		}
+		prf := s.jsKeyGen(key, mset.acc.Name)
 		if prf != nil {
 			// We are encrypted here, fill in correct cipher selection.
 			fsCfg.Cipher = s.getOpts().JetStreamCipher
@@ -5278,7 +5504,7 @@ func (mset *stream) processJetStreamMsg(subject, reply string, hdr, msg []byte,
 
 	// Apply the input subject transform if any
 	if mset.itr != nil {
-		ts, err := mset.itr.Match(subject)
+		ts, err := mset.itr.MatchWithHeader(subject, hdr)
 		if err == nil {
 			// no filtering: if the subject doesn't map the source of the transform, don't change it
 			subject = ts
@@ -5883,7 +6109,7 @@ func (mset *stream) processJetStreamMsg(subject, reply string, hdr, msg []byte,
 	var tlseq uint64
 	var thdrsOnly bool
 	if mset.tr != nil {
-		tsubj, _ = mset.tr.Match(subject)
+		tsubj, _ = mset.tr.MatchWithHeader(subject, hdr)
 		if mset.cfg.RePublish != nil {
 			thdrsOnly = mset.cfg.RePublish.HeadersOnly
 		}
@@ -13,7 +13,10 @@
 
 package server
 
-import "testing"
+import (
+	"strings"
+	"testing"
+)
 
 // FuzzSubjectsCollide performs fuzz testing on the NATS subject collision detection logic.
 // It verifies the behavior of the SubjectsCollide function which determines if two NATS
@@ -69,3 +72,70 @@ func FuzzSubjectsCollide(f *testing.F) {
 		SubjectsCollide(s1, s2)
 	})
 }
+
+// FuzzSubjectTransform performs fuzz testing on subject transforms and their mapping functions.
+// It verifies that valid transforms never panic when matching subjects, and that reversible
+// transforms can be reversed back to the original subject.
+func FuzzSubjectTransform(f *testing.F) {
+	corpuses := []struct {
+		src     string
+		dest    string
+		subject string
+	}{
+		{src: "foo.*", dest: "bar.$1", subject: "foo.a"},
+		{src: "foo.*.*", dest: "bar.{{wildcard(2)}}.{{wildcard(1)}}", subject: "foo.a.b"},
+		{src: "foo.*.*.*", dest: "bar.{{reorder(3,1,2)}}", subject: "foo.a.b.c"},
+		{src: "*.*", dest: "{{lower(1)}}.{{upper(2)}}", subject: "FOO.bar"},
+		{src: "*", dest: "{{regex(1, ^dev-(\\w+)$)}}", subject: "dev-orders"},
+		{src: "*", dest: "{{regex(1, [0-9]+)}}", subject: "abc"},
+		{src: "*", dest: "bucket.{{hash(1,8)}}", subject: "foo"},
+		{src: "*", dest: "{{partition(10,1)}}.{{splitfromleft(1,2)}}", subject: "12345"},
+		{src: "*", dest: "{{split(1,-)}}", subject: "-a--b-"},
+		{src: "foo.>", dest: "bar.{{header(Region, none)}}.>", subject: "foo.a.b"},
+	}
+
+	for _, crp := range corpuses {
+		f.Add(crp.src, crp.dest, crp.subject)
+	}
+
+	f.Fuzz(func(t *testing.T, src, dest, subject string) {
+		if !IsValidPublishSubject(subject) || ValidateMapping(src, dest) != nil {
+			return
+		}
+		tr, err := NewSubjectTransform(src, dest)
+		if err != nil || tr == nil {
+			return
+		}
+		res, err := tr.Match(subject)
+		if err != nil {
+			return
+		}
+
+		for _, token := range strings.Split(src, tsep) {
+			if len(token) > 1 && strings.ContainsAny(token, "*>") {
+				return
+			}
+		}
+		if tr, err = NewSubjectTransformStrict(src, dest); err != nil || tr == nil {
+			return
+		}
+		// Only transforms placing every wildcard exactly once can be reversed.
+		used := make(map[int]bool)
+		for i, mfType := range tr.dtokmftypes {
+			if mfType != Wildcard && mfType != Reorder {
+				continue
+			}
+			for _, sti := range tr.dtokmftokindexesargs[i] {
+				if used[sti] {
+					return
+				}
+				used[sti] = true
+			}
+		}
+		if rtr := tr.reverse(); rtr != nil {
+			if rres, err := rtr.Match(res); err != nil || rres != subject {
+				t.Fatalf("Reverse of transform %q to %q of %q produced %q", src, dest, subject, rres)
+			}
+		}
+	})
+}
@@ -19,6 +19,7 @@ import (
 	"math"
 	"math/rand"
 	"regexp"
+	"slices"
 	"strconv"
 	"strings"
 )
@@ -36,6 +37,12 @@ var (
 	leftMappingFunctionRegEx           = regexp.MustCompile(`{{\s*[lL]eft\s*\((.*)\)\s*}}`)
 	rightMappingFunctionRegEx          = regexp.MustCompile(`{{\s*[rR]ight\s*\((.*)\)\s*}}`)
 	randomMappingFunctionRegEx         = regexp.MustCompile(`{{\s*[rR]andom\s*\((.*)\)\s*}}`)
+	headerMappingFunctionRegEx         = regexp.MustCompile(`{{\s*[hH]eader\s*\((.*)\)\s*}}`)
+	regexMappingFunctionRegEx          = regexp.MustCompile(`{{\s*[rR]egex\s*\((.*)\)\s*}}`)
+	lowerMappingFunctionRegEx          = regexp.MustCompile(`{{\s*[lL]ower\s*\((.*)\)\s*}}`)
+	upperMappingFunctionRegEx          = regexp.MustCompile(`{{\s*[uU]pper\s*\((.*)\)\s*}}`)
+	hashMappingFunctionRegEx           = regexp.MustCompile(`{{\s*[hH]ash\s*\((.*)\)\s*}}`)
+	reorderMappingFunctionRegEx        = regexp.MustCompile(`{{\s*[rR]eorder\s*\((.*)\)\s*}}`)
 )
 
 // Enum for the subject mapping subjectTransform function types
@@ -52,19 +59,33 @@ const (
 	Left
 	Right
 	Random
+	Header
+	Regex
+	Lower
+	Upper
+	Hash
+	Reorder
 )
 
+// Largest number of hex characters produced by the hash mapping function.
+const maxHashMappingWidth = 16
+
+// Token used by the header mapping function when the header is missing or
+// its value is not a valid subject token, and no default was given.
+const headerMappingDefaultToken = "_"
+
 // Transforms for arbitrarily mapping subjects from one to another for maps, tees and filters.
 // These can also be used for proper mapping on wildcard exports/imports.
 // These will be grouped and caching and locking are assumed to be in the upper layers.
 type subjectTransform struct {
 	src, dest            string
-	dtoks                []string // destination tokens
-	stoks                []string // source tokens
-	dtokmftypes          []int16  // destination token mapping function types
-	dtokmftokindexesargs [][]int  // destination token mapping function array of source token index arguments
-	dtokmfintargs        []int32  // destination token mapping function int32 arguments
-	dtokmfstringargs     []string // destination token mapping function string arguments
+	dtoks                []string         // destination tokens
+	stoks                []string         // source tokens
+	dtokmftypes          []int16          // destination token mapping function types
+	dtokmftokindexesargs [][]int          // destination token mapping function array of source token index arguments
+	dtokmfintargs        []int32          // destination token mapping function int32 arguments
+	dtokmfstringargs     []string         // destination token mapping function string arguments
+	dtokmfregexes        []*regexp.Regexp // destination token mapping function compiled regular expressions
 }
 
 // SubjectTransformer transforms subjects using mappings
@@ -115,6 +136,12 @@ func NewSubjectTransformWithStrict(src, dest string, strict bool) (*subjectTrans
 		}
 
 		nphs := 0
+		// In strict mode, each source wildcard must be placed exactly once so
+		// that the transform can be reversed.
+		var used []bool
+		if strict {
+			used = make([]bool, npwcs+1)
+		}
 		for _, token := range dtokens {
 			tranformType, transformArgWildcardIndexes, transfomArgInt, transformArgString, err := indexPlaceHolders(token)
 			if err != nil {
@@ -122,7 +149,8 @@ func NewSubjectTransformWithStrict(src, dest string, strict bool) (*subjectTrans
 			}
 
 			if strict {
-				if tranformType != NoTransform && tranformType != Wildcard {
+				// Only functions that just place wildcards can be reversed.
+				if tranformType != NoTransform && tranformType != Wildcard && tranformType != Reorder {
 					return nil, &mappingDestinationErr{token, ErrMappingDestinationNotSupportedForImport}
 				}
 			}
@@ -132,11 +160,11 @@ func NewSubjectTransformWithStrict(src, dest string, strict bool) (*subjectTrans
 				dtokMappingFunctionTokenIndexes = append(dtokMappingFunctionTokenIndexes, []int{-1})
 				dtokMappingFunctionIntArgs = append(dtokMappingFunctionIntArgs, -1)
 				dtokMappingFunctionStringArgs = append(dtokMappingFunctionStringArgs, _EMPTY_)
-			} else if tranformType == Random {
-				dtokMappingFunctionTypes = append(dtokMappingFunctionTypes, Random)
+			} else if tranformType == Random || tranformType == Header {
+				dtokMappingFunctionTypes = append(dtokMappingFunctionTypes, tranformType)
 				dtokMappingFunctionTokenIndexes = append(dtokMappingFunctionTokenIndexes, []int{})
 				dtokMappingFunctionIntArgs = append(dtokMappingFunctionIntArgs, transfomArgInt)
-				dtokMappingFunctionStringArgs = append(dtokMappingFunctionStringArgs, _EMPTY_)
+				dtokMappingFunctionStringArgs = append(dtokMappingFunctionStringArgs, transformArgString)
 			} else {
 				nphs += len(transformArgWildcardIndexes)
 				// Now build up our runtime mapping from dest to source tokens.
@@ -145,6 +173,15 @@ func NewSubjectTransformWithStrict(src, dest string, strict bool) (*subjectTrans
 					if wildcardIndex > npwcs {
 						return nil, &mappingDestinationErr{fmt.Sprintf("%s: [%d]", token, wildcardIndex), ErrMappingDestinationIndexOutOfRange}
 					}
+					if strict {
+						if wildcardIndex < 1 {
+							return nil, &mappingDestinationErr{fmt.Sprintf("%s: [%d]", token, wildcardIndex), ErrMappingDestinationIndexOutOfRange}
+						}
+						if used[wildcardIndex] {
+							return nil, &mappingDestinationErr{fmt.Sprintf("%s: [%d]", token, wildcardIndex), ErrMappingDestinationNotSupportedForImport}
+						}
+						used[wildcardIndex] = true
+					}
 					stis = append(stis, sti[wildcardIndex])
 				}
 				dtokMappingFunctionTypes = append(dtokMappingFunctionTypes, tranformType)
@@ -154,14 +191,14 @@ func NewSubjectTransformWithStrict(src, dest string, strict bool) (*subjectTrans
 
 			}
 		}
-		if strict && nphs < npwcs {
+		if strict && (nphs < npwcs || slices.Contains(used[1:], false)) {
 			// not all wildcards are being used in the destination
 			return nil, &mappingDestinationErr{dest, ErrMappingDestinationNotUsingAllWildcards}
 		}
 	} else {
 		// no wildcards used in the source: check that no transform functions are used in the destination
 		for _, token := range dtokens {
-			tranformType, _, transfomArgInt, _, err := indexPlaceHolders(token)
+			tranformType, _, transfomArgInt, transformArgString, err := indexPlaceHolders(token)
 			if err != nil {
 				return nil, err
 			}
@@ -171,17 +208,30 @@ func NewSubjectTransformWithStrict(src, dest string, strict bool) (*subjectTrans
 				dtokMappingFunctionTokenIndexes = append(dtokMappingFunctionTokenIndexes, []int{-1})
 				dtokMappingFunctionIntArgs = append(dtokMappingFunctionIntArgs, -1)
 				dtokMappingFunctionStringArgs = append(dtokMappingFunctionStringArgs, _EMPTY_)
-			} else if tranformType == Random || tranformType == Partition {
+			} else if strict {
+				return nil, &mappingDestinationErr{token, ErrMappingDestinationNotSupportedForImport}
+			} else if tranformType == Random || tranformType == Partition || tranformType == Header {
 				dtokMappingFunctionTypes = append(dtokMappingFunctionTypes, tranformType)
 				dtokMappingFunctionTokenIndexes = append(dtokMappingFunctionTokenIndexes, []int{})
 				dtokMappingFunctionIntArgs = append(dtokMappingFunctionIntArgs, transfomArgInt)
-				dtokMappingFunctionStringArgs = append(dtokMappingFunctionStringArgs, _EMPTY_)
+				dtokMappingFunctionStringArgs = append(dtokMappingFunctionStringArgs, transformArgString)
 			} else {
 				return nil, &mappingDestinationErr{token, ErrMappingDestinationIndexOutOfRange}
 			}
 		}
 	}
 
+	// Compile the regular expressions once, they were validated when parsed.
+	var dtokMappingFunctionRegexes []*regexp.Regexp
+	for i, mfType := range dtokMappingFunctionTypes {
+		if mfType == Regex {
+			if dtokMappingFunctionRegexes == nil {
+				dtokMappingFunctionRegexes = make([]*regexp.Regexp, len(dtokMappingFunctionTypes))
+			}
+			dtokMappingFunctionRegexes[i] = regexp.MustCompile(dtokMappingFunctionStringArgs[i])
+		}
+	}
+
 	return &subjectTransform{
 		src:                  src,
 		dest:                 dest,
@@ -191,6 +241,7 @@ func NewSubjectTransformWithStrict(src, dest string, strict bool) (*subjectTrans
 		dtokmftokindexesargs: dtokMappingFunctionTokenIndexes,
 		dtokmfintargs:        dtokMappingFunctionIntArgs,
 		dtokmfstringargs:     dtokMappingFunctionStringArgs,
+		dtokmfregexes:        dtokMappingFunctionRegexes,
 	}, nil
 }
 
@@ -230,6 +281,21 @@ func transformIndexIntArgsHelper(token string, args []string, transformType int1
 	return transformType, []int{i}, int32(mappingFunctionIntArg), _EMPTY_, nil
 }
 
+// Helper for mapping functions that take a single wildcard index as argument
+func transformIndexArgHelper(token string, args []string, transformType int16) (int16, []int, int32, string, error) {
+	if len(args) == 1 && args[0] == _EMPTY_ {
+		return BadTransform, []int{}, -1, _EMPTY_, &mappingDestinationErr{token, ErrMappingDestinationNotEnoughArgs}
+	}
+	if len(args) > 1 {
+		return BadTransform, []int{}, -1, _EMPTY_, &mappingDestinationErr{token, ErrMappingDestinationTooManyArgs}
+	}
+	i, err := strconv.Atoi(strings.Trim(args[0], " "))
+	if err != nil {
+		return BadTransform, []int{}, -1, _EMPTY_, &mappingDestinationErr{token, ErrMappingDestinationInvalidArg}
+	}
+	return transformType, []int{i}, -1, _EMPTY_, nil
+}
+
 // Helper to ingest and index the subjectTransform destination token (e.g. $x or {{}}) in the token
 // returns a transformation type, and three function arguments: an array of source subject token indexes,
 // and a single number (e.g. number of partitions, or a slice size), and a string (e.g.a split delimiter)
@@ -366,6 +432,89 @@ func indexPlaceHolders(token string) (int16, []int, int32, string, error) {
 				return Random, []int{}, int32(mappingFunctionIntArg), _EMPTY_, nil
 			}
 
+			// Regex(token, expression)
+			// The expression may contain commas, so only split off the token index.
+			// It can not contain dots, as those separate the destination tokens.
+			if cs := regexMappingFunctionRegEx.FindStringSubmatch(token); len(cs) > 1 {
+				ti, expr, ok := strings.Cut(cs[1], ",")
+				expr = strings.TrimSpace(expr)
+				if !ok || expr == _EMPTY_ {
+					return BadTransform, []int{}, -1, _EMPTY_, &mappingDestinationErr{token, ErrMappingDestinationNotEnoughArgs}
+				}
+				i, err := strconv.Atoi(strings.TrimSpace(ti))
+				if err != nil {
+					return BadTransform, []int{}, -1, _EMPTY_, &mappingDestinationErr{token, ErrMappingDestinationInvalidArg}
+				}
+				re, err := regexp.Compile(expr)
+				if err != nil || re.NumSubexp() > 1 {
+					return BadTransform, []int{}, -1, _EMPTY_, &mappingDestinationErr{token, ErrMappingDestinationInvalidArg}
+				}
+				return Regex, []int{i}, -1, expr, nil
+			}
+
+			// Lower(token)
+			args = getMappingFunctionArgs(lowerMappingFunctionRegEx, token)
+			if args != nil {
+				return transformIndexArgHelper(token, args, Lower)
+			}
+
+			// Upper(token)
+			args = getMappingFunctionArgs(upperMappingFunctionRegEx, token)
+			if args != nil {
+				return transformIndexArgHelper(token, args, Upper)
+			}
+
+			// Hash(token, width)
+			args = getMappingFunctionArgs(hashMappingFunctionRegEx, token)
+			if args != nil {
+				tt, idx, width, sarg, err := transformIndexIntArgsHelper(token, args, Hash)
+				if err == nil && (width < 1 || width > maxHashMappingWidth) {
+					return BadTransform, []int{}, -1, _EMPTY_, &mappingDestinationErr{token, ErrMappingDestinationInvalidArg}
+				}
+				return tt, idx, width, sarg, err
+			}
+
+			// Reorder(token1, token2, ...)
+			args = getMappingFunctionArgs(reorderMappingFunctionRegEx, token)
+			if args != nil {
+				if len(args) < 2 {
+					return BadTransform, []int{}, -1, _EMPTY_, &mappingDestinationErr{token, ErrMappingDestinationNotEnoughArgs}
+				}
+				tokenIndexes := make([]int, len(args))
+				for ti, t := range args {
+					i, err := strconv.Atoi(strings.TrimSpace(t))
+					if err != nil {
+						return BadTransform, []int{}, -1, _EMPTY_, &mappingDestinationErr{token, ErrMappingDestinationInvalidArg}
+					}
+					tokenIndexes[ti] = i
+				}
+				return Reorder, tokenIndexes, -1, _EMPTY_, nil
+			}
+
+			// Header(name) or Header(name, default)
+			args = getMappingFunctionArgs(headerMappingFunctionRegEx, token)
+			if args != nil {
+				if len(args) == 1 && args[0] == _EMPTY_ {
+					return BadTransform, []int{}, -1, _EMPTY_, &mappingDestinationErr{token, ErrMappingDestinationNotEnoughArgs}
+				}
+				if len(args) > 2 {
+					return BadTransform, []int{}, -1, _EMPTY_, &mappingDestinationErr{token, ErrMappingDestinationTooManyArgs}
+				}
+				name := strings.TrimSpace(args[0])
+				if name == _EMPTY_ || strings.ContainsAny(name, " \t:,") {
+					return BadTransform, []int{}, -1, _EMPTY_, &mappingDestinationErr{token, ErrMappingDestinationInvalidArg}
+				}
+				def := headerMappingDefaultToken
+				if len(args) == 2 {
+					def = strings.TrimSpace(args[1])
+					if !isValidHeaderMappingToken(def) {
+						return BadTransform, []int{}, -1, _EMPTY_, &mappingDestinationErr{token, ErrMappingDestinationInvalidArg}
+					}
+				}
+				// The name and the default are kept together as the string argument.
+				return Header, []int{}, -1, name + "," + def, nil
+			}
+
 			return BadTransform, []int{}, -1, _EMPTY_, &mappingDestinationErr{token, ErrUnknownMappingDestinationFunction}
 		}
 	}
@@ -403,6 +552,12 @@ func transformUntokenize(subject string) (string, []string) {
 		if args := getMappingFunctionArgs(wildcardMappingFunctionRegEx, token); (len(token) > 1 && token[0] == '$' && token[1] >= '1' && token[1] <= '9') || (len(args) == 1 && args[0] != _EMPTY_) {
 			phs = append(phs, token)
 			nda = append(nda, pwcs)
+		} else if args := getMappingFunctionArgs(reorderMappingFunctionRegEx, token); len(args) > 1 {
+			// Each reordered token is a placeholder of its own.
+			for _, arg := range args {
+				phs = append(phs, "$"+strings.TrimSpace(arg))
+				nda = append(nda, pwcs)
+			}
 		} else {
 			nda = append(nda, token)
 		}
@@ -430,6 +585,12 @@ func tokenizeSubject(subject string) []string {
 //
 // This API is not part of the public API and not subject to SemVer protections
 func (tr *subjectTransform) Match(subject string) (string, error) {
+	return tr.MatchWithHeader(subject, nil)
+}
+
+// MatchWithHeader is like Match, but header mapping functions take their
+// values from the given message header.
+func (tr *subjectTransform) MatchWithHeader(subject string, hdr []byte) (string, error) {
 	// Special case: matches any and no no-op subjectTransform. May not be legal config for some features
 	// but specific validations made at subjectTransform create time
 	if (tr.src == fwcs || tr.src == _EMPTY_) && (tr.dest == fwcs || tr.dest == _EMPTY_) {
@@ -444,7 +605,7 @@ func (tr *subjectTransform) Match(subject string) (string, error) {
 	}
 
 	if (tr.src == _EMPTY_ || tr.src == fwcs) || isSubsetMatch(tts, tr.src) {
-		return tr.TransformTokenizedSubject(tts), nil
+		return tr.transformTokenizedSubject(tts, hdr), nil
 	}
 	return _EMPTY_, ErrNoTransforms
 }
@@ -456,6 +617,23 @@ func (tr *subjectTransform) TransformSubject(subject string) string {
 	return tr.TransformTokenizedSubject(tokenizeSubject(subject))
 }
 
+// Checks that a header value or default can be used as a subject token.
+func isValidHeaderMappingToken(v string) bool {
+	if v == _EMPTY_ || v == pwcs || v == fwcs {
+		return false
+	}
+	return !strings.ContainsAny(v, " \t\r\n.")
+}
+
+// Returns the subject token for a header mapping function argument.
+func headerMappingToken(arg string, hdr []byte) string {
+	name, def, _ := strings.Cut(arg, ",")
+	if v := sliceHeader(name, hdr); v != nil && isValidHeaderMappingToken(bytesToString(v)) {
+		return string(v)
+	}
+	return def
+}
+
 func (tr *subjectTransform) getRandomPartition(ceiling int) string {
 	// Avoid an integer divide by zero panic below.
 	if ceiling == 0 {
@@ -466,19 +644,43 @@ func (tr *subjectTransform) getRandomPartition(ceiling int) string {
 }
 
 func (tr *subjectTransform) getHashPartition(key []byte, numBuckets int) string {
+	return strconv.Itoa(hashPartition(key, numBuckets))
+}
+
+// Returns the partition of the key used by the partition mapping function.
+func hashPartition(key []byte, numBuckets int) int {
 	// Avoid an integer divide by zero panic below.
-	if numBuckets == 0 {
-		return "0"
+	if numBuckets <= 0 {
+		return 0
 	}
 
 	h := fnv.New32a()
 	_, _ = h.Write(key)
 
-	return strconv.Itoa(int(h.Sum32() % uint32(numBuckets)))
+	return int(h.Sum32() % uint32(numBuckets))
+}
+
+// Returns the last width hex characters of the 64 bit hash of the token.
+func (tr *subjectTransform) getHashBucket(token string, width int) string {
+	h := fnv.New64a()
+	_, _ = h.Write([]byte(token))
+
+	var buf [maxHashMappingWidth]byte
+	const hexDigits = "0123456789abcdef"
+	sum := h.Sum64()
+	for i := len(buf) - 1; i >= 0; i-- {
+		buf[i] = hexDigits[sum&0xf]
+		sum >>= 4
+	}
+	return string(buf[len(buf)-width:])
 }
 
 // Do a subjectTransform on the subject to the dest subject.
 func (tr *subjectTransform) TransformTokenizedSubject(tokens []string) string {
+	return tr.transformTokenizedSubject(tokens, nil)
+}
+
+func (tr *subjectTransform) transformTokenizedSubject(tokens []string, hdr []byte) string {
 	if len(tr.dtokmftypes) == 0 {
 		return tr.dest
 	}
@@ -613,6 +815,30 @@ func (tr *subjectTransform) TransformTokenizedSubject(tokens []string) string {
 				}
 			case Random:
 				b.WriteString(tr.getRandomPartition(int(tr.dtokmfintargs[i])))
+			case Header:
+				b.WriteString(headerMappingToken(tr.dtokmfstringargs[i], hdr))
+			case Regex:
+				sourceToken := tokens[tr.dtokmftokindexesargs[i][0]]
+				// Use the first capture group, or the whole match if there is none.
+				// If there is no match, or it would be empty, keep the token as is.
+				if m := tr.dtokmfregexes[i].FindStringSubmatch(sourceToken); len(m) > 0 && m[len(m)-1] != _EMPTY_ {
+					b.WriteString(m[len(m)-1])
+				} else {
+					b.WriteString(sourceToken)
+				}
+			case Lower:
+				b.WriteString(strings.ToLower(tokens[tr.dtokmftokindexesargs[i][0]]))
+			case Upper:
+				b.WriteString(strings.ToUpper(tokens[tr.dtokmftokindexesargs[i][0]]))
+			case Hash:
+				b.WriteString(tr.getHashBucket(tokens[tr.dtokmftokindexesargs[i][0]], int(tr.dtokmfintargs[i])))
+			case Reorder:
+				for j, sourceToken := range tr.dtokmftokindexesargs[i] {
+					if j > 0 {
+						b.WriteByte(btsep)
+					}
+					b.WriteString(tokens[sourceToken])
+				}
 			}
 		}
 
@@ -642,15 +868,28 @@ func (tr *subjectTransform) reverse() *subjectTransform {
 	// If we are here we need to dynamically get the correct reverse
 	// of this subjectTransform.
 	nsrc, phs := transformUntokenize(tr.dest)
+	// Map each source wildcard to its position in the reversed source.
+	positions := make(map[int]int, len(phs))
+	for pos, ph := range phs {
+		_, idx, _, _, err := indexPlaceHolders(ph)
+		if err != nil || len(idx) != 1 {
+			return nil
+		}
+		if _, ok := positions[idx[0]]; !ok {
+			positions[idx[0]] = pos + 1
+		}
+	}
 	var nda []string
+	var wi int
 	for _, token := range tr.stoks {
 		if token == pwcs {
-			if len(phs) == 0 {
+			wi++
+			pos, ok := positions[wi]
+			if !ok {
 				// TODO(dlc) - Should not happen
 				return nil
 			}
-			nda = append(nda, phs[0])
-			phs = phs[1:]
+			nda = append(nda, fmt.Sprintf("$%d", pos))
 		} else {
 			nda = append(nda, token)
 		}
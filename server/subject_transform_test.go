@@ -88,6 +88,26 @@ func TestPlaceHolderIndex(t *testing.T) {
 	if err != nil || transformType != Right || len(indexes) != 1 || indexes[0] != 3 || position != 2 {
 		t.Fatalf("Error parsing %s", testString)
 	}
+	testString = "{{Hash(1,8)}}"
+	transformType, indexes, position, _, err = indexPlaceHolders(testString)
+
+	if err != nil || transformType != Hash || len(indexes) != 1 || indexes[0] != 1 || position != 8 {
+		t.Fatalf("Error parsing %s", testString)
+	}
+
+	testString = "{{Reorder(3,1,2)}}"
+	transformType, indexes, _, _, err = indexPlaceHolders(testString)
+
+	if err != nil || transformType != Reorder || !reflect.DeepEqual(indexes, []int{3, 1, 2}) {
+		t.Fatalf("Error parsing %s", testString)
+	}
+
+	testString = "{{regex(2, ^(\\w+)-[0-9]{2,4}$)}}"
+	transformType, indexes, _, expr, err := indexPlaceHolders(testString)
+
+	if err != nil || transformType != Regex || len(indexes) != 1 || indexes[0] != 2 || expr != "^(\\w+)-[0-9]{2,4}$" {
+		t.Fatalf("Error parsing %s", testString)
+	}
 }
 
 func TestSubjectTransformHelpers(t *testing.T) {
@@ -133,6 +153,33 @@ func TestSubjectTransformHelpers(t *testing.T) {
 	if reverse.TransformSubject(transformed) != subject {
 		t.Fatal("Reversed transform subject not matching")
 	}
+
+	filter, placeHolders = transformUntokenize("foo.{{reorder(3,1)}}.$2")
+	if filter != "foo.*.*.*" || !equals(placeHolders, []string{"$3", "$1", "$2"}) {
+		t.Fatalf("transformUntokenize for not returning expected result")
+	}
+
+	tr = newReversibleTransform("foo.*.*.*", "bar.{{reorder(3, 1, 2)}}")
+	subject = "foo.a.b.c"
+	transformed = tr.TransformSubject(subject)
+	if transformed != "bar.c.a.b" {
+		t.Fatalf("Unexpected transformed subject %q", transformed)
+	}
+	reverse = tr.reverse()
+	if reverse.TransformSubject(transformed) != subject {
+		t.Fatal("Reversed transform subject not matching")
+	}
+
+	// Placing a wildcard more than once can not be reversed.
+	for _, dest := range []string{"bar.$1.$1", "bar.{{reorder(1,1)}}", "bar.{{reorder(2,1)}}.$1"} {
+		if _, err := NewSubjectTransformStrict("foo.*.*", dest); err == nil {
+			t.Fatalf("Expected an error for reversible transform to %q", dest)
+		}
+		// Non strict transforms allow it.
+		if _, err := NewSubjectTransform("foo.*.*", dest); err != nil {
+			t.Fatalf("Error getting transform to %q: %v", dest, err)
+		}
+	}
 }
 
 func TestSubjectTransforms(t *testing.T) {
@@ -155,6 +202,10 @@ func TestSubjectTransforms(t *testing.T) {
 	shouldErr("foo.*", "bar.$1.>", false)                 // fwcs have to match.
 	shouldErr("foo.>", "bar.baz", false)                  // fwcs have to match.
 	shouldErr("foo.*.*", "bar.$2", true)                  // Must place all pwcs.
+	shouldErr("foo.*.*", "bar.$1.$1", true)               // Must place each pwc once.
+	shouldErr("foo.*.*", "bar.{{reorder(1,1)}}", true)    // Must place each pwc once.
+	shouldErr("foo.*.*", "bar.$1.{{wildcard(1)}}", true)  // Must place each pwc once.
+	shouldErr("foo.*.*", "bar.$2.$2.$1", true)            // Must place each pwc once.
 	shouldErr("foo.*", "foo.$foo", true)                  // invalid $ value
 	shouldErr("foo.*", "bar.{{Partition(2,1)}}", true)    // can only use Wildcard function (and old-style $x) in import transform
 	shouldErr("foo.*", "foo.{{wildcard(2)}}", false)      // Mapping function being passed an out of range wildcard index
@@ -167,6 +218,27 @@ func TestSubjectTransforms(t *testing.T) {
 	shouldErr("foo.*", "foo.{{ wildcard5) }}", false)     // Bad mapping function
 	shouldErr("foo.*", "foo.{{splitLeft(2,2}}", false)    // arg out of range
 	shouldErr("foo", "bla.{{wildcard(1)}}", false)        // arg out of range with no wildcard in the source
+	shouldErr("foo.*", "foo.{{header()}}", false)         // Not enough arguments passed to the header function
+	shouldErr("foo.*", "foo.{{header(A,b,c)}}", false)    // Too many arguments passed to the header function
+	shouldErr("foo.*", "foo.{{header(A,*)}}", false)      // Default is not a valid token
+	shouldErr("foo.*", "foo.{{header(A)}}.$1", true)      // Can not be used in import transforms
+	shouldErr("foo.*", "foo.{{regex(1)}}", false)         // Not enough arguments passed to the regex function
+	shouldErr("foo.*", "foo.{{regex(x,a)}}", false)       // Invalid token index passed to the regex function
+	shouldErr("foo.*", "foo.{{regex(1,a(b)}}", false)     // Invalid regular expression
+	shouldErr("foo.*", "foo.{{regex(1,(a)(b))}}", false)  // More than one capture group
+	shouldErr("foo.*", "foo.{{regex(2,a)}}", false)       // Wildcard index out of range
+	shouldErr("foo.*", "foo.{{lower()}}", false)          // Not enough arguments passed to the lower function
+	shouldErr("foo.*", "foo.{{upper(1,2)}}", false)       // Too many arguments passed to the upper function
+	shouldErr("foo.*", "foo.{{hash(1)}}", false)          // Not enough arguments passed to the hash function
+	shouldErr("foo.*", "foo.{{hash(1,0)}}", false)        // Hash width too small
+	shouldErr("foo.*", "foo.{{hash(1,17)}}", false)       // Hash width too large
+	shouldErr("foo.*", "foo.{{reorder(1)}}", false)       // Not enough arguments passed to the reorder function
+	shouldErr("foo.*.*", "foo.{{reorder(1,3)}}", false)   // Wildcard index out of range
+	shouldErr("foo.*.*", "foo.{{reorder(1,x)}}", false)   // Invalid argument passed to the reorder function
+	shouldErr("foo.*", "foo.{{lower(1)}}", true)          // Can not be used in import transforms
+	shouldErr("foo.*", "foo.{{hash(1,4)}}", true)         // Can not be used in import transforms
+	shouldErr("foo.*", "foo.{{regex(1,a)}}", true)        // Can not be used in import transforms
+	shouldErr("foo", "foo.{{random(2)}}", true)           // Can not be used in import transforms
 
 	shouldErr("foo.*", fmt.Sprintf("foo.{{partition(%d)}}", math.MaxInt32+1), false) // Larger than int32
 	shouldErr("foo.*", fmt.Sprintf("foo.{{random(%d)}}", math.MaxInt32+1), false)    // Larger than int32
@@ -181,6 +253,7 @@ func TestSubjectTransforms(t *testing.T) {
 	}
 
 	shouldBeOK("foo.*", "bar.{{Wildcard(1)}}", true)
+	shouldBeOK("foo.*.*", "bar.{{Reorder(2,1)}}", true)
 
 	shouldBeOK("foo.*.*", "bar.$2", false)              // don't have to use all pwcs.
 	shouldBeOK("foo.*.*", "bar.{{wildcard(1)}}", false) // don't have to use all pwcs.
@@ -247,6 +320,38 @@ func TestSubjectTransforms(t *testing.T) {
 	shouldMatch("foo.bar", "baz.{{partition(10)}}", "foo.bar", "baz.6")
 	shouldMatch("foo.baz", "qux.{{partition(10)}}", "foo.baz", "qux.4")
 	shouldMatch("test.subject", "result.{{partition(5)}}", "test.subject", "result.0")
+	shouldMatch("orders.*", "orders.{{header(Region)}}.$1", "orders.1", "orders._.1")
+	shouldMatch("orders", "orders.{{header(Region, none)}}", "orders", "orders.none")
+	shouldMatch("*.*", "{{lower(1)}}.{{upper(2)}}", "FooBar.baz", "foobar.BAZ")
+	shouldMatch("*", "{{regex(1, ^dev-(\\w+)$)}}", "dev-orders", "orders")
+	shouldMatch("*", "{{regex(1, ^dev-(\\w+)$)}}", "prod-orders", "prod-orders") // No match keeps the token
+	shouldMatch("*", "{{regex(1, [0-9]+)}}", "order-123-x", "123")               // No capture group uses the whole match
+	shouldMatch("*", "{{regex(1, ^a(x*)$)}}", "a", "a")                          // Empty capture keeps the token
+	shouldMatch("*.*.*", "x.{{reorder(3,1,2)}}.y", "a.b.c", "x.c.a.b.y")
+	shouldMatch("*", "bucket.{{hash(1,4)}}", "foo", "bucket.d577")
+	shouldMatch("*", "bucket.{{hash(1,16)}}", "foo", "bucket.dcb27518fed9d577")
+}
+
+func TestSubjectTransformHeader(t *testing.T) {
+	hdr := genHeader(nil, "Region", "eu")
+	hdr = genHeader(hdr, "Tenant", "acme.corp")
+
+	tr, err := NewSubjectTransform("orders.*", "orders.{{header(Region)}}.{{wildcard(1)}}")
+	require_NoError(t, err)
+	subj, err := tr.MatchWithHeader("orders.1", hdr)
+	require_NoError(t, err)
+	require_Equal(t, subj, "orders.eu.1")
+	// Without a header the default token is used.
+	subj, err = tr.Match("orders.1")
+	require_NoError(t, err)
+	require_Equal(t, subj, "orders._.1")
+
+	// Header values that are not valid tokens use the default.
+	tr, err = NewSubjectTransform("orders.>", "{{header(Tenant, unknown)}}.{{header(Missing, x)}}.>")
+	require_NoError(t, err)
+	subj, err = tr.MatchWithHeader("orders.a.b", hdr)
+	require_NoError(t, err)
+	require_Equal(t, subj, "unknown.x.a.b")
 }
 
 func TestSubjectTransformDoesntPanicTransformingMissingToken(t *testing.T) {
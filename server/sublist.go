@@ -1293,7 +1293,13 @@ func ValidateMapping(src string, dest string) error {
 				!sliceFromLeftMappingFunctionRegEx.MatchString(t) &&
 				!sliceFromRightMappingFunctionRegEx.MatchString(t) &&
 				!splitMappingFunctionRegEx.MatchString(t) &&
-				!randomMappingFunctionRegEx.MatchString(t) {
+				!randomMappingFunctionRegEx.MatchString(t) &&
+				!headerMappingFunctionRegEx.MatchString(t) &&
+				!regexMappingFunctionRegEx.MatchString(t) &&
+				!lowerMappingFunctionRegEx.MatchString(t) &&
+				!upperMappingFunctionRegEx.MatchString(t) &&
+				!hashMappingFunctionRegEx.MatchString(t) &&
+				!reorderMappingFunctionRegEx.MatchString(t) {
 				return &mappingDestinationErr{t, ErrUnknownMappingDestinationFunction}
 			} else {
 				continue
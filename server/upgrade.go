@@ -0,0 +1,128 @@
+// Copyright 2025 The NATS Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"net"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Names of the listeners handed over to the new process on a binary upgrade.
+const (
+	clientListenerName  = "client"
+	routeListenerName   = "route"
+	gatewayListenerName = "gateway"
+	leafListenerName    = "leafnode"
+	wsListenerName      = "websocket"
+	mqttListenerName    = "mqtt"
+	monitorListenerName = "monitor"
+)
+
+// Sent by the new process to the upgraded one once it accepts connections.
+const upgradeReady = "+OK\r\n"
+
+// Time given to the new process to take over the listeners and be
+// ready to accept connections.
+var upgradeTimeout = 30 * time.Second
+
+// State of the listener handoff between the upgraded and the new process.
+type upgradeState struct {
+	mu sync.Mutex
+	// Listeners received from the upgraded process and not in use yet.
+	inherited map[string]net.Listener
+	// Listeners that are handed over if this server is upgraded.
+	listeners map[string]net.Listener
+	// Connection to the upgraded process, to notify it once ready.
+	conn net.Conn
+	// Set while this server is being upgraded.
+	inProgress bool
+}
+
+// Returns a TCP listener for the given host and port, the one inherited
+// from the upgraded process if any, and records it so that it can be
+// handed over on a binary upgrade. Keepalives are disabled for the NATS
+// protocol listeners, see natsListen().
+func (s *Server) listen(name, hp string, natsProto bool) (net.Listener, error) {
+	l := s.inheritedListener(name, hp)
+	if l == nil {
+		var err error
+		if natsProto {
+			l, err = natsListen("tcp", hp)
+		} else {
+			l, err = net.Listen("tcp", hp)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	s.upgrade.mu.Lock()
+	if s.upgrade.listeners == nil {
+		s.upgrade.listeners = make(map[string]net.Listener)
+	}
+	s.upgrade.listeners[name] = l
+	s.upgrade.mu.Unlock()
+	return l, nil
+}
+
+// Returns the inherited listener of the given name, unless the new
+// configuration uses a different port.
+func (s *Server) inheritedListener(name, hp string) net.Listener {
+	s.upgrade.mu.Lock()
+	l := s.upgrade.inherited[name]
+	delete(s.upgrade.inherited, name)
+	s.upgrade.mu.Unlock()
+	if l == nil {
+		return nil
+	}
+	port := strconv.Itoa(l.Addr().(*net.TCPAddr).Port)
+	if _, p, err := net.SplitHostPort(hp); err == nil && p != "0" && p != "-1" && p != port {
+		s.Warnf("Not using inherited %s listener on %s, port is now %s", name, l.Addr(), p)
+		l.Close()
+		return nil
+	}
+	s.Noticef("Using inherited %s listener on %s", name, l.Addr())
+	return l
+}
+
+// Notifies the upgraded process once this server accepts connections,
+// so that it can enter lame duck mode. Inherited listeners that are not
+// used are closed.
+func (s *Server) completeUpgrade() {
+	defer s.grWG.Done()
+
+	err := s.readyForConnections(upgradeTimeout)
+
+	s.upgrade.mu.Lock()
+	conn, unused := s.upgrade.conn, s.upgrade.inherited
+	s.upgrade.conn, s.upgrade.inherited = nil, nil
+	s.upgrade.mu.Unlock()
+
+	if conn == nil {
+		return
+	}
+	defer conn.Close()
+	for name, l := range unused {
+		s.Noticef("Closing inherited %s listener on %s, not used", name, l.Addr())
+		l.Close()
+	}
+	if err != nil {
+		s.Errorf("Not notifying the upgraded process: %v", err)
+		return
+	}
+	if _, err := conn.Write([]byte(upgradeReady)); err != nil {
+		s.Errorf("Error notifying the upgraded process: %v", err)
+	}
+}
@@ -0,0 +1,258 @@
+// Copyright 2025 The NATS Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build !windows && !wasm
+
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// Maximum number of listeners that can be handed over.
+const maxUpgradeListeners = 16
+
+// Upgrades the server to the binary of its executable, which may have been
+// replaced since the server was started. The new process is started with
+// the same arguments and the --upgrade option, and takes over the listeners
+// over a Unix socket. Once it accepts connections, this server enters lame
+// duck mode. If the new process fails to start, this server keeps running.
+//
+// Servers with JetStream enabled are not upgraded, they need to be restarted.
+// The new process can not open the stores while this one still serves the
+// clients of its streams and consumers during lame duck mode.
+//
+// The Unix socket is created in a new directory only accessible to the user
+// running the server, and where supported, each process checks that the
+// other one runs as the same user.
+func (s *Server) upgradeBinary() error {
+	if s.JetStreamEnabled() {
+		return errors.New("not supported with JetStream enabled, the server needs to be restarted")
+	}
+	s.mu.RLock()
+	ldm := s.ldm
+	s.mu.RUnlock()
+	if ldm || s.isShuttingDown() {
+		return errors.New("server is shutting down")
+	}
+	s.upgrade.mu.Lock()
+	if s.upgrade.inProgress {
+		s.upgrade.mu.Unlock()
+		return errors.New("upgrade already in progress")
+	}
+	s.upgrade.inProgress = true
+	s.upgrade.mu.Unlock()
+	defer func() {
+		s.upgrade.mu.Lock()
+		s.upgrade.inProgress = false
+		s.upgrade.mu.Unlock()
+	}()
+
+	exe, err := os.Executable()
+	if err != nil {
+		return err
+	}
+	// The directory is created with permissions 0700.
+	dir, err := os.MkdirTemp(_EMPTY_, fmt.Sprintf("%s-upgrade-", processName))
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "upgrade.sock")
+	ln, err := net.ListenUnix("unix", &net.UnixAddr{Name: path, Net: "unix"})
+	if err != nil {
+		return fmt.Errorf("unable to listen on %q: %v", path, err)
+	}
+	defer ln.Close()
+
+	cmd := exec.Command(exe, upgradeArgs(os.Args[1:], path)...)
+	cmd.Stdout, cmd.Stderr = os.Stdout, os.Stderr
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("unable to start %q: %v", exe, err)
+	}
+	// Reap the new process if it exits before this one.
+	go cmd.Wait()
+
+	s.Noticef("Handing over listeners to new process %d", cmd.Process.Pid)
+	if err := s.handoffListeners(ln); err != nil {
+		cmd.Process.Kill()
+		return err
+	}
+	s.Noticef("New process %d is accepting connections", cmd.Process.Pid)
+	go s.lameDuckMode()
+	return nil
+}
+
+// Returns the arguments of the new process, which are the ones of this
+// process, without the --upgrade option of a previous upgrade.
+func upgradeArgs(args []string, path string) []string {
+	nargs := []string{"--upgrade", path}
+	for i := 0; i < len(args); i++ {
+		switch arg := args[i]; {
+		case arg == "-upgrade" || arg == "--upgrade":
+			i++
+		case strings.HasPrefix(arg, "-upgrade=") || strings.HasPrefix(arg, "--upgrade="):
+		default:
+			nargs = append(nargs, arg)
+		}
+	}
+	return nargs
+}
+
+// Sends the listeners to the new process connecting to the given Unix
+// socket listener, and waits for it to accept connections.
+func (s *Server) handoffListeners(ln *net.UnixListener) error {
+	s.upgrade.mu.Lock()
+	names := make([]string, 0, len(s.upgrade.listeners))
+	files := make([]*os.File, 0, len(s.upgrade.listeners))
+	for name, l := range s.upgrade.listeners {
+		fl, ok := l.(interface{ File() (*os.File, error) })
+		if !ok {
+			continue
+		}
+		f, err := fl.File()
+		if err != nil {
+			s.Warnf("Not handing over %s listener: %v", name, err)
+			continue
+		}
+		names = append(names, name)
+		files = append(files, f)
+	}
+	s.upgrade.mu.Unlock()
+	defer func() {
+		for _, f := range files {
+			f.Close()
+		}
+	}()
+	if len(files) == 0 {
+		return errors.New("no listener to hand over")
+	}
+
+	ln.SetDeadline(time.Now().Add(upgradeTimeout))
+	conn, err := ln.AcceptUnix()
+	if err != nil {
+		return fmt.Errorf("new process did not connect: %v", err)
+	}
+	defer conn.Close()
+	if err := checkUpgradePeer(conn); err != nil {
+		return err
+	}
+	conn.SetDeadline(time.Now().Add(upgradeTimeout))
+
+	b, err := json.Marshal(names)
+	if err != nil {
+		return err
+	}
+	// Do not use Fd() since it would put the listeners in blocking mode.
+	fds := make([]int, 0, len(files))
+	for _, f := range files {
+		rc, err := f.SyscallConn()
+		if err != nil {
+			return err
+		}
+		rc.Control(func(fd uintptr) { fds = append(fds, int(fd)) })
+	}
+	if _, _, err := conn.WriteMsgUnix(b, syscall.UnixRights(fds...), nil); err != nil {
+		return fmt.Errorf("unable to send listeners: %v", err)
+	}
+
+	// Wait for the new process to accept connections.
+	ready := make([]byte, len(upgradeReady))
+	if _, err := io.ReadFull(conn, ready); err != nil {
+		return fmt.Errorf("new process failed to start: %v", err)
+	} else if string(ready) != upgradeReady {
+		return fmt.Errorf("new process failed to start: unexpected response %q", ready)
+	}
+	return nil
+}
+
+// Receives the listeners of the upgraded process from the given Unix
+// socket. They are used instead of creating new ones, and the upgraded
+// process is notified once this server accepts connections.
+func (s *Server) receiveListeners(path string) error {
+	conn, err := net.DialTimeout("unix", path, upgradeTimeout)
+	if err != nil {
+		return err
+	}
+	uc := conn.(*net.UnixConn)
+	if err := checkUpgradePeer(uc); err != nil {
+		conn.Close()
+		return err
+	}
+	uc.SetReadDeadline(time.Now().Add(upgradeTimeout))
+
+	b := make([]byte, 1024)
+	oob := make([]byte, syscall.CmsgSpace(maxUpgradeListeners*4))
+	n, oobn, _, _, err := uc.ReadMsgUnix(b, oob)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	var fds []int
+	msgs, err := syscall.ParseSocketControlMessage(oob[:oobn])
+	if err == nil {
+		for i := range msgs {
+			rights, rerr := syscall.ParseUnixRights(&msgs[i])
+			if rerr != nil {
+				err = rerr
+				break
+			}
+			fds = append(fds, rights...)
+		}
+	}
+	var names []string
+	if err == nil {
+		if err = json.Unmarshal(b[:n], &names); err == nil && len(names) != len(fds) {
+			err = fmt.Errorf("received %d listeners for %d names", len(fds), len(names))
+		}
+	}
+
+	inherited := make(map[string]net.Listener, len(fds))
+	for i, fd := range fds {
+		if err != nil {
+			syscall.Close(fd)
+			continue
+		}
+		f := os.NewFile(uintptr(fd), names[i])
+		var l net.Listener
+		// The listener uses a duplicate of the descriptor.
+		l, err = net.FileListener(f)
+		f.Close()
+		if err == nil {
+			inherited[names[i]] = l
+		}
+	}
+	if err != nil {
+		for _, l := range inherited {
+			l.Close()
+		}
+		conn.Close()
+		return err
+	}
+	uc.SetReadDeadline(time.Time{})
+
+	s.upgrade.mu.Lock()
+	s.upgrade.inherited, s.upgrade.conn = inherited, conn
+	s.upgrade.mu.Unlock()
+	return nil
+}
@@ -1151,10 +1151,12 @@ func (s *Server) startWebsocketServer() {
 		proto = wsSchemePrefixTLS
 		config := o.TLSConfig.Clone()
 		config.GetConfigForClient = s.wsGetTLSConfig
-		hl, err = tls.Listen("tcp", hp, config)
+		if hl, err = s.listen(wsListenerName, hp, false); err == nil {
+			hl = tls.NewListener(hl, config)
+		}
 	} else {
 		proto = wsSchemePrefix
-		hl, err = net.Listen("tcp", hp)
+		hl, err = s.listen(wsListenerName, hp, false)
 	}
 	s.websocket.listenerErr = err
 	if err != nil {
@@ -1268,6 +1270,8 @@ func (s *Server) createWSClient(conn net.Conn, ws *websocket) *client {
 
 	s.mu.Lock()
 	info = s.copyInfo()
+	// Websocket clients use the permessage-deflate extension instead.
+	info.Compression = _EMPTY_
 	// Check auth, override if applicable.
 	if !info.AuthRequired {
 		// Set info.AuthRequired since this is what is sent to the client.
@@ -1071,6 +1071,123 @@ func TestServiceLatencyFailureReportingSingleServer(t *testing.T) {
 	}
 }
 
+func TestServiceLatencyHistograms(t *testing.T) {
+	sc := createSuperCluster(t, 1, 1)
+	defer sc.shutdown()
+
+	// Now add in new service export to FOO and have bar import that with tracking enabled.
+	sc.setupLatencyTracking(t, 100)
+
+	nc := clientConnect(t, sc.clusters[0].opts[0], "foo")
+	defer nc.Close()
+
+	// The service listener.
+	serviceTime := 10 * time.Millisecond
+	nc.Subscribe("ngs.usage.*", func(msg *nats.Msg) {
+		time.Sleep(serviceTime)
+		msg.Respond([]byte("22 msgs"))
+	})
+	nc.Flush()
+
+	// Requestor
+	nc2 := clientConnect(t, sc.clusters[0].opts[0], "bar")
+	defer nc2.Close()
+
+	for range 20 {
+		if _, err := nc2.Request("ngs.usage", []byte("1h"), time.Second); err != nil {
+			t.Fatalf("Expected a response")
+		}
+	}
+	// A request with no reply subject is counted as an error.
+	nc2.Publish("ngs.usage", []byte("1h"))
+	nc2.Flush()
+
+	checkHistogram := func(h *server.ServiceLatencyHistogram) {
+		t.Helper()
+		if h.Account != "FOO" || h.Service != "ngs.usage.*" || h.Importer != "BAR" {
+			t.Fatalf("Unexpected histogram: %+v", h)
+		}
+		// Default windows.
+		if len(h.Windows) != 3 {
+			t.Fatalf("Expected 3 windows, got %d", len(h.Windows))
+		}
+		for i, window := range []time.Duration{time.Minute, 5 * time.Minute, 15 * time.Minute} {
+			st := h.Windows[i]
+			if st.Window != window || st.Count != 20 || st.Errors != 1 {
+				t.Fatalf("Unexpected window stats: %+v", st)
+			}
+			if st.P50 < serviceTime || st.P50 > st.P90 || st.P90 > st.P99 || st.P99 > st.Max {
+				t.Fatalf("Unexpected latency percentiles: %+v", st)
+			}
+		}
+	}
+
+	s := sc.clusters[0].servers[0]
+	checkFor(t, time.Second, 15*time.Millisecond, func() error {
+		lz, err := s.Latencyz(&server.LatencyzOptions{Account: "FOO"})
+		if err != nil {
+			return err
+		}
+		if len(lz.Histograms) != 1 {
+			return fmt.Errorf("Expected 1 histogram, got %d", len(lz.Histograms))
+		}
+		if st := lz.Histograms[0].Windows[0]; st.Count != 20 || st.Errors != 1 {
+			return fmt.Errorf("Unexpected window stats: %+v", st)
+		}
+		return nil
+	})
+	lz, err := s.Latencyz(&server.LatencyzOptions{Service: "ngs.usage.*"})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(lz.Histograms) != 1 {
+		t.Fatalf("Expected 1 histogram, got %d", len(lz.Histograms))
+	}
+	checkHistogram(lz.Histograms[0])
+
+	// Accountz lists the histograms of the export.
+	az, err := s.Accountz(&server.AccountzOptions{Account: "FOO"})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	var found bool
+	for _, e := range az.Account.Exports {
+		if e.Subject == "ngs.usage.*" {
+			if len(e.LatencyHistograms) != 1 {
+				t.Fatalf("Expected 1 histogram, got %d", len(e.LatencyHistograms))
+			}
+			checkHistogram(e.LatencyHistograms[0])
+			found = true
+		}
+	}
+	if !found {
+		t.Fatalf("Service export not found in accountz")
+	}
+
+	// And so does the system request.
+	snc := clientConnect(t, sc.clusters[0].opts[0], "sys")
+	defer snc.Close()
+	resp, err := snc.Request("$SYS.REQ.ACCOUNT.FOO.LATENCYZ", nil, time.Second)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	var apiResp struct {
+		Data *server.Latencyz `json:"data"`
+	}
+	if err := json.Unmarshal(resp.Data, &apiResp); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if apiResp.Data == nil || len(apiResp.Data.Histograms) != 1 {
+		t.Fatalf("Unexpected response: %s", resp.Data)
+	}
+	checkHistogram(apiResp.Data.Histograms[0])
+
+	// Nothing for the importing account.
+	if lz, err = s.Latencyz(&server.LatencyzOptions{Account: "BAR"}); err != nil || len(lz.Histograms) != 0 {
+		t.Fatalf("Unexpected histograms for BAR: %+v, %v", lz, err)
+	}
+}
+
 func TestServiceLatencyFailureReportingMultipleServers(t *testing.T) {
 	sc := createSuperCluster(t, 3, 3)
 	defer sc.shutdown()